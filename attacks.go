@@ -0,0 +1,143 @@
+package melange
+
+// Tablas de ataques precalculadas para las piezas que no se deslizan. Las de alfiles y
+// torres dependen de la ocupación y se calculan recorriendo los rayos.
+var (
+	kingAttacks   = stepAttacks(dirAll)
+	knightAttacks = stepAttacks([]Direction{{2, 1}, {2, -1}, {1, 2}, {1, -2}, {-2, 1}, {-2, -1}, {-1, 2}, {-1, -2}})
+	// pawnAttacks[0] son las casillas que ataca un peón blanco y pawnAttacks[1] uno negro
+	pawnAttacks = [2][64]uint64{stepAttacks([]Direction{{1, 1}, {1, -1}}), stepAttacks([]Direction{{-1, 1}, {-1, -1}})}
+)
+
+// stepAttacks calcula, para cada casilla, las casillas a un paso en las direcciones dadas.
+func stepAttacks(dirs []Direction) [64]uint64 {
+	var table [64]uint64
+	for sq := 0; sq < 64; sq++ {
+		for _, d := range dirs {
+			r, c := int8(sq/8)+d.dr, int8(sq%8)+d.dc
+			if r >= 0 && r < 8 && c >= 0 && c < 8 {
+				table[sq] |= 1 << (r*8 + c)
+			}
+		}
+	}
+	return table
+}
+
+// slidingAttacks recorre los rayos desde sq hasta la primera casilla ocupada (incluida).
+func slidingAttacks(sq uint8, occupancy uint64, dirs []Direction) uint64 {
+	var attacks uint64
+	for _, d := range dirs {
+		r, c := int8(sq/8), int8(sq%8)
+		for {
+			r += d.dr
+			c += d.dc
+			if r < 0 || r > 7 || c < 0 || c > 7 {
+				break
+			}
+			to := uint64(1) << (r*8 + c)
+			attacks |= to
+			if occupancy&to != 0 {
+				break
+			}
+		}
+	}
+	return attacks
+}
+
+func bishopAttacks(sq uint8, occupancy uint64) uint64 {
+	return slidingAttacks(sq, occupancy, dirDiagonal)
+}
+
+func rookAttacks(sq uint8, occupancy uint64) uint64 {
+	return slidingAttacks(sq, occupancy, dirStraight)
+}
+
+// AttackersTo devuelve las piezas de ambos colores que atacan la casilla sq (0-63) con la
+// ocupación indicada. Solo cuentan las piezas presentes en occupancy, de modo que al
+// quitar una pieza aparecen los ataques en rayos X de las que estaban detrás.
+func (b *Board) AttackersTo(sq uint8, occupancy uint64) uint64 {
+	w, k := &b.WhitePieces, &b.BlackPieces
+	diagonal := w.Bishops | w.Queens | k.Bishops | k.Queens
+	straight := w.Rooks | w.Queens | k.Rooks | k.Queens
+	attackers := pawnAttacks[1][sq]&w.Pawns |
+		pawnAttacks[0][sq]&k.Pawns |
+		knightAttacks[sq]&(w.Knights|k.Knights) |
+		kingAttacks[sq]&(w.King|k.King) |
+		bishopAttacks(sq, occupancy)&diagonal |
+		rookAttacks(sq, occupancy)&straight
+	return attackers & occupancy
+}
+
+// seeValues son los valores de las piezas para el intercambio estático, indexados por Piece.
+var seeValues = [7]int{0, 100, 320, 330, 500, 900, 20000}
+
+// SEE estima el resultado en centipawns para el bando que mueve de la secuencia de
+// capturas en la casilla de destino que empieza con m, si ambos bandos capturan siempre
+// con la pieza de menor valor y pueden parar cuando no les conviene seguir. No comprueba
+// si las capturas dejan al rey en jaque.
+func (b *Board) SEE(m Move) int {
+	from, to := m.GetFrom64(), m.GetTo64()
+	piece, isWhite := b.PieceAtSquare(from)
+	occupancy := b.AllPieces() &^ from
+
+	var gain [32]int
+	if captured, _ := b.PieceAtSquare(to); captured != 0 {
+		gain[0] = seeValues[captured]
+	} else if m.IsCapture() && piece == Pawn {
+		// Al paso: el peón capturado está detrás de la casilla de destino
+		gain[0] = seeValues[Pawn]
+		if isWhite {
+			occupancy &^= to >> 8
+		} else {
+			occupancy &^= to << 8
+		}
+	}
+	attackerValue := seeValues[piece]
+	if promo := m.PromotionPiece(); promo != 0 {
+		gain[0] += seeValues[promo] - seeValues[Pawn]
+		attackerValue = seeValues[promo]
+	}
+
+	attackers := b.AttackersTo(m.To(), occupancy)
+	side := !isWhite
+	d := 0
+	for d < len(gain)-1 {
+		own := b.BlackOccupiedSquares()
+		if side {
+			own = b.WhiteOccupiedSquares()
+		}
+		lva, lvaPiece := b.leastValuableAttacker(attackers&own, side)
+		if lva == 0 {
+			break
+		}
+		// El rey solo puede capturar si el rival ya no ataca la casilla
+		if lvaPiece == King && attackers&^own != 0 {
+			break
+		}
+		d++
+		gain[d] = attackerValue - gain[d-1]
+		attackerValue = seeValues[lvaPiece]
+		occupancy &^= lva
+		attackers = b.AttackersTo(m.To(), occupancy)
+		side = !side
+	}
+	for ; d > 0; d-- {
+		gain[d-1] = -max(-gain[d-1], gain[d])
+	}
+	return gain[0]
+}
+
+// leastValuableAttacker devuelve la casilla y el tipo de la pieza de menor valor de
+// attackers, que deben ser todas del color isWhite.
+func (b *Board) leastValuableAttacker(attackers uint64, isWhite bool) (uint64, Piece) {
+	pieces := &b.BlackPieces
+	if isWhite {
+		pieces = &b.WhitePieces
+	}
+	for p := Pawn; p <= King; p++ {
+		if bb := attackers & pieces.Get(p); bb != 0 {
+			return bb & -bb, p
+		}
+	}
+	return 0, 0
+}
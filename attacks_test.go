@@ -0,0 +1,94 @@
+package melange
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAttackersTo(t *testing.T) {
+	b := &Board{}
+	assert.NilError(t, b.SetFen("4k3/8/2n5/3p4/4P3/2N5/8/R3K2B w - - 0 1"))
+	d5 := uint8(35)
+	assert.Equal(t, b.AttackersTo(d5, b.AllPieces()), E4|C3)
+	// Sin el peón de e4 aparece el alfil de h1 en rayos X
+	assert.Equal(t, b.AttackersTo(d5, b.AllPieces()&^E4), C3|H1)
+	// Ambos colores: la torre y el caballo blancos atacan a4 y el caballo negro d4
+	assert.Equal(t, b.AttackersTo(24, b.AllPieces()), A1|C3)
+	assert.Equal(t, b.AttackersTo(27, b.AllPieces()), C6)
+}
+
+func TestAttackersToMatchesSquareAttacked(t *testing.T) {
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	}
+	for _, fen := range fens {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(fen))
+		for sq := uint8(0); sq < 64; sq++ {
+			attackers := b.AttackersTo(sq, b.AllPieces())
+			byBlack := attackers&b.BlackOccupiedSquares() != 0
+			byWhite := attackers&b.WhiteOccupiedSquares() != 0
+			row, col := int8(sq/8), int8(sq%8)
+			assert.Equal(t, byBlack, b.SquareAttacked(row, col, true), "%s %s", fen, squareToString(sq))
+			assert.Equal(t, byWhite, b.SquareAttacked(row, col, false), "%s %s", fen, squareToString(sq))
+		}
+	}
+}
+
+func findMove(t *testing.T, b *Board, uci string) Move {
+	for _, m := range b.GetLegalMoves() {
+		s := m.ToSimpleString()
+		if promo := m.PromotionPiece(); promo != 0 {
+			s += string(" pnbrq"[promo])
+		}
+		if s == uci {
+			return m
+		}
+	}
+	t.Fatalf("movimiento %s no encontrado", uci)
+	return NoMove
+}
+
+func TestSEE(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move string
+		see  int
+	}{
+		// Peón indefenso
+		{"1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "e1e5", 100},
+		// Caballo por peón defendido y con más defensores detrás
+		{"1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1", "d3e5", -220},
+		// Rayos X: la dama detrás de la torre mantiene la captura
+		{"3r3k/3r4/8/3p4/8/8/3R4/3QK3 w - - 0 1", "d2d5", 100 - 500},
+		{"3r3k/8/8/3p4/8/8/3R4/3QK3 w - - 0 1", "d2d5", 100},
+		// Al paso
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 100},
+		// Promoción sin captura en casilla defendida
+		{"3rk3/2P5/8/8/8/8/8/4K3 w - - 0 1", "c7c8q", 0 - 100},
+		// El rey blanco recupera el alfil
+		{"4k3/8/8/8/8/2b5/3p4/3QK3 w - - 0 1", "d1d2", 100 - 900 + 330},
+		// El rey solo recaptura si la casilla no está defendida
+		{"4k3/3p4/8/8/8/8/3R4/6K1 w - - 0 1", "d2d7", 100 - 500},
+		{"4k3/3p4/8/8/8/8/3R4/3R2K1 w - - 0 1", "d2d7", 100},
+	}
+	for _, tt := range tests {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(tt.fen))
+		assert.Equal(t, b.SEE(findMove(t, b, tt.move)), tt.see, "%s %s", tt.fen, tt.move)
+	}
+}
+
+func TestGoodCapturesOrder(t *testing.T) {
+	b := &Board{}
+	// Dxd5 pierde la dama; exd5 gana un peón y Txa7 una torre
+	assert.NilError(t, b.SetFen("4k3/r7/2p5/3p4/4P3/8/8/R2QK3 w - - 0 1"))
+	var got []string
+	for _, m := range goodCaptures(b, b.GetLegalMoves()) {
+		got = append(got, m.ToSimpleString())
+	}
+	assert.DeepEqual(t, got, []string{"a1a7", "e4d5"})
+}
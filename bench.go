@@ -0,0 +1,70 @@
+package melange
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultBenchDepth es la profundidad de bench cuando no se indica otra.
+const DefaultBenchDepth = 6
+
+// benchPositions es el conjunto fijo de posiciones de bench: aperturas, medios juegos
+// tácticos y tranquilos y finales. No debe cambiar, o dejarían de ser comparables las
+// firmas de commits distintos.
+var benchPositions = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 10",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 11",
+	"4rrk1/pp1n3p/3q2pQ/2p1pb2/2PP4/2P3N1/P2B2PP/4RRK1 b - - 7 19",
+	"rq3rk1/ppp2ppp/1bnpb3/3N2B1/3NP3/7P/PPPQ1PP1/2KR3R w - - 7 14",
+	"r1bq1r1k/1pp1n1pp/1p1p4/4p2Q/4Pp2/1BNP4/PPP2PPP/3R1RK1 w - - 2 14",
+	"r3r1k1/2p2ppp/p1p1bn2/8/1q2P3/2NPQN2/PPP3PP/R4RK1 b - - 2 15",
+	"r1bbk1nr/pp3p1p/2n5/1N4p1/2Np1B2/8/PPP2PPP/2KR1B1R w kq - 0 13",
+	"r1bq1rk1/ppp1nppp/4n3/3p3Q/3P4/1BP1B3/PP1N2PP/R4RK1 w - - 1 16",
+	"4r1k1/r1q2ppp/ppp2n2/4P3/5Rb1/1N1BQ3/PPP3PP/R5K1 w - - 1 17",
+	"2rqkb1r/ppp2p2/2npb1p1/1N1Nn2p/2P1PP2/8/PP2B1PP/R1BQK2R b KQ - 0 11",
+	"r1bq1b1r/ppp3kp/2np4/4p1B1/2B1P3/2N5/PPP2PPP/R2QK2R w KQ - 0 10",
+	"6k1/6p1/6Pp/ppp5/3pn2P/1P3K2/1PP2P2/3N4 b - - 0 1",
+	"3b4/5kp1/1p1p1p1p/pP1PpP1P/P1P1P3/3KN3/8/8 w - - 0 1",
+	"8/6pk/1p6/8/PP3p1p/5P2/4KP1q/3Q4 w - - 0 1",
+	"7k/3p2pp/4q3/8/4Q3/5Kp1/P6b/8 w - - 0 1",
+	"8/2p5/8/2kPKp1p/2p4P/2P5/3P4/8 w - - 0 1",
+	"8/1p3pp1/7p/5P1P/2k3P1/8/2K2P2/8 w - - 0 1",
+}
+
+// BenchResult es el resultado de Bench.
+type BenchResult struct {
+	Nodes   int64
+	Elapsed time.Duration
+}
+
+// NPS devuelve los nodos por segundo.
+func (r BenchResult) NPS() int64 {
+	return r.Nodes * 1000 / max(r.Elapsed.Milliseconds(), 1)
+}
+
+// Bench busca cada posición del conjunto a la profundidad indicada, con un buscador nuevo
+// de un solo hilo para que el resultado sea reproducible, y escribe en w los nodos de cada
+// posición y el total. Con la misma profundidad y evaluación el total de nodos solo cambia
+// si cambia la búsqueda, así que sirve de firma para detectar cambios funcionales entre
+// commits; los nodos por segundo miden la velocidad.
+func Bench(depth int, eval Evaluator, w io.Writer) BenchResult {
+	var total BenchResult
+	for i, fen := range benchPositions {
+		b := &Board{}
+		if err := b.SetFen(fen); err != nil {
+			panic(fmt.Sprintf("bench position %d: %v", i+1, err))
+		}
+		start := time.Now()
+		res := NewSearcher(eval).Search(b, SearchLimits{Depth: depth})
+		total.Elapsed += time.Since(start)
+		total.Nodes += res.Nodes
+		fmt.Fprintf(w, "Position %2d/%d: %-8s %10d nodes  %s\n", i+1, len(benchPositions), res.BestMove.UCIString(), res.Nodes, fen)
+	}
+	fmt.Fprintln(w, "===========================")
+	fmt.Fprintf(w, "Total time (ms) : %d\n", total.Elapsed.Milliseconds())
+	fmt.Fprintf(w, "Nodes searched  : %d\n", total.Nodes)
+	fmt.Fprintf(w, "Nodes/second    : %d\n", total.NPS())
+	return total
+}
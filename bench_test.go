@@ -0,0 +1,29 @@
+package melange
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBenchIsDeterministic(t *testing.T) {
+	var out bytes.Buffer
+	first := Bench(3, DefaultEvalParams(), &out)
+	assert.Assert(t, first.Nodes > 0)
+	assert.Equal(t, strings.Count(out.String(), "nodes "), len(benchPositions))
+	assert.Assert(t, strings.Contains(out.String(), "Nodes/second"))
+
+	// La firma no depende de búsquedas anteriores
+	second := Bench(3, DefaultEvalParams(), &bytes.Buffer{})
+	assert.Equal(t, second.Nodes, first.Nodes)
+}
+
+func TestBenchPositionsAreValid(t *testing.T) {
+	for _, fen := range benchPositions {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(fen))
+		assert.Assert(t, b.hasLegalMove(), fen)
+	}
+}
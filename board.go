@@ -14,10 +14,32 @@ type Board struct {
 	EnPassant   uint8        // Square index for en passant target (0-63), 0 if none
 	HalfMove    uint32       // Halfmove clock for fifty-move rule
 	FullMove    uint32       // Fullmove number starting at 1 and incremented after Black's move
+	Chess960    bool         // Castling moves are written king-takes-rook in UCI (see UCIMove)
+	Variant     Variant      // Rules being played (see Variant)
+	Checks      [2]uint8     // Checks given by White and Black, counted in ThreeCheck
+	Hands       [2]Hand      // Pieces in hand of White and Black in Crazyhouse
+	Promoted    uint64       // Promoted pieces, which go back to hand as pawns when captured (Crazyhouse)
+
+	castlingRooks [4]uint8   // Starting square of the rook of each castling right, XOR its standard square (see castlingRook)
+	squares       [64]uint8  // Piece on each square, kept in sync with the bitboards (see syncSquares)
+	hash          uint64     // Zobrist key, kept up to date by MovePiece (see Hash)
+	nnue          *nnueState // NNUE accumulators, only when a network is attached (see AttachNetwork)
+}
+
+// blackPiece marca en Board.squares las piezas negras: cada casilla guarda la pieza
+// (Pawn..King), más blackPiece si es negra, o 0 si está vacía.
+const blackPiece = 8
+
+// squareCode es el valor de Board.squares para la pieza piece del color isWhite.
+func squareCode(piece Piece, isWhite bool) uint8 {
+	if isWhite {
+		return uint8(piece)
+	}
+	return uint8(piece) | blackPiece
 }
 
 func NewBoard() *Board {
-	return &Board{
+	b := &Board{
 		WhitePieces: Pieces{
 			Pawns:   A2 | B2 | C2 | D2 | E2 | F2 | G2 | H2,
 			Knights: B1 | G1,
@@ -40,10 +62,12 @@ func NewBoard() *Board {
 		HalfMove:    0,
 		FullMove:    1,
 	}
+	b.syncSquares()
+	return b
 }
 
 func (b *Board) Clone() *Board {
-	return &Board{
+	c := &Board{
 		WhitePieces: b.WhitePieces,
 		BlackPieces: b.BlackPieces,
 		WhiteToMove: b.WhiteToMove,
@@ -51,27 +75,29 @@ func (b *Board) Clone() *Board {
 		EnPassant:   b.EnPassant,
 		HalfMove:    b.HalfMove,
 		FullMove:    b.FullMove,
+		Chess960:    b.Chess960,
+		Variant:     b.Variant,
+		Checks:      b.Checks,
+		Hands:       b.Hands,
+		Promoted:    b.Promoted,
+
+		castlingRooks: b.castlingRooks,
+		squares:       b.squares,
+		hash:          b.hash,
 	}
-}
-
-func (b *Board) NewMove(t MoveType, from uint64, to uint64, piece Piece) Move {
-	return Move{
-		From:     uint8(bits.TrailingZeros64(from)),
-		To:       uint8(bits.TrailingZeros64(to)),
-		Piece:    piece,
-		Type:     t,
-		Castling: b.Castling,
-		// EnPassant siempre inicia en 0; solo los dobles avances de peón lo establecerán
-		EnPassant: 0,
+	if b.nnue != nil {
+		c.nnue = b.nnue.clone()
 	}
+	return c
 }
 
-// MoveType must be MoveCapture or one MovePromoCapture
-// Checks if rook is captured and updates castling rights if needed
-func (b *Board) NewCaptureMove(t MoveType, from uint64, to uint64, piece Piece) Move {
-	move := b.NewMove(t, from, to, piece)
-	move.CheckCapturedRook(b.WhiteToMove, to, b)
-	return move
+// Equal checks if two boards are identical in piece placement and turn.
+func (b *Board) Equal(other *Board) bool {
+	return b.WhitePieces == other.WhitePieces &&
+		b.BlackPieces == other.BlackPieces &&
+		b.WhiteToMove == other.WhiteToMove &&
+		b.EnPassant == other.EnPassant &&
+		b.Castling == other.Castling
 }
 
 func (b *Board) AllPieces() uint64 {
@@ -118,6 +144,9 @@ func (b *Board) GetPiecesToMove() *Pieces {
 	return &b.BlackPieces
 }
 
+// MovePiece aplica move, del bando isWhite, y actualiza el turno, la casilla al paso, los
+// relojes y los derechos de enroque: los pierde el bando que mueve el rey o una torre de
+// enroque, y el rival si se le captura una.
 func (b *Board) MovePiece(move Move, isWhite bool) {
 	var pieces *Pieces
 	if isWhite {
@@ -125,14 +154,43 @@ func (b *Board) MovePiece(move Move, isWhite bool) {
 	} else {
 		pieces = &b.BlackPieces
 	}
+	piece := b.MovingPiece(move)
+	from, to := move.From(), move.To()
+	c := colorIndex(isWhite)
+	// La clave pierde aquí el enroque y la casilla al paso de antes y recupera al final los
+	// nuevos
+	b.hash ^= zobristCastling[b.Castling&15] ^ b.enPassantKey()
+
+	// El reloj de cincuenta movimientos vuelve a 0 con los movimientos de peón y las
+	// capturas, y el número de jugada sube tras mover las negras
+	if piece == Pawn || move.IsCapture() {
+		b.HalfMove = 0
+	} else {
+		b.HalfMove++
+	}
+	if !isWhite {
+		b.FullMove++
+	}
 
-	b.Castling = move.Castling
+	switch {
+	case move.IsDrop():
+	case piece == King && isWhite:
+		b.Castling &^= WhiteKingSide | WhiteQueenSide
+	case piece == King:
+		b.Castling &^= BlackKingSide | BlackQueenSide
+	case piece == Rook:
+		b.Castling &^= b.castlingRightsOfRook(move.GetFrom64(), isWhite)
+	}
 
-	if move.Type&MoveCapture != 0 {
+	if move.IsCapture() {
 		destBit := move.GetTo64()
+		captured := b.squares[to]
+		if captured == squareCode(Rook, !isWhite) {
+			b.Castling &^= b.castlingRightsOfRook(destBit, !isWhite)
+		}
 		// Detectar captura en passant antes de mover la pieza: si es captura de peón a casilla EnPassant previa y
 		// la casilla destino está vacía
-		if move.Piece == Pawn && b.EnPassant == move.To && !b.IsSquareOccupied(destBit) { // casilla destino vacía => en passant
+		if piece == Pawn && b.EnPassant == to && captured == 0 { // casilla destino vacía => en passant
 			if isWhite {
 				// Captura peón negro que está justo detrás (una fila abajo en términos de bitboard: destino >> 8)
 				captured := destBit >> 8
@@ -148,74 +206,89 @@ func (b *Board) MovePiece(move Move, isWhite bool) {
 		}
 
 	}
+	if b.Variant == Crazyhouse {
+		b.updateHands(move, isWhite)
+	}
+
+	if b.nnue != nil {
+		b.nnue.update(b, move, piece, isWhite)
+	}
 
-	switch move.Piece {
+	// Las piezas soltadas no salen de ninguna casilla
+	fromBB, toBB := move.GetFrom64(), move.GetTo64()
+	if move.IsDrop() {
+		fromBB = 0
+	} else {
+		b.squares[from] = 0
+		b.hash ^= zobristPieces[c][piece][from]
+	}
+	placed := piece
+	if promo := move.PromotionPiece(); promo != 0 {
+		placed = promo
+	}
+	b.squares[to] = squareCode(placed, isWhite)
+	b.hash ^= zobristPieces[c][placed][to]
+	switch piece {
 	case Pawn:
-		pieces.Pawns &= ^move.GetFrom64()
-		pieces.Pawns |= move.GetTo64()
+		pieces.Pawns &= ^fromBB
+		pieces.Pawns |= toBB
 		// Handle promotion: replace pawn with promoted piece
-		if (move.Type & MovePromotion) != 0 {
-			// remove pawn at destination
-			pieces.Pawns &= ^move.GetTo64()
-			// add promoted piece according to flags
-			if (move.Type & 16) != 0 { // Knight
-				pieces.Knights |= move.GetTo64()
-			} else if (move.Type & 32) != 0 { // Bishop
-				pieces.Bishops |= move.GetTo64()
-			} else if (move.Type & 64) != 0 { // Rook
-				pieces.Rooks |= move.GetTo64()
-			} else if (move.Type & 128) != 0 { // Queen
-				pieces.Queens |= move.GetTo64()
-			}
+		switch move.PromotionPiece() {
+		case Knight:
+			pieces.Pawns &= ^toBB
+			pieces.Knights |= toBB
+		case Bishop:
+			pieces.Pawns &= ^toBB
+			pieces.Bishops |= toBB
+		case Rook:
+			pieces.Pawns &= ^toBB
+			pieces.Rooks |= toBB
+		case Queen:
+			pieces.Pawns &= ^toBB
+			pieces.Queens |= toBB
+		case King:
+			pieces.Pawns &= ^toBB
+			pieces.King |= toBB
 		}
 	case Knight:
-		pieces.Knights &= ^move.GetFrom64()
-		pieces.Knights |= move.GetTo64()
+		pieces.Knights &= ^fromBB
+		pieces.Knights |= toBB
 	case Bishop:
-		pieces.Bishops &= ^move.GetFrom64()
-		pieces.Bishops |= move.GetTo64()
+		pieces.Bishops &= ^fromBB
+		pieces.Bishops |= toBB
 	case Rook:
-		pieces.Rooks &= ^move.GetFrom64()
-		pieces.Rooks |= move.GetTo64()
+		pieces.Rooks &= ^fromBB
+		pieces.Rooks |= toBB
 	case Queen:
-		pieces.Queens &= ^move.GetFrom64()
-		pieces.Queens |= move.GetTo64()
+		pieces.Queens &= ^fromBB
+		pieces.Queens |= toBB
 	case King:
-		pieces.King &= ^move.GetFrom64()
-		pieces.King |= move.GetTo64()
+		pieces.King &= ^fromBB
+		pieces.King |= toBB
 		// If castling, move the rook as well
-		switch move.Type {
-		case MoveKingCastle:
-			if isWhite {
-				// White king-side castle: move rook from H1 to F1
-				pieces.Rooks &= ^H1
-				pieces.Rooks |= F1
-			} else {
-				// Black king-side castle: move rook from H8 to F8
-				pieces.Rooks &= ^H8
-				pieces.Rooks |= F8
-			}
-		case MoveQueenCastle:
-			if isWhite {
-				// White queen-side castle: move rook from A1 to D1
-				pieces.Rooks &= ^A1
-				pieces.Rooks |= D1
-			} else {
-				// Black queen-side castle: move rook from A8 to D8
-				pieces.Rooks &= ^A8
-				pieces.Rooks |= D8
+		if rookFrom, rookTo, ok := b.castlingRookSquares(move.Type(), isWhite); ok {
+			pieces.Rooks &= ^rookFrom
+			pieces.Rooks |= rookTo
+			rookFromSq, rookToSq := bits.TrailingZeros64(rookFrom), bits.TrailingZeros64(rookTo)
+			// En Chess960 la torre puede salir de la casilla a la que llega el rey
+			if rookFrom != toBB {
+				b.squares[rookFromSq] = 0
 			}
+			b.squares[rookToSq] = squareCode(Rook, isWhite)
+			b.hash ^= zobristPieces[c][Rook][rookFromSq] ^ zobristPieces[c][Rook][rookToSq]
 		}
 	default:
 		panic("Unknown piece type in MovePiece")
 	}
 
 	// Si el movimiento es un doble avance de peón establecer EnPassant, si no limpiarlo
-	if move.Piece == Pawn {
-		// Un doble avance se reconoce porque la diferencia de índices es 16 (dos filas) y no es captura
-		if (move.Type&MoveCapture) == 0 && (move.From+16 == move.To || move.From == move.To+16) {
+	if piece == Pawn && !move.IsDrop() {
+		// Un doble avance se reconoce porque la diferencia de índices es 16 (dos filas) y no es captura.
+		// En Horde los peones de la primera fila también avanzan dos casillas, pero no se
+		// pueden capturar al paso
+		if !move.IsCapture() && (from+16 == to || from == to+16) && from/8 != 0 {
 			// Casilla intermedia = (from+to)/2
-			mid := (uint16(move.From) + uint16(move.To)) / 2
+			mid := (uint16(from) + uint16(to)) / 2
 			b.EnPassant = uint8(mid)
 		} else {
 			b.EnPassant = 0
@@ -223,10 +296,29 @@ func (b *Board) MovePiece(move Move, isWhite bool) {
 	} else {
 		b.EnPassant = 0
 	}
+	b.hash ^= zobristCastling[b.Castling&15] ^ b.enPassantKey() ^ zobristBlack
 	b.WhiteToMove = !b.WhiteToMove
+	if b.Variant == ThreeCheck && b.IsKingInCheck(b.WhiteToMove) {
+		b.hash ^= checksKey(c, b.Checks[c])
+		b.Checks[c]++
+		b.hash ^= checksKey(c, b.Checks[c])
+	}
 }
 
 func (b *Board) CapturePiece(square uint64, isWhite bool) {
+	if b.Variant == Crazyhouse {
+		b.pocketCapture(square, isWhite)
+	}
+	piece, pieceIsWhite := b.PieceAtSquare(square)
+	// Only Antichess kings can be captured, the other variants keep them on the board
+	if piece != 0 && pieceIsWhite == isWhite && (piece != King || b.Variant == Antichess) {
+		sq := bits.TrailingZeros64(square)
+		b.squares[sq] = 0
+		b.hash ^= zobristPieces[colorIndex(isWhite)][piece][sq]
+		if b.nnue != nil {
+			b.nnue.remove(isWhite, piece, sq)
+		}
+	}
 	if isWhite {
 		// Captura pieza blanca
 		b.WhitePieces.Pawns &= ^square
@@ -234,6 +326,9 @@ func (b *Board) CapturePiece(square uint64, isWhite bool) {
 		b.WhitePieces.Bishops &= ^square
 		b.WhitePieces.Rooks &= ^square
 		b.WhitePieces.Queens &= ^square
+		if b.Variant == Antichess { // The king is just another piece
+			b.WhitePieces.King &= ^square
+		}
 	} else {
 		// Captura pieza negra
 		b.BlackPieces.Pawns &= ^square
@@ -241,36 +336,77 @@ func (b *Board) CapturePiece(square uint64, isWhite bool) {
 		b.BlackPieces.Bishops &= ^square
 		b.BlackPieces.Rooks &= ^square
 		b.BlackPieces.Queens &= ^square
+		if b.Variant == Antichess {
+			b.BlackPieces.King &= ^square
+		}
 	}
 }
 
+// PieceAtSquare devuelve la pieza de la casilla square (un bitboard con una sola casilla) y
+// si es blanca, o 0 si está vacía.
 func (b *Board) PieceAtSquare(square uint64) (Piece, bool) {
-	if b.WhitePieces.Pawns&square != 0 {
-		return Pawn, true
-	} else if b.WhitePieces.Knights&square != 0 {
-		return Knight, true
-	} else if b.WhitePieces.Bishops&square != 0 {
-		return Bishop, true
-	} else if b.WhitePieces.Rooks&square != 0 {
-		return Rook, true
-	} else if b.WhitePieces.Queens&square != 0 {
-		return Queen, true
-	} else if b.WhitePieces.King&square != 0 {
-		return King, true
-	} else if b.BlackPieces.Pawns&square != 0 {
-		return Pawn, false
-	} else if b.BlackPieces.Knights&square != 0 {
-		return Knight, false
-	} else if b.BlackPieces.Bishops&square != 0 {
-		return Bishop, false
-	} else if b.BlackPieces.Rooks&square != 0 {
-		return Rook, false
-	} else if b.BlackPieces.Queens&square != 0 {
-		return Queen, false
-	} else if b.BlackPieces.King&square != 0 {
-		return King, false
-	}
-	return 0, false
+	if square == 0 {
+		return 0, false
+	}
+	code := b.squareAt(bits.TrailingZeros64(square))
+	return Piece(code &^ blackPiece), code != 0 && code&blackPiece == 0
+}
+
+// squareAt devuelve el contenido de la casilla sq (0-63) codificado como en Board.squares.
+func (b *Board) squareAt(sq int) uint8 {
+	return b.squares[sq]
+}
+
+// syncSquares reconstruye squares a partir de los bitboards y recalcula la clave Zobrist.
+// NewBoard y SetFen ya la llaman; quien monte o modifique el tablero a mano debe llamarla
+// después.
+func (b *Board) syncSquares() {
+	b.squares = [64]uint8{}
+	for piece := Pawn; piece <= King; piece++ {
+		for bb := b.WhitePieces.Get(piece); bb != 0; bb &= bb - 1 {
+			b.squares[bits.TrailingZeros64(bb)] = squareCode(piece, true)
+		}
+		for bb := b.BlackPieces.Get(piece); bb != 0; bb &= bb - 1 {
+			b.squares[bits.TrailingZeros64(bb)] = squareCode(piece, false)
+		}
+	}
+	b.hash = b.Hash()
+}
+
+// checkSquares comprueba que squares y los bitboards describen la misma posición y que
+// ninguna casilla está en dos bitboards.
+func (b *Board) checkSquares() error {
+	var seen uint64
+	for _, side := range []struct {
+		pieces  *Pieces
+		isWhite bool
+	}{{&b.WhitePieces, true}, {&b.BlackPieces, false}} {
+		for piece := Pawn; piece <= King; piece++ {
+			bb := side.pieces.Get(piece)
+			if seen&bb != 0 {
+				return fmt.Errorf("casillas en dos bitboards: %016x", seen&bb)
+			}
+			seen |= bb
+		}
+	}
+	for sq := 0; sq < 64; sq++ {
+		piece, isWhite := Piece(0), false
+		for p := Pawn; p <= King; p++ {
+			if b.WhitePieces.Get(p)&(uint64(1)<<sq) != 0 {
+				piece, isWhite = p, true
+			} else if b.BlackPieces.Get(p)&(uint64(1)<<sq) != 0 {
+				piece = p
+			}
+		}
+		want := uint8(0)
+		if piece != 0 {
+			want = squareCode(piece, isWhite)
+		}
+		if b.squares[sq] != want {
+			return fmt.Errorf("casilla %s: %d en squares y %d en los bitboards", squareToString(uint8(sq)), b.squares[sq], want)
+		}
+	}
+	return nil
 }
 
 func (b *Board) ToString() string {
@@ -282,30 +418,11 @@ func (b *Board) ToString() string {
 		col := i % 8
 
 		res := "."
-		if b.WhitePieces.Pawns&square != 0 {
-			res = "P"
-		} else if b.WhitePieces.Knights&square != 0 {
-			res = "N"
-		} else if b.WhitePieces.Bishops&square != 0 {
-			res = "B"
-		} else if b.WhitePieces.Rooks&square != 0 {
-			res = "R"
-		} else if b.WhitePieces.Queens&square != 0 {
-			res = "Q"
-		} else if b.WhitePieces.King&square != 0 {
-			res = "K"
-		} else if b.BlackPieces.Pawns&square != 0 {
-			res = "p"
-		} else if b.BlackPieces.Knights&square != 0 {
-			res = "n"
-		} else if b.BlackPieces.Bishops&square != 0 {
-			res = "b"
-		} else if b.BlackPieces.Rooks&square != 0 {
-			res = "r"
-		} else if b.BlackPieces.Queens&square != 0 {
-			res = "q"
-		} else if b.BlackPieces.King&square != 0 {
-			res = "k"
+		if piece, isWhite := b.PieceAtSquare(square); piece != 0 {
+			res = string(" PNBRQK"[piece])
+			if !isWhite {
+				res = string(" pnbrqk"[piece])
+			}
 		}
 		board[row][col] = res
 	}
@@ -343,316 +460,308 @@ func (b *Board) ToString() string {
 	return result
 }
 
+// maxMoves es el tamaño de los buffers de movimientos que se reservan en la pila: la
+// posición con más movimientos conocida del ajedrez normal tiene 218. En Crazyhouse, con
+// muchas piezas en la reserva, se pueden superar y la lista pasa al heap.
+const maxMoves = 256
+
 // GetLegalMoves generates all pseudo-legal moves for the current player. Does not check for uncovered king.
+// Variant rules are applied: no moves once a variant rule ends the game, only captures
+// when one is available in Antichess, and drops after the board moves in Crazyhouse.
 func (b *Board) GetLegalMoves() MoveList {
-	var legalMoves MoveList
+	return b.GenerateMoves(nil)
+}
 
-	for i := int8(0); i < 64; i++ {
-		square := A1 << i
-		row := i / 8
-		col := i % 8
-		pieceType, isWhite := b.PieceAtSquare(square)
-		if pieceType == 0 || isWhite != b.WhiteToMove {
-			continue
-		}
+// GenerateMoves es GetLegalMoves añadiendo los movimientos a moves, normalmente un buffer
+// en la pila para no reservar memoria en cada nodo:
+//
+//	var buf [maxMoves]Move
+//	moves := b.GenerateMoves(buf[:0])
+func (b *Board) GenerateMoves(moves MoveList) MoveList {
+	start := len(moves)
+	own := b.WhiteOccupiedSquares()
+	if !b.WhiteToMove {
+		own = b.BlackOccupiedSquares()
+	}
+	for bb := own; bb != 0; bb &= bb - 1 {
+		moves = b.appendMovesFrom(moves, int8(bits.TrailingZeros64(bb)))
+	}
+	if b.Variant == Crazyhouse {
+		moves = b.appendDrops(moves)
+	}
+	if b.Variant != Standard {
+		moves = moves[:start+len(b.filterVariantMoves(moves[start:]))]
+	}
+	return moves
+}
 
-		switch pieceType {
-		case Pawn:
-			if isWhite {
-				// WHITE PAWN MOVES
-				if row == 6 { // Promotion rank (from rank 7 to 8)
-					// Forward promotions
-					to := square << 8
-					if !b.IsSquareOccupied(to) {
-						legalMoves.Add(b.NewMove(MoveKnightPromo, square, to, Pawn))
-						legalMoves.Add(b.NewMove(MoveBishopPromo, square, to, Pawn))
-						legalMoves.Add(b.NewMove(MoveRookPromo, square, to, Pawn))
-						legalMoves.Add(b.NewMove(MoveQueenPromo, square, to, Pawn))
-					}
-					// Capture promotions (no en passant possible on last rank)
-					if col < 7 {
-						toCap := square << 9
-						if b.IsSquareOccupiedByBlack(toCap) {
-							legalMoves.Add(b.NewCaptureMove(MoveKnightPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(MoveBishopPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(MoveRookPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(QueenPromoCapture, square, toCap, Pawn))
-						}
-					}
-					if col > 0 {
-						toCap := square << 7
-						if b.IsSquareOccupiedByBlack(toCap) {
-							legalMoves.Add(b.NewCaptureMove(MoveKnightPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(MoveBishopPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(MoveRookPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(QueenPromoCapture, square, toCap, Pawn))
-						}
-					}
-				} else {
-					// Normal forward single
-					if row < 7 {
-						to := square << 8
-						if !b.IsSquareOccupied(to) {
-							move := b.NewMove(MoveNormal, square, to, Pawn)
-							move.EnPassant = 0
-							legalMoves.Add(move)
-						}
-					}
-					// Double advance from starting rank
-					if row == 1 {
-						to := square << 16
-						if !b.IsSquareOccupied(to) && !b.IsSquareOccupied(square<<8) {
-							move := b.NewMove(MoveNormal, square, to, Pawn)
-							move.EnPassant = uint8(bits.TrailingZeros64(square << 8))
-							legalMoves.Add(move)
-						}
-					}
-					// Captures / en passant
-					if row < 7 && col < 7 { // capture right
-						to := square << 9
-						if b.IsSquareOccupiedByBlack(to) {
-							move := b.NewCaptureMove(MoveCapture, square, to, Pawn)
-							legalMoves.Add(move)
-						} else if b.EnPassant != 0 && to == (uint64(1)<<b.EnPassant) {
-							move := b.NewCaptureMove(MoveCapture, square, to, Pawn)
-							legalMoves.Add(move)
-						}
-					}
-					if row < 7 && col > 0 { // capture left
-						to := square << 7
-						if b.IsSquareOccupiedByBlack(to) {
-							move := b.NewCaptureMove(MoveCapture, square, to, Pawn)
-							legalMoves.Add(move)
-						} else if b.EnPassant != 0 && to == (uint64(1)<<b.EnPassant) {
-							move := b.NewCaptureMove(MoveCapture, square, to, Pawn)
-							legalMoves.Add(move)
-						}
-					}
+// emptyAt, whiteAt y blackAt consultan en squares la casilla del bitboard square, que tiene
+// que tener una sola casilla. Son la forma rápida de IsSquareOccupied y compañía al generar
+// movimientos.
+func (b *Board) emptyAt(square uint64) bool {
+	return b.squares[bits.TrailingZeros64(square)] == 0
+}
+
+func (b *Board) whiteAt(square uint64) bool {
+	code := b.squares[bits.TrailingZeros64(square)]
+	return code != 0 && code&blackPiece == 0
+}
+
+func (b *Board) blackAt(square uint64) bool {
+	return b.squares[bits.TrailingZeros64(square)]&blackPiece != 0
+}
+
+// appendMovesFrom añade a legalMoves los movimientos pseudo-legales de la pieza situada en
+// la casilla i (0-63), si es del bando al mover.
+func (b *Board) appendMovesFrom(legalMoves MoveList, i int8) MoveList {
+	square := A1 << i
+	row := i / 8
+	col := i % 8
+	pieceType, isWhite := b.PieceAtSquare(square)
+	if pieceType == 0 || isWhite != b.WhiteToMove {
+		return legalMoves
+	}
+
+	switch pieceType {
+	case Pawn:
+		if isWhite {
+			// WHITE PAWN MOVES
+			if row == 6 { // Promotion rank (from rank 7 to 8)
+				// Forward promotions
+				to := square << 8
+				if b.emptyAt(to) {
+					legalMoves = b.appendPromotions(legalMoves, square, to, false)
 				}
-			} else { // BLACK
-				if row == 1 { // Promotion (from rank 2 to 1)
-					// Forward promotions
-					to := square >> 8
-					if !b.IsSquareOccupied(to) {
-						legalMoves.Add(b.NewMove(MoveKnightPromo, square, to, Pawn))
-						legalMoves.Add(b.NewMove(MoveBishopPromo, square, to, Pawn))
-						legalMoves.Add(b.NewMove(MoveRookPromo, square, to, Pawn))
-						legalMoves.Add(b.NewMove(MoveQueenPromo, square, to, Pawn))
+				// Capture promotions (no en passant possible on last rank)
+				if col < 7 {
+					toCap := square << 9
+					if b.blackAt(toCap) {
+						legalMoves = b.appendPromotions(legalMoves, square, toCap, true)
 					}
-					// Capture promotions
-					if col < 7 { // capture right (from black perspective)
-						toCap := square >> 7
-						if b.IsSquareOccupiedByWhite(toCap) {
-							legalMoves.Add(b.NewCaptureMove(MoveKnightPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(MoveBishopPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(MoveRookPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(QueenPromoCapture, square, toCap, Pawn))
-						}
-					}
-					if col > 0 { // capture left
-						toCap := square >> 9
-						if b.IsSquareOccupiedByWhite(toCap) {
-							legalMoves.Add(b.NewCaptureMove(MoveKnightPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(MoveBishopPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(MoveRookPromoCapture, square, toCap, Pawn))
-							legalMoves.Add(b.NewCaptureMove(QueenPromoCapture, square, toCap, Pawn))
-						}
-					}
-				} else {
-					// Normal single forward
-					if row > 0 {
-						to := square >> 8
-						if !b.IsSquareOccupied(to) {
-							move := b.NewMove(MoveNormal, square, to, Pawn)
-							move.EnPassant = 0
-							legalMoves.Add(move)
-						}
+				}
+				if col > 0 {
+					toCap := square << 7
+					if b.blackAt(toCap) {
+						legalMoves = b.appendPromotions(legalMoves, square, toCap, true)
 					}
-					// Double advance from starting rank (row 6 -> row 4)
-					if row == 6 {
-						to := square >> 16
-						if !b.IsSquareOccupied(to) && !b.IsSquareOccupied(square>>8) {
-							move := b.NewMove(MoveNormal, square, to, Pawn)
-							move.EnPassant = uint8(bits.TrailingZeros64(square >> 8))
-							legalMoves.Add(move)
-						}
+				}
+			} else {
+				// Normal forward single
+				if row < 7 {
+					to := square << 8
+					if b.emptyAt(to) {
+						legalMoves = append(legalMoves, NewMove(MoveNormal, square, to))
 					}
-					// Captures / en passant
-					if row > 0 && col < 7 {
-						to := square >> 7
-						if b.IsSquareOccupiedByWhite(to) {
-							move := b.NewCaptureMove(MoveCapture, square, to, Pawn)
-							legalMoves.Add(move)
-						} else if b.EnPassant != 0 && to == (uint64(1)<<b.EnPassant) {
-							move := b.NewCaptureMove(MoveCapture, square, to, Pawn)
-							legalMoves.Add(move)
-						}
+				}
+				// Double advance from starting rank (also from the first rank in Horde)
+				if row == 1 || row == 0 && b.Variant == Horde {
+					to := square << 16
+					if b.emptyAt(to) && b.emptyAt(square<<8) {
+						legalMoves = append(legalMoves, NewMove(MoveNormal, square, to))
 					}
-					if row > 0 && col > 0 {
-						to := square >> 9
-						if b.IsSquareOccupiedByWhite(to) {
-							move := b.NewCaptureMove(MoveCapture, square, to, Pawn)
-							legalMoves.Add(move)
-						} else if b.EnPassant != 0 && to == (uint64(1)<<b.EnPassant) {
-							move := b.NewCaptureMove(MoveCapture, square, to, Pawn)
-							legalMoves.Add(move)
-						}
+				}
+				// Captures / en passant
+				if row < 7 && col < 7 { // capture right
+					to := square << 9
+					if b.blackAt(to) {
+						legalMoves = append(legalMoves, NewMove(MoveCapture, square, to))
+					} else if b.EnPassant != 0 && to == (uint64(1)<<b.EnPassant) {
+						legalMoves = append(legalMoves, NewMove(MoveCapture, square, to))
 					}
 				}
-			}
-		case Knight:
-			// Generate knight moves
-			knightMoves := []int8{17, 15, 10, 6, -17, -15, -10, -6}
-			for _, moveOffset := range knightMoves {
-				toIndex := i + moveOffset
-				toCol := toIndex % 8
-				colDelta := math.Abs(float64(toCol - col)) // Ensure movement is not out of bounds
-				if toIndex >= 0 && toIndex < 64 && (colDelta <= 2) {
-					to := uint64(1) << toIndex
-					occupiedByWhite := b.IsSquareOccupiedByWhite(to)
-					occupiedByBlack := b.IsSquareOccupiedByBlack(to)
-					// Knight cannot move to a square occupied by a piece of the same color
-					moveForbidden := (isWhite && occupiedByWhite) || (!isWhite && occupiedByBlack)
-					if !moveForbidden {
-						// move := b.Clone()
-						move := b.NewMove(MoveNormal, square, to, Knight)
-						if (isWhite && occupiedByBlack) || (!isWhite && occupiedByWhite) {
-							// move.CapturePiece(to, !isWhite)
-							move.Type = MoveCapture
-							move.CheckCapturedRook(isWhite, to, b)
-						}
-						// move.MovePiece(square, to, isWhite, Knight)
-						legalMoves.Add(move)
+				if row < 7 && col > 0 { // capture left
+					to := square << 7
+					if b.blackAt(to) {
+						legalMoves = append(legalMoves, NewMove(MoveCapture, square, to))
+					} else if b.EnPassant != 0 && to == (uint64(1)<<b.EnPassant) {
+						legalMoves = append(legalMoves, NewMove(MoveCapture, square, to))
 					}
 				}
 			}
-		case Bishop:
-			// Movimientos en las 4 diagonales: NE, NO, SE, SO
-			for _, dir := range dirDiagonal {
-				dirMoves := getLegalMovesInOneDirection(b, row, col, square, dir, isWhite, Bishop)
-				legalMoves.Append(dirMoves)
-			}
-		case Rook:
-			rookMoves := []Move{}
-			// Movimientos en las 4 direcciones: N, S, E, O
-			for _, dir := range dirStraight {
-				dirMoves := getLegalMovesInOneDirection(b, row, col, square, dir, isWhite, Rook)
-				rookMoves = append(rookMoves, dirMoves...)
-			}
-
-			if isWhite {
-				// Update white castling rights
-				if square == A1 && (b.Castling&WhiteQueenSide != 0) {
-					// Update castling rights for rook moves from original squares only if rights are set
-					for i := range rookMoves {
-						rookMoves[i].Castling &^= WhiteQueenSide
+		} else { // BLACK
+			if row == 1 { // Promotion (from rank 2 to 1)
+				// Forward promotions
+				to := square >> 8
+				if b.emptyAt(to) {
+					legalMoves = b.appendPromotions(legalMoves, square, to, false)
+				}
+				// Capture promotions
+				if col < 7 { // capture right (from black perspective)
+					toCap := square >> 7
+					if b.whiteAt(toCap) {
+						legalMoves = b.appendPromotions(legalMoves, square, toCap, true)
 					}
-				} else if square == H1 && (b.Castling&WhiteKingSide != 0) {
-					for i := range rookMoves {
-						rookMoves[i].Castling &^= WhiteKingSide
+				}
+				if col > 0 { // capture left
+					toCap := square >> 9
+					if b.whiteAt(toCap) {
+						legalMoves = b.appendPromotions(legalMoves, square, toCap, true)
 					}
 				}
 			} else {
-				// Update black castling rights
-				if square == A8 && (b.Castling&BlackQueenSide != 0) {
-					// Update castling rights for rook moves from original squares only if rights are set
-					for i := range rookMoves {
-						rookMoves[i].Castling &^= BlackQueenSide
-					}
-				} else if square == H8 && (b.Castling&BlackKingSide != 0) {
-					for i := range rookMoves {
-						rookMoves[i].Castling &^= BlackKingSide
+				// Normal single forward
+				if row > 0 {
+					to := square >> 8
+					if b.emptyAt(to) {
+						legalMoves = append(legalMoves, NewMove(MoveNormal, square, to))
 					}
 				}
-			}
-
-			legalMoves = append(legalMoves, rookMoves...)
-		case Queen:
-			// Movimientos en las 8 direcciones: N, S, E, O, NE, NO, SE, SO
-			for _, dir := range dirAll {
-				dirMoves := getLegalMovesInOneDirection(b, row, col, square, dir, isWhite, Queen)
-				legalMoves = append(legalMoves, dirMoves...)
-			}
-		case King:
-			// Movimientos en las 8 direcciones pero solo una casilla
-			for _, dir := range dirAll {
-				r := row + dir.dr
-				c := col + dir.dc
-				if r >= 0 && r < 8 && c >= 0 && c < 8 {
-					toIndex := r*8 + c
-					to := uint64(1) << toIndex
-					occupiedByWhite := b.IsSquareOccupiedByWhite(to)
-					occupiedByBlack := b.IsSquareOccupiedByBlack(to)
-					// King cannot move to a square occupied by a piece of the same color
-					moveForbidden := ((isWhite && occupiedByWhite) || (!isWhite && occupiedByBlack)) ||
-						b.SquareAttacked(r, c, isWhite)
-					if !moveForbidden {
-						// move := b.Clone()
-						move := b.NewMove(MoveNormal, square, to, King)
-						// Update castling rights
-						if isWhite {
-							move.Castling &^= WhiteKingSide | WhiteQueenSide
-						} else {
-							move.Castling &^= BlackKingSide | BlackQueenSide
-						}
-						if (isWhite && occupiedByBlack) || (!isWhite && occupiedByWhite) {
-							// move.CapturePiece(to, !isWhite)
-							move.Type = MoveCapture
-							move.CheckCapturedRook(isWhite, to, b)
-						}
-						// move.MovePiece(square, to, isWhite, King)
-						legalMoves = append(legalMoves, move)
+				// Double advance from starting rank (row 6 -> row 4)
+				if row == 6 {
+					to := square >> 16
+					if b.emptyAt(to) && b.emptyAt(square>>8) {
+						legalMoves = append(legalMoves, NewMove(MoveNormal, square, to))
 					}
 				}
-			}
-
-			if square == E1 && isWhite {
-				// Now check for castling rights
-				if isWhite {
-					// White king-side castling
-					if b.Castling&WhiteKingSide != 0 &&
-						!b.IsSquareOccupied(F1|G1) &&
-						!b.SquareAttacked(0, 4, true) &&
-						!b.SquareAttacked(0, 5, true) &&
-						!b.SquareAttacked(0, 6, true) {
-						move := b.NewMove(MoveKingCastle, square, G1, King)
-						legalMoves = append(legalMoves, move)
+				// Captures / en passant
+				if row > 0 && col < 7 {
+					to := square >> 7
+					if b.whiteAt(to) {
+						legalMoves = append(legalMoves, NewMove(MoveCapture, square, to))
+					} else if b.EnPassant != 0 && to == (uint64(1)<<b.EnPassant) {
+						legalMoves = append(legalMoves, NewMove(MoveCapture, square, to))
 					}
-					// White queen-side castling
-					if b.Castling&WhiteQueenSide != 0 &&
-						!b.IsSquareOccupied(B1|C1|D1) &&
-						!b.SquareAttacked(0, 4, true) &&
-						!b.SquareAttacked(0, 3, true) &&
-						!b.SquareAttacked(0, 2, true) {
-						move := b.NewMove(MoveQueenCastle, square, C1, King)
-						legalMoves = append(legalMoves, move)
+				}
+				if row > 0 && col > 0 {
+					to := square >> 9
+					if b.whiteAt(to) {
+						legalMoves = append(legalMoves, NewMove(MoveCapture, square, to))
+					} else if b.EnPassant != 0 && to == (uint64(1)<<b.EnPassant) {
+						legalMoves = append(legalMoves, NewMove(MoveCapture, square, to))
 					}
 				}
 			}
-			if square == E8 && !isWhite {
-				// Black king-side castling
-				if b.Castling&BlackKingSide != 0 &&
-					!b.IsSquareOccupied(F8|G8) &&
-					!b.SquareAttacked(7, 4, false) &&
-					!b.SquareAttacked(7, 5, false) &&
-					!b.SquareAttacked(7, 6, false) {
-					move := b.NewMove(MoveKingCastle, square, G8, King)
-					legalMoves = append(legalMoves, move)
+		}
+	case Knight:
+		// Generate knight moves
+		knightMoves := []int8{17, 15, 10, 6, -17, -15, -10, -6}
+		for _, moveOffset := range knightMoves {
+			toIndex := i + moveOffset
+			toCol := toIndex % 8
+			colDelta := math.Abs(float64(toCol - col)) // Ensure movement is not out of bounds
+			if toIndex >= 0 && toIndex < 64 && (colDelta <= 2) {
+				to := uint64(1) << toIndex
+				occupiedByWhite := b.whiteAt(to)
+				occupiedByBlack := b.blackAt(to)
+				// Knight cannot move to a square occupied by a piece of the same color
+				moveForbidden := (isWhite && occupiedByWhite) || (!isWhite && occupiedByBlack)
+				if !moveForbidden {
+					t := MoveNormal
+					if (isWhite && occupiedByBlack) || (!isWhite && occupiedByWhite) {
+						t = MoveCapture
+					}
+					legalMoves = append(legalMoves, NewMove(t, square, to))
 				}
-				// Black queen-side castling
-				if b.Castling&BlackQueenSide != 0 &&
-					!b.IsSquareOccupied(B8|C8|D8) &&
-					!b.SquareAttacked(7, 4, false) &&
-					!b.SquareAttacked(7, 3, false) &&
-					!b.SquareAttacked(7, 2, false) {
-					move := b.NewMove(MoveQueenCastle, square, C8, King)
-					legalMoves = append(legalMoves, move)
+			}
+		}
+	case Bishop:
+		// Movimientos en las 4 diagonales: NE, NO, SE, SO
+		for _, dir := range dirDiagonal {
+			legalMoves = b.appendMovesInDirection(legalMoves, row, col, square, dir, isWhite)
+		}
+	case Rook:
+		// Movimientos en las 4 direcciones: N, S, E, O
+		for _, dir := range dirStraight {
+			legalMoves = b.appendMovesInDirection(legalMoves, row, col, square, dir, isWhite)
+		}
+	case Queen:
+		// Movimientos en las 8 direcciones: N, S, E, O, NE, NO, SE, SO
+		for _, dir := range dirAll {
+			legalMoves = b.appendMovesInDirection(legalMoves, row, col, square, dir, isWhite)
+		}
+	case King:
+		// Movimientos en las 8 direcciones pero solo una casilla
+		for _, dir := range dirAll {
+			r := row + dir.dr
+			c := col + dir.dc
+			if r >= 0 && r < 8 && c >= 0 && c < 8 {
+				toIndex := r*8 + c
+				to := uint64(1) << toIndex
+				occupiedByWhite := b.whiteAt(to)
+				occupiedByBlack := b.blackAt(to)
+				// King cannot move to a square occupied by a piece of the same color
+				moveForbidden := ((isWhite && occupiedByWhite) || (!isWhite && occupiedByBlack)) ||
+					b.Variant.royalKing() && b.SquareAttacked(r, c, isWhite)
+				if !moveForbidden {
+					t := MoveNormal
+					if (isWhite && occupiedByBlack) || (!isWhite && occupiedByWhite) {
+						t = MoveCapture
+					}
+					legalMoves = append(legalMoves, NewMove(t, square, to))
 				}
-
 			}
-		default:
-			// For simplicity, other pieces are not implemented in this example
 		}
+
+		legalMoves = b.appendCastlingMoves(legalMoves, uint8(i), isWhite)
+	default:
+		// For simplicity, other pieces are not implemented in this example
+	}
+	return legalMoves
+}
+
+// appendCastlingMoves añade los enroques del rey situado en kingSq. Vale también para
+// Chess960: el rey y la torre pueden empezar en cualquier columna, pero acaban como en el
+// ajedrez normal (rey en g o c, torre en f o d). Las casillas que recorren ambos tienen que
+// estar vacías salvo las que ocupan ellos mismos, y el rey no puede estar en jaque ni pasar
+// por casillas atacadas. Como en el resto de movimientos, que la casilla final no quede
+// atacada tras mover la torre se comprueba al validar el movimiento.
+func (b *Board) appendCastlingMoves(legalMoves MoveList, kingSq uint8, isWhite bool) MoveList {
+	rank, own := uint8(0), &b.WhitePieces
+	rights := [2]CastleRights{WhiteKingSide, WhiteQueenSide}
+	if !isWhite {
+		rank, own = 56, &b.BlackPieces
+		rights = [2]CastleRights{BlackKingSide, BlackQueenSide}
+	}
+	if kingSq/8 != rank/8 || !b.Variant.royalKing() {
+		return legalMoves
+	}
+	for i, right := range rights {
+		rookSq := b.castlingRook(right)
+		kingSide := i == 0
+		if b.Castling&right == 0 || own.Rooks&(uint64(1)<<rookSq) == 0 || (rookSq > kingSq) != kingSide {
+			continue
+		}
+		t, kingTo, rookTo := MoveKingCastle, rank+6, rank+5
+		if !kingSide {
+			t, kingTo, rookTo = MoveQueenCastle, rank+2, rank+3
+		}
+		others := b.AllPieces() &^ (uint64(1)<<kingSq | uint64(1)<<rookSq)
+		if others&(rankSpan(kingSq, kingTo)|rankSpan(rookSq, rookTo)) != 0 {
+			continue
+		}
+		attacked := false
+		for sq := min(kingSq, kingTo); sq <= max(kingSq, kingTo) && !attacked; sq++ {
+			attacked = b.SquareAttacked(int8(sq/8), int8(sq%8), isWhite)
+		}
+		if attacked {
+			continue
+		}
+		legalMoves = append(legalMoves, NewMove(t, uint64(1)<<kingSq, uint64(1)<<kingTo))
+	}
+	return legalMoves
+}
+
+// rankSpan devuelve las casillas de una fila entre a y b, ambas incluidas.
+func rankSpan(a, b uint8) uint64 {
+	lo, hi := min(a, b), max(a, b)
+	return (uint64(1)<<(hi-lo+1) - 1) << lo
+}
+
+// promotionTypes son los tipos de las promociones sin captura, en el orden en que se generan.
+var promotionTypes = []MoveType{MoveKnightPromo, MoveBishopPromo, MoveRookPromo, MoveQueenPromo, MoveKingPromo}
+
+// appendPromotions añade las promociones del peón de from al llegar a to, incluida la de
+// rey en Antichess.
+func (b *Board) appendPromotions(legalMoves MoveList, from, to uint64, capture bool) MoveList {
+	types := promotionTypes
+	if b.Variant != Antichess {
+		types = types[:4]
+	}
+	for _, t := range types {
+		if capture {
+			t |= MoveCapture
+		}
+		legalMoves = append(legalMoves, NewMove(t, from, to))
 	}
 	return legalMoves
 }
@@ -682,10 +791,9 @@ var dirAll = []Direction{
 	{-1, -1}, // SO
 }
 
-// getLegalMovesInOneDirection generates legal moves for sliding pieces (Bishop, Rook, Queen) in a given direction.
-func getLegalMovesInOneDirection(b *Board, r int8, c int8, square uint64, dir Direction, isWhite bool, piece Piece) []Move {
-	var legalMoves []Move
-	// from := r*8 + c
+// appendMovesInDirection añade a legalMoves los movimientos de una pieza deslizante
+// (alfil, torre o dama) situada en la fila r y columna c, en la dirección dir.
+func (b *Board) appendMovesInDirection(legalMoves MoveList, r int8, c int8, square uint64, dir Direction, isWhite bool) MoveList {
 	for {
 		r += dir.dr
 		c += dir.dc
@@ -694,24 +802,16 @@ func getLegalMovesInOneDirection(b *Board, r int8, c int8, square uint64, dir Di
 		}
 		toIndex := r*8 + c
 		to := uint64(1) << toIndex
-		if b.IsSquareOccupied(to) {
-			blackCapture := isWhite && b.IsSquareOccupiedByBlack(to)
-			whiteCapture := !isWhite && b.IsSquareOccupiedByWhite(to)
+		if !b.emptyAt(to) {
+			blackCapture := isWhite && b.blackAt(to)
+			whiteCapture := !isWhite && b.whiteAt(to)
 			// Si es pieza enemiga, permite captura
 			if whiteCapture || blackCapture {
-				// move := b.Clone()
-				// move.CapturePiece(to, whiteCapture)
-				// move.MovePiece(square, to, isWhite, piece)
-				move := b.NewCaptureMove(MoveCapture, square, to, piece)
-				legalMoves = append(legalMoves, move)
+				legalMoves = append(legalMoves, NewMove(MoveCapture, square, to))
 			}
 			break // No puede saltar piezas
-		} else {
-			// move := b.Clone()
-			// move.MovePiece(square, to, isWhite, piece)
-			move := b.NewMove(MoveNormal, square, to, piece)
-			legalMoves = append(legalMoves, move)
 		}
+		legalMoves = append(legalMoves, NewMove(MoveNormal, square, to))
 	}
 	return legalMoves
 }
@@ -843,6 +943,15 @@ func (b *Board) SquareAttacked(row, col int8, isWhite bool) bool {
 	return false
 }
 
+// hasNonPawnMaterial indica si el bando tiene alguna pieza además del rey y los peones.
+func (b *Board) hasNonPawnMaterial(isWhite bool) bool {
+	p := &b.BlackPieces
+	if isWhite {
+		p = &b.WhitePieces
+	}
+	return p.Knights|p.Bishops|p.Rooks|p.Queens != 0
+}
+
 func (b *Board) IsKingInCheck(isWhite bool) bool {
 	var kingBB uint64
 	if isWhite {
@@ -850,6 +959,10 @@ func (b *Board) IsKingInCheck(isWhite bool) bool {
 	} else {
 		kingBB = b.BlackPieces.King
 	}
+	// Antichess has no checks and the Horde has no king
+	if kingBB == 0 || !b.Variant.royalKing() {
+		return false
+	}
 	kingSq := uint8(bits.TrailingZeros64(kingBB))
 	row := int8(kingSq / 8)
 	col := int8(kingSq % 8)
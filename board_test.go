@@ -1,6 +1,7 @@
 package melange
 
 import (
+	"math/rand"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -9,7 +10,7 @@ import (
 func TestMovePiece_WhitePawn(t *testing.T) {
 	board := NewBoard()
 	// e2 to e3: e2 = 12, e3 = 20
-	move := board.NewMove(MoveNormal, E2, E3, Pawn)
+	move := NewMove(MoveNormal, E2, E3)
 	board.MovePiece(move, true)
 	piece, isWhite := board.PieceAtSquare(E3)
 	assert.Assert(t, piece == Pawn && isWhite, "Expected white pawn at e3 after move")
@@ -19,7 +20,7 @@ func TestMovePiece_WhitePawn(t *testing.T) {
 func TestMovePiece_BlackPawn(t *testing.T) {
 	board := NewBoard()
 	// e7 to e6: e7 = 52, e6 = 44
-	move := board.NewMove(MoveNormal, E7, E6, Pawn)
+	move := NewMove(MoveNormal, E7, E6)
 	board.MovePiece(move, false)
 	piece, isWhite := board.PieceAtSquare(E6)
 	assert.Assert(t, piece == Pawn && !isWhite, "Expected black pawn at e6 after move")
@@ -52,6 +53,7 @@ func TestGetLegalMoves_WhiteCapturePawnRight(t *testing.T) {
 	board.WhiteToMove = true
 	board.WhitePieces.Pawns = A4
 	board.BlackPieces.Pawns = B5
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// a4 to a5 and a4 captures b5
 	expected := "a4a5, a4xb5"
@@ -68,6 +70,7 @@ func TestGetLegalMoves_WhiteCapturePawnLeft(t *testing.T) {
 	board.WhiteToMove = true
 	board.WhitePieces.Pawns = B4
 	board.BlackPieces.Pawns = A5
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// b4 to b5 and b4 captures a5
 	expected := "b4b5, b4xa5"
@@ -84,6 +87,7 @@ func TestGetLegalMoves_BlackCapturePawnRight(t *testing.T) {
 	board.WhiteToMove = false
 	board.BlackPieces.Pawns = A5
 	board.WhitePieces.Pawns = B4
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// a5 to a4 and a5 captures b4
 	expected := "a5a4, a5xb4"
@@ -99,6 +103,7 @@ func TestGetLegalMoves_BlackCapturePawnLeft(t *testing.T) {
 	board.WhiteToMove = false
 	board.BlackPieces.Pawns = B5
 	board.WhitePieces.Pawns = A4
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// b5 to b4 and b5 captures a4
 	expected := "b5b4, b5xa4"
@@ -113,6 +118,7 @@ func TestGetLegalMoves_KnightCornerSW(t *testing.T) {
 	board := &Board{}
 	board.WhiteToMove = true
 	board.WhitePieces.Knights = B2
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// Knight can move to a4 (16) and c4 (64), d3 (32), d1 (8)
 	expected := "b2a4, b2c4, b2d1, b2d3"
@@ -123,6 +129,7 @@ func TestGetLegalMoves_KnightCornerSE(t *testing.T) {
 	board := &Board{}
 	board.WhiteToMove = false
 	board.BlackPieces.Knights = H2
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// Knight can move to g4 (64), f3 (32), f1 (8)
 	expected := "h2f1, h2f3, h2g4"
@@ -133,6 +140,7 @@ func TestGetLegalMoves_KnightCornerNW(t *testing.T) {
 	board := &Board{}
 	board.WhiteToMove = false
 	board.BlackPieces.Knights = A8
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// Knight can move to b6 (16), c7 (32)
 	expected := "a8b6, a8c7"
@@ -143,6 +151,7 @@ func TestGetLegalMoves_KnightCornerNE(t *testing.T) {
 	board := &Board{}
 	board.WhiteToMove = true
 	board.WhitePieces.Knights = H8
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 
 	// Knight can move to g6 (64), f7 (32)
@@ -156,6 +165,7 @@ func TestGetLegalMoves_Bishop(t *testing.T) {
 	board.WhitePieces.Bishops = E5
 	board.BlackPieces.Pawns = G3
 	board.WhitePieces.Pawns = D6
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// Bishop can move to a1, b2, c3, d4, f4, f6, g7, h8, and pawn can move d6d7
 	expected := "d6d7, e5a1, e5b2, e5c3, e5d4, e5f4, e5f6, e5g7, e5h8, e5xg3"
@@ -168,6 +178,7 @@ func TestGetLegalMoves_Rook(t *testing.T) {
 	board.BlackPieces.Rooks = E5
 	board.BlackPieces.Pawns = E3
 	board.WhitePieces.Pawns = B5
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// Rook can move to c5, d5, e4, e6, e7, e8, f5, g5, h5, b5 (capture), and pawn can move e3e2
 	expected := "e3e2, e5c5, e5d5, e5e4, e5e6, e5e7, e5e8, e5f5, e5g5, e5h5, e5xb5"
@@ -180,6 +191,7 @@ func TestGetLegalMoves_Queen(t *testing.T) {
 	board.WhitePieces.Queens = E5
 	board.BlackPieces.Pawns = E3
 	board.WhitePieces.Pawns = B5
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// Queen can move to 22 places and 1 capture,  and pawn can move b5b6
 	expected := "b5b6, e5a1, e5b2, e5b8, e5c3, e5c5, e5c7, e5d4, e5d5, e5d6, e5e4, e5e6, e5e7, e5e8, e5f4, e5f5, e5f6, e5g3, e5g5, e5g7, e5h2, e5h5, e5h8, e5xe3"
@@ -192,6 +204,7 @@ func TestGetLegalMoves_King(t *testing.T) {
 	board.WhitePieces.King = E5
 	board.BlackPieces.Pawns = F5
 	board.WhitePieces.Pawns = D5
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// King can move to d4, d6, e6, f4, f6, capture f5 and pawn can move d5d6
 	expected := "d5d6, e5d4, e5d6, e5e6, e5f4, e5f6, e5xf5"
@@ -205,6 +218,7 @@ func TestSquareAttackedByKnights(t *testing.T) {
 	board.WhitePieces.King = E5
 	board.BlackPieces.Knights = F5
 	board.WhitePieces.Pawns = D5
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// Pawn can move 1, King can move 5 (2 attacked by knight)
 	expected := "d5d6, e5e4, e5e6, e5f4, e5f6, e5xf5"
@@ -218,6 +232,7 @@ func TestSquareAttackedByBishops(t *testing.T) {
 	board.WhitePieces.King = E5
 	board.BlackPieces.Bishops = F5
 	board.WhitePieces.Pawns = D5
+	board.syncSquares()
 	legalMoves := board.GetLegalMoves()
 	// Pawn can move 1, King can move 5 (2 attacked by bishop)
 	expected := "d5d6, e5d4, e5d6, e5f4, e5f6, e5xf5"
@@ -231,6 +246,7 @@ func TestSquareAttackedByRooks(t *testing.T) {
 	board.WhitePieces.King = E5
 	board.BlackPieces.Rooks = F5
 	board.WhitePieces.Pawns = D5
+	board.syncSquares()
 	legalMoves := board.GetLegalMoves()
 	// Pawn can move 1, King can move 5 (2 attacked by rook)
 	expected := "d5d6, e5d4, e5d6, e5e4, e5e6, e5xf5"
@@ -244,6 +260,7 @@ func TestSquareAttackedByQueens(t *testing.T) {
 	board.WhitePieces.King = E5
 	board.BlackPieces.Queens = F5
 	board.WhitePieces.Pawns = D5
+	board.syncSquares()
 	legalMoves := board.GetLegalMoves()
 	// Pawn can move 1, King can move 3 (4 attacked by queen)
 	expected := "d5d6, e5d4, e5d6, e5xf5"
@@ -257,6 +274,7 @@ func TestSquareAttackedByKing(t *testing.T) {
 	board.WhitePieces.King = E5
 	board.BlackPieces.King = G5
 	board.WhitePieces.Pawns = D5
+	board.syncSquares()
 	legalMoves := board.GetLegalMoves()
 	// Pawn can move 1, King can move 4
 	expected := "d5d6, e5d4, e5d6, e5e4, e5e6"
@@ -269,6 +287,7 @@ func TestWhiteKingSideCastle(t *testing.T) {
 	board.WhitePieces.King = E1
 	board.WhitePieces.Rooks = H1
 	board.Castling = WhiteKingSide
+	board.syncSquares()
 	legalMoves := board.GetLegalMoves()
 	// King can castle king-side
 	expected := "O-O, e1d1, e1d2, e1e2, e1f1, e1f2, h1f1, h1g1, h1h2, h1h3, h1h4, h1h5, h1h6, h1h7, h1h8"
@@ -281,6 +300,7 @@ func TestWhiteQueenSideCastle(t *testing.T) {
 	board.WhitePieces.King = E1
 	board.WhitePieces.Rooks = A1
 	board.Castling = WhiteQueenSide
+	board.syncSquares()
 	legalMoves := board.GetLegalMoves()
 	// King can castle queen-side
 	expected := "O-O-O, a1a2, a1a3, a1a4, a1a5, a1a6, a1a7, a1a8, a1b1, a1c1, a1d1, e1d1, e1d2, e1e2, e1f1, e1f2"
@@ -293,6 +313,7 @@ func TestBlackKingSideCastle(t *testing.T) {
 	board.BlackPieces.King = E8
 	board.BlackPieces.Rooks = H8
 	board.Castling = BlackKingSide
+	board.syncSquares()
 	legalMoves := board.GetLegalMoves()
 	// King can castle king-side
 	expected := "O-O, e8d7, e8d8, e8e7, e8f7, e8f8, h8f8, h8g8, h8h1, h8h2, h8h3, h8h4, h8h5, h8h6, h8h7"
@@ -305,6 +326,7 @@ func TestBlackQueenSideCastle(t *testing.T) {
 	board.BlackPieces.King = E8
 	board.BlackPieces.Rooks = A8
 	board.Castling = BlackQueenSide
+	board.syncSquares()
 	legalMoves := board.GetLegalMoves()
 	// King can castle queen-side
 	expected := "O-O-O, a8a1, a8a2, a8a3, a8a4, a8a5, a8a6, a8a7, a8b8, a8c8, a8d8, e8d7, e8d8, e8e7, e8f7, e8f8"
@@ -320,6 +342,7 @@ func TestEnPassantGenerationWhite(t *testing.T) {
 	// Simular que el último movimiento fue d7-d5 => target en passant es d6 (index 43? recalculamos)
 	// Indices: a1=0 => d5 = (fila 5-1=4)*8 + (col d=3) = 4*8+3=35 correcto. d6 = (fila 6-1=5)*8+3=43
 	board.EnPassant = 43
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	expected := "e5e6, e5xd6"
 	assert.Equal(t, moves.ToString(true), expected, "Legal moves do not match expected moves")
@@ -332,10 +355,9 @@ func TestEnPassantExecutionWhite(t *testing.T) {
 	board.WhitePieces.Pawns = E5
 	board.BlackPieces.Pawns = D5
 	board.EnPassant = 43 // d6
+	board.syncSquares()
 	// Crear movimiento e5xd6 (en passant)
-	move := board.NewMove(MoveCapture, E5, D6, Pawn)
-	// Marcar que el tablero tenía EnPassant previo
-	move.EnPassant = 0 // Después del movimiento se limpia
+	move := NewMove(MoveCapture, E5, D6)
 	board.MovePiece(move, true)
 	// Peón negro en d5 debería haber sido capturado
 	assert.Assert(t, board.BlackPieces.Pawns&D5 == 0, "Black pawn on d5 should be captured via en passant")
@@ -350,6 +372,7 @@ func TestEnPassantGenerationBlack(t *testing.T) {
 	// e4 index: (fila 4-1=3)*8 + 4? file e=4 => 3*8+4=28 (coincide con E5 antes) Wait: E4 constant is 28 yes.
 	// e3 index: (fila 3-1=2)*8 +4 = 20
 	board.EnPassant = 20
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	expected := "d4d3, d4xe3"
 	assert.Equal(t, moves.ToString(true), expected, "Legal moves do not match expected moves")
@@ -361,7 +384,8 @@ func TestEnPassantExecutionBlack(t *testing.T) {
 	board.BlackPieces.Pawns = D4
 	board.WhitePieces.Pawns = E4
 	board.EnPassant = 20 // e3
-	move := board.NewMove(MoveCapture, D4, E3, Pawn)
+	board.syncSquares()
+	move := NewMove(MoveCapture, D4, E3)
 	board.MovePiece(move, false)
 	assert.Assert(t, board.WhitePieces.Pawns&E4 == 0, "White pawn on e4 should be captured via en passant")
 }
@@ -372,6 +396,7 @@ func TestWhitePromotionGeneration(t *testing.T) {
 	// White pawn on a7 (ready to promote), and black piece on b8 to allow capture promotion
 	board.WhitePieces.Pawns = A7
 	board.BlackPieces.Knights = B8
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	// From a7 -> a8 promotions (4) and a7xb8 promotions (4); sort expected lexicographically
 	expectedSlice := []string{"a7a8=B", "a7a8=N", "a7a8=Q", "a7a8=R", "a7xb8=B", "a7xb8=N", "a7xb8=Q", "a7xb8=R"}
@@ -385,8 +410,101 @@ func TestBlackPromotionGeneration(t *testing.T) {
 	// Black pawn on h2 (index 15) ready to promote moving to h1, white piece on g1 for capture promotions
 	board.BlackPieces.Pawns = H2
 	board.WhitePieces.Knights = G1
+	board.syncSquares()
 	moves := board.GetLegalMoves()
 	expectedSlice := []string{"h2h1=B", "h2h1=N", "h2h1=Q", "h2h1=R", "h2xg1=B", "h2xg1=N", "h2xg1=Q", "h2xg1=R"}
 	got := moves.ToStringArraySorted()
 	assert.DeepEqual(t, got, expectedSlice)
 }
+
+func TestMovePacking(t *testing.T) {
+	for _, mt := range moveTypeCodes {
+		if mt == 0 {
+			continue
+		}
+		m := NewMove(mt, G7, H8)
+		assert.Equal(t, m.Type(), mt)
+		assert.Equal(t, m.From(), uint8(54))
+		assert.Equal(t, m.To(), uint8(63))
+	}
+	drop := NewDrop(Knight, F3)
+	assert.Assert(t, drop.IsDrop() && drop != NoMove)
+	assert.Equal(t, drop.DropPiece(), Knight)
+	assert.Equal(t, drop.UCIString(), "N@f3")
+}
+
+func TestMovePieceCastlingRights(t *testing.T) {
+	board := &Board{}
+	assert.NilError(t, board.SetFen("r3k2r/8/8/8/8/8/8/R3K1NR w KQkq - 0 1"))
+	// La torre que sale de h1 pierde el enroque corto
+	board.MovePiece(NewMove(MoveNormal, H1, H2), true)
+	assert.Equal(t, board.Castling, WhiteQueenSide|BlackKingSide|BlackQueenSide)
+	// Capturar la torre de a8 quita el enroque largo de las negras
+	board.WhiteToMove = true
+	board.MovePiece(NewMove(MoveCapture, A1, A8), true)
+	assert.Equal(t, board.Castling, BlackKingSide)
+	// Mover el rey pierde los dos
+	board.MovePiece(NewMove(MoveNormal, E8, D8), false)
+	assert.Equal(t, board.Castling, CastleRights(0))
+}
+
+func TestMovePieceClocks(t *testing.T) {
+	board := &Board{}
+	assert.NilError(t, board.SetFen("4k3/4p3/8/8/8/8/8/4K1N1 w - - 7 30"))
+	board.MovePiece(NewMove(MoveNormal, G1, F3), true)
+	assert.Equal(t, board.Fen(), "4k3/4p3/8/8/8/5N2/8/4K3 b - - 8 30")
+	st := board.perftMakeMove(NewMove(MoveNormal, E7, E5))
+	assert.Equal(t, board.Fen(), "4k3/8/8/4p3/8/5N2/8/4K3 w - e6 0 31")
+	board.unmakeMove(st)
+	assert.Equal(t, board.Fen(), "4k3/4p3/8/8/8/5N2/8/4K3 b - - 8 30")
+}
+
+func TestSquaresMatchBitboards(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	for _, tc := range []struct {
+		variant Variant
+		fen     string
+	}{
+		{Standard, "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -"},
+		{Standard, "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1"},
+		{Standard, "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9"},
+		{Antichess, Antichess.StartFEN()},
+		{Crazyhouse, "r1bqk2r/pppp1ppp/2n1p3/4P3/1b1Pn3/2NB1N2/PPP2PPP/R1BQK2R[] b KQkq - 0 1"},
+	} {
+		b := &Board{Variant: tc.variant}
+		assert.NilError(t, b.SetFen(tc.fen))
+		start := b.Fen()
+		// Partidas aleatorias con make/unmake y con MovePiece, comprobando en cada paso
+		var states []moveState
+		game := NewGame(b)
+		for ply := 0; ply < 80; ply++ {
+			moves := legalMoves(b)
+			if len(moves) == 0 {
+				break
+			}
+			m := moves[rng.Intn(len(moves))]
+			states = append(states, b.perftMakeMove(m))
+			assert.NilError(t, b.checkSquares(), "%s", b.Fen())
+			game.Play(m)
+			assert.NilError(t, game.Board.checkSquares(), "%s", game.Board.Fen())
+		}
+		for i := len(states) - 1; i >= 0; i-- {
+			b.unmakeMove(states[i])
+			assert.NilError(t, b.checkSquares())
+		}
+		assert.Equal(t, b.Fen(), start)
+	}
+
+	// Un tablero montado bitboard a bitboard necesita syncSquares
+	b := &Board{WhiteToMove: true}
+	b.WhitePieces.Rooks = A1
+	b.BlackPieces.Knights = A8
+	assert.ErrorContains(t, b.checkSquares(), "a1")
+	b.syncSquares()
+	assert.Equal(t, len(b.GetLegalMoves()), 14)
+	piece, isWhite := b.PieceAtSquare(A8)
+	assert.Assert(t, piece == Knight && !isWhite)
+	assert.NilError(t, b.checkSquares())
+	b.squares[0] = squareCode(Queen, true)
+	assert.ErrorContains(t, b.checkSquares(), "a1")
+}
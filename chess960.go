@@ -0,0 +1,58 @@
+package melange
+
+import "fmt"
+
+// Chess960Positions es el número de posiciones iniciales de Chess960.
+const Chess960Positions = 960
+
+// chess960Knights son las parejas de casillas libres, de las cinco que quedan tras colocar
+// alfiles y dama, en las que van los caballos según la numeración de Scharnagl.
+var chess960Knights = [10][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}
+
+// NewChess960Board devuelve la posición inicial de Chess960 número n (0-959) según la
+// numeración de Scharnagl, en la que la 518 es la del ajedrez normal. El tablero queda en
+// modo Chess960.
+func NewChess960Board(n int) (*Board, error) {
+	if n < 0 || n >= Chess960Positions {
+		return nil, fmt.Errorf("posición de Chess960 fuera de rango: %d", n)
+	}
+	var rank [8]byte
+	// Alfil de casillas claras (b, d, f, h) y de oscuras (a, c, e, g)
+	rank[n%4*2+1] = 'b'
+	n /= 4
+	rank[n%4*2] = 'b'
+	n /= 4
+	// La dama en la n-ésima casilla libre, después los caballos y en las tres que quedan
+	// torre, rey y torre
+	place := func(piece byte, nth int) {
+		for file := range rank {
+			if rank[file] != 0 {
+				continue
+			}
+			if nth == 0 {
+				rank[file] = piece
+				return
+			}
+			nth--
+		}
+	}
+	place('q', n%6)
+	n /= 6
+	knights := chess960Knights[n]
+	place('n', knights[1])
+	place('n', knights[0])
+	for _, piece := range []byte("rkr") {
+		place(piece, 0)
+	}
+
+	black := string(rank[:])
+	white := ""
+	for _, c := range rank {
+		white += string(c - 'a' + 'A')
+	}
+	b := &Board{Chess960: true}
+	if err := b.SetFen(black + "/pppppppp/8/8/8/8/PPPPPPPP/" + white + " w KQkq - 0 1"); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
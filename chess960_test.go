@@ -0,0 +1,108 @@
+package melange
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNewChess960Board(t *testing.T) {
+	for n, want := range map[int]string{
+		0:   "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1",
+		518: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		959: "rkrnnqbb/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w KQkq - 0 1",
+	} {
+		b, err := NewChess960Board(n)
+		assert.NilError(t, err)
+		assert.Equal(t, b.Fen(), want, "posición %d", n)
+		assert.Assert(t, b.Chess960)
+	}
+	_, err := NewChess960Board(960)
+	assert.ErrorContains(t, err, "fuera de rango")
+
+	// Todas distintas, con los alfiles en casillas de distinto color y el rey entre las torres
+	seen := map[string]bool{}
+	for n := range Chess960Positions {
+		b, err := NewChess960Board(n)
+		assert.NilError(t, err)
+		rank := strings.Split(b.Fen(), "/")[0]
+		assert.Assert(t, !seen[rank], "posición %d repetida: %s", n, rank)
+		seen[rank] = true
+		bishops := strings.Index(rank, "b") + strings.LastIndex(rank, "b")
+		assert.Equal(t, bishops%2, 1, rank)
+		assert.Assert(t, strings.Index(rank, "r") < strings.Index(rank, "k"), rank)
+		assert.Assert(t, strings.LastIndex(rank, "r") > strings.Index(rank, "k"), rank)
+	}
+}
+
+func TestChess960Fen(t *testing.T) {
+	for _, tc := range []struct{ fen, want string }{
+		// Shredder-FEN: la torre de cada derecho por su columna
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+			"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9"},
+		// X-FEN: la columna solo cuando la torre no es la más exterior
+		{"rk2r2r/8/8/8/8/8/8/RK2R2R w AEae - 0 1", "rk2r2r/8/8/8/8/8/8/RK2R2R w EQeq - 0 1"},
+		{"rk2r2r/8/8/8/8/8/8/RK2R2R w QEqe - 0 1", "rk2r2r/8/8/8/8/8/8/RK2R2R w EQeq - 0 1"},
+		{"rk2r3/8/8/8/8/8/8/RK2R3 w AEae - 0 1", "rk2r3/8/8/8/8/8/8/RK2R3 w KQkq - 0 1"},
+		{"4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", "4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1"},
+	} {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(tc.fen))
+		assert.Equal(t, b.Fen(), tc.want)
+	}
+	b := &Board{}
+	assert.ErrorContains(t, b.SetFen("4k3/8/8/8/8/8/4K3/R6R w H - 0 1"), "sin rey")
+}
+
+func TestChess960Castling(t *testing.T) {
+	// El rey en g1 y la torre en h1: en el enroque corto el rey no se mueve
+	b := &Board{Chess960: true}
+	assert.NilError(t, b.SetFen("1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1"))
+	m, ok := parseUCIMove(b, "g1h1")
+	assert.Assert(t, ok)
+	assert.Equal(t, m.Type(), MoveKingCastle)
+	assert.Equal(t, b.SAN(m), "O-O")
+	b.MovePiece(m, true)
+	assert.Equal(t, b.Fen(), "1r4kr/8/8/8/8/8/8/1R3RK1 b kq - 1 1")
+
+	// Enroque largo con el rey moviéndose a la derecha, b1 → c1, y la torre de a1 a d1
+	assert.NilError(t, b.SetFen("rk5r/8/8/8/8/8/8/RK5R w KQkq - 0 1"))
+	m, ok = parseUCIMove(b, "b1a1")
+	assert.Assert(t, ok)
+	assert.Equal(t, b.UCIMove(m), "b1a1")
+	assert.Equal(t, m.ToSimpleString(), "b1c1")
+	b.MovePiece(m, true)
+	assert.Equal(t, b.Fen(), "rk5r/8/8/8/8/8/8/2KR3R b kq - 1 1")
+
+	// La torre que tapa un ataque a la casilla final del rey no permite enrocar: tras el
+	// enroque largo la torre de a1 daría jaque en c1
+	assert.NilError(t, b.SetFen("4k3/8/8/8/8/8/8/qR2K3 w B - 0 1"))
+	_, ok = parseUCIMove(b, "e1b1")
+	assert.Assert(t, !ok)
+
+	// Sin UCI_Chess960 los enroques se escriben como siempre
+	b = NewBoard()
+	assert.NilError(t, b.SetFen("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"))
+	m, ok = parseUCIMove(b, "e1g1")
+	assert.Assert(t, ok)
+	assert.Equal(t, b.UCIMove(m), "e1g1")
+	b.Chess960 = true
+	assert.Equal(t, b.UCIMove(m), "e1h1")
+}
+
+func TestChess960Perft(t *testing.T) {
+	// Posiciones de la tabla de perft de Chess960 de la chessprogramming wiki
+	for _, tc := range []struct {
+		fen   string
+		nodes []int
+	}{
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", []int{21, 528, 12189, 326672}},
+		{"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", []int{21, 807, 18002, 667366}},
+		{"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9", []int{20, 479, 10471, 273318}},
+		{"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9", []int{22, 593, 13440, 382958}},
+		{"1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9", []int{28, 1120, 31058, 1171749}},
+	} {
+		checkPerftNodes(t, tc.fen, tc.nodes)
+	}
+}
@@ -0,0 +1,141 @@
+package melange
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hand es la reserva de un bando en Crazyhouse: cuántas piezas de cada tipo tiene para
+// soltar, indexadas por Piece. El rey nunca se captura, así que no tiene hueco.
+type Hand [King]uint8
+
+// handValues son los valores de las piezas en la reserva, algo por encima de los del
+// tablero: se pueden soltar en la casilla donde más daño hacen. El peón es el que más
+// gana, porque se suelta casi en cualquier sitio y sirve para tapar jaques.
+var handValues = [King]int{0, 140, 340, 340, 520, 950}
+
+// value es el material de la reserva en centipawns.
+func (h *Hand) value() int {
+	score := 0
+	for p := Pawn; p < King; p++ {
+		score += int(h[p]) * handValues[p]
+	}
+	return score
+}
+
+// backRanks son la primera y la última fila, donde no se pueden soltar peones.
+const backRanks uint64 = 0xFF000000000000FF
+
+// updateHands mantiene la reserva y las piezas coronadas al aplicar un movimiento de
+// Crazyhouse: la pieza soltada sale de la reserva, una pieza coronada sigue marcada al
+// moverse y las promociones quedan marcadas. Las capturas se apuntan en pocketCapture.
+func (b *Board) updateHands(move Move, isWhite bool) {
+	if move.IsDrop() {
+		b.addToHand(isWhite, move.DropPiece(), -1)
+		return
+	}
+	promoted := b.Promoted
+	if b.Promoted&move.GetFrom64() != 0 {
+		b.Promoted = b.Promoted&^move.GetFrom64() | move.GetTo64()
+	}
+	if move.PromotionPiece() != 0 {
+		b.Promoted |= move.GetTo64()
+	}
+	b.hash ^= promotedKey(promoted ^ b.Promoted)
+}
+
+// pocketCapture pasa a la reserva del rival la pieza del color isWhite que se captura en
+// square. Las piezas coronadas vuelven a ser peones.
+func (b *Board) pocketCapture(square uint64, isWhite bool) {
+	piece, pieceIsWhite := b.PieceAtSquare(square)
+	if piece == 0 || piece == King || pieceIsWhite != isWhite {
+		return
+	}
+	if b.Promoted&square != 0 {
+		piece = Pawn
+		b.Promoted &^= square
+		b.hash ^= promotedKey(square)
+	}
+	b.addToHand(!isWhite, piece, 1)
+}
+
+// addToHand suma n piezas piece a la reserva del color isWhite y actualiza la clave.
+func (b *Board) addToHand(isWhite bool, piece Piece, n int) {
+	c := colorIndex(isWhite)
+	b.hash ^= handKey(c, piece, b.Hands[c][piece])
+	b.Hands[c][piece] = uint8(int(b.Hands[c][piece]) + n)
+	b.hash ^= handKey(c, piece, b.Hands[c][piece])
+}
+
+// appendDrops añade las piezas que el bando al mover puede soltar: cualquiera de su reserva
+// en cualquier casilla vacía, salvo los peones en la primera y la última fila.
+func (b *Board) appendDrops(moves MoveList) MoveList {
+	hand := &b.Hands[colorIndex(b.WhiteToMove)]
+	empty := b.EmptySquares()
+	for p := Pawn; p < King; p++ {
+		if hand[p] == 0 {
+			continue
+		}
+		targets := empty
+		if p == Pawn {
+			targets &^= backRanks
+		}
+		for ; targets != 0; targets &= targets - 1 {
+			to := targets & -targets
+			moves = append(moves, NewDrop(p, to))
+		}
+	}
+	return moves
+}
+
+// isPseudoLegalDrop es isPseudoLegal para las piezas soltadas.
+func (b *Board) isPseudoLegalDrop(m Move) bool {
+	piece := m.DropPiece()
+	if b.Variant != Crazyhouse || piece < Pawn || piece >= King ||
+		b.Hands[colorIndex(b.WhiteToMove)][piece] == 0 || b.IsSquareOccupied(m.GetTo64()) {
+		return false
+	}
+	return piece != Pawn || m.GetTo64()&backRanks == 0
+}
+
+// cutPocket separa de la colocación de piezas de una FEN la reserva de Crazyhouse, que va
+// entre corchetes ("...RNBQKBNR[Qp]") o como novena fila ("...RNBQKBNR/Qp").
+func cutPocket(placement string) (board, pocket string, ok bool) {
+	if i := strings.IndexByte(placement, '['); i >= 0 {
+		return placement[:i], strings.TrimSuffix(placement[i+1:], "]"), true
+	}
+	if strings.Count(placement, "/") == 8 {
+		i := strings.LastIndexByte(placement, '/')
+		return placement[:i], placement[i+1:], true
+	}
+	return placement, "", false
+}
+
+// setPocket interpreta la reserva de una FEN: una letra por pieza, en mayúsculas las de
+// las blancas. "-" es una reserva vacía.
+func (b *Board) setPocket(pocket string) error {
+	for _, ch := range pocket {
+		if ch == '-' {
+			continue
+		}
+		i := strings.IndexRune("pnbrq", ch|0x20)
+		if i < 0 {
+			return fmt.Errorf("FEN inválido: pieza en reserva '%c'", ch)
+		}
+		isWhite := ch >= 'A' && ch <= 'Z'
+		b.Hands[colorIndex(isWhite)][Piece(i+1)]++
+	}
+	return nil
+}
+
+// pocketString escribe las reservas para la FEN, primero las blancas y de mayor a menor
+// valor (Qp para dama blanca y peón negro).
+func (b *Board) pocketString() string {
+	pocket := ""
+	for c, letters := range []string{" PNBRQ", " pnbrq"} {
+		for p := Queen; p >= Pawn; p-- {
+			pocket += strings.Repeat(letters[p:p+1], int(b.Hands[c][p]))
+		}
+	}
+	return pocket
+}
@@ -0,0 +1,126 @@
+package melange
+
+import (
+	"math/rand"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCrazyhousePerft(t *testing.T) {
+	// Valores de referencia de otros generadores de Crazyhouse
+	for _, tc := range []struct {
+		fen   string
+		nodes []int
+	}{
+		{Crazyhouse.StartFEN(), []int{20, 400, 8902, 197281}},
+		{"2k5/8/8/8/8/8/8/4K3[QRBNPqrbnp] w - - 0 1", []int{301, 75353}},
+		{"r1bqk2r/pppp1ppp/2n1p3/4P3/1b1Pn3/2NB1N2/PPP2PPP/R1BQK2R[] b KQkq - 0 1", []int{42, 1347, 58057}},
+		// La dama coronada vuelve a la reserva como peón
+		{"4k3/1Q~6/8/8/4b3/8/Kpp5/8/ b - - 0 1", []int{20, 360, 5445, 132758}},
+	} {
+		b := &Board{Variant: Crazyhouse}
+		assert.NilError(t, b.SetFen(tc.fen))
+		for i, want := range tc.nodes {
+			assert.Equal(t, b.Perft(i+1).Nodes, want, "%s depth %d", tc.fen, i+1)
+		}
+	}
+	if testing.Short() {
+		return
+	}
+	// A profundidad 5 ya se sueltan piezas desde la posición inicial
+	assert.Equal(t, NewVariantBoard(Crazyhouse).Perft(5).Nodes, 4888832)
+}
+
+func TestCrazyhouseFen(t *testing.T) {
+	for _, tc := range []struct{ fen, want string }{
+		{"r1bqk2r/pppp1ppp/2n5/4p3/1b2P3/2N2N2/PPPP1PPP/R1BQK2R[Bp] w KQkq - 0 5",
+			"r1bqk2r/pppp1ppp/2n5/4p3/1b2P3/2N2N2/PPPP1PPP/R1BQK2R[Bp] w KQkq - 0 5"},
+		// Novena fila como reserva, en cualquier orden
+		{"4k3/8/8/8/8/8/8/4K3/pQqNP w - - 0 1", "4k3/8/8/8/8/8/8/4K3[QNPqp] w - - 0 1"},
+		{"4k3/1Q~6/8/8/8/8/8/4K3[-] w - - 0 1", "4k3/1Q~6/8/8/8/8/8/4K3[] w - - 0 1"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", "4k3/8/8/8/8/8/8/4K3[] w - - 0 1"},
+	} {
+		b := &Board{Variant: Crazyhouse}
+		assert.NilError(t, b.SetFen(tc.fen))
+		assert.Equal(t, b.Fen(), tc.want)
+	}
+	b := &Board{Variant: Crazyhouse}
+	assert.ErrorContains(t, b.SetFen("4k3/8/8/8/8/8/8/4K3[K] w - - 0 1"), "reserva")
+}
+
+func TestCrazyhouseDrops(t *testing.T) {
+	b := &Board{Variant: Crazyhouse}
+	assert.NilError(t, b.SetFen("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R[] w KQkq - 2 3"))
+	game := NewGame(b)
+	for _, s := range []string{"Nxe5", "Nxe5", "d4", "N@f3"} {
+		m, err := game.Board.ParseSAN(s)
+		assert.NilError(t, err, s)
+		game.Play(m)
+	}
+	assert.Equal(t, game.Board.Fen(), "r1bqkbnr/pppp1ppp/8/4n3/3PP3/5n2/PPP2PPP/RNBQKB1R[P] w KQkq - 1 5")
+
+	// Las piezas soltadas se escriben con @, en SAN y en UCI
+	b = &Board{Variant: Crazyhouse}
+	assert.NilError(t, b.SetFen("4k3/8/8/8/8/8/8/4K3[Pn] w - - 0 1"))
+	drop, ok := parseUCIMove(b, "P@d7")
+	assert.Assert(t, ok)
+	assert.Equal(t, b.SAN(drop), "P@d7+")
+	assert.Equal(t, drop.UCIString(), "P@d7")
+	_, ok = parseUCIMove(b, "P@d8")
+	assert.Assert(t, !ok)
+	_, ok = parseUCIMove(b, "N@f7")
+	assert.Assert(t, !ok)
+	m, err := b.ParseSAN("@d6")
+	assert.NilError(t, err)
+	assert.Assert(t, m.IsDrop())
+	assert.Equal(t, len(NewGame(b).LegalMoves()), 5+48)
+
+	// Al capturar una pieza coronada se recupera un peón
+	assert.NilError(t, b.SetFen("3qk3/4Q~3/8/8/8/8/8/4K3[] b - - 0 1"))
+	m, err = b.ParseSAN("Qxe7+")
+	assert.NilError(t, err)
+	key := b.Hash()
+	st := b.perftMakeMove(m)
+	assert.Equal(t, b.Fen(), "4k3/4q3/8/8/8/8/8/4K3[p] w - - 0 2")
+	b.unmakeMove(st)
+	assert.Equal(t, b.Hash(), key)
+	assert.Equal(t, b.Fen(), "3qk3/4Q~3/8/8/8/8/8/4K3[] b - - 0 1")
+}
+
+func TestCrazyhouseUCI(t *testing.T) {
+	ProcessUciCommand("setoption name UCI_Variant value crazyhouse")
+	defer ProcessUciCommand("setoption name UCI_Variant value chess")
+	ProcessUciCommand("position startpos moves e2e4 d7d5 e4d5 d8d5 b1c3 d5a5 P@d5")
+	assert.Equal(t, GetCurrentBoard().Fen(), "rnb1kbnr/ppp1pppp/8/q2P4/8/2N5/PPPP1PPP/R1BQKBNR[p] b KQkq - 0 4")
+}
+
+func TestCrazyhouseNNUE(t *testing.T) {
+	net := randomNetwork(32, 2)
+	board := NewVariantBoard(Crazyhouse)
+	board.AttachNetwork(net)
+	rng := rand.New(rand.NewSource(5))
+	for ply := 0; ply < 80; ply++ {
+		moves := legalMoves(board)
+		if len(moves) == 0 {
+			break
+		}
+		board.perftMakeMove(moves[rng.Intn(len(moves))])
+		assert.Equal(t, net.Evaluate(board), net.evaluateReference(board), "%s", board.Fen())
+	}
+}
+
+func TestCrazyhouseSearch(t *testing.T) {
+	// Mate del pasillo soltando la dama en cualquier casilla de la octava
+	b := &Board{Variant: Crazyhouse}
+	assert.NilError(t, b.SetFen("6k1/5ppp/8/8/8/8/8/4K3[Q] w - - 0 1"))
+	r := NewSearcher(evalParams).Search(b, SearchLimits{Depth: 3})
+	assert.Assert(t, r.BestMove.IsDrop() && r.BestMove.To()/8 == 7, r.BestMove.UCIString())
+	assert.Assert(t, r.Score >= mateScore-maxPly, r.Score)
+
+	// La reserva cuenta como material
+	b.Hands = [2]Hand{}
+	empty := b.Evaluate(evalParams)
+	b.Hands[1][Knight] = 1
+	assert.Assert(t, b.Evaluate(evalParams) < empty-300)
+}
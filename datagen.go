@@ -0,0 +1,356 @@
+package melange
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DatagenConfig configura la generación de datos de entrenamiento por autojuego.
+type DatagenConfig struct {
+	Games   int   // Número de partidas a jugar
+	Workers int   // Goroutines jugando partidas en paralelo
+	Depth   int   // Profundidad fija por jugada; se ignora si Nodes no es 0
+	Nodes   int64 // Nodos fijos por jugada
+	Seed    int64
+
+	// Aperturas: si Book tiene posiciones (FEN) se elige una al azar por partida; después
+	// se juegan RandomPlies movimientos aleatorios para diversificar.
+	Book        []string
+	RandomPlies int
+
+	MaxPlies         int // Jugadas tras la apertura a partir de las cuales se dan tablas (0: sin límite)
+	AdjudicateScore  int // Se adjudica la victoria si |score| supera este valor (0: nunca)
+	MaxRecordedScore int // Posiciones con |score| mayor no se guardan (0: sin límite). Los mates nunca se guardan
+
+	Format    string // "text" (por defecto) o "binary"
+	Evaluator Evaluator
+}
+
+// DatagenStats resume la generación.
+type DatagenStats struct {
+	Games     int
+	Positions int
+	WhiteWins int
+	BlackWins int
+	Draws     int
+}
+
+// TrainingPosition es una posición tranquila con la puntuación de la búsqueda y el
+// resultado final de la partida, ambos desde el punto de vista de las blancas.
+type TrainingPosition struct {
+	Board  *Board
+	Score  int
+	Result GameResult
+}
+
+// resultValue convierte el resultado a la escala 1 / 0.5 / 0 (victoria blanca / tablas / derrota).
+func resultValue(r GameResult) float64 {
+	switch r {
+	case WhiteWins:
+		return 1
+	case BlackWins:
+		return 0
+	default:
+		return 0.5
+	}
+}
+
+// LoadBook lee un fichero de aperturas con una posición FEN o EPD por línea.
+// Las líneas vacías y las que empiezan por '#' se ignoran; cualquier otra línea que no
+// sea una posición válida es un error.
+func LoadBook(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var book []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("posición inválida en %s: %s", path, line)
+		}
+		// Las EPD no llevan relojes; solo interesan los cuatro primeros campos
+		fen := strings.Join(fields[:4], " ")
+		if err := (&Board{}).SetFen(fen); err != nil {
+			return nil, fmt.Errorf("posición inválida en %s: %s: %w", path, line, err)
+		}
+		book = append(book, fen)
+	}
+	return book, scanner.Err()
+}
+
+// GenerateTrainingData juega las partidas configuradas y escribe las posiciones en w.
+// Cada partida usa su propia semilla derivada de cfg.Seed y empieza con el buscador
+// limpio, de modo que el resultado es reproducible salvo por el orden en que se escriben
+// las partidas.
+func GenerateTrainingData(cfg DatagenConfig, w io.Writer) (DatagenStats, error) {
+	if cfg.Evaluator == nil {
+		cfg.Evaluator = DefaultEvalParams()
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Depth <= 0 && cfg.Nodes <= 0 {
+		return DatagenStats{}, errors.New("datagen: hace falta una profundidad o un número de nodos")
+	}
+	if cfg.Nodes > 0 {
+		cfg.Depth = 0
+	}
+	for _, fen := range cfg.Book {
+		if err := (&Board{}).SetFen(fen); err != nil {
+			return DatagenStats{}, fmt.Errorf("datagen: posición inválida en el libro: %s: %w", fen, err)
+		}
+	}
+	var writeRecord func(*bufio.Writer, TrainingPosition) error
+	switch cfg.Format {
+	case "", "text":
+		writeRecord = writeTextRecord
+	case "binary":
+		writeRecord = writeBinaryRecord
+	default:
+		return DatagenStats{}, fmt.Errorf("datagen: formato desconocido %q", cfg.Format)
+	}
+
+	type finishedGame struct {
+		positions []TrainingPosition
+		result    GameResult
+	}
+	jobs := make(chan int)
+	games := make(chan finishedGame)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			searcher := NewSearcher(cfg.Evaluator)
+			for game := range jobs {
+				searcher.newGame()
+				positions, result := playTrainingGame(cfg, searcher, rand.New(rand.NewSource(cfg.Seed+int64(game))))
+				games <- finishedGame{positions, result}
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < cfg.Games; i++ {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(games)
+	}()
+
+	// Un único escritor evita tener que sincronizar la salida entre workers
+	out := bufio.NewWriter(w)
+	stats := DatagenStats{}
+	var err error
+	for game := range games {
+		if err != nil {
+			continue // Vaciar el canal para que terminen los workers
+		}
+		stats.Games++
+		switch game.result {
+		case WhiteWins:
+			stats.WhiteWins++
+		case BlackWins:
+			stats.BlackWins++
+		default:
+			stats.Draws++
+		}
+		for _, p := range game.positions {
+			if err = writeRecord(out, p); err != nil {
+				break
+			}
+			stats.Positions++
+		}
+	}
+	if err != nil {
+		return stats, err
+	}
+	return stats, out.Flush()
+}
+
+// playTrainingGame juega una partida de autojuego y devuelve sus posiciones tranquilas
+// etiquetadas con el resultado final.
+func playTrainingGame(cfg DatagenConfig, searcher *Searcher, rng *rand.Rand) ([]TrainingPosition, GameResult) {
+	start := NewBoard()
+	if len(cfg.Book) > 0 {
+		// GenerateTrainingData ya ha validado el libro
+		start = &Board{}
+		_ = start.SetFen(cfg.Book[rng.Intn(len(cfg.Book))])
+	}
+	game := NewGame(start)
+
+	// Apertura aleatoria
+	for i := 0; i < cfg.RandomPlies; i++ {
+		moves := game.LegalMoves()
+		if len(moves) == 0 {
+			break
+		}
+		game.Play(moves[rng.Intn(len(moves))])
+	}
+
+	var positions []TrainingPosition
+	openingPlies := len(game.Moves)
+	limits := SearchLimits{Depth: cfg.Depth, Nodes: cfg.Nodes}
+	result := Ongoing
+	for {
+		if result, _ = game.Status(); result != Ongoing {
+			break
+		}
+		if cfg.MaxPlies > 0 && len(game.Moves)-openingPlies >= cfg.MaxPlies {
+			result = Draw
+			break
+		}
+		b := game.Board
+		searcher.GameHistory = game.History()
+		res := searcher.Search(b, limits)
+		score := res.Score
+		if !b.WhiteToMove {
+			score = -score
+		}
+		if cfg.AdjudicateScore > 0 && (score >= cfg.AdjudicateScore || score <= -cfg.AdjudicateScore) {
+			result = WhiteWins
+			if score < 0 {
+				result = BlackWins
+			}
+			break
+		}
+		// Solo posiciones tranquilas: sin jaque y con una mejor jugada que no captura ni corona.
+		// Las puntuaciones de mate no son una evaluación y no sirven como etiqueta
+		quiet := !b.IsKingInCheck(b.WhiteToMove) && !res.BestMove.IsCapture() && res.BestMove.Type()&MovePromotion == 0
+		if quiet && !isMateScore(score) && (cfg.MaxRecordedScore == 0 || (score <= cfg.MaxRecordedScore && score >= -cfg.MaxRecordedScore)) {
+			positions = append(positions, TrainingPosition{Board: b.Clone(), Score: score})
+		}
+		game.Play(res.BestMove)
+	}
+	for i := range positions {
+		positions[i].Result = result
+	}
+	return positions, result
+}
+
+// writeTextRecord escribe una línea "FEN | score | resultado".
+func writeTextRecord(w *bufio.Writer, p TrainingPosition) error {
+	_, err := fmt.Fprintf(w, "%s | %d | %.1f\n", p.Board.Fen(), p.Score, resultValue(p.Result))
+	return err
+}
+
+// binaryRecordSize es el tamaño de cada posición en formato binario:
+//
+//	uint64 ocupación | 16 bytes de piezas (4 bits por pieza ocupada, en orden de casilla) |
+//	uint8 turno (bit 7) y enroques (bits 0-3) | uint8 casilla al paso | uint8 halfmove |
+//	uint16 fullmove | int16 score | uint8 resultado (0 derrota, 1 tablas, 2 victoria blanca)
+//
+// Cada pieza se codifica como tipo (1-6) más 8 si es negra. Todos los enteros son little endian.
+const binaryRecordSize = 32
+
+func writeBinaryRecord(w *bufio.Writer, p TrainingPosition) error {
+	var rec [binaryRecordSize]byte
+	if err := encodeTrainingPosition(&rec, p); err != nil {
+		return err
+	}
+	_, err := w.Write(rec[:])
+	return err
+}
+
+// encodeTrainingPosition falla si la posición tiene más de 32 piezas, que no caben en el registro.
+func encodeTrainingPosition(rec *[binaryRecordSize]byte, p TrainingPosition) error {
+	b := p.Board
+	occ := b.AllPieces()
+	if n := bits.OnesCount64(occ); n > 32 {
+		return fmt.Errorf("datagen: la posición tiene %d piezas y el formato binario admite 32", n)
+	}
+	binary.LittleEndian.PutUint64(rec[0:], occ)
+	i := 0
+	for bb := occ; bb != 0; bb &= bb - 1 {
+		piece, isWhite := b.PieceAtSquare(bb & -bb)
+		code := byte(piece)
+		if !isWhite {
+			code |= 8
+		}
+		rec[8+i/2] |= code << (4 * (i % 2))
+		i++
+	}
+	flags := byte(b.Castling)
+	if !b.WhiteToMove {
+		flags |= 0x80
+	}
+	rec[24] = flags
+	rec[25] = b.EnPassant
+	rec[26] = byte(min(b.HalfMove, 255))
+	binary.LittleEndian.PutUint16(rec[27:], uint16(min(b.FullMove, 65535)))
+	score := max(min(p.Score, 32767), -32767)
+	binary.LittleEndian.PutUint16(rec[29:], uint16(int16(score)))
+	rec[31] = byte(resultValue(p.Result) * 2)
+	return nil
+}
+
+// DecodeTrainingPosition lee una posición escrita en formato binario.
+func DecodeTrainingPosition(rec []byte) (TrainingPosition, error) {
+	if len(rec) < binaryRecordSize {
+		return TrainingPosition{}, errors.New("datagen: registro binario incompleto")
+	}
+	b := &Board{}
+	occ := binary.LittleEndian.Uint64(rec[0:])
+	if bits.OnesCount64(occ) > 32 {
+		return TrainingPosition{}, errors.New("datagen: registro binario inválido")
+	}
+	i := 0
+	for bb := occ; bb != 0; bb &= bb - 1 {
+		sq := bb & -bb
+		code := (rec[8+i/2] >> (4 * (i % 2))) & 0xF
+		i++
+		piece := Piece(code & 7)
+		if piece < Pawn || piece > King {
+			return TrainingPosition{}, errors.New("datagen: pieza inválida en registro binario")
+		}
+		pieces := &b.WhitePieces
+		if code&8 != 0 {
+			pieces = &b.BlackPieces
+		}
+		switch piece {
+		case Pawn:
+			pieces.Pawns |= sq
+		case Knight:
+			pieces.Knights |= sq
+		case Bishop:
+			pieces.Bishops |= sq
+		case Rook:
+			pieces.Rooks |= sq
+		case Queen:
+			pieces.Queens |= sq
+		case King:
+			pieces.King |= sq
+		}
+	}
+	b.Castling = CastleRights(rec[24] & 0x0F)
+	b.WhiteToMove = rec[24]&0x80 == 0
+	b.EnPassant = rec[25]
+	b.HalfMove = uint32(rec[26])
+	b.FullMove = uint32(binary.LittleEndian.Uint16(rec[27:]))
+	b.syncSquares()
+	p := TrainingPosition{Board: b, Score: int(int16(binary.LittleEndian.Uint16(rec[29:])))}
+	switch rec[31] {
+	case 2:
+		p.Result = WhiteWins
+	case 0:
+		p.Result = BlackWins
+	default:
+		p.Result = Draw
+	}
+	return p, nil
+}
@@ -0,0 +1,126 @@
+package melange
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGenerateTrainingDataText(t *testing.T) {
+	cfg := DatagenConfig{Games: 3, Workers: 2, Depth: 1, Seed: 7, RandomPlies: 4, MaxPlies: 30}
+	var buf bytes.Buffer
+	stats, err := GenerateTrainingData(cfg, &buf)
+	assert.NilError(t, err)
+	assert.Equal(t, stats.Games, 3)
+	assert.Equal(t, stats.WhiteWins+stats.BlackWins+stats.Draws, 3)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, len(lines), stats.Positions)
+	for _, line := range lines {
+		parts := strings.Split(line, " | ")
+		assert.Equal(t, len(parts), 3, line)
+		b := &Board{}
+		assert.NilError(t, b.SetFen(parts[0]))
+		// Solo se guardan posiciones sin jaque
+		assert.Assert(t, !b.IsKingInCheck(b.WhiteToMove), line)
+		assert.Assert(t, parts[2] == "1.0" || parts[2] == "0.5" || parts[2] == "0.0", line)
+	}
+}
+
+func TestGenerateTrainingDataReproducible(t *testing.T) {
+	// Con la misma semilla salen las mismas partidas aunque cambie el reparto entre workers
+	run := func(workers int) []string {
+		cfg := DatagenConfig{Games: 4, Workers: workers, Nodes: 1000, Seed: 11, RandomPlies: 4, MaxPlies: 30}
+		var buf bytes.Buffer
+		_, err := GenerateTrainingData(cfg, &buf)
+		assert.NilError(t, err)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		sort.Strings(lines)
+		return lines
+	}
+	assert.DeepEqual(t, run(1), run(3))
+}
+
+func TestGenerateTrainingDataBinary(t *testing.T) {
+	cfg := DatagenConfig{Games: 2, Workers: 1, Nodes: 300, Seed: 3, RandomPlies: 6, MaxPlies: 20, Format: "binary",
+		Book: []string{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -"}}
+	var buf bytes.Buffer
+	stats, err := GenerateTrainingData(cfg, &buf)
+	assert.NilError(t, err)
+	assert.Equal(t, buf.Len(), stats.Positions*binaryRecordSize)
+	for rec := buf.Bytes(); len(rec) > 0; rec = rec[binaryRecordSize:] {
+		p, err := DecodeTrainingPosition(rec)
+		assert.NilError(t, err)
+		assert.Equal(t, p.Board.WhitePieces.King != 0 && p.Board.BlackPieces.King != 0, true)
+	}
+}
+
+func TestTrainingPositionBinaryRoundTrip(t *testing.T) {
+	b := &Board{}
+	assert.NilError(t, b.SetFen("r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 b kq - 12 40"))
+	var rec [binaryRecordSize]byte
+	assert.NilError(t, encodeTrainingPosition(&rec, TrainingPosition{Board: b, Score: -153, Result: BlackWins}))
+	p, err := DecodeTrainingPosition(rec[:])
+	assert.NilError(t, err)
+	assert.Equal(t, p.Board.Fen(), b.Fen())
+	assert.Equal(t, p.Score, -153)
+	assert.Equal(t, p.Result, BlackWins)
+}
+
+func TestGenerateTrainingDataNeedsLimit(t *testing.T) {
+	_, err := GenerateTrainingData(DatagenConfig{Games: 1}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "profundidad")
+}
+
+func TestTrainingPositionTooManyPieces(t *testing.T) {
+	b := NewBoard()
+	b.WhitePieces.Pawns |= 0xFF0000 // 40 piezas
+	b.syncSquares()
+	var rec [binaryRecordSize]byte
+	assert.ErrorContains(t, encodeTrainingPosition(&rec, TrainingPosition{Board: b}), "32")
+}
+
+func TestLoadBook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epd")
+	content := "# aperturas\n\nrnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - bm e5;\n"
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0o644))
+	book, err := LoadBook(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, book, []string{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq -"})
+
+	assert.NilError(t, os.WriteFile(path, []byte("rnbqkbnr/pppppppp/8/8/8/8/PPPPXPPP/RNBQKBNR w KQkq -\n"), 0o644))
+	_, err = LoadBook(path)
+	assert.ErrorContains(t, err, "posición inválida")
+}
+
+func TestGenerateTrainingDataInvalidBook(t *testing.T) {
+	cfg := DatagenConfig{Games: 1, Depth: 1, Book: []string{"not a fen at all"}}
+	_, err := GenerateTrainingData(cfg, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "libro")
+}
+
+func TestPlayTrainingGameSkipsMateScores(t *testing.T) {
+	// Mate en uno: la búsqueda devuelve una puntuación de mate que no debe guardarse
+	cfg := DatagenConfig{Depth: 2, Book: []string{"6k1/5ppp/8/8/8/8/5PPP/R5K1 w - -"}}
+	positions, result := playTrainingGame(cfg, NewSearcher(DefaultEvalParams()), rand.New(rand.NewSource(1)))
+	assert.Equal(t, result, WhiteWins)
+	assert.Equal(t, len(positions), 0)
+}
+
+func TestPlayTrainingGameMaxPliesAfterOpening(t *testing.T) {
+	cfg := DatagenConfig{Depth: 1, RandomPlies: 8, MaxPlies: 2}
+	searcher := NewSearcher(DefaultEvalParams())
+	positions, result := playTrainingGame(cfg, searcher, rand.New(rand.NewSource(2)))
+	assert.Equal(t, result, Draw)
+	// Las jugadas aleatorias no cuentan para MaxPlies: se juegan dos jugadas buscadas
+	assert.Assert(t, len(positions) > 0 && len(positions) <= 2)
+	for _, p := range positions {
+		assert.Assert(t, p.Board.FullMove >= 5)
+	}
+}
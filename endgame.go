@@ -0,0 +1,305 @@
+package melange
+
+// Conocimiento de finales que la evaluación general no ve: un bitbase de KPK generado por
+// análisis retrógrado, evaluaciones específicas para dar mate con KQK, KRK y KBNK, y
+// factores de escala para finales que tienden a tablas aunque haya ventaja de material.
+
+import "math/bits"
+
+// knownWinScore es la base de las evaluaciones de finales ganados. Queda muy por encima de
+// cualquier ventaja de material normal y por debajo de las puntuaciones de mate y tablas.
+const knownWinScore = 10000
+
+// Factores de escala en sesenta y cuatroavos
+const (
+	scaleNormal = 64
+	scaleDraw   = 0
+)
+
+// endgameFunc evalúa un final desde el punto de vista de las blancas; strong indica si el
+// bando fuerte es el blanco.
+type endgameFunc func(b *Board, strongWhite bool) int
+
+// endgameEvaluators asocia firmas de material (las de tbMaterialKey) con su evaluación.
+var endgameEvaluators = map[string]endgameFunc{
+	"KQvK":  evaluateKXK,
+	"KvKQ":  evaluateKXK,
+	"KRvK":  evaluateKXK,
+	"KvKR":  evaluateKXK,
+	"KBNvK": evaluateKBNK,
+	"KvKBN": evaluateKBNK,
+	"KPvK":  evaluateKPK,
+	"KvKP":  evaluateKPK,
+}
+
+// evaluateEndgame devuelve la evaluación específica del final si la hay.
+func (b *Board) evaluateEndgame() (int, bool) {
+	if bits.OnesCount64(b.AllPieces()) > 5 {
+		return 0, false
+	}
+	key := tbMaterialKey(b)
+	f, ok := endgameEvaluators[key]
+	if !ok {
+		return 0, false
+	}
+	return f(b, key[1] != 'v'), true
+}
+
+// sides devuelve las piezas del bando fuerte y del débil.
+func (b *Board) sides(strongWhite bool) (strong, weak *Pieces) {
+	if strongWhite {
+		return &b.WhitePieces, &b.BlackPieces
+	}
+	return &b.BlackPieces, &b.WhitePieces
+}
+
+// fromStrong convierte una puntuación del bando fuerte al punto de vista de las blancas.
+func fromStrong(score int, strongWhite bool) int {
+	if strongWhite {
+		return score
+	}
+	return -score
+}
+
+func squareDistance(a, b int) int {
+	return max(abs(a&7-b&7), abs(a>>3-b>>3))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// pushToEdge premia que el rey esté cerca del borde del tablero.
+func pushToEdge(sq int) int {
+	f, r := sq&7, sq>>3
+	return 20 * (6 - min(f, 7-f) - min(r, 7-r))
+}
+
+// pushClose premia que los reyes estén cerca: el rey fuerte tiene que ayudar a dar mate.
+func pushClose(a, b int) int {
+	return 20 * (8 - squareDistance(a, b))
+}
+
+// evaluateKXK lleva al rey débil al borde en KQK y KRK.
+func evaluateKXK(b *Board, strongWhite bool) int {
+	strong, weak := b.sides(strongWhite)
+	sk, wk := bits.TrailingZeros64(strong.King), bits.TrailingZeros64(weak.King)
+	score := knownWinScore + pushToEdge(wk) + pushClose(sk, wk)
+	if strong.Queens != 0 {
+		score += 900
+	} else {
+		score += 500
+	}
+	return fromStrong(score, strongWhite)
+}
+
+// evaluateKBNK lleva al rey débil a una esquina del color del alfil, la única en la que
+// se puede dar mate.
+func evaluateKBNK(b *Board, strongWhite bool) int {
+	strong, weak := b.sides(strongWhite)
+	sk, wk := bits.TrailingZeros64(strong.King), bits.TrailingZeros64(weak.King)
+	bishop := bits.TrailingZeros64(strong.Bishops)
+	// Las esquinas a1 y h8 son oscuras; con alfil de casillas claras se refleja la columna
+	if squareColor(bishop) != squareColor(0) {
+		wk ^= 7
+	}
+	corner := min(squareDistance(wk, 0), squareDistance(wk, 63))
+	score := knownWinScore + 650 + 40*(7-corner) + pushToEdge(wk) + pushClose(sk, wk)
+	return fromStrong(score, strongWhite)
+}
+
+// evaluateKPK usa el bitbase: las posiciones ganadas valen más cuanto más avanzado el peón
+// y el resto son tablas.
+func evaluateKPK(b *Board, strongWhite bool) int {
+	strong, weak := b.sides(strongWhite)
+	sk, wk := bits.TrailingZeros64(strong.King), bits.TrailingZeros64(weak.King)
+	pawn := bits.TrailingZeros64(strong.Pawns)
+	if !probeKPK(sk, pawn, wk, strongWhite, b.WhiteToMove == strongWhite) {
+		return 0
+	}
+	rank := pawn >> 3
+	if !strongWhite {
+		rank = 7 - rank
+	}
+	return fromStrong(knownWinScore+100+10*rank, strongWhite)
+}
+
+func squareColor(sq int) int {
+	return (sq&7 + sq>>3) & 1
+}
+
+// scaleFactor devuelve el factor por el que se multiplica la evaluación (en sesenta y
+// cuatroavos) en finales con tendencia a tablas. score es la evaluación sin escalar desde
+// el punto de vista de las blancas.
+func (b *Board) scaleFactor(score int) int {
+	w, k := &b.WhitePieces, &b.BlackPieces
+	strongWhite := score > 0
+	strong, weak := b.sides(strongWhite)
+
+	// Alfil equivocado: peones de torre cuya casilla de coronación no es del color del
+	// alfil, con el rey débil delante
+	if strong.Knights|strong.Rooks|strong.Queens == 0 && bits.OnesCount64(strong.Bishops) == 1 && strong.Pawns != 0 {
+		for _, file := range []uint64{fileA, fileA << 7} {
+			if strong.Pawns&^file != 0 {
+				continue
+			}
+			queening := bits.TrailingZeros64(file) + 56
+			if !strongWhite {
+				queening -= 56
+			}
+			wk := bits.TrailingZeros64(weak.King)
+			if squareColor(queening) != squareColor(bits.TrailingZeros64(strong.Bishops)) && squareDistance(queening, wk) <= 1 {
+				return scaleDraw
+			}
+		}
+	}
+
+	// Torre contra alfil sin peones: casi siempre tablas
+	if w.Pawns|k.Pawns == 0 && strong.Knights|strong.Bishops|strong.Queens == 0 && bits.OnesCount64(strong.Rooks) == 1 &&
+		weak.Knights|weak.Rooks|weak.Queens == 0 && bits.OnesCount64(weak.Bishops) == 1 {
+		return 8
+	}
+
+	// Alfiles de distinto color sin más piezas que los peones
+	if w.Knights|w.Rooks|w.Queens|k.Knights|k.Rooks|k.Queens == 0 &&
+		bits.OnesCount64(w.Bishops) == 1 && bits.OnesCount64(k.Bishops) == 1 &&
+		squareColor(bits.TrailingZeros64(w.Bishops)) != squareColor(bits.TrailingZeros64(k.Bishops)) {
+		return 24
+	}
+	return scaleNormal
+}
+
+const fileA uint64 = 0x0101010101010101
+
+// Bitbase de KPK: un bit por posición con las blancas como bando del peón, el peón en
+// las columnas a-d y las filas 2-7. Indica si las blancas ganan.
+const kpkSize = 2 * 24 * 64 * 64
+
+var kpkBitbase [kpkSize / 64]uint64
+
+func kpkIndex(whiteToMove bool, bk, wk, pawn int) int {
+	stm := 0
+	if !whiteToMove {
+		stm = 1
+	}
+	return wk | bk<<6 | stm<<12 | (pawn&7)<<13 | (6-pawn>>3)<<15
+}
+
+// probeKPK indica si el bando del peón gana. Las casillas son las reales; strongWhite
+// indica el color del bando fuerte y strongToMove si le toca mover.
+func probeKPK(strongKing, pawn, weakKing int, strongWhite, strongToMove bool) bool {
+	if !strongWhite {
+		strongKing, pawn, weakKing = strongKing^56, pawn^56, weakKing^56
+	}
+	if pawn&7 > 3 {
+		strongKing, pawn, weakKing = strongKing^7, pawn^7, weakKing^7
+	}
+	idx := kpkIndex(strongToMove, weakKing, strongKing, pawn)
+	return kpkBitbase[idx/64]&(1<<(idx%64)) != 0
+}
+
+// Resultados del análisis retrógrado, combinables como bits
+const (
+	kpkInvalid byte = 0
+	kpkUnknown byte = 1
+	kpkDraw    byte = 2
+	kpkWin     byte = 4
+)
+
+func init() {
+	initKPK()
+}
+
+// initKPK clasifica todas las posiciones de KPK y repite hasta que ninguna cambia.
+func initKPK() {
+	db := make([]byte, kpkSize)
+	for idx := range db {
+		db[idx] = kpkClassifyInitial(idx)
+	}
+	for changed := true; changed; {
+		changed = false
+		for idx, r := range db {
+			if r == kpkUnknown {
+				if db[idx] = kpkClassify(db, idx); db[idx] != kpkUnknown {
+					changed = true
+				}
+			}
+		}
+	}
+	for idx, r := range db {
+		if r == kpkWin {
+			kpkBitbase[idx/64] |= 1 << (idx % 64)
+		}
+	}
+}
+
+func kpkDecode(idx int) (whiteToMove bool, bk, wk, pawn int) {
+	wk = idx & 63
+	bk = idx >> 6 & 63
+	whiteToMove = idx>>12&1 == 0
+	pawn = (idx >> 13 & 3) + (6-idx>>15)*8
+	return
+}
+
+func kpkClassifyInitial(idx int) byte {
+	whiteToMove, bk, wk, pawn := kpkDecode(idx)
+	pawnAttacks := uint64(0)
+	if pawn&7 > 0 {
+		pawnAttacks |= 1 << (pawn + 7)
+	}
+	if pawn&7 < 7 {
+		pawnAttacks |= 1 << (pawn + 9)
+	}
+	switch {
+	case squareDistance(wk, bk) <= 1 || wk == pawn || bk == pawn:
+		return kpkInvalid
+	case whiteToMove && pawnAttacks&(1<<bk) != 0:
+		return kpkInvalid // Las negras estarían en jaque sin mover
+	case whiteToMove && pawn>>3 == 6 && wk != pawn+8 && bk != pawn+8 &&
+		(squareDistance(bk, pawn+8) > 1 || squareDistance(wk, pawn+8) == 1):
+		return kpkWin // El peón corona y no se puede capturar la dama
+	case !whiteToMove && kingAttacks[bk]&^(kingAttacks[wk]|pawnAttacks) == 0:
+		return kpkDraw // Ahogado
+	case !whiteToMove && kingAttacks[bk]&(1<<pawn)&^kingAttacks[wk] != 0:
+		return kpkDraw // El rey negro captura el peón
+	}
+	return kpkUnknown
+}
+
+// kpkClassify combina los resultados de las jugadas posibles: el bando al mover elige
+// el mejor para él (victoria para las blancas, tablas para las negras).
+func kpkClassify(db []byte, idx int) byte {
+	whiteToMove, bk, wk, pawn := kpkDecode(idx)
+	r := kpkInvalid
+	if whiteToMove {
+		for bb := kingAttacks[wk]; bb != 0; bb &= bb - 1 {
+			r |= db[kpkIndex(false, bk, bits.TrailingZeros64(bb), pawn)]
+		}
+		if pawn>>3 < 6 {
+			r |= db[kpkIndex(false, bk, wk, pawn+8)]
+		}
+		if pawn>>3 == 1 && pawn+8 != wk && pawn+8 != bk {
+			r |= db[kpkIndex(false, bk, wk, pawn+16)]
+		}
+		switch {
+		case r&kpkWin != 0:
+			return kpkWin
+		case r&kpkUnknown != 0:
+			return kpkUnknown
+		}
+		return kpkDraw
+	}
+	for bb := kingAttacks[bk]; bb != 0; bb &= bb - 1 {
+		r |= db[kpkIndex(true, bits.TrailingZeros64(bb), wk, pawn)]
+	}
+	switch {
+	case r&kpkDraw != 0:
+		return kpkDraw
+	case r&kpkUnknown != 0:
+		return kpkUnknown
+	}
+	return kpkWin
+}
@@ -0,0 +1,82 @@
+package melange
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func evalFen(t *testing.T, fen string) int {
+	b := &Board{}
+	assert.NilError(t, b.SetFen(fen))
+	return b.Evaluate(DefaultEvalParams())
+}
+
+func TestKPKBitbase(t *testing.T) {
+	tests := []struct {
+		fen string
+		win bool
+	}{
+		// Oposición: con las blancas al mover son tablas, con las negras se gana
+		{"8/8/8/4k3/8/4K3/4P3/8 w - - 0 1", false},
+		{"8/8/8/4k3/8/4K3/4P3/8 b - - 0 1", true},
+		// Rey en sexta delante del peón: se gana mueva quien mueva
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", true},
+		{"8/8/8/8/8/4k3/4P3/4K3 w - - 0 1", false},
+		// El rey débil no alcanza al peón
+		{"7k/8/8/8/P7/8/8/4K3 w - - 0 1", true},
+		// Peón de torre con el rey débil en la esquina
+		{"k7/8/K7/P7/8/8/8/8 w - - 0 1", false},
+		// Colores cambiados
+		{"8/4p3/4k3/8/4K3/8/8/8 b - - 0 1", false},
+		{"8/4p3/4k3/8/4K3/8/8/8 w - - 0 1", true},
+		{"4k3/8/8/8/8/8/p7/4K3 b - - 0 1", true},
+	}
+	for _, tt := range tests {
+		score := evalFen(t, tt.fen)
+		if tt.win {
+			assert.Assert(t, score > knownWinScore || score < -knownWinScore, "%s: %d", tt.fen, score)
+		} else {
+			assert.Equal(t, score, 0, tt.fen)
+		}
+	}
+}
+
+func TestKXKPushesKingToEdge(t *testing.T) {
+	center := evalFen(t, "8/8/8/3k4/8/8/8/1Q2K3 w - - 0 1")
+	edge := evalFen(t, "k7/8/8/8/8/8/8/1Q2K3 w - - 0 1")
+	assert.Assert(t, edge > center && center > knownWinScore)
+
+	// Mismo final con las negras como bando fuerte
+	assert.Assert(t, evalFen(t, "r3k3/8/8/8/8/8/8/4K3 w - - 0 1") < -knownWinScore)
+}
+
+func TestKBNKRightCorner(t *testing.T) {
+	// Alfil de casillas oscuras: el mate solo es posible en a1 o h8
+	right := evalFen(t, "7k/8/8/8/3K4/8/8/2B1N3 w - - 0 1")
+	wrong := evalFen(t, "k7/8/8/8/3K4/8/8/2B1N3 w - - 0 1")
+	assert.Assert(t, right > wrong && wrong > knownWinScore)
+}
+
+func TestEndgameScaleFactors(t *testing.T) {
+	p := DefaultEvalParams()
+	tests := []struct {
+		fen   string
+		scale int
+	}{
+		// Alfiles de distinto color
+		{"4k3/8/3b4/8/3P4/1P6/2B5/4K3 w - - 0 1", 24},
+		// Alfil que no controla la casilla de coronación del peón de torre
+		{"1k6/8/8/8/P7/8/1B6/4K3 w - - 0 1", scaleDraw},
+		{"4k3/8/8/8/P7/8/1B6/4K3 w - - 0 1", scaleNormal},
+		// Torre contra alfil
+		{"4k3/8/3b4/8/8/8/8/R3K3 w - - 0 1", 8},
+		{"4k3/8/8/8/8/8/8/RN2K3 w - - 0 1", scaleNormal},
+	}
+	for _, tt := range tests {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(tt.fen))
+		raw := p.Evaluate(b)
+		assert.Equal(t, b.Evaluate(p), raw*tt.scale/scaleNormal, tt.fen)
+	}
+}
@@ -0,0 +1,379 @@
+package melange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EPD es una posición de un fichero de pruebas EPD con los códigos de operación que usamos:
+// bm (mejores movimientos), am (movimientos a evitar), id, c0 (comentario; en STS lleva
+// los pesos de los movimientos) y acd (profundidad del análisis).
+type EPD struct {
+	Fen        string
+	ID         string
+	BestMoves  []Move
+	AvoidMoves []Move
+	Comment    string
+	Depth      int               // acd, 0 si no aparece
+	Weights    map[Move]int      // Puntos de cada movimiento según c0 (solo suites tipo STS)
+	Ops        map[string]string // Operandos de todas las operaciones, por código
+}
+
+// ParseEPD lee una línea EPD: los cuatro campos de la posición seguidos de operaciones
+// "código operandos;". Los movimientos de bm y am se dan en SAN.
+func ParseEPD(line string) (EPD, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return EPD{}, fmt.Errorf("EPD inválida: %s", line)
+	}
+	epd := EPD{Fen: strings.Join(fields[:4], " "), Ops: map[string]string{}}
+	b := &Board{}
+	if err := b.SetFen(epd.Fen); err != nil {
+		return EPD{}, fmt.Errorf("EPD inválida: %s: %w", line, err)
+	}
+
+	// Las operaciones empiezan tras el cuarto campo
+	rest := line
+	for range 4 {
+		rest = strings.TrimLeft(rest, " \t")
+		if i := strings.IndexAny(rest, " \t"); i >= 0 {
+			rest = rest[i:]
+		} else {
+			rest = ""
+		}
+	}
+	ops, err := splitEPDOps(rest)
+	if err != nil {
+		return EPD{}, fmt.Errorf("EPD inválida: %s: %w", line, err)
+	}
+	for _, op := range ops {
+		code, operands, _ := strings.Cut(op, " ")
+		operands = strings.TrimSpace(operands)
+		epd.Ops[code] = operands
+		switch code {
+		case "bm", "am":
+			moves, err := parseEPDMoves(b, operands)
+			if err != nil {
+				return EPD{}, fmt.Errorf("EPD %s: %w", code, err)
+			}
+			if code == "bm" {
+				epd.BestMoves = moves
+			} else {
+				epd.AvoidMoves = moves
+			}
+		case "id":
+			epd.ID = unquote(operands)
+		case "c0":
+			epd.Comment = unquote(operands)
+			epd.Weights = parseMoveWeights(b, epd.Comment)
+		case "acd":
+			if epd.Depth, err = strconv.Atoi(operands); err != nil || epd.Depth < 1 {
+				return EPD{}, fmt.Errorf("EPD acd inválido: %s", operands)
+			}
+		}
+	}
+	return epd, nil
+}
+
+// splitEPDOps separa las operaciones por ';' respetando las cadenas entre comillas.
+func splitEPDOps(s string) ([]string, error) {
+	var ops []string
+	var current strings.Builder
+	quoted := false
+	for _, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			current.WriteRune(c)
+		case c == ';' && !quoted:
+			if op := strings.TrimSpace(current.String()); op != "" {
+				ops = append(ops, op)
+			}
+			current.Reset()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("comillas sin cerrar")
+	}
+	if op := strings.TrimSpace(current.String()); op != "" {
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func parseEPDMoves(b *Board, operands string) ([]Move, error) {
+	var moves []Move
+	for _, san := range strings.Fields(operands) {
+		m, err := b.ParseSAN(san)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+func unquote(s string) string {
+	return strings.Trim(s, "\"")
+}
+
+// parseMoveWeights interpreta comentarios como los de STS, "f5=10, Be5+=2, Bf2=3", en los
+// que cada movimiento lleva los puntos que vale. Devuelve nil si el comentario no tiene
+// ese formato.
+func parseMoveWeights(b *Board, comment string) map[Move]int {
+	weights := map[Move]int{}
+	for _, item := range strings.Split(comment, ",") {
+		item = strings.TrimSpace(item)
+		// El peso va tras el último '=' (las promociones llevan otro: e8=Q=10)
+		i := strings.LastIndex(item, "=")
+		if i <= 0 {
+			return nil
+		}
+		points, err := strconv.Atoi(item[i+1:])
+		if err != nil {
+			return nil
+		}
+		m, err := b.ParseSAN(item[:i])
+		if err != nil {
+			return nil
+		}
+		weights[m] = points
+	}
+	return weights
+}
+
+// LoadEPD lee un fichero de pruebas EPD. Las líneas vacías y las que empiezan por '#' se
+// ignoran.
+func LoadEPD(path string) ([]EPD, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var suite []EPD
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		epd, err := ParseEPD(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		suite = append(suite, epd)
+	}
+	return suite, scanner.Err()
+}
+
+// Solves indica si m resuelve la posición: es uno de los bm (si los hay) y ninguno de
+// los am.
+func (e *EPD) Solves(m Move) bool {
+	for _, avoid := range e.AvoidMoves {
+		if m == avoid {
+			return false
+		}
+	}
+	if len(e.BestMoves) == 0 {
+		return len(e.AvoidMoves) > 0
+	}
+	for _, best := range e.BestMoves {
+		if m == best {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxPoints devuelve los puntos del mejor movimiento según c0, o 0 si no hay pesos.
+func (e *EPD) MaxPoints() int {
+	best := 0
+	for _, points := range e.Weights {
+		best = max(best, points)
+	}
+	return best
+}
+
+// DefaultEPDDepth es la profundidad de las posiciones sin acd cuando no se fija ningún límite.
+const DefaultEPDDepth = 8
+
+// EPDConfig configura RunEPD. Las posiciones con acd se buscan a esa profundidad salvo
+// que Limits fije algún límite; sin ninguno de los dos se usa DefaultEPDDepth.
+type EPDConfig struct {
+	Limits    SearchLimits
+	Evaluator Evaluator // nil = DefaultEvalParams()
+	Threads   int
+	HashMB    int // 0 = DefaultHashMB; la tabla se vacía antes de cada posición
+}
+
+// EPDResult es el resultado de una posición de la suite.
+type EPDResult struct {
+	ID       string
+	Move     Move
+	SAN      string
+	Solved   bool
+	Points   int // Según los pesos de c0
+	Depth    int
+	Nodes    int64
+	Elapsed  time.Duration
+	SolvedAt time.Duration // Tiempo hasta encontrar la solución y no volver a cambiarla
+}
+
+// EPDReport resume la ejecución de una suite.
+type EPDReport struct {
+	Results   []EPDResult
+	Solved    int
+	Points    int
+	MaxPoints int
+	Nodes     int64
+	Elapsed   time.Duration
+}
+
+// RunEPD busca cada posición de la suite y escribe en w una línea por posición y la tabla
+// de resumen. El tiempo hasta la solución es el de la primera iteración desde la que el
+// mejor movimiento ya resuelve la posición hasta el final de la búsqueda.
+func RunEPD(suite []EPD, cfg EPDConfig, w io.Writer) EPDReport {
+	var report EPDReport
+	if cfg.Evaluator == nil {
+		cfg.Evaluator = DefaultEvalParams()
+	}
+	hashMB := cfg.HashMB
+	if hashMB <= 0 {
+		hashMB = DefaultHashMB
+	}
+	tt := NewTranspositionTable(hashMB)
+	for i := range suite {
+		epd := &suite[i]
+		tt.Clear()
+		r := runEPDPosition(epd, cfg, tt)
+		if r.ID == "" {
+			r.ID = strconv.Itoa(i + 1)
+		}
+		report.Results = append(report.Results, r)
+		if r.Solved {
+			report.Solved++
+		}
+		report.Points += r.Points
+		report.MaxPoints += epd.MaxPoints()
+		report.Nodes += r.Nodes
+		report.Elapsed += r.Elapsed
+
+		status := "failed"
+		if r.Solved {
+			status = "solved"
+		}
+		fmt.Fprintf(w, "%4d/%d %-20s %-6s %-8s expected %-16s depth %2d  %s\n", i+1, len(suite), r.ID,
+			status, r.SAN, epdExpected(epd), r.Depth, formatSolvedAt(r))
+	}
+	writeEPDSummary(w, suite, report)
+	return report
+}
+
+func runEPDPosition(epd *EPD, cfg EPDConfig, tt *TranspositionTable) EPDResult {
+	b := &Board{}
+	if err := b.SetFen(epd.Fen); err != nil {
+		panic(err) // ParseEPD ya validó la posición
+	}
+	limits := cfg.Limits
+	if limits == (SearchLimits{}) {
+		limits.Depth = epd.Depth
+		if limits.Depth == 0 {
+			limits.Depth = DefaultEPDDepth
+		}
+	}
+	searcher := NewSearcher(cfg.Evaluator)
+	searcher.Threads = cfg.Threads
+	searcher.TT = tt
+
+	start := time.Now()
+	solvedAt := time.Duration(-1)
+	searcher.OnIteration = func(r SearchResult) {
+		if r.LowerBound || r.UpperBound {
+			return
+		}
+		switch {
+		case !epd.Solves(r.BestMove):
+			solvedAt = -1
+		case solvedAt < 0:
+			solvedAt = time.Since(start)
+		}
+	}
+	res := searcher.Search(b, limits)
+	r := EPDResult{
+		ID:      epd.ID,
+		Move:    res.BestMove,
+		SAN:     b.SAN(res.BestMove),
+		Solved:  epd.Solves(res.BestMove),
+		Points:  epd.Weights[res.BestMove],
+		Depth:   res.Depth,
+		Nodes:   res.Nodes,
+		Elapsed: time.Since(start),
+	}
+	if r.Solved {
+		r.SolvedAt = max(solvedAt, 0)
+	}
+	return r
+}
+
+func epdExpected(epd *EPD) string {
+	b := &Board{}
+	_ = b.SetFen(epd.Fen)
+	var parts []string
+	for _, m := range epd.BestMoves {
+		parts = append(parts, b.SAN(m))
+	}
+	for _, m := range epd.AvoidMoves {
+		parts = append(parts, "!"+b.SAN(m))
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatSolvedAt(r EPDResult) string {
+	if !r.Solved {
+		return ""
+	}
+	return fmt.Sprintf("in %d ms", r.SolvedAt.Milliseconds())
+}
+
+func writeEPDSummary(w io.Writer, suite []EPD, report EPDReport) {
+	total := len(suite)
+	fmt.Fprintln(w, "===========================")
+	fmt.Fprintf(w, "Solved          : %d/%d (%.1f%%)\n", report.Solved, total, percent(report.Solved, total))
+	if report.MaxPoints > 0 {
+		fmt.Fprintf(w, "STS points      : %d/%d (%.1f%%)\n", report.Points, report.MaxPoints, percent(report.Points, report.MaxPoints))
+	}
+	var solvedTime time.Duration
+	for _, r := range report.Results {
+		if r.Solved {
+			solvedTime += r.SolvedAt
+		}
+	}
+	if report.Solved > 0 {
+		fmt.Fprintf(w, "Time to solution: %d ms (avg %d ms)\n", solvedTime.Milliseconds(), solvedTime.Milliseconds()/int64(report.Solved))
+	}
+	fmt.Fprintf(w, "Total time (ms) : %d\n", report.Elapsed.Milliseconds())
+	fmt.Fprintf(w, "Nodes searched  : %d\n", report.Nodes)
+	var failed []string
+	for _, r := range report.Results {
+		if !r.Solved {
+			failed = append(failed, r.ID)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(w, "Failed          : %s\n", strings.Join(failed, " "))
+	}
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
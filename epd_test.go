@@ -0,0 +1,102 @@
+package melange
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const stsLine = `1kr5/3n4/q3p2p/p2n2p1/PppB1P2/5BP1/1P2Q2P/3R2K1 w - - bm f5; id "Undermine.001"; c0 "f5=10, Be5+=2, Bf2=3, Bg4=2";`
+
+func TestParseEPD(t *testing.T) {
+	epd, err := ParseEPD(`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; am Qxg7+ Nxd5; id "WAC.001"; acd 6; c1 "a; b";`)
+	assert.NilError(t, err)
+	assert.Equal(t, epd.Fen, "2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - -")
+	assert.Equal(t, epd.ID, "WAC.001")
+	assert.Equal(t, epd.Depth, 6)
+	assert.Equal(t, len(epd.BestMoves), 1)
+	assert.Equal(t, epd.BestMoves[0].UCIString(), "g3g6")
+	assert.Equal(t, len(epd.AvoidMoves), 2)
+	// El ';' entre comillas no separa operaciones
+	assert.Equal(t, epd.Ops["c1"], `"a; b"`)
+	assert.Assert(t, epd.Weights == nil)
+
+	assert.Assert(t, epd.Solves(epd.BestMoves[0]))
+	assert.Assert(t, !epd.Solves(epd.AvoidMoves[0]))
+
+	for _, bad := range []string{
+		"8/8/8 w - - bm e4;",
+		"4k3/8/8/8/8/8/8/4K3 w - - bm e4;",
+		`4k3/8/8/8/8/8/8/4K3 w - - id "sin cerrar;`,
+		"4k3/8/8/8/8/8/8/4K3 w - - acd x;",
+	} {
+		_, err := ParseEPD(bad)
+		assert.Assert(t, err != nil, bad)
+	}
+}
+
+func TestParseEPDWeights(t *testing.T) {
+	epd, err := ParseEPD(stsLine)
+	assert.NilError(t, err)
+	assert.Equal(t, epd.Comment, "f5=10, Be5+=2, Bf2=3, Bg4=2")
+	assert.Equal(t, len(epd.Weights), 4)
+	assert.Equal(t, epd.MaxPoints(), 10)
+	b := &Board{}
+	assert.NilError(t, b.SetFen(epd.Fen))
+	be5, _ := b.ParseSAN("Be5")
+	assert.Equal(t, epd.Weights[be5], 2)
+
+	// Un comentario cualquiera no tiene pesos
+	epd, err = ParseEPD(`4k3/8/8/8/8/8/4P3/4K3 w - - bm e4; c0 "lo más natural";`)
+	assert.NilError(t, err)
+	assert.Assert(t, epd.Weights == nil)
+	assert.Equal(t, epd.MaxPoints(), 0)
+}
+
+func TestAvoidMoveOnly(t *testing.T) {
+	epd, err := ParseEPD("4k3/8/2p5/3p4/8/8/8/3QK3 w - - am Qxd5;")
+	assert.NilError(t, err)
+	b := &Board{}
+	assert.NilError(t, b.SetFen(epd.Fen))
+	qd2, _ := b.ParseSAN("Qd2")
+	assert.Assert(t, epd.Solves(qd2))
+	assert.Assert(t, !epd.Solves(epd.AvoidMoves[0]))
+}
+
+func TestRunEPD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suite.epd")
+	suite := strings.Join([]string{
+		"# Comentario",
+		`6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - bm Ra8#; id "mate1";`,
+		`4k3/8/2p5/3p4/8/8/8/3QK3 w - - am Qxd5; id "avoid";`,
+		"",
+		// Un bm que deja la dama colgada: el motor no lo juega
+		`4k3/8/8/8/8/8/3q4/3RK3 b - - bm Kf8; id "imposible"; acd 2;`,
+		stsLine,
+	}, "\n")
+	assert.NilError(t, os.WriteFile(path, []byte(suite), 0o644))
+	positions, err := LoadEPD(path)
+	assert.NilError(t, err)
+	assert.Equal(t, len(positions), 4)
+
+	var out bytes.Buffer
+	report := RunEPD(positions, EPDConfig{Limits: SearchLimits{Depth: 3}, HashMB: 1}, &out)
+	assert.Equal(t, len(report.Results), 4)
+	assert.Assert(t, report.Results[0].Solved)
+	assert.Equal(t, report.Results[0].SAN, "Ra8#")
+	assert.Assert(t, report.Results[1].Solved)
+	assert.Assert(t, !report.Results[2].Solved)
+	assert.Equal(t, report.MaxPoints, 10)
+	assert.Equal(t, report.Points, report.Results[3].Points)
+	assert.Assert(t, strings.Contains(out.String(), "Solved          : "), out.String())
+	assert.Assert(t, strings.Contains(out.String(), "STS points      : "), out.String())
+	assert.Assert(t, strings.Contains(out.String(), "Failed          : imposible"), out.String())
+
+	// Sin límites se usa acd
+	report = RunEPD(positions[2:3], EPDConfig{}, &bytes.Buffer{})
+	assert.Equal(t, report.Results[0].Depth, 2)
+}
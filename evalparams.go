@@ -0,0 +1,76 @@
+package melange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// LoadEvalParams lee un fichero JSON con parámetros de evaluación. Los campos ausentes
+// conservan el valor por defecto, así que basta con escribir los pesos que se quieren
+// cambiar para un test A/B.
+func LoadEvalParams(path string) (*EvalParams, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadEvalParams(f)
+}
+
+// ReadEvalParams decodifica parámetros de evaluación en JSON partiendo de los valores por defecto.
+func ReadEvalParams(r io.Reader) (*EvalParams, error) {
+	p := DefaultEvalParams()
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("parámetros de evaluación inválidos: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Save escribe los parámetros en formato JSON indentado.
+func (p *EvalParams) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write serializa los parámetros como JSON indentado.
+func (p *EvalParams) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// Validate comprueba que todas las piece-square tables tengan 64 casillas.
+func (p *EvalParams) Validate() error {
+	tables := []struct {
+		name  string
+		table []int
+	}{
+		{"pos_pawn", p.PosPawn},
+		{"pos_knight", p.PosKnight},
+		{"pos_bishop", p.PosBishop},
+		{"pos_rook", p.PosRook},
+		{"pos_queen", p.PosQueen},
+		{"pos_king_middle", p.PosKingMiddle},
+		{"pos_king_end", p.PosKingEnd},
+	}
+	for _, t := range tables {
+		if len(t.table) != 64 {
+			return fmt.Errorf("parámetros de evaluación inválidos: %s tiene %d casillas, se esperaban 64", t.name, len(t.table))
+		}
+	}
+	return nil
+}
@@ -4,118 +4,161 @@ import "math/bits"
 
 type Centipawn int
 
-const (
-	CpPawn         int = 100
-	CpKnight       int = 320
-	CpBishop       int = 330
-	CpRook         int = 500
-	CpQueen        int = 900
-	CpKing         int = 10000
-	CpDoubledPawn  int = 50
-	CpStuckedPawn  int = 30
-	CpIsolatedPawn int = 20
-	CpBishopPair   int = 30
-)
-
-// Posicionamiento de piezas (piece-square tables)
-// Valores en centipawns
-// Las tablas están definidas desde la perspectiva de las blancas visualmente.
-// Para las blancas, se debe invertir el índice (63 - sq).
-var PosPawn = []int{
-	0, 0, 0, 0, 0, 0, 0, 0,
-	50, 50, 50, 50, 50, 50, 50, 50,
-	10, 10, 20, 30, 30, 20, 10, 10,
-	5, 5, 10, 25, 25, 10, 5, 5,
-	0, 0, 0, 20, 20, 0, 0, 0,
-	5, -5, -10, 0, 0, -10, -5, 5,
-	5, 10, 10, -20, -20, 10, 10, 5,
-	0, 0, 0, 0, 0, 0, 0, 0,
+// Evaluator calcula la evaluación estática de una posición en centipawns desde el punto de
+// vista de las blancas. Lo implementan la evaluación clásica (*EvalParams) y la red NNUE (*Network).
+type Evaluator interface {
+	Evaluate(b *Board) int
 }
 
-var PosKnight = []int{
-	-50, -40, -30, -30, -30, -30, -40, -50,
-	-40, -20, 0, 0, 0, 0, -20, -40,
-	-30, 0, 10, 15, 15, 10, 0, -30,
-	-30, 5, 15, 20, 20, 15, 5, -30,
-	-30, 0, 15, 20, 20, 15, 0, -30,
-	-30, 5, 10, 15, 15, 10, 5, -30,
-	-40, -20, 0, 5, 5, 0, -20, -40,
-	-50, -40, -30, -30, -30, -30, -40, -50,
+// EvalParams agrupa todos los pesos de la evaluación clásica (valores de material y
+// piece-square tables) para poder cambiarlos sin recompilar. El rey no tiene valor de
+// material: ambos bandos tienen siempre uno y se anularía. Se pasa explícitamente a
+// Board.Evaluate; DefaultEvalParams devuelve los valores de referencia del motor.
+type EvalParams struct {
+	Pawn   int `json:"pawn"`
+	Knight int `json:"knight"`
+	Bishop int `json:"bishop"`
+	Rook   int `json:"rook"`
+	Queen  int `json:"queen"`
+	// Términos de estructura reservados; la evaluación todavía no los aplica
+	DoubledPawn  int `json:"doubled_pawn"`
+	StuckedPawn  int `json:"stucked_pawn"`
+	IsolatedPawn int `json:"isolated_pawn"`
+	BishopPair   int `json:"bishop_pair"`
+
+	// Posicionamiento de piezas (piece-square tables)
+	// Valores en centipawns
+	// Las tablas están definidas desde la perspectiva de las blancas visualmente.
+	// Para las blancas, se debe invertir el índice (63 - sq).
+	PosPawn       []int `json:"pos_pawn"`
+	PosKnight     []int `json:"pos_knight"`
+	PosBishop     []int `json:"pos_bishop"`
+	PosRook       []int `json:"pos_rook"`
+	PosQueen      []int `json:"pos_queen"`
+	PosKingMiddle []int `json:"pos_king_middle"`
+	// PosKingEnd todavía no se usa: evalPositions aplica siempre PosKingMiddle al rey.
+	PosKingEnd []int `json:"pos_king_end"`
 }
 
-var PosBishop = []int{
-	-20, -10, -10, -10, -10, -10, -10, -20,
-	-10, 0, 0, 0, 0, 0, 0, -10,
-	-10, 0, 5, 10, 10, 5, 0, -10,
-	-10, 5, 5, 10, 10, 5, 5, -10,
-	-10, 0, 10, 10, 10, 10, 0, -10,
-	-10, 10, 10, 10, 10, 10, 10, -10,
-	-10, 5, 0, 0, 0, 0, 5, -10,
-	-20, -10, -10, -10, -10, -10, -10, -20,
-}
-
-var PosRook = []int{
-	0, 0, 0, 0, 0, 0, 0, 0,
-	5, 10, 10, 10, 10, 10, 10, 5,
-	-5, 0, 0, 0, 0, 0, 0, -5,
-	-5, 0, 0, 0, 0, 0, 0, -5,
-	-5, 0, 0, 0, 0, 0, 0, -5,
-	-5, 0, 0, 0, 0, 0, 0, -5,
-	-5, 0, 0, 0, 0, 0, 0, -5,
-	0, 0, 0, 5, 5, 0, 0, 0,
-}
-
-var PosQueen = []int{
-	-20, -10, -10, -5, -5, -10, -10, -20,
-	-10, 0, 0, 0, 0, 0, 0, -10,
-	-10, 0, 5, 5, 5, 5, 0, -10,
-	-5, 0, 5, 5, 5, 5, 0, -5,
-	0, 0, 5, 5, 5, 5, 0, -5,
-	-10, 5, 5, 5, 5, 5, 0, -10,
-	-10, 0, 5, 0, 0, 0, 0, -10,
-	-20, -10, -10, -5, -5, -10, -10, -20,
-}
-
-var PosKingMiddle = []int{
-	-30, -40, -40, -50, -50, -40, -40, -30,
-	-30, -40, -40, -50, -50, -40, -40, -30,
-	-30, -40, -40, -50, -50, -40, -40, -30,
-	-30, -40, -40, -50, -50, -40, -40, -30,
-	-20, -30, -30, -40, -40, -30, -30, -20,
-	-10, -20, -20, -20, -20, -20, -20, -10,
-	20, 20, 0, 0, 0, 0, 20, 20,
-	20, 30, 10, 0, 0, 10, 30, 20,
+// DefaultEvalParams devuelve una copia nueva de los parámetros por defecto, de modo que
+// quien la reciba puede modificarla sin afectar a otros usuarios.
+func DefaultEvalParams() *EvalParams {
+	return &EvalParams{
+		Pawn:         100,
+		Knight:       320,
+		Bishop:       330,
+		Rook:         500,
+		Queen:        900,
+		DoubledPawn:  50,
+		StuckedPawn:  30,
+		IsolatedPawn: 20,
+		BishopPair:   30,
+
+		PosPawn: []int{
+			0, 0, 0, 0, 0, 0, 0, 0,
+			50, 50, 50, 50, 50, 50, 50, 50,
+			10, 10, 20, 30, 30, 20, 10, 10,
+			5, 5, 10, 25, 25, 10, 5, 5,
+			0, 0, 0, 20, 20, 0, 0, 0,
+			5, -5, -10, 0, 0, -10, -5, 5,
+			5, 10, 10, -20, -20, 10, 10, 5,
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+		PosKnight: []int{
+			-50, -40, -30, -30, -30, -30, -40, -50,
+			-40, -20, 0, 0, 0, 0, -20, -40,
+			-30, 0, 10, 15, 15, 10, 0, -30,
+			-30, 5, 15, 20, 20, 15, 5, -30,
+			-30, 0, 15, 20, 20, 15, 0, -30,
+			-30, 5, 10, 15, 15, 10, 5, -30,
+			-40, -20, 0, 5, 5, 0, -20, -40,
+			-50, -40, -30, -30, -30, -30, -40, -50,
+		},
+		PosBishop: []int{
+			-20, -10, -10, -10, -10, -10, -10, -20,
+			-10, 0, 0, 0, 0, 0, 0, -10,
+			-10, 0, 5, 10, 10, 5, 0, -10,
+			-10, 5, 5, 10, 10, 5, 5, -10,
+			-10, 0, 10, 10, 10, 10, 0, -10,
+			-10, 10, 10, 10, 10, 10, 10, -10,
+			-10, 5, 0, 0, 0, 0, 5, -10,
+			-20, -10, -10, -10, -10, -10, -10, -20,
+		},
+		PosRook: []int{
+			0, 0, 0, 0, 0, 0, 0, 0,
+			5, 10, 10, 10, 10, 10, 10, 5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			0, 0, 0, 5, 5, 0, 0, 0,
+		},
+		PosQueen: []int{
+			-20, -10, -10, -5, -5, -10, -10, -20,
+			-10, 0, 0, 0, 0, 0, 0, -10,
+			-10, 0, 5, 5, 5, 5, 0, -10,
+			-5, 0, 5, 5, 5, 5, 0, -5,
+			0, 0, 5, 5, 5, 5, 0, -5,
+			-10, 5, 5, 5, 5, 5, 0, -10,
+			-10, 0, 5, 0, 0, 0, 0, -10,
+			-20, -10, -10, -5, -5, -10, -10, -20,
+		},
+		PosKingMiddle: []int{
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-20, -30, -30, -40, -40, -30, -30, -20,
+			-10, -20, -20, -20, -20, -20, -20, -10,
+			20, 20, 0, 0, 0, 0, 20, 20,
+			20, 30, 10, 0, 0, 10, 30, 20,
+		},
+		PosKingEnd: []int{
+			-50, -40, -30, -20, -20, -30, -40, -50,
+			-30, -20, -10, 0, 0, -10, -20, -30,
+			-30, -10, 20, 30, 30, 20, -10, -30,
+			-30, -10, 30, 40, 40, 30, -10, -30,
+			-30, -10, 30, 40, 40, 30, -10, -30,
+			-30, -10, 20, 30, 30, 20, -10, -30,
+			-30, -30, 0, 0, 0, 0, -30, -30,
+			-50, -30, -30, -30, -30, -30, -30, -50,
+		},
+	}
 }
 
-var PosKingEnd = []int{
-	-50, -40, -30, -20, -20, -30, -40, -50,
-	-30, -20, -10, 0, 0, -10, -20, -30,
-	-30, -10, 20, 30, 30, 20, -10, -30,
-	-30, -10, 30, 40, 40, 30, -10, -30,
-	-30, -10, 30, 40, 40, 30, -10, -30,
-	-30, -10, 20, 30, 30, 20, -10, -30,
-	-30, -30, 0, 0, 0, 0, -30, -30,
-	-50, -30, -30, -30, -30, -30, -30, -50,
+// Evaluate evalúa la posición con el evaluador indicado. Los finales conocidos (KPK, KQK,
+// KRK, KBNK) tienen su propia evaluación, y en los que tienden a tablas se escala la del
+// evaluador.
+func (b *Board) Evaluate(e Evaluator) int {
+	if b.Variant != Standard {
+		return b.evaluateVariant(e)
+	}
+	if score, ok := b.evaluateEndgame(); ok {
+		return score
+	}
+	score := e.Evaluate(b)
+	return score * b.scaleFactor(score) / scaleNormal
 }
 
-func (b *Board) Evaluate() int {
+// Evaluate implementa Evaluator con la evaluación clásica de material y posición.
+func (p *EvalParams) Evaluate(b *Board) int {
 	// Suma simple de material: (blancas - negras)
 	// Usa popcount para cada bitboard y multiplica por el valor de la pieza.
-	scoreWhite := b.WhitePieces.evalMaterial() + b.WhitePieces.evalPositions(true)
-	scoreBlack := b.BlackPieces.evalMaterial() + b.BlackPieces.evalPositions(false)
+	scoreWhite := b.WhitePieces.evalMaterial(p) + b.WhitePieces.evalPositions(p, true)
+	scoreBlack := b.BlackPieces.evalMaterial(p) + b.BlackPieces.evalPositions(p, false)
 
 	return scoreWhite - scoreBlack
 }
 
-func (p *Pieces) evalMaterial() int {
+func (p *Pieces) evalMaterial(params *EvalParams) int {
 	material := 0
 
-	material += bitsOnesCount(p.Pawns) * CpPawn
-	material += bitsOnesCount(p.Knights) * CpKnight
-	material += bitsOnesCount(p.Bishops) * CpBishop
-	material += bitsOnesCount(p.Rooks) * CpRook
-	material += bitsOnesCount(p.Queens) * CpQueen
-	material += bitsOnesCount(p.King) * CpKing
+	material += bitsOnesCount(p.Pawns) * params.Pawn
+	material += bitsOnesCount(p.Knights) * params.Knight
+	material += bitsOnesCount(p.Bishops) * params.Bishop
+	material += bitsOnesCount(p.Rooks) * params.Rook
+	material += bitsOnesCount(p.Queens) * params.Queen
 
 	return material
 }
@@ -125,7 +168,7 @@ func bitsOnesCount(bb uint64) int {
 	return bits.OnesCount64(bb)
 }
 
-func (p *Pieces) evalPositions(isWhite bool) int {
+func (p *Pieces) evalPositions(params *EvalParams, isWhite bool) int {
 	score := 0
 
 	// Helper to accumulate score from a bitboard and its piece-square table.
@@ -142,15 +185,15 @@ func (p *Pieces) evalPositions(isWhite bool) int {
 		}
 	}
 
-	accumulate(p.Pawns, PosPawn)
-	accumulate(p.Knights, PosKnight)
-	accumulate(p.Bishops, PosBishop)
-	accumulate(p.Rooks, PosRook)
-	accumulate(p.Queens, PosQueen)
+	accumulate(p.Pawns, params.PosPawn)
+	accumulate(p.Knights, params.PosKnight)
+	accumulate(p.Bishops, params.PosBishop)
+	accumulate(p.Rooks, params.PosRook)
+	accumulate(p.Queens, params.PosQueen)
 
 	// Para el rey usamos siempre la tabla de medio juego por ahora.
 	// (Una heurística de final podría añadirse más adelante detectando material reducido.)
-	accumulate(p.King, PosKingMiddle)
+	accumulate(p.King, params.PosKingMiddle)
 
 	return score
 }
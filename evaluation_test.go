@@ -1,6 +1,8 @@
 package melange
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -8,74 +10,116 @@ import (
 
 func TestEvalMaterial(t *testing.T) {
 	board := NewBoard()
+	p := DefaultEvalParams()
 
-	totalMaterial := CpPawn*8 + CpKnight*2 + CpBishop*2 + CpRook*2 + CpQueen + CpKing
+	totalMaterial := p.Pawn*8 + p.Knight*2 + p.Bishop*2 + p.Rook*2 + p.Queen
 
-	assert.Equal(t, board.WhitePieces.evalMaterial(), totalMaterial)
-	assert.Equal(t, board.BlackPieces.evalMaterial(), totalMaterial)
-	assert.Equal(t, board.Evaluate(), 0)
+	assert.Equal(t, board.WhitePieces.evalMaterial(p), totalMaterial)
+	assert.Equal(t, board.BlackPieces.evalMaterial(p), totalMaterial)
+	assert.Equal(t, board.Evaluate(p), 0)
 }
 
 func TestEvalMaterial2(t *testing.T) {
 	board := NewBoard()
 	board.SetFen("8/2p5/3p4/KP5r/1R3p1k/8/4P13/8 w - - 0 1 ")
 
-	totalMaterialWhite := CpPawn*2 + CpRook + CpKing
-	totalMaterialBlack := CpPawn*3 + CpRook + CpKing
+	p := DefaultEvalParams()
 
-	assert.Equal(t, board.WhitePieces.evalMaterial(), totalMaterialWhite)
-	assert.Equal(t, board.BlackPieces.evalMaterial(), totalMaterialBlack)
+	totalMaterialWhite := p.Pawn*2 + p.Rook
+	totalMaterialBlack := p.Pawn*3 + p.Rook
+
+	assert.Equal(t, board.WhitePieces.evalMaterial(p), totalMaterialWhite)
+	assert.Equal(t, board.BlackPieces.evalMaterial(p), totalMaterialBlack)
 }
 
 func TestEvalMaterial3(t *testing.T) {
 	board := NewBoard()
 	board.SetFen("r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1")
 
-	totalMaterialWhite := CpPawn*8 + CpKnight*2 + CpBishop*2 + CpRook*2 + CpQueen + CpKing
-	totalMaterialBlack := CpPawn*7 + CpKnight*2 + CpBishop*2 + CpRook*2 + CpQueen + CpKing
+	p := DefaultEvalParams()
+
+	totalMaterialWhite := p.Pawn*8 + p.Knight*2 + p.Bishop*2 + p.Rook*2 + p.Queen
+	totalMaterialBlack := p.Pawn*7 + p.Knight*2 + p.Bishop*2 + p.Rook*2 + p.Queen
 
-	assert.Equal(t, board.WhitePieces.evalMaterial(), totalMaterialWhite)
-	assert.Equal(t, board.BlackPieces.evalMaterial(), totalMaterialBlack)
+	assert.Equal(t, board.WhitePieces.evalMaterial(p), totalMaterialWhite)
+	assert.Equal(t, board.BlackPieces.evalMaterial(p), totalMaterialBlack)
 }
 
 func TestEvalPosition(t *testing.T) {
 	board := NewBoard()
-	assert.Equal(t, board.WhitePieces.evalPositions(true), -95)
-	assert.Equal(t, board.BlackPieces.evalPositions(false), -95)
+	p := DefaultEvalParams()
+	assert.Equal(t, board.WhitePieces.evalPositions(p, true), -95)
+	assert.Equal(t, board.BlackPieces.evalPositions(p, false), -95)
 }
 
 func TestEvalPosition2(t *testing.T) {
 	board := NewBoard()
 	board.SetFen("8/2p5/3p4/KP5r/1R3p1k/8/4P13/8 w - - 0 1 ")
 
-	scoreWhite := PosPawn[toIdxSym(B5)] + PosPawn[toIdxSym(E2)] + PosRook[toIdxSym(B4)] + PosKingMiddle[toIdxSym(A5)]
-	scoreBlack := PosPawn[toIdx(C7)] + PosPawn[toIdx(D6)] + PosPawn[toIdx(F4)] + PosRook[toIdx(H5)] + PosKingMiddle[toIdx(H4)]
+	p := DefaultEvalParams()
+
+	scoreWhite := p.PosPawn[toIdxSym(B5)] + p.PosPawn[toIdxSym(E2)] + p.PosRook[toIdxSym(B4)] + p.PosKingMiddle[toIdxSym(A5)]
+	scoreBlack := p.PosPawn[toIdx(C7)] + p.PosPawn[toIdx(D6)] + p.PosPawn[toIdx(F4)] + p.PosRook[toIdx(H5)] + p.PosKingMiddle[toIdx(H4)]
 
-	assert.Equal(t, board.WhitePieces.evalPositions(true), scoreWhite)
-	assert.Equal(t, board.BlackPieces.evalPositions(false), scoreBlack)
+	assert.Equal(t, board.WhitePieces.evalPositions(p, true), scoreWhite)
+	assert.Equal(t, board.BlackPieces.evalPositions(p, false), scoreBlack)
 }
 
 func TestEvalPosition3(t *testing.T) {
 	board := NewBoard()
 	board.SetFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - ")
+	p := DefaultEvalParams()
 
-	assert.Equal(t, board.WhitePieces.evalPositions(true), 130)
-	assert.Equal(t, board.BlackPieces.evalPositions(false), 25)
+	assert.Equal(t, board.WhitePieces.evalPositions(p, true), 130)
+	assert.Equal(t, board.BlackPieces.evalPositions(p, false), 25)
 }
 
 func TestFullEval(t *testing.T) {
 	board := NewBoard()
-	assert.Equal(t, board.Evaluate(), 0)
+	assert.Equal(t, board.Evaluate(DefaultEvalParams()), 0)
 }
 
 func TestFullEval2(t *testing.T) {
 	board := NewBoard()
 	board.SetFen("8/2p5/3p4/KP5r/1R3p1k/8/4P13/8 w - - 0 1 ")
-	assert.Equal(t, board.Evaluate(), -130)
+	assert.Equal(t, board.Evaluate(DefaultEvalParams()), -130)
 }
 
 func TestFullEval3(t *testing.T) {
 	board := NewBoard()
 	board.SetFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - ")
-	assert.Equal(t, board.Evaluate(), 105)
+	assert.Equal(t, board.Evaluate(DefaultEvalParams()), 105)
+}
+
+func TestEvalParamsJSONRoundTrip(t *testing.T) {
+	p := DefaultEvalParams()
+	p.Knight = 300
+	p.PosRook[0] = 7
+
+	var buf bytes.Buffer
+	assert.NilError(t, p.Write(&buf))
+	loaded, err := ReadEvalParams(&buf)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, loaded, p)
+}
+
+func TestEvalParamsPartialJSON(t *testing.T) {
+	// Los campos ausentes conservan los valores por defecto
+	p, err := ReadEvalParams(strings.NewReader(`{"pawn": 120}`))
+	assert.NilError(t, err)
+	assert.Equal(t, p.Pawn, 120)
+	assert.Equal(t, p.Knight, DefaultEvalParams().Knight)
+
+	board := NewBoard()
+	board.SetFen("4k3/8/8/8/8/8/4P3/4K3 w - - 0 1")
+	def := DefaultEvalParams()
+	// KPK tiene evaluación propia en Board.Evaluate: se compara la evaluación clásica
+	assert.Equal(t, p.Evaluate(board)-def.Evaluate(board), 20)
+}
+
+func TestEvalParamsInvalidJSON(t *testing.T) {
+	_, err := ReadEvalParams(strings.NewReader(`{"pos_pawn": [1, 2, 3]}`))
+	assert.ErrorContains(t, err, "pos_pawn")
+	_, err = ReadEvalParams(strings.NewReader(`{"pawnn": 100}`))
+	assert.ErrorContains(t, err, "pawnn")
 }
@@ -1,6 +1,11 @@
 package melange
 
-import "fmt"
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
 
 // SetFEN inicializa el tablero a partir de un string FEN
 func (b *Board) SetFen(fen string) error {
@@ -8,16 +13,41 @@ func (b *Board) SetFen(fen string) error {
 	b.WhitePieces = Pieces{}
 	b.BlackPieces = Pieces{}
 
+	fields := splitFEN(fen)
+	// Three-check: jaques que faltan para ganar, como campo propio tras la casilla al paso
+	// ("3+3") o al final de la FEN ("+0+0", los ya dados)
+	b.Checks = [2]uint8{}
+	if len(fields) > 4 && strings.Contains(fields[4], "+") {
+		if err := b.setChecks(fields[4], true); err != nil {
+			return err
+		}
+		fields = append(fields[:4], fields[5:]...)
+	} else if last := fields[len(fields)-1]; len(fields) > 4 && strings.HasPrefix(last, "+") {
+		if err := b.setChecks(last[1:], false); err != nil {
+			return err
+		}
+		fields = fields[:len(fields)-1]
+	}
 	parts := make([]string, 6)
-	n := copy(parts, splitFEN(fen))
+	n := copy(parts, fields)
 	if n < 4 {
 		return fmt.Errorf("FEN inválido: %s", fen)
 	}
 
+	// Crazyhouse: reserva y piezas coronadas (marcadas con '~')
+	b.Hands = [2]Hand{}
+	b.Promoted = 0
+	placement, pocket, ok := cutPocket(parts[0])
+	if ok {
+		if err := b.setPocket(pocket); err != nil {
+			return err
+		}
+	}
+
 	// Parse piezas
 	rank := 7
 	file := 0
-	for _, ch := range parts[0] {
+	for _, ch := range placement {
 		if ch == '/' {
 			rank--
 			file = 0
@@ -27,6 +57,10 @@ func (b *Board) SetFen(fen string) error {
 			file += int(ch - '0')
 			continue
 		}
+		if ch == '~' && file > 0 {
+			b.Promoted |= uint64(1) << (rank*8 + file - 1)
+			continue
+		}
 		sq := uint64(1) << (rank*8 + file)
 		switch ch {
 		case 'P':
@@ -63,21 +97,8 @@ func (b *Board) SetFen(fen string) error {
 	b.WhiteToMove = parts[1] == "w"
 
 	// Parse derechos de enroque
-	b.Castling = 0
-	for _, ch := range parts[2] {
-		switch ch {
-		case 'K':
-			b.Castling |= WhiteKingSide
-		case 'Q':
-			b.Castling |= WhiteQueenSide
-		case 'k':
-			b.Castling |= BlackKingSide
-		case 'q':
-			b.Castling |= BlackQueenSide
-		case '-':
-		default:
-			return fmt.Errorf("FEN inválido: derecho de enroque desconocido '%c'", ch)
-		}
+	if err := b.setCastling(parts[2]); err != nil {
+		return err
 	}
 
 	// Parse en passant
@@ -101,6 +122,10 @@ func (b *Board) SetFen(fen string) error {
 	} else {
 		b.FullMove = 1
 	}
+	b.syncSquares()
+	if b.nnue != nil {
+		b.nnue.refresh(b)
+	}
 	return nil
 }
 
@@ -132,3 +157,185 @@ func parseFENSquare(sq string) (int, error) {
 	}
 	return rank*8 + file, nil
 }
+
+// Fen devuelve la posición en notación FEN
+func (b *Board) Fen() string {
+	fen := ""
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			piece, isWhite := b.PieceAtSquare(uint64(1) << (rank*8 + file))
+			if piece == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fen += fmt.Sprint(empty)
+				empty = 0
+			}
+			ch := " pnbrqk"[piece]
+			if isWhite {
+				ch -= 'a' - 'A'
+			}
+			fen += string(ch)
+			if b.Promoted&(uint64(1)<<(rank*8+file)) != 0 {
+				fen += "~"
+			}
+		}
+		if empty > 0 {
+			fen += fmt.Sprint(empty)
+		}
+		if rank > 0 {
+			fen += "/"
+		}
+	}
+	if b.Variant == Crazyhouse {
+		fen += "[" + b.pocketString() + "]"
+	}
+
+	if b.WhiteToMove {
+		fen += " w "
+	} else {
+		fen += " b "
+	}
+
+	fen += b.castlingString()
+
+	if b.EnPassant != 0 {
+		fen += " " + squareToString(b.EnPassant)
+	} else {
+		fen += " -"
+	}
+	if b.Variant == ThreeCheck {
+		fen += fmt.Sprintf(" %d+%d", maxChecks-min(b.Checks[0], maxChecks), maxChecks-min(b.Checks[1], maxChecks))
+	}
+	return fmt.Sprintf("%s %d %d", fen, b.HalfMove, b.FullMove)
+}
+
+// setChecks interpreta los jaques de Three-check, "blancas+negras": los que faltan para
+// ganar si remaining o los ya dados si no.
+func (b *Board) setChecks(field string, remaining bool) error {
+	white, black, ok := strings.Cut(field, "+")
+	w, errW := strconv.Atoi(white)
+	k, errB := strconv.Atoi(black)
+	if !ok || errW != nil || errB != nil || w < 0 || w > maxChecks || k < 0 || k > maxChecks {
+		return fmt.Errorf("FEN inválido: jaques '%s'", field)
+	}
+	if remaining {
+		w, k = maxChecks-w, maxChecks-k
+	}
+	b.Checks = [2]uint8{uint8(w), uint8(k)}
+	return nil
+}
+
+// setCastling interpreta el campo de enroques en cualquiera de sus formas: KQkq, X-FEN (K
+// y Q son la torre más exterior de ese lado del rey; una letra de columna elige otra) y
+// Shredder-FEN (solo letras de columna, en mayúsculas las blancas).
+func (b *Board) setCastling(field string) error {
+	b.Castling = 0
+	b.castlingRooks = [4]uint8{}
+	for _, ch := range field {
+		if ch == '-' {
+			continue
+		}
+		isWhite := ch >= 'A' && ch <= 'Z'
+		lower := ch | 0x20
+		rank, king := 0, b.BlackPieces.King
+		if isWhite {
+			king = b.WhitePieces.King
+		} else {
+			rank = 7
+		}
+		kingSq := bits.TrailingZeros64(king)
+		kingOnRank := king != 0 && kingSq/8 == rank
+
+		var kingSide bool
+		var rookSq int
+		switch {
+		case lower == 'k' || lower == 'q':
+			kingSide = lower == 'k'
+			sq, ok := b.outerRook(isWhite, kingSide)
+			if !ok {
+				// Sin torre se respeta el derecho con la casilla del ajedrez normal
+				sq = int(standardCastlingRooks[castlingIndex(isWhite, kingSide)])
+			}
+			rookSq = sq
+		case lower >= 'a' && lower <= 'h':
+			if !kingOnRank {
+				return fmt.Errorf("FEN inválido: enroque '%c' sin rey en su fila", ch)
+			}
+			rookSq = rank*8 + int(lower-'a')
+			kingSide = rookSq > kingSq
+		default:
+			return fmt.Errorf("FEN inválido: derecho de enroque desconocido '%c'", ch)
+		}
+		right := CastleRights(1) << castlingIndex(isWhite, kingSide)
+		b.Castling |= right
+		b.setCastlingRook(right, uint8(rookSq))
+	}
+	return nil
+}
+
+// castlingIndex es la posición del derecho de enroque en CastleRights.
+func castlingIndex(isWhite, kingSide bool) int {
+	i := 0
+	if !kingSide {
+		i = 1
+	}
+	if !isWhite {
+		i += 2
+	}
+	return i
+}
+
+// outerRook busca la torre más alejada del rey en su fila, del lado indicado.
+func (b *Board) outerRook(isWhite, kingSide bool) (int, bool) {
+	rank, pieces := 0, &b.WhitePieces
+	if !isWhite {
+		rank, pieces = 7, &b.BlackPieces
+	}
+	kingSq := bits.TrailingZeros64(pieces.King)
+	if pieces.King == 0 || kingSq/8 != rank {
+		return 0, false
+	}
+	if kingSide {
+		for sq := rank*8 + 7; sq > kingSq; sq-- {
+			if pieces.Rooks&(uint64(1)<<sq) != 0 {
+				return sq, true
+			}
+		}
+	} else {
+		for sq := rank * 8; sq < kingSq; sq++ {
+			if pieces.Rooks&(uint64(1)<<sq) != 0 {
+				return sq, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// castlingString escribe los derechos de enroque en X-FEN: KQkq salvo que la torre no sea
+// la más exterior de su lado, caso en que se da su columna. En el ajedrez normal coincide
+// con el FEN de siempre.
+func (b *Board) castlingString() string {
+	castling := ""
+	for i, letter := range "KQkq" {
+		right := CastleRights(1) << i
+		if b.Castling&right == 0 {
+			continue
+		}
+		isWhite := i < 2
+		rookSq := int(b.castlingRook(right))
+		if sq, ok := b.outerRook(isWhite, i%2 == 0); ok && sq != rookSq {
+			letter = 'a' + rune(rookSq%8)
+			if isWhite {
+				letter -= 'a' - 'A'
+			}
+		}
+		castling += string(letter)
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	return castling
+}
@@ -0,0 +1,23 @@
+package melange
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFenRoundTrip(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 b kq - 12 40",
+	}
+	for _, fen := range fens {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(fen))
+		assert.Equal(t, b.Fen(), fen)
+	}
+	assert.Equal(t, NewBoard().Fen(), fens[0])
+}
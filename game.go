@@ -0,0 +1,126 @@
+package melange
+
+import "math/bits"
+
+// GameResult es el estado de una partida desde el punto de vista de las reglas.
+type GameResult int
+
+const (
+	Ongoing GameResult = iota
+	WhiteWins
+	BlackWins
+	Draw
+)
+
+func (r GameResult) String() string {
+	switch r {
+	case WhiteWins:
+		return "1-0"
+	case BlackWins:
+		return "0-1"
+	case Draw:
+		return "1/2-1/2"
+	default:
+		return "*"
+	}
+}
+
+// Game lleva una partida: la posición actual, los movimientos jugados y las posiciones
+// anteriores para detectar repeticiones.
+type Game struct {
+	Board   *Board
+	Moves   MoveList
+	history []uint64 // Claves Zobrist de las posiciones, incluida la actual
+}
+
+// NewGame empieza una partida desde la posición indicada (que no se modifica).
+func NewGame(start *Board) *Game {
+	b := start.Clone()
+	return &Game{Board: b, history: []uint64{b.Hash()}}
+}
+
+// Play aplica un movimiento legal.
+func (g *Game) Play(m Move) {
+	b := g.Board
+	b.MovePiece(m, b.WhiteToMove)
+	g.Moves.Add(m)
+	g.history = append(g.history, b.Hash())
+}
+
+// LegalMoves devuelve los movimientos legales de la posición actual.
+func (g *Game) LegalMoves() MoveList {
+	return g.Board.strictLegalMoves()
+}
+
+// strictLegalMoves filtra GetLegalMoves dejando solo los movimientos que no dejan al rey
+// propio en jaque.
+func (b *Board) strictLegalMoves() MoveList {
+	var moves MoveList
+	var buf [maxMoves]Move
+	for _, m := range b.GenerateMoves(buf[:0]) {
+		if b.isMoveLegal(m) {
+			moves.Add(m)
+		}
+	}
+	return moves
+}
+
+// Status devuelve el resultado de la partida según las reglas (las de la variante, mate,
+// ahogado, regla de los cincuenta movimientos, triple repetición y material insuficiente)
+// y el motivo.
+func (g *Game) Status() (GameResult, string) {
+	b := g.Board
+	if result, reason, over := b.VariantOutcome(); over {
+		return result, reason
+	}
+	if len(g.LegalMoves()) == 0 {
+		return b.noMovesResult()
+	}
+	if b.HalfMove >= 100 {
+		return Draw, "fifty-move rule"
+	}
+	if g.Repetitions() >= 3 {
+		return Draw, "threefold repetition"
+	}
+	if b.Variant == Standard && b.InsufficientMaterial() {
+		return Draw, "insufficient material"
+	}
+	return Ongoing, ""
+}
+
+// Repetitions devuelve cuántas veces se ha dado la posición actual en la partida.
+func (g *Game) Repetitions() int {
+	current := g.history[len(g.history)-1]
+	count := 0
+	for _, key := range g.history {
+		if key == current {
+			count++
+		}
+	}
+	return count
+}
+
+// History devuelve las claves Zobrist de las posiciones anteriores a la actual, de la
+// más antigua a la más reciente, para pasarlas a Searcher.GameHistory.
+func (g *Game) History() []uint64 {
+	return g.history[:len(g.history)-1]
+}
+
+// InsufficientMaterial indica si ningún bando puede dar mate: rey contra rey, rey y pieza
+// menor contra rey, o reyes con alfiles todos del mismo color.
+func (b *Board) InsufficientMaterial() bool {
+	w, k := &b.WhitePieces, &b.BlackPieces
+	if w.Pawns|w.Rooks|w.Queens|k.Pawns|k.Rooks|k.Queens != 0 {
+		return false
+	}
+	minors := bits.OnesCount64(w.Knights | w.Bishops | k.Knights | k.Bishops)
+	if minors <= 1 {
+		return true
+	}
+	if w.Knights|k.Knights != 0 {
+		return false
+	}
+	const darkSquares uint64 = 0xAA55AA55AA55AA55
+	bishops := w.Bishops | k.Bishops
+	return bishops&darkSquares == 0 || bishops&^darkSquares == 0
+}
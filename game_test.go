@@ -0,0 +1,81 @@
+package melange
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func gameFromFen(t *testing.T, fen string) *Game {
+	b := &Board{}
+	assert.NilError(t, b.SetFen(fen))
+	return NewGame(b)
+}
+
+func playUCI(t *testing.T, g *Game, moves ...string) {
+	for _, uci := range moves {
+		m, ok := parseUCIMove(g.Board, uci)
+		assert.Assert(t, ok, uci)
+		g.Play(m)
+	}
+}
+
+func TestGameStatusCheckmate(t *testing.T) {
+	g := NewGame(NewBoard())
+	playUCI(t, g, "f2f3", "e7e5", "g2g4", "d8h4")
+	result, reason := g.Status()
+	assert.Equal(t, result, BlackWins)
+	assert.Equal(t, reason, "checkmate")
+	assert.Equal(t, result.String(), "0-1")
+}
+
+func TestGameStatusStalemate(t *testing.T) {
+	g := gameFromFen(t, "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1")
+	result, reason := g.Status()
+	assert.Equal(t, result, Draw)
+	assert.Equal(t, reason, "stalemate")
+}
+
+func TestGameStatusRepetition(t *testing.T) {
+	g := NewGame(NewBoard())
+	playUCI(t, g, "g1f3", "g8f6", "f3g1", "f6g8", "g1f3", "g8f6", "f3g1")
+	result, _ := g.Status()
+	assert.Equal(t, result, Ongoing)
+	assert.Equal(t, g.Repetitions(), 2)
+	assert.Equal(t, len(g.History()), 7)
+	assert.Equal(t, g.History()[0], NewBoard().Hash())
+	playUCI(t, g, "f6g8")
+	result, reason := g.Status()
+	assert.Equal(t, result, Draw)
+	assert.Equal(t, reason, "threefold repetition")
+}
+
+func TestGameClocks(t *testing.T) {
+	g := NewGame(NewBoard())
+	playUCI(t, g, "e2e4", "g8f6", "g1f3")
+	assert.Equal(t, g.Board.HalfMove, uint32(2))
+	assert.Equal(t, g.Board.FullMove, uint32(2))
+
+	g = gameFromFen(t, "4k3/8/8/8/8/8/8/R3K3 w - - 99 80")
+	playUCI(t, g, "a1a2")
+	result, reason := g.Status()
+	assert.Equal(t, result, Draw)
+	assert.Equal(t, reason, "fifty-move rule")
+}
+
+func TestInsufficientMaterial(t *testing.T) {
+	cases := map[string]bool{
+		"4k3/8/8/8/8/8/8/4K3 w - - 0 1":    true,
+		"4k3/8/8/8/8/8/8/2B1K3 w - - 0 1":  true,
+		"4k3/8/8/8/8/8/8/1N2K3 w - - 0 1":  true,
+		"2b1k3/8/8/8/8/8/8/3BK3 w - - 0 1": true,  // Alfiles en casillas claras
+		"3bk3/8/8/8/8/8/8/3BK3 w - - 0 1":  false, // Alfiles de distinto color
+		"4k3/8/8/8/8/8/8/1NN1K3 w - - 0 1": false,
+		"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1":  false,
+	}
+	for fen, expected := range cases {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(fen))
+		assert.Equal(t, b.InsufficientMaterial(), expected, fen)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+	melange "zentense/melange"
+)
+
+// runDatagen implements the "datagen" command: self-play games to generate training data.
+func runDatagen(args []string) error {
+	fs := flag.NewFlagSet("datagen", flag.ContinueOnError)
+	games := fs.Int("games", 100, "number of self-play games")
+	depth := fs.Int("depth", 4, "fixed search depth per move (ignored if -nodes is set)")
+	nodes := fs.Int64("nodes", 0, "fixed number of nodes per move")
+	workers := fs.Int("workers", runtime.NumCPU(), "games played in parallel")
+	book := fs.String("book", "", "opening book with one FEN/EPD per line")
+	randomPlies := fs.Int("random", 8, "random plies played after the opening")
+	maxPlies := fs.Int("maxplies", 400, "games longer than this many plies after the opening are scored as draws")
+	adjudicate := fs.Int("adjudicate", 2500, "adjudicate a win when |score| exceeds this (0 disables)")
+	maxScore := fs.Int("maxscore", 2000, "do not record positions with |score| above this (0 records all)")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "random seed")
+	format := fs.String("format", "text", "output format: text or binary")
+	out := fs.String("out", "", "output file (default stdout)")
+	evalFile := fs.String("evalfile", "", "evaluation parameters in JSON")
+	nnueFile := fs.String("nnue", "", "NNUE network, used instead of the classical evaluation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := melange.DatagenConfig{
+		Games:            *games,
+		Workers:          *workers,
+		Depth:            *depth,
+		Nodes:            *nodes,
+		Seed:             *seed,
+		RandomPlies:      *randomPlies,
+		MaxPlies:         *maxPlies,
+		AdjudicateScore:  *adjudicate,
+		MaxRecordedScore: *maxScore,
+		Format:           *format,
+	}
+	if *book != "" {
+		positions, err := melange.LoadBook(*book)
+		if err != nil {
+			return err
+		}
+		cfg.Book = positions
+	}
+	switch {
+	case *nnueFile != "":
+		net, err := melange.LoadNetwork(*nnueFile)
+		if err != nil {
+			return err
+		}
+		cfg.Evaluator = net
+	case *evalFile != "":
+		params, err := melange.LoadEvalParams(*evalFile)
+		if err != nil {
+			return err
+		}
+		cfg.Evaluator = params
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	start := time.Now()
+	stats, err := melange.GenerateTrainingData(cfg, w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "games %d (+%d =%d -%d) positions %d time %s\n",
+		stats.Games, stats.WhiteWins, stats.Draws, stats.BlackWins, stats.Positions, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+	melange "zentense/melange"
+)
+
+// runEPD implements the "epd" command: search every position of an EPD test suite (WAC,
+// ECM, STS...) and report which ones are solved.
+func runEPD(args []string) error {
+	fs := flag.NewFlagSet("epd", flag.ContinueOnError)
+	depth := fs.Int("depth", 0, "fixed search depth per position (default: acd, or 8)")
+	movetime := fs.Int("movetime", 0, "search time per position in milliseconds")
+	nodes := fs.Int64("nodes", 0, "fixed number of nodes per position")
+	threads := fs.Int("threads", 1, "search threads")
+	hash := fs.Int("hash", melange.DefaultHashMB, "transposition table size in MB")
+	evalFile := fs.String("evalfile", "", "evaluation parameters in JSON")
+	nnueFile := fs.String("nnue", "", "NNUE network, used instead of the classical evaluation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: epd [flags] <file.epd>")
+	}
+	suite, err := melange.LoadEPD(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cfg := melange.EPDConfig{
+		Limits: melange.SearchLimits{
+			Depth:    *depth,
+			Nodes:    *nodes,
+			MoveTime: time.Duration(*movetime) * time.Millisecond,
+		},
+		Threads: *threads,
+		HashMB:  *hash,
+	}
+	switch {
+	case *nnueFile != "":
+		net, err := melange.LoadNetwork(*nnueFile)
+		if err != nil {
+			return err
+		}
+		cfg.Evaluator = net
+	case *evalFile != "":
+		params, err := melange.LoadEvalParams(*evalFile)
+		if err != nil {
+			return err
+		}
+		cfg.Evaluator = params
+	}
+	melange.RunEPD(suite, cfg, os.Stdout)
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	melange "zentense/melange"
+)
+
+// optionFlags collects repeated "-option1 Name=Value" flags.
+type optionFlags map[string]string
+
+func (o optionFlags) String() string { return fmt.Sprint(map[string]string(o)) }
+
+func (o optionFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected Name=Value, got %q", s)
+	}
+	o[name] = value
+	return nil
+}
+
+// runMatch implements the "match" command: play two UCI engines against each other, in
+// the style of cutechess-cli.
+func runMatch(args []string) error {
+	fs := flag.NewFlagSet("match", flag.ContinueOnError)
+	engine1 := fs.String("engine1", "", "path to the first engine")
+	engine2 := fs.String("engine2", "", "path to the second engine")
+	name1 := fs.String("name1", "", "name of the first engine (default: its id name)")
+	name2 := fs.String("name2", "", "name of the second engine (default: its id name)")
+	options1, options2 := optionFlags{}, optionFlags{}
+	fs.Var(options1, "option1", "UCI option Name=Value for the first engine (repeatable)")
+	fs.Var(options2, "option2", "UCI option Name=Value for the second engine (repeatable)")
+	games := fs.Int("games", 2, "number of games (rounded up to an even number)")
+	tc := fs.String("tc", "", "time control: [moves/]seconds[+increment], e.g. 10+0.1")
+	depth := fs.Int("depth", 0, "fixed search depth per move")
+	nodes := fs.Int64("nodes", 0, "fixed number of nodes per move")
+	margin := fs.Int("timemargin", 50, "milliseconds an engine may exceed its clock")
+	openings := fs.String("openings", "", "opening file: EPD/FEN (one position per line) or PGN")
+	concurrency := fs.Int("concurrency", 1, "games played at the same time")
+	pgnOut := fs.String("pgnout", "", "PGN output file")
+	event := fs.String("event", "", "PGN Event tag")
+	sprt := fs.Bool("sprt", false, "stop as soon as a sequential probability ratio test accepts H0 or H1 (-games is then the maximum)")
+	elo0 := fs.Float64("elo0", 0, "SPRT: Elo difference of H0")
+	elo1 := fs.Float64("elo1", 5, "SPRT: Elo difference of H1")
+	alpha := fs.Float64("alpha", 0.05, "SPRT: probability of accepting H1 when H0 is true")
+	beta := fs.Float64("beta", 0.05, "SPRT: probability of accepting H0 when H1 is true")
+	model := fs.String("model", "pentanomial", "SPRT: statistical model, pentanomial (game pairs) or trinomial (games)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *engine1 == "" || *engine2 == "" {
+		return fmt.Errorf("usage: match -engine1 <path> -engine2 <path> [flags]")
+	}
+
+	cfg := melange.MatchConfig{
+		Engines: [2]melange.MatchEngine{
+			{Path: *engine1, Name: *name1, Options: options1},
+			{Path: *engine2, Name: *name2, Options: options2},
+		},
+		Games:       *games,
+		Depth:       *depth,
+		Nodes:       *nodes,
+		TimeMargin:  time.Duration(*margin) * time.Millisecond,
+		Concurrency: *concurrency,
+		Event:       *event,
+	}
+	if *sprt {
+		if *model != "pentanomial" && *model != "trinomial" {
+			return fmt.Errorf("unknown SPRT model: %s", *model)
+		}
+		cfg.SPRT = &melange.SPRT{Elo0: *elo0, Elo1: *elo1, Alpha: *alpha, Beta: *beta, Pentanomial: *model == "pentanomial"}
+	}
+	if *tc != "" {
+		control, err := melange.ParseTimeControl(*tc)
+		if err != nil {
+			return err
+		}
+		cfg.TimeControl = control
+	}
+	if *openings != "" {
+		book, err := melange.LoadOpenings(*openings)
+		if err != nil {
+			return err
+		}
+		cfg.Openings = book
+	}
+	if *pgnOut == "" {
+		_, err := melange.RunMatch(cfg, nil, os.Stdout)
+		return err
+	}
+	f, err := os.Create(*pgnOut)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = melange.RunMatch(cfg, f, os.Stdout)
+	return err
+}
@@ -4,21 +4,69 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
 	melange "zentense/melange"
 )
 
 func main() {
+	// Non-UCI commands given on the command line
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "datagen":
+			err = runDatagen(os.Args[2:])
+		case "epd":
+			err = runEPD(os.Args[2:])
+		case "match":
+			err = runMatch(os.Args[2:])
+		case "xboard":
+			err = runXBoard(bufio.NewScanner(os.Stdin))
+		case "bench":
+			err = runBench(os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command: %s", os.Args[1])
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Main loop listens to standard input
 	fmt.Println("Melange v0.1")
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
 		line := scanner.Text()
+		// GUIs speaking CECP start with 'xboard'; the rest of the session uses that protocol
+		if line == "xboard" {
+			if err := runXBoard(scanner); err != nil {
+				println("Exiting:", err.Error())
+			}
+			return
+		}
 		if melange.ProcessUciCommand(line) {
 			break
 		}
 	}
+	melange.FinishUciSearch()
 	if err := scanner.Err(); err != nil {
 		println("Exiting:", err.Error())
 	}
 
 }
+
+// runBench implements the "bench [depth]" command, used to check that a change does not
+// alter the search (same node count) and to measure its speed.
+func runBench(args []string) error {
+	depth := melange.DefaultBenchDepth
+	if len(args) > 0 {
+		d, err := strconv.Atoi(args[0])
+		if err != nil || d < 1 {
+			return fmt.Errorf("invalid bench depth: %s", args[0])
+		}
+		depth = d
+	}
+	melange.Bench(depth, melange.DefaultEvalParams(), os.Stdout)
+	return nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	melange "zentense/melange"
+)
+
+// runXBoard reads CECP (XBoard) commands until 'quit' or the end of the input.
+func runXBoard(scanner *bufio.Scanner) error {
+	x := melange.NewXBoard(os.Stdout)
+	for scanner.Scan() {
+		if x.Process(scanner.Text()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
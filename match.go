@@ -0,0 +1,417 @@
+package melange
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// noClockTimeout es lo que se espera a un motor que juega sin reloj (por profundidad o
+// nodos) antes de dar la partida por abortada.
+const noClockTimeout = 5 * time.Minute
+
+// TimeControl es un control de tiempo al estilo de cutechess-cli: Moves jugadas en Base
+// (0 = toda la partida) con Increment por jugada.
+type TimeControl struct {
+	Moves     int
+	Base      time.Duration
+	Increment time.Duration
+}
+
+// ParseTimeControl lee controles de tiempo como "40/60", "10+0.1" o "40/120+1", con los
+// tiempos en segundos.
+func ParseTimeControl(s string) (TimeControl, error) {
+	var tc TimeControl
+	rest := s
+	if moves, base, ok := strings.Cut(s, "/"); ok {
+		n, err := strconv.Atoi(moves)
+		if err != nil || n < 1 {
+			return TimeControl{}, fmt.Errorf("control de tiempo inválido: %s", s)
+		}
+		tc.Moves, rest = n, base
+	}
+	base, inc, hasInc := strings.Cut(rest, "+")
+	var err error
+	if tc.Base, err = parseSeconds(base); err != nil || tc.Base <= 0 {
+		return TimeControl{}, fmt.Errorf("control de tiempo inválido: %s", s)
+	}
+	if hasInc {
+		if tc.Increment, err = parseSeconds(inc); err != nil || tc.Increment < 0 {
+			return TimeControl{}, fmt.Errorf("control de tiempo inválido: %s", s)
+		}
+	}
+	return tc, nil
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// String devuelve el control en el formato de la etiqueta TimeControl de PGN.
+func (tc TimeControl) String() string {
+	s := strconv.FormatFloat(tc.Base.Seconds(), 'f', -1, 64)
+	if tc.Moves > 0 {
+		s = fmt.Sprintf("%d/%s", tc.Moves, s)
+	}
+	if tc.Increment > 0 {
+		s += "+" + strconv.FormatFloat(tc.Increment.Seconds(), 'f', -1, 64)
+	}
+	return s
+}
+
+// MatchEngine es uno de los dos motores de un match.
+type MatchEngine struct {
+	Path    string
+	Name    string            // Para el PGN y el informe; vacío = id name del motor
+	Options map[string]string // Opciones UCI que se fijan al arrancar
+}
+
+// MatchConfig configura RunMatch.
+type MatchConfig struct {
+	Engines [2]MatchEngine
+	Games   int // Se redondea a un número par: cada apertura se juega con ambos colores
+
+	// Límites de cada jugada: reloj, profundidad o nodos (se pueden combinar). Sin reloj
+	// no hay pérdidas por tiempo.
+	TimeControl TimeControl
+	Depth       int
+	Nodes       int64
+	TimeMargin  time.Duration // Tiempo que un motor puede pasarse del reloj sin perder
+
+	Openings    []Opening // Se usan en orden; sin aperturas se parte de la posición inicial
+	Concurrency int       // Partidas simultáneas, cada una con su par de procesos (0 = 1)
+	Event       string
+
+	// Si SPRT no es nil el match termina en cuanto el test acepta una de las hipótesis;
+	// Games es entonces el máximo de partidas
+	SPRT *SPRT
+}
+
+// MatchStats son los resultados del match desde el punto de vista del primer motor.
+type MatchStats struct {
+	Wins, Draws, Losses int
+
+	// Pentanomial cuenta las parejas de partidas (misma apertura, colores cambiados)
+	// según los medios puntos que sumó en ellas el primer motor, de 0 a 4
+	Pentanomial [5]int
+
+	// Con SPRT, la razón de verosimilitud logarítmica al final del match y la decisión
+	LLR  float64
+	SPRT SPRTResult
+}
+
+// Games devuelve el número de partidas jugadas.
+func (s MatchStats) Games() int {
+	return s.Wins + s.Draws + s.Losses
+}
+
+// Score devuelve la puntuación media del primer motor (1 = ganó todas).
+func (s MatchStats) Score() float64 {
+	if s.Games() == 0 {
+		return 0.5
+	}
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(s.Games())
+}
+
+// Elo estima la diferencia de Elo del primer motor sobre el segundo y el margen de error
+// con un 95% de confianza, con el modelo trinomial (cada partida es independiente).
+func (s MatchStats) Elo() (elo, margin float64) {
+	n := float64(s.Games())
+	if n == 0 {
+		return 0, 0
+	}
+	score := s.Score()
+	variance := (float64(s.Wins)*math.Pow(1-score, 2) + float64(s.Draws)*math.Pow(0.5-score, 2) +
+		float64(s.Losses)*math.Pow(score, 2)) / n
+	dev := 1.959964 * math.Sqrt(variance/n)
+	return scoreToElo(score), (scoreToElo(min(score+dev, 1)) - scoreToElo(max(score-dev, 0))) / 2
+}
+
+// LOS es la probabilidad de que el primer motor sea más fuerte (likelihood of superiority).
+func (s MatchStats) LOS() float64 {
+	if s.Wins+s.Losses == 0 {
+		return 0.5
+	}
+	return 0.5 * (1 + math.Erf(float64(s.Wins-s.Losses)/math.Sqrt(2*float64(s.Wins+s.Losses))))
+}
+
+// scoreToElo convierte una puntuación media en diferencia de Elo. Las puntuaciones de 0 o
+// 1 dan ±Inf.
+func scoreToElo(score float64) float64 {
+	return -400 * math.Log10(1/score-1)
+}
+
+// matchGame es una partida terminada del match.
+type matchGame struct {
+	round       int
+	firstWhite  bool // El primer motor lleva las blancas
+	pgn         PGNGame
+	termination string
+}
+
+// RunMatch juega el match y escribe cada partida en PGN en pgn (si no es nil) y el
+// progreso en log al estilo de cutechess-cli. Las partidas se juegan por parejas con la
+// misma apertura y los colores cambiados. Un motor que se cuelga, termina o no arranca
+// aborta el match; un movimiento ilegal o pasarse de tiempo pierde la partida. Con SPRT
+// no se empiezan más parejas en cuanto el test decide.
+func RunMatch(cfg MatchConfig, pgn, log io.Writer) (MatchStats, error) {
+	if cfg.TimeControl.Base <= 0 && cfg.Depth <= 0 && cfg.Nodes <= 0 {
+		return MatchStats{}, fmt.Errorf("el match necesita un control de tiempo, una profundidad o un número de nodos")
+	}
+	if cfg.SPRT != nil {
+		if err := cfg.SPRT.Validate(); err != nil {
+			return MatchStats{}, err
+		}
+	}
+	pairs := (cfg.Games + 1) / 2
+	workers := max(1, min(cfg.Concurrency, pairs))
+	var (
+		stats    MatchStats
+		mu       sync.Mutex
+		firstErr error
+		next     atomic.Int64
+		stop     atomic.Bool // El SPRT ha terminado o ha habido un error
+		wg       sync.WaitGroup
+	)
+	// record anota una partida y devuelve los medios puntos del primer motor en ella; con
+	// pairPoints >= 0 además cierra la pareja, que ha sumado esos medios puntos
+	record := func(g matchGame, names [2]string, pairPoints int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		first := g.pgn.Result == WhiteWins
+		if !g.firstWhite {
+			first = g.pgn.Result == BlackWins
+		}
+		points := 0
+		switch {
+		case g.pgn.Result == Draw:
+			stats.Draws++
+			points = 1
+		case first:
+			stats.Wins++
+			points = 2
+		default:
+			stats.Losses++
+		}
+		if pairPoints >= 0 {
+			stats.Pentanomial[pairPoints+points]++
+		}
+		if pgn != nil {
+			if err := g.pgn.WritePGN(pgn); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		fmt.Fprintf(log, "Finished game %d (%s vs %s): %s {%s}\n", g.round, g.pgn.White, g.pgn.Black, g.pgn.Result, g.termination)
+		fmt.Fprintf(log, "Score of %s vs %s: %d - %d - %d  [%.3f] %d\n", names[0], names[1],
+			stats.Wins, stats.Losses, stats.Draws, stats.Score(), stats.Games())
+		if cfg.SPRT != nil {
+			if cfg.SPRT.Pentanomial {
+				p := stats.Pentanomial
+				fmt.Fprintf(log, "Ptnml(0-2): %d, %d, %d, %d, %d\n", p[0], p[1], p[2], p[3], p[4])
+			}
+			// La decisión es la del momento en que se cruza un límite; las partidas que
+			// estaban en curso se anotan pero ya no la cambian
+			llr, result := cfg.SPRT.Test(stats)
+			stats.LLR = llr
+			if stats.SPRT == SPRTContinue && result != SPRTContinue {
+				stats.SPRT = result
+				stop.Store(true)
+			}
+			lower, upper := cfg.SPRT.Bounds()
+			fmt.Fprintf(log, "SPRT: llr %.3g (%.1f%%), lbound %.3g, ubound %.3g\n", llr, 100*llr/upper, lower, upper)
+		}
+		return points
+	}
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		stop.Store(true)
+	}
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var engines [2]*UCIEngine
+			for i, e := range cfg.Engines {
+				engine, err := StartUCIEngine(e.Path, e.Options)
+				if err != nil {
+					fail(err)
+					break
+				}
+				if e.Name != "" {
+					engine.Name = e.Name
+				}
+				engines[i] = engine
+				defer engine.Close()
+			}
+			if engines[1] == nil {
+				return
+			}
+			names := [2]string{engines[0].Name, engines[1].Name}
+			for !stop.Load() {
+				pair := int(next.Add(1)) - 1
+				if pair >= pairs {
+					return
+				}
+				opening := Opening{Fen: startFEN}
+				if len(cfg.Openings) > 0 {
+					opening = cfg.Openings[pair%len(cfg.Openings)]
+				}
+				pairPoints := -1
+				for i := range 2 {
+					round := 2*pair + i + 1
+					white, black := engines[0], engines[1]
+					if i == 1 {
+						white, black = black, white
+					}
+					g, err := playMatchGame(cfg, white, black, opening)
+					if err != nil {
+						fail(fmt.Errorf("partida %d: %w", round, err))
+						return
+					}
+					g.round, g.firstWhite = round, i == 0
+					g.pgn.Round = strconv.Itoa(round)
+					pairPoints = record(g, names, pairPoints)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return stats, firstErr
+	}
+	elo, margin := stats.Elo()
+	fmt.Fprintf(log, "Elo difference: %.1f +/- %.1f, LOS: %.1f %%, DrawRatio: %.1f %%\n",
+		elo, margin, 100*stats.LOS(), 100*float64(stats.Draws)/float64(max(stats.Games(), 1)))
+	if cfg.SPRT != nil {
+		if stats.SPRT == SPRTContinue {
+			fmt.Fprintln(log, "SPRT: no hypothesis accepted")
+		} else {
+			fmt.Fprintf(log, "SPRT: %s was accepted\n", stats.SPRT)
+		}
+	}
+	return stats, nil
+}
+
+// playMatchGame juega una partida desde la apertura hasta que termina según las reglas
+// (Game.Status), un motor juega un movimiento ilegal o se queda sin tiempo.
+func playMatchGame(cfg MatchConfig, white, black *UCIEngine, opening Opening) (matchGame, error) {
+	start := &Board{}
+	if err := start.SetFen(opening.Fen); err != nil {
+		return matchGame{}, err
+	}
+	game := NewGame(start)
+	var uciMoves []string
+	for _, m := range opening.Moves {
+		uciMoves = append(uciMoves, m.UCIString())
+		game.Play(m)
+	}
+	g := matchGame{pgn: PGNGame{
+		Event: cfg.Event, Date: time.Now().Format("2006.01.02"),
+		White: white.Name, Black: black.Name, Fen: opening.Fen,
+	}}
+	if cfg.Event == "" {
+		g.pgn.Event = "Melange match"
+	}
+	tc := cfg.TimeControl
+	hasClock := tc.Base > 0
+	if hasClock {
+		g.pgn.Tags = append(g.pgn.Tags, [2]string{"TimeControl", tc.String()})
+	}
+	for _, e := range []*UCIEngine{white, black} {
+		if err := e.NewGame(); err != nil {
+			return matchGame{}, err
+		}
+	}
+
+	engines := [2]*UCIEngine{white, black}
+	clocks := [2]time.Duration{tc.Base, tc.Base}
+	var played [2]int
+	lose := func(side int, reason string) {
+		g.pgn.Result, g.termination = WhiteWins, reason
+		if side == 0 {
+			g.pgn.Result = BlackWins
+		}
+	}
+	for {
+		if result, reason := game.Status(); result != Ongoing {
+			g.pgn.Result, g.termination = result, reason
+			break
+		}
+		side := colorIndex(game.Board.WhiteToMove)
+		goArgs, timeout := matchGoArgs(cfg, clocks, played[side]), noClockTimeout
+		if hasClock {
+			timeout = clocks[side] + cfg.TimeMargin + time.Second
+		}
+		reply, err := engines[side].Go(opening.Fen, uciMoves, goArgs, timeout)
+		if err != nil {
+			return matchGame{}, err
+		}
+		if hasClock {
+			clocks[side] -= reply.Elapsed
+			if clocks[side] < -cfg.TimeMargin {
+				lose(side, "time forfeit")
+				break
+			}
+			clocks[side] += tc.Increment
+			played[side]++
+			if tc.Moves > 0 && played[side]%tc.Moves == 0 {
+				clocks[side] += tc.Base
+			}
+		}
+		move, ok := NoMove, false
+		for _, m := range game.LegalMoves() {
+			if m.UCIString() == reply.BestMove {
+				move, ok = m, true
+				break
+			}
+		}
+		if !ok {
+			lose(side, "illegal move "+reply.BestMove)
+			break
+		}
+		game.Play(move)
+		uciMoves = append(uciMoves, reply.BestMove)
+	}
+	g.pgn.Moves = game.Moves
+	g.pgn.Tags = append(g.pgn.Tags, [2]string{"Termination", g.termination})
+	return g, nil
+}
+
+// matchGoArgs devuelve los argumentos de go para el bando al mover, que ha jugado ya
+// played movimientos en la partida.
+func matchGoArgs(cfg MatchConfig, clocks [2]time.Duration, played int) string {
+	var args []string
+	if tc := cfg.TimeControl; tc.Base > 0 {
+		args = append(args,
+			"wtime", strconv.FormatInt(max(clocks[0].Milliseconds(), 1), 10),
+			"btime", strconv.FormatInt(max(clocks[1].Milliseconds(), 1), 10))
+		if tc.Increment > 0 {
+			inc := strconv.FormatInt(tc.Increment.Milliseconds(), 10)
+			args = append(args, "winc", inc, "binc", inc)
+		}
+		if tc.Moves > 0 {
+			args = append(args, "movestogo", strconv.Itoa(tc.Moves-played%tc.Moves))
+		}
+	}
+	if cfg.Depth > 0 {
+		args = append(args, "depth", strconv.Itoa(cfg.Depth))
+	}
+	if cfg.Nodes > 0 {
+		args = append(args, "nodes", strconv.FormatInt(cfg.Nodes, 10))
+	}
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,158 @@
+package melange
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// buildEngine compila el ejecutable de melange para jugar matches contra sí mismo.
+func buildEngine(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("compila el motor")
+	}
+	path := filepath.Join(t.TempDir(), "melange")
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	out, err := exec.Command("go", "build", "-o", path, "./main").CombinedOutput()
+	assert.NilError(t, err, string(out))
+	return path
+}
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		s  string
+		tc TimeControl
+	}{
+		{"60", TimeControl{Base: time.Minute}},
+		{"10+0.1", TimeControl{Base: 10 * time.Second, Increment: 100 * time.Millisecond}},
+		{"40/120+1", TimeControl{Moves: 40, Base: 2 * time.Minute, Increment: time.Second}},
+		{"0.5+0.005", TimeControl{Base: 500 * time.Millisecond, Increment: 5 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		tc, err := ParseTimeControl(tt.s)
+		assert.NilError(t, err, tt.s)
+		assert.Equal(t, tc, tt.tc, tt.s)
+		assert.Equal(t, tc.String(), tt.s)
+	}
+	for _, bad := range []string{"", "0", "x+1", "10+", "0/10", "10+-1"} {
+		_, err := ParseTimeControl(bad)
+		assert.Assert(t, err != nil, bad)
+	}
+}
+
+func TestMatchStatsElo(t *testing.T) {
+	s := MatchStats{Wins: 60, Draws: 20, Losses: 20}
+	assert.Equal(t, s.Score(), 0.7)
+	elo, margin := s.Elo()
+	assert.Assert(t, math.Abs(elo-147.2) < 0.1, elo)
+	assert.Assert(t, margin > 50 && margin < 100, margin)
+	assert.Assert(t, s.LOS() > 0.99)
+
+	even := MatchStats{Wins: 10, Draws: 10, Losses: 10}
+	elo, _ = even.Elo()
+	assert.Equal(t, elo, 0.0)
+	assert.Equal(t, even.LOS(), 0.5)
+	// Con pocas partidas el intervalo se sale de [0, 1]
+	_, margin = MatchStats{Wins: 1, Draws: 1}.Elo()
+	assert.Assert(t, math.IsInf(margin, 1))
+	elo, margin = MatchStats{}.Elo()
+	assert.Equal(t, elo+margin, 0.0)
+}
+
+func TestMatchGoArgs(t *testing.T) {
+	cfg := MatchConfig{TimeControl: TimeControl{Moves: 40, Base: time.Minute, Increment: time.Second}, Depth: 3}
+	args := matchGoArgs(cfg, [2]time.Duration{1500 * time.Millisecond, -time.Millisecond}, 41)
+	assert.Equal(t, args, "wtime 1500 btime 1 winc 1000 binc 1000 movestogo 39 depth 3")
+	assert.Equal(t, matchGoArgs(MatchConfig{Nodes: 500}, [2]time.Duration{}, 0), "nodes 500")
+}
+
+func TestMatchAgainstItself(t *testing.T) {
+	engine := buildEngine(t)
+	openings, err := ParsePGN("1. e4 e5 2. Nf3 Nc6 *\n1. d4 d5 *")
+	assert.NilError(t, err)
+	cfg := MatchConfig{
+		Engines: [2]MatchEngine{
+			{Path: engine, Name: "A", Options: map[string]string{"Hash": "1"}},
+			{Path: engine, Options: map[string]string{"Hash": "1"}},
+		},
+		Games:       4,
+		Depth:       1,
+		Openings:    openings,
+		Concurrency: 2,
+	}
+	var pgn, log bytes.Buffer
+	stats, err := RunMatch(cfg, &pgn, &log)
+	assert.NilError(t, err, log.String())
+	assert.Equal(t, stats.Games(), 4)
+	assert.Equal(t, strings.Count(pgn.String(), "[Event "), 4)
+	assert.Assert(t, strings.Contains(pgn.String(), `[White "A"]`))
+	assert.Assert(t, strings.Contains(pgn.String(), `[Black "Melange v0.1"]`))
+	assert.Assert(t, strings.Contains(pgn.String(), "1. e4 e5 2. Nf3 Nc6 3. "))
+	assert.Assert(t, strings.Contains(log.String(), "Score of A vs Melange v0.1: "))
+	assert.Assert(t, strings.Contains(log.String(), "Elo difference: "))
+
+	// Con reloj: la etiqueta TimeControl y ninguna pérdida por tiempo con margen holgado
+	cfg.Games, cfg.Depth, cfg.Concurrency = 2, 2, 1
+	cfg.TimeControl = TimeControl{Base: 20 * time.Second, Increment: 100 * time.Millisecond}
+	cfg.TimeMargin = time.Second
+	pgn.Reset()
+	stats, err = RunMatch(cfg, &pgn, &bytes.Buffer{})
+	assert.NilError(t, err)
+	assert.Equal(t, stats.Games(), 2)
+	assert.Assert(t, strings.Contains(pgn.String(), `[TimeControl "20+0.1"]`))
+	assert.Assert(t, !strings.Contains(pgn.String(), "time forfeit"))
+}
+
+// brokenEngine escribe un motor que contesta al protocolo pero siempre juega a1a1.
+func brokenEngine(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("usa un script de shell como motor")
+	}
+	path := filepath.Join(t.TempDir(), "broken.sh")
+	script := `#!/bin/sh
+while read cmd; do
+	case "$cmd" in
+	uci) echo "id name Broken"; echo uciok ;;
+	isready) echo readyok ;;
+	go*) echo "info depth 1 score cp 5"; echo "bestmove a1a1" ;;
+	quit) exit 0 ;;
+	esac
+done
+`
+	assert.NilError(t, os.WriteFile(path, []byte(script), 0o755))
+	return path
+}
+
+func TestMatchIllegalMove(t *testing.T) {
+	broken := brokenEngine(t)
+	engine := buildEngine(t)
+	cfg := MatchConfig{
+		Engines: [2]MatchEngine{{Path: engine}, {Path: broken}},
+		Games:   2,
+		Depth:   1,
+	}
+	var pgn bytes.Buffer
+	stats, err := RunMatch(cfg, &pgn, &bytes.Buffer{})
+	assert.NilError(t, err)
+	assert.Equal(t, stats, MatchStats{Wins: 2, Pentanomial: [5]int{4: 1}})
+	assert.Equal(t, strings.Count(pgn.String(), `[Termination "illegal move a1a1"]`), 2)
+
+	// Un motor que no existe aborta el match
+	cfg.Engines[1].Path = filepath.Join(t.TempDir(), "missing")
+	_, err = RunMatch(cfg, nil, &bytes.Buffer{})
+	assert.Assert(t, err != nil)
+	_, err = RunMatch(MatchConfig{Engines: cfg.Engines, Games: 2}, nil, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "control de tiempo")
+}
@@ -138,7 +138,7 @@ const (
 	MoveCapture            MoveType = 1
 	MoveKingCastle         MoveType = 2
 	MoveQueenCastle        MoveType = 4
-	MoveDoublePawn         MoveType = 6
+	MoveDrop               MoveType = 6 // Crazyhouse: el origen guarda la pieza soltada (ver NewDrop)
 	MovePromotion          MoveType = 8
 	MoveKnightPromo        MoveType = MovePromotion | 16
 	MoveBishopPromo        MoveType = MovePromotion | 32
@@ -148,56 +148,209 @@ const (
 	MoveBishopPromoCapture MoveType = MoveBishopPromo | MoveCapture
 	MoveRookPromoCapture   MoveType = MoveRookPromo | MoveCapture
 	QueenPromoCapture      MoveType = MoveQueenPromo | MoveCapture
+	MoveKingPromo          MoveType = MovePromotion | MoveKingCastle // Solo en Antichess
+	MoveKingPromoCapture   MoveType = MoveKingPromo | MoveCapture
 )
 
-type Move struct {
-	From      uint8
-	To        uint8
-	Piece     Piece
-	Type      MoveType
-	Castling  CastleRights
-	EnPassant uint8
+// Move es un movimiento empaquetado en 16 bits: casilla de origen (bits 0-5), de destino
+// (6-11) y un código de tipo (12-15, ver moveTypeCodes). En las piezas soltadas de
+// Crazyhouse el origen guarda la pieza. El estado del tablero que cambia al mover
+// (enroques, al paso) no va en el movimiento: lo calcula MovePiece y se guarda en
+// moveState para deshacerlo. El valor cero (a1a1) no es ningún movimiento.
+type Move uint16
+
+// NoMove es el valor cero de Move, que no corresponde a ningún movimiento.
+const NoMove Move = 0
+
+// moveTypeCodes son los tipos de movimiento que caben en los 4 bits del código, indexados
+// por él. Las promociones van a partir del 8 y cada una seguida de su captura.
+var moveTypeCodes = [16]MoveType{
+	MoveNormal, MoveCapture, MoveKingCastle, MoveQueenCastle, MoveDrop, MoveKingPromo, MoveKingPromoCapture, 0,
+	MoveKnightPromo, MoveKnightPromoCapture, MoveBishopPromo, MoveBishopPromoCapture,
+	MoveRookPromo, MoveRookPromoCapture, MoveQueenPromo, QueenPromoCapture,
+}
+
+// moveCodeOfType es la inversa de moveTypeCodes.
+var moveCodeOfType = func() (codes [256]uint8) {
+	for code, t := range moveTypeCodes {
+		if t != 0 || code == 0 {
+			codes[t] = uint8(code)
+		}
+	}
+	return codes
+}()
+
+// newMove crea un movimiento de tipo t entre las casillas from y to (0-63).
+func newMove(from, to uint8, t MoveType) Move {
+	return Move(from) | Move(to)<<6 | Move(moveCodeOfType[t])<<12
+}
+
+// NewMove crea un movimiento de tipo t entre las casillas de los bitboards from y to.
+func NewMove(t MoveType, from, to uint64) Move {
+	return newMove(uint8(bits.TrailingZeros64(from)), uint8(bits.TrailingZeros64(to)), t)
+}
+
+// NewDrop crea el movimiento de Crazyhouse que suelta piece de la reserva en to.
+func NewDrop(piece Piece, to uint64) Move {
+	return newMove(uint8(piece), uint8(bits.TrailingZeros64(to)), MoveDrop)
+}
+
+// From es la casilla de origen (0-63). En las piezas soltadas no es una casilla (ver DropPiece).
+func (m Move) From() uint8 {
+	return uint8(m & 63)
 }
 
-func (m *Move) GetFrom64() uint64 {
-	return uint64(1) << m.From
+// To es la casilla de destino (0-63).
+func (m Move) To() uint8 {
+	return uint8(m >> 6 & 63)
 }
 
-func (m *Move) GetTo64() uint64 {
-	return uint64(1) << m.To
+// Type es el tipo del movimiento.
+func (m Move) Type() MoveType {
+	return moveTypeCodes[m>>12]
 }
 
-func (m *Move) IsCapture() bool {
-	return (m.Type & MoveCapture) != 0
+func (m Move) GetFrom64() uint64 {
+	return uint64(1) << m.From()
 }
 
-func (m *Move) ToSimpleString() string {
-	return fmt.Sprintf("%s%s", squareToString(m.From), squareToString(m.To))
+func (m Move) GetTo64() uint64 {
+	return uint64(1) << m.To()
 }
 
-// Update castling rights when rook is captured
-func (m *Move) CheckCapturedRook(isWhite bool, destBit uint64, b *Board) {
-	if isWhite && b.BlackPieces.Rooks&destBit != 0 {
-		switch destBit {
-		case A8:
-			m.Castling &^= BlackQueenSide
-		case H8:
-			m.Castling &^= BlackKingSide
+func (m Move) IsCapture() bool {
+	return (m.Type() & MoveCapture) != 0
+}
+
+// IsDrop indica si el movimiento suelta una pieza de la reserva (Crazyhouse).
+func (m Move) IsDrop() bool {
+	return m.Type() == MoveDrop
+}
+
+// DropPiece devuelve la pieza que se suelta, o 0 si el movimiento no es de Crazyhouse.
+func (m Move) DropPiece() Piece {
+	if !m.IsDrop() {
+		return 0
+	}
+	return Piece(m.From())
+}
+
+// PromotionPiece devuelve la pieza a la que corona el movimiento, o 0 si no es una promoción.
+func (m Move) PromotionPiece() Piece {
+	t := m.Type()
+	if t&MovePromotion == 0 {
+		return 0
+	}
+	switch {
+	case t&MoveKingCastle != 0:
+		return King
+	case t&16 != 0:
+		return Knight
+	case t&32 != 0:
+		return Bishop
+	case t&64 != 0:
+		return Rook
+	default:
+		return Queen
+	}
+}
+
+// MovingPiece devuelve la pieza que mueve m en la posición actual: la de la casilla de
+// origen, o la que se suelta.
+func (b *Board) MovingPiece(m Move) Piece {
+	if m.IsDrop() {
+		return m.DropPiece()
+	}
+	piece, _ := b.PieceAtSquare(m.GetFrom64())
+	return piece
+}
+
+// standardCastlingRooks son las casillas de las torres de enroque en el ajedrez normal.
+var standardCastlingRooks = [4]uint8{7, 0, 63, 56} // H1, A1, H8, A8
+
+// castlingRook devuelve la casilla de salida de la torre del derecho de enroque right. Se
+// guarda combinada con la del ajedrez normal para que un Board sin inicializar, o montado
+// pieza a pieza, tenga las torres de siempre.
+func (b *Board) castlingRook(right CastleRights) uint8 {
+	i := bits.TrailingZeros8(uint8(right))
+	return b.castlingRooks[i] ^ standardCastlingRooks[i]
+}
+
+// setCastlingRook fija la casilla de salida de la torre del derecho de enroque right.
+func (b *Board) setCastlingRook(right CastleRights, square uint8) {
+	i := bits.TrailingZeros8(uint8(right))
+	b.castlingRooks[i] = square ^ standardCastlingRooks[i]
+}
+
+// castlingRightsOfRook devuelve los derechos de enroque del bando que se pierden si su torre
+// sale de square o es capturada allí.
+func (b *Board) castlingRightsOfRook(square uint64, isWhite bool) CastleRights {
+	rights := [2]CastleRights{WhiteKingSide, WhiteQueenSide}
+	if !isWhite {
+		rights = [2]CastleRights{BlackKingSide, BlackQueenSide}
+	}
+	var lost CastleRights
+	for _, right := range rights {
+		if b.Castling&right != 0 && uint64(1)<<b.castlingRook(right) == square {
+			lost |= right
 		}
-	} else if !isWhite && b.WhitePieces.Rooks&destBit != 0 {
-		switch destBit {
-		case A1:
-			m.Castling &^= WhiteQueenSide
-		case H1:
-			m.Castling &^= WhiteKingSide
+	}
+	return lost
+}
+
+// castlingRookSquares devuelve las casillas de origen y destino de la torre en un enroque:
+// sale de la casilla de su derecho de enroque (en Chess960 cualquier columna) y acaba en
+// la columna f o d. ok es false si el tipo de movimiento no es un enroque.
+func (b *Board) castlingRookSquares(t MoveType, isWhite bool) (from, to uint64, ok bool) {
+	switch {
+	case t == MoveKingCastle && isWhite:
+		return uint64(1) << b.castlingRook(WhiteKingSide), F1, true
+	case t == MoveKingCastle:
+		return uint64(1) << b.castlingRook(BlackKingSide), F8, true
+	case t == MoveQueenCastle && isWhite:
+		return uint64(1) << b.castlingRook(WhiteQueenSide), D1, true
+	case t == MoveQueenCastle:
+		return uint64(1) << b.castlingRook(BlackQueenSide), D8, true
+	}
+	return 0, 0, false
+}
+
+// ToSimpleString devuelve las casillas de origen y destino (e2e4), o la pieza y la casilla
+// en las piezas soltadas (N@f3).
+func (m Move) ToSimpleString() string {
+	if m.IsDrop() {
+		return string(" PNBRQK"[m.DropPiece()]) + "@" + squareToString(m.To())
+	}
+	return fmt.Sprintf("%s%s", squareToString(m.From()), squareToString(m.To()))
+}
+
+// UCIString devuelve el movimiento en notación UCI: como ToSimpleString, más la pieza a la
+// que se corona en las promociones (e7e8q, o e7e8k en Antichess).
+func (m Move) UCIString() string {
+	if promo := m.PromotionPiece(); promo != 0 {
+		return m.ToSimpleString() + string(" pnbrqk"[promo])
+	}
+	return m.ToSimpleString()
+}
+
+// UCIMove devuelve m en notación UCI. En Chess960 los enroques se escriben como el rey
+// que captura su propia torre (e1h1), que es lo que esperan las interfaces con
+// UCI_Chess960; en el ajedrez normal es UCIString. Sirve para cualquier posición de la
+// partida, no solo para la actual: las casillas de las torres no cambian.
+func (b *Board) UCIMove(m Move) string {
+	if b.Chess960 {
+		// Las blancas enrocan en la primera fila
+		if rookFrom, _, ok := b.castlingRookSquares(m.Type(), m.From() < 8); ok {
+			return squareToString(m.From()) + squareToString(uint8(bits.TrailingZeros64(rookFrom)))
 		}
 	}
+	return m.UCIString()
 }
 
-func (m *Move) ToString() string {
-	from := squareToString(m.From)
-	to := squareToString(m.To)
-	mt := m.Type
+func (m Move) ToString() string {
+	from := squareToString(m.From())
+	to := squareToString(m.To())
+	mt := m.Type()
 	// Enroques
 	if mt == MoveKingCastle {
 		return "O-O"
@@ -205,6 +358,9 @@ func (m *Move) ToString() string {
 	if mt == MoveQueenCastle {
 		return "O-O-O"
 	}
+	if mt == MoveDrop {
+		return m.ToSimpleString()
+	}
 	// Promociones con captura
 	if (mt&MoveCapture) != 0 && (mt&MovePromotion) != 0 {
 		promo := ""
@@ -216,6 +372,8 @@ func (m *Move) ToString() string {
 			promo = "=R"
 		} else if (mt & 128) != 0 {
 			promo = "=Q"
+		} else if (mt & MoveKingCastle) != 0 {
+			promo = "=K"
 		}
 		return fmt.Sprintf("%sx%s%s%s", from, to, promo, "")
 	}
@@ -230,6 +388,8 @@ func (m *Move) ToString() string {
 			promo = "=R"
 		} else if (mt & 128) != 0 {
 			promo = "=Q"
+		} else if (mt & MoveKingCastle) != 0 {
+			promo = "=K"
 		}
 		return fmt.Sprintf("%s%s%s", from, to, promo)
 	}
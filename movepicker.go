@@ -0,0 +1,344 @@
+package melange
+
+import "math/bits"
+
+// Etapas del selector de movimientos, en el orden en que se recorren
+const (
+	stageTT = iota
+	stageGenCaptures
+	stageGoodCaptures
+	stagePromotions
+	stageKillers
+	stageCounter
+	stageGenQuiets
+	stageQuiets
+	stageBadCaptures
+	stageDone
+)
+
+// maxHistory limita los valores del historial de movimientos tranquilos.
+const maxHistory = 16384
+
+type scoredMove struct {
+	move  Move
+	score int
+}
+
+// movePicker entrega los movimientos pseudo-legales de una posición por etapas: primero
+// el de la tabla de transposición, después las capturas que no pierden material (por
+// MVV-LVA), las promociones, los killers, el contraataque al movimiento anterior, los
+// tranquilos por historial y al final las capturas perdedoras según SEE. Cada grupo se
+// genera solo al llegar a su etapa, así que un corte beta temprano ahorra generar el resto.
+type movePicker struct {
+	b       *Board
+	s       *Searcher
+	ttMove  Move
+	killers [2]Move
+	counter Move
+
+	stage   int
+	moves   []scoredMove
+	idx     int
+	bad     []scoredMove
+	killerI int
+}
+
+func newMovePicker(s *Searcher, ttMove Move, ply int) *movePicker {
+	mp := &movePicker{b: s.board, s: s, ttMove: ttMove, killers: s.killers[ply]}
+	if ply > 0 {
+		prev := s.moveStack[ply-1]
+		mp.counter = s.counter[prev.From()][prev.To()]
+	}
+	return mp
+}
+
+// next devuelve el siguiente movimiento; ok es false cuando no quedan más.
+func (mp *movePicker) next() (m Move, ok bool) {
+	b := mp.b
+	for {
+		switch mp.stage {
+		case stageTT:
+			mp.stage++
+			if mp.ttMove != NoMove && b.isPseudoLegal(mp.ttMove) {
+				return mp.ttMove, true
+			}
+		case stageGenCaptures:
+			mp.moves = mp.moves[:0]
+			var buf [maxMoves]Move
+			for _, m := range b.captureMoves(buf[:0]) {
+				mp.moves = append(mp.moves, scoredMove{m, mvvLva(b, m)})
+			}
+			mp.idx = 0
+			mp.stage++
+		case stageGoodCaptures:
+			m, ok := mp.pickBest()
+			if !ok {
+				mp.moves = mp.moves[:0]
+				var buf [32]Move
+				for _, m := range b.quietPromotions(buf[:0]) {
+					mp.moves = append(mp.moves, scoredMove{m, int(m.PromotionPiece())})
+				}
+				mp.idx = 0
+				mp.stage++
+				continue
+			}
+			if m == mp.ttMove {
+				continue
+			}
+			if b.SEE(m) < 0 {
+				mp.bad = append(mp.bad, scoredMove{m, 0})
+				continue
+			}
+			return m, true
+		case stagePromotions:
+			m, ok := mp.pickBest()
+			if !ok {
+				mp.stage++
+				continue
+			}
+			if m != mp.ttMove {
+				return m, true
+			}
+		case stageKillers:
+			if mp.killerI >= len(mp.killers) {
+				mp.stage++
+				continue
+			}
+			m := mp.killers[mp.killerI]
+			mp.killerI++
+			if m != NoMove && m != mp.ttMove && b.isPseudoLegal(m) {
+				return m, true
+			}
+		case stageCounter:
+			mp.stage++
+			m := mp.counter
+			if m != NoMove && m != mp.ttMove && m != mp.killers[0] && m != mp.killers[1] &&
+				!m.IsCapture() && m.Type()&MovePromotion == 0 && b.isPseudoLegal(m) {
+				return m, true
+			}
+		case stageGenQuiets:
+			mp.moves = mp.moves[:0]
+			color := colorIndex(b.WhiteToMove)
+			var buf [maxMoves]Move
+			for _, m := range b.quietMoves(buf[:0]) {
+				mp.moves = append(mp.moves, scoredMove{m, mp.s.history[color][m.From()][m.To()]})
+			}
+			mp.idx = 0
+			mp.stage++
+		case stageQuiets:
+			m, ok := mp.pickBest()
+			if !ok {
+				mp.moves, mp.idx = mp.bad, 0
+				mp.stage++
+				continue
+			}
+			if m != mp.ttMove && m != mp.killers[0] && m != mp.killers[1] && m != mp.counter {
+				return m, true
+			}
+		case stageBadCaptures:
+			if mp.idx >= len(mp.moves) {
+				mp.stage++
+				continue
+			}
+			mp.idx++
+			return mp.moves[mp.idx-1].move, true
+		default:
+			return NoMove, false
+		}
+	}
+}
+
+// pickBest devuelve el movimiento restante de mayor puntuación (selección perezosa: en
+// los nodos con corte no hace falta ordenar la lista entera).
+func (mp *movePicker) pickBest() (Move, bool) {
+	if mp.idx >= len(mp.moves) {
+		return NoMove, false
+	}
+	best := mp.idx
+	for i := mp.idx + 1; i < len(mp.moves); i++ {
+		if mp.moves[i].score > mp.moves[best].score {
+			best = i
+		}
+	}
+	mp.moves[mp.idx], mp.moves[best] = mp.moves[best], mp.moves[mp.idx]
+	mp.idx++
+	return mp.moves[mp.idx-1].move, true
+}
+
+// mvvLva ordena las capturas por la víctima más valiosa y, a igualdad, el atacante menos
+// valioso. Las promociones con captura suman la pieza a la que se corona.
+func mvvLva(b *Board, m Move) int {
+	victim, _ := b.PieceAtSquare(m.GetTo64())
+	if victim == 0 {
+		victim = Pawn // Al paso
+	}
+	score := 8*seeValues[victim] - int(b.MovingPiece(m))
+	if promo := m.PromotionPiece(); promo != 0 {
+		score += 8 * seeValues[promo]
+	}
+	return score
+}
+
+func colorIndex(isWhite bool) int {
+	if isWhite {
+		return 0
+	}
+	return 1
+}
+
+// isPseudoLegal indica si m es uno de los movimientos pseudo-legales de la posición. Se
+// usa para los movimientos que vienen de otras posiciones (tabla de transposición,
+// killers) generando solo los de la pieza de la casilla de origen.
+func (b *Board) isPseudoLegal(m Move) bool {
+	if m.IsDrop() {
+		return b.isPseudoLegalDrop(m)
+	}
+	var buf [32]Move
+	for _, candidate := range b.appendMovesFrom(buf[:0], int8(m.From())) {
+		if candidate == m {
+			return true
+		}
+	}
+	return false
+}
+
+// captureMoves genera solo las capturas pseudo-legales del bando al mover (incluidas al
+// paso y promociones con captura), iguales a las que devuelve GetLegalMoves, usando las
+// tablas de ataques. Los añade a moves.
+func (b *Board) captureMoves(moves MoveList) MoveList {
+	isWhite := b.WhiteToMove
+	own, enemy := &b.WhitePieces, b.BlackOccupiedSquares()
+	promoRow := 6
+	if !isWhite {
+		own, enemy = &b.BlackPieces, b.WhiteOccupiedSquares()
+		promoRow = 1
+	}
+	occupancy := b.AllPieces()
+	color := colorIndex(isWhite)
+
+	for bb := own.Pawns; bb != 0; bb &= bb - 1 {
+		sq := bits.TrailingZeros64(bb)
+		from := uint64(1) << sq
+		for targets := pawnAttacks[color][sq] & enemy; targets != 0; targets &= targets - 1 {
+			to := targets & -targets
+			if sq/8 == promoRow {
+				for _, t := range []MoveType{MoveKnightPromoCapture, MoveBishopPromoCapture, MoveRookPromoCapture, QueenPromoCapture} {
+					moves = append(moves, NewMove(t, from, to))
+				}
+			} else {
+				moves = append(moves, NewMove(MoveCapture, from, to))
+			}
+		}
+		if b.EnPassant != 0 && sq/8 != promoRow && pawnAttacks[color][sq]&(uint64(1)<<b.EnPassant)&^occupancy != 0 {
+			moves = append(moves, NewMove(MoveCapture, from, uint64(1)<<b.EnPassant))
+		}
+	}
+
+	add := func(pieces uint64, piece Piece, attacks func(sq uint8) uint64) {
+		for bb := pieces; bb != 0; bb &= bb - 1 {
+			sq := uint8(bits.TrailingZeros64(bb))
+			from := uint64(1) << sq
+			for targets := attacks(sq) & enemy; targets != 0; targets &= targets - 1 {
+				to := targets & -targets
+				if piece == King && b.SquareAttacked(int8(bits.TrailingZeros64(to)/8), int8(bits.TrailingZeros64(to)%8), isWhite) {
+					continue
+				}
+				moves = append(moves, NewMove(MoveCapture, from, to))
+			}
+		}
+	}
+	add(own.Knights, Knight, func(sq uint8) uint64 { return knightAttacks[sq] })
+	add(own.Bishops, Bishop, func(sq uint8) uint64 { return bishopAttacks(sq, occupancy) })
+	add(own.Rooks, Rook, func(sq uint8) uint64 { return rookAttacks(sq, occupancy) })
+	add(own.Queens, Queen, func(sq uint8) uint64 { return bishopAttacks(sq, occupancy) | rookAttacks(sq, occupancy) })
+	add(own.King, King, func(sq uint8) uint64 { return kingAttacks[sq] })
+	return moves
+}
+
+// quietMoves genera los movimientos pseudo-legales del bando al mover que no son capturas
+// ni promociones (incluidos enroques y, en Crazyhouse, las caídas), iguales a los que
+// devuelve GetLegalMoves. Los añade a moves.
+func (b *Board) quietMoves(moves MoveList) MoveList {
+	start := len(moves)
+	isWhite := b.WhiteToMove
+	own := &b.WhitePieces
+	if !isWhite {
+		own = &b.BlackPieces
+	}
+	occupancy := b.AllPieces()
+
+	for bb := own.Pawns; bb != 0; bb &= bb - 1 {
+		sq := bits.TrailingZeros64(bb)
+		row := sq / 8
+		from := uint64(1) << sq
+		if isWhite {
+			if row == 6 || occupancy&(from<<8) != 0 {
+				continue
+			}
+			moves = append(moves, NewMove(MoveNormal, from, from<<8))
+			if (row == 1 || row == 0 && b.Variant == Horde) && occupancy&(from<<16) == 0 {
+				moves = append(moves, NewMove(MoveNormal, from, from<<16))
+			}
+		} else {
+			if row <= 1 || occupancy&(from>>8) != 0 {
+				continue
+			}
+			moves = append(moves, NewMove(MoveNormal, from, from>>8))
+			if row == 6 && occupancy&(from>>16) == 0 {
+				moves = append(moves, NewMove(MoveNormal, from, from>>16))
+			}
+		}
+	}
+
+	add := func(pieces uint64, attacks func(sq uint8) uint64) {
+		for bb := pieces; bb != 0; bb &= bb - 1 {
+			sq := uint8(bits.TrailingZeros64(bb))
+			from := uint64(1) << sq
+			for targets := attacks(sq) &^ occupancy; targets != 0; targets &= targets - 1 {
+				moves = append(moves, NewMove(MoveNormal, from, targets&-targets))
+			}
+		}
+	}
+	add(own.Knights, func(sq uint8) uint64 { return knightAttacks[sq] })
+	add(own.Bishops, func(sq uint8) uint64 { return bishopAttacks(sq, occupancy) })
+	add(own.Rooks, func(sq uint8) uint64 { return rookAttacks(sq, occupancy) })
+	add(own.Queens, func(sq uint8) uint64 { return bishopAttacks(sq, occupancy) | rookAttacks(sq, occupancy) })
+	for bb := own.King; bb != 0; bb &= bb - 1 {
+		sq := uint8(bits.TrailingZeros64(bb))
+		from := uint64(1) << sq
+		for targets := kingAttacks[sq] &^ occupancy; targets != 0; targets &= targets - 1 {
+			to := bits.TrailingZeros64(targets)
+			if b.Variant.royalKing() && b.SquareAttacked(int8(to/8), int8(to%8), isWhite) {
+				continue
+			}
+			moves = append(moves, NewMove(MoveNormal, from, targets&-targets))
+		}
+		moves = b.appendCastlingMoves(moves, sq, isWhite)
+	}
+	if b.Variant == Crazyhouse {
+		moves = b.appendDrops(moves)
+	}
+	if b.Variant != Standard {
+		moves = moves[:start+len(b.filterVariantMoves(moves[start:]))]
+	}
+	return moves
+}
+
+// quietPromotions añade a moves las promociones sin captura del bando al mover.
+func (b *Board) quietPromotions(moves MoveList) MoveList {
+	pawns, shift := b.WhitePieces.Pawns&0x00FF000000000000, 8
+	if !b.WhiteToMove {
+		pawns, shift = b.BlackPieces.Pawns&0xFF00, -8
+	}
+	for bb := pawns; bb != 0; bb &= bb - 1 {
+		sq := bits.TrailingZeros64(bb)
+		to := uint64(1) << (sq + shift)
+		if b.IsSquareOccupied(to) {
+			continue
+		}
+		for _, t := range []MoveType{MoveKnightPromo, MoveBishopPromo, MoveRookPromo, MoveQueenPromo} {
+			moves = append(moves, NewMove(t, uint64(1)<<sq, to))
+		}
+	}
+	return moves
+}
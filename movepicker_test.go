@@ -0,0 +1,127 @@
+package melange
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+var pickerFens = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1",
+}
+
+// pickAll recorre el selector entero y devuelve los movimientos en el orden entregado.
+func pickAll(s *Searcher, ttMove Move, ply int) []Move {
+	var moves []Move
+	mp := newMovePicker(s, ttMove, ply)
+	for m, ok := mp.next(); ok; m, ok = mp.next() {
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+func TestCaptureMovesMatchGetLegalMoves(t *testing.T) {
+	for _, fen := range pickerFens {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(fen))
+		for _, m := range b.GetLegalMoves() {
+			st := b.perftMakeMove(m)
+			var want, got []Move
+			for _, m := range b.GetLegalMoves() {
+				if m.IsCapture() || m.Type()&MovePromotion != 0 {
+					want = append(want, m)
+				}
+			}
+			got = b.captureMoves(got)
+			got = b.quietPromotions(got)
+			assert.Equal(t, len(got), len(want), "%s %s", fen, m.ToSimpleString())
+			for _, w := range want {
+				assert.Assert(t, containsMove(got, w), "%s falta %s", b.Fen(), w.ToSimpleString())
+			}
+			b.unmakeMove(st)
+		}
+	}
+}
+
+func TestQuietMovesMatchGetLegalMoves(t *testing.T) {
+	boards := []*Board{NewVariantBoard(Horde), NewVariantBoard(Crazyhouse), NewVariantBoard(KingOfTheHill)}
+	for _, fen := range pickerFens {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(fen))
+		boards = append(boards, b)
+	}
+	ch := &Board{Variant: Crazyhouse}
+	assert.NilError(t, ch.SetFen("r1bqk2r/pppp1ppp/2n1p3/4P3/1b1Pn3/2NB1N2/PPP2PPP/R1BQK2R[Pn] b KQkq - 0 1"))
+	boards = append(boards, ch)
+	for _, b := range boards {
+		for _, m := range b.GetLegalMoves() {
+			st := b.perftMakeMove(m)
+			var want []Move
+			for _, m := range b.GetLegalMoves() {
+				if !m.IsCapture() && m.Type()&MovePromotion == 0 {
+					want = append(want, m)
+				}
+			}
+			got := b.quietMoves(nil)
+			assert.Equal(t, len(got), len(want), "%s", b.Fen())
+			for _, w := range want {
+				assert.Assert(t, containsMove(got, w), "%s falta %s", b.Fen(), w.ToSimpleString())
+			}
+			b.unmakeMove(st)
+		}
+	}
+}
+
+func TestMovePickerYieldsEveryMoveOnce(t *testing.T) {
+	for _, fen := range pickerFens {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(fen))
+		s := NewSearcher(DefaultEvalParams())
+		s.board = b
+		legal := b.GetLegalMoves()
+		// Killers y contraataque que pueden no ser válidos en esta posición
+		s.killers[1] = [2]Move{legal[0], newMove(0, 63, MoveNormal)}
+		s.moveStack[0] = legal[len(legal)-1]
+		s.counter[legal[len(legal)-1].From()][legal[len(legal)-1].To()] = legal[1]
+		for _, ttMove := range []Move{NoMove, legal[len(legal)/2], newMove(12, 36, MoveNormal)} {
+			got := pickAll(s, ttMove, 1)
+			assert.Equal(t, len(got), len(legal), fen)
+			for i, m := range got {
+				assert.Assert(t, containsMove(legal, m), "%s: %s", fen, m.ToSimpleString())
+				assert.Assert(t, !containsMove(got[:i], m), "%s: %s repetido", fen, m.ToSimpleString())
+			}
+			if ttMove == legal[len(legal)/2] {
+				assert.Equal(t, got[0], ttMove)
+			}
+		}
+	}
+}
+
+func TestMovePickerOrder(t *testing.T) {
+	b := &Board{}
+	// Txa7 gana una torre, exd5 un peón y Dxd5 pierde la dama
+	assert.NilError(t, b.SetFen("4k3/r7/2p5/3p4/4P3/8/8/R2QK3 w - - 0 1"))
+	s := NewSearcher(DefaultEvalParams())
+	s.board = b
+	killer := findMove(t, b, "e1f2")
+	s.killers[0][0] = killer
+	got := pickAll(s, NoMove, 0)
+	assert.Equal(t, got[0].ToSimpleString(), "a1a7")
+	assert.Equal(t, got[1].ToSimpleString(), "e4d5")
+	assert.Equal(t, got[2], killer)
+	assert.Equal(t, got[len(got)-1].ToSimpleString(), "d1d5")
+}
+
+func containsMove(moves []Move, m Move) bool {
+	for _, c := range moves {
+		if c == m {
+			return true
+		}
+	}
+	return false
+}
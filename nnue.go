@@ -0,0 +1,363 @@
+package melange
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// Red neuronal de evaluación eficientemente actualizable (NNUE) con arquitectura
+// (768 -> N) x 2 -> 1:
+//
+//   - 768 entradas binarias: 2 colores x 6 piezas x 64 casillas, vistas desde cada bando.
+//   - Un transformador de características compartido produce un acumulador de N valores
+//     por perspectiva. El acumulador se actualiza incrementalmente al mover piezas.
+//   - La capa de salida concatena [bando al mover, bando contrario], aplica CReLU
+//     (recorte a [0, nnueQA]) y calcula un único valor.
+//
+// Todo se calcula con enteros cuantizados, de modo que la inferencia incremental y la
+// implementación de referencia (evaluateReference) dan resultados idénticos bit a bit.
+
+const (
+	nnueInputs = 768
+	nnueQA     = 255 // Escala de cuantización del transformador de características
+	nnueQB     = 64  // Escala de cuantización de la capa de salida
+	nnueScale  = 400 // Conversión de la salida de la red a centipawns
+
+	nnueMagic   = "MLNN"
+	nnueVersion = 1
+	// Límite de seguridad al leer ficheros para no reservar memoria absurda
+	nnueMaxHidden = 4096
+)
+
+// Network contiene los pesos cuantizados de la red.
+type Network struct {
+	Hidden int
+	// FeatureWeights[f*Hidden+i] es el peso de la entrada f sobre la neurona i
+	FeatureWeights []int16
+	FeatureBias    []int16
+	// OutputWeights tiene 2*Hidden valores: primero el bando al mover, luego el contrario
+	OutputWeights []int16
+	OutputBias    int32
+}
+
+// NewNetwork crea una red con todos los pesos a cero.
+func NewNetwork(hidden int) *Network {
+	return &Network{
+		Hidden:         hidden,
+		FeatureWeights: make([]int16, nnueInputs*hidden),
+		FeatureBias:    make([]int16, hidden),
+		OutputWeights:  make([]int16, 2*hidden),
+	}
+}
+
+// LoadNetwork lee una red desde un fichero.
+func LoadNetwork(path string) (*Network, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadNetwork(bufio.NewReader(f))
+}
+
+// ReadNetwork decodifica una red. Formato (little endian):
+//
+//	"MLNN" | uint32 versión | uint32 N | int16 pesos[768*N] | int16 bias[N] |
+//	int16 salida[2*N] | int32 bias de salida
+func ReadNetwork(r io.Reader) (*Network, error) {
+	var header struct {
+		Magic   [4]byte
+		Version uint32
+		Hidden  uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("red NNUE inválida: %w", err)
+	}
+	if string(header.Magic[:]) != nnueMagic {
+		return nil, errors.New("red NNUE inválida: cabecera desconocida")
+	}
+	if header.Version != nnueVersion {
+		return nil, fmt.Errorf("red NNUE inválida: versión %d no soportada", header.Version)
+	}
+	if header.Hidden == 0 || header.Hidden > nnueMaxHidden {
+		return nil, fmt.Errorf("red NNUE inválida: tamaño de capa oculta %d", header.Hidden)
+	}
+	n := NewNetwork(int(header.Hidden))
+	for _, data := range []any{n.FeatureWeights, n.FeatureBias, n.OutputWeights, &n.OutputBias} {
+		if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+			return nil, fmt.Errorf("red NNUE inválida: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// Save escribe la red en un fichero.
+func (n *Network) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := n.Write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write serializa la red en el formato que entiende ReadNetwork.
+func (n *Network) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, nnueMagic); err != nil {
+		return err
+	}
+	for _, data := range []any{uint32(nnueVersion), uint32(n.Hidden), n.FeatureWeights, n.FeatureBias, n.OutputWeights, n.OutputBias} {
+		if err := binary.Write(w, binary.LittleEndian, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nnueFeature devuelve el índice de entrada de una pieza vista desde una perspectiva.
+// Desde la perspectiva negra el tablero se refleja verticalmente y se intercambian los colores,
+// así que la red siempre "ve" sus propias piezas en la mitad baja del tablero.
+func nnueFeature(perspectiveWhite bool, pieceIsWhite bool, piece Piece, sq int) int {
+	if !perspectiveWhite {
+		sq ^= 56
+	}
+	side := 0
+	if pieceIsWhite != perspectiveWhite {
+		side = 1
+	}
+	return side*384 + int(piece-1)*64 + sq
+}
+
+// accumulator guarda la salida del transformador de características para cada perspectiva.
+// Índice 0: blancas, índice 1: negras.
+type accumulator [2][]int16
+
+// nnueState es el estado incremental asociado a un tablero: una pila de acumuladores
+// (uno por cada movimiento hecho con perftMakeMove) cuya cima refleja la posición actual.
+type nnueState struct {
+	net   *Network
+	stack []accumulator
+}
+
+func (s *nnueState) top() *accumulator {
+	return &s.stack[len(s.stack)-1]
+}
+
+func (s *nnueState) clone() *nnueState {
+	c := &nnueState{net: s.net, stack: make([]accumulator, 1)}
+	t := s.top()
+	c.stack[0] = accumulator{append([]int16(nil), t[0]...), append([]int16(nil), t[1]...)}
+	return c
+}
+
+// push duplica la cima de la pila para que el siguiente movimiento pueda deshacerse con pop.
+func (s *nnueState) push() {
+	t := s.top()
+	if len(s.stack) < cap(s.stack) {
+		// Reutilizar la memoria de una entrada anterior
+		s.stack = s.stack[:len(s.stack)+1]
+		next := s.top()
+		if next[0] == nil {
+			next[0] = make([]int16, s.net.Hidden)
+			next[1] = make([]int16, s.net.Hidden)
+		}
+		copy(next[0], t[0])
+		copy(next[1], t[1])
+		return
+	}
+	s.stack = append(s.stack, accumulator{append([]int16(nil), t[0]...), append([]int16(nil), t[1]...)})
+}
+
+func (s *nnueState) pop() {
+	s.stack = s.stack[:len(s.stack)-1]
+}
+
+// refresh recalcula la cima de la pila desde cero a partir de las piezas del tablero.
+func (s *nnueState) refresh(b *Board) {
+	t := s.top()
+	copy(t[0], s.net.FeatureBias)
+	copy(t[1], s.net.FeatureBias)
+	for _, isWhite := range []bool{true, false} {
+		pieces := &b.BlackPieces
+		if isWhite {
+			pieces = &b.WhitePieces
+		}
+		for piece := Pawn; piece <= King; piece++ {
+			bb := pieces.Get(piece)
+			for bb != 0 {
+				sq := bits.TrailingZeros64(bb)
+				bb &= bb - 1
+				s.add(isWhite, piece, sq)
+			}
+		}
+	}
+}
+
+func (s *nnueState) add(isWhite bool, piece Piece, sq int) {
+	t := s.top()
+	h := s.net.Hidden
+	for p := 0; p < 2; p++ {
+		f := nnueFeature(p == 0, isWhite, piece, sq)
+		w := s.net.FeatureWeights[f*h : f*h+h]
+		acc := t[p]
+		for i := range acc {
+			acc[i] += w[i]
+		}
+	}
+}
+
+func (s *nnueState) remove(isWhite bool, piece Piece, sq int) {
+	t := s.top()
+	h := s.net.Hidden
+	for p := 0; p < 2; p++ {
+		f := nnueFeature(p == 0, isWhite, piece, sq)
+		w := s.net.FeatureWeights[f*h : f*h+h]
+		acc := t[p]
+		for i := range acc {
+			acc[i] -= w[i]
+		}
+	}
+}
+
+// update aplica al acumulador el desplazamiento de la pieza que mueve (incluidas
+// promociones, la torre del enroque y las piezas soltadas de Crazyhouse). Las capturas se
+// descuentan en CapturePiece. piece es la pieza que mueve.
+func (s *nnueState) update(b *Board, move Move, piece Piece, isWhite bool) {
+	from := int(move.From())
+	to := int(move.To())
+	if move.IsDrop() {
+		s.add(isWhite, piece, to)
+		return
+	}
+	s.remove(isWhite, piece, from)
+	if promo := move.PromotionPiece(); promo != 0 {
+		s.add(isWhite, promo, to)
+	} else {
+		s.add(isWhite, piece, to)
+	}
+	if rookFrom, rookTo, ok := b.castlingRookSquares(move.Type(), isWhite); ok && piece == King {
+		s.remove(isWhite, Rook, bits.TrailingZeros64(rookFrom))
+		s.add(isWhite, Rook, bits.TrailingZeros64(rookTo))
+	}
+}
+
+// AttachNetwork asocia la red al tablero y calcula sus acumuladores. A partir de aquí
+// MovePiece, CapturePiece y make/unmake los mantienen actualizados de forma incremental.
+// Con net == nil se desactiva la actualización incremental.
+func (b *Board) AttachNetwork(net *Network) {
+	if net == nil {
+		b.nnue = nil
+		return
+	}
+	b.nnue = &nnueState{net: net, stack: make([]accumulator, 1, 64)}
+	b.nnue.stack[0] = accumulator{make([]int16, net.Hidden), make([]int16, net.Hidden)}
+	b.nnue.refresh(b)
+}
+
+// prepareEvaluator devuelve el tablero sobre el que buscar con el evaluador indicado. Para la
+// red NNUE es una copia con los acumuladores incrementales activados; para el resto de
+// evaluadores es el propio tablero.
+func prepareEvaluator(b *Board, eval Evaluator) *Board {
+	net, ok := eval.(*Network)
+	if !ok || (b.nnue != nil && b.nnue.net == net) {
+		return b
+	}
+	board := b.Clone()
+	board.AttachNetwork(net)
+	return board
+}
+
+// Evaluate implementa Evaluator. Devuelve la evaluación en centipawns desde el punto de
+// vista de las blancas, como la evaluación clásica. Si el tablero tiene la red asociada se
+// usan sus acumuladores incrementales; si no, se calculan desde cero.
+func (n *Network) Evaluate(b *Board) int {
+	var acc *accumulator
+	if b.nnue != nil && b.nnue.net == n {
+		acc = b.nnue.top()
+	} else {
+		s := &nnueState{net: n, stack: []accumulator{{make([]int16, n.Hidden), make([]int16, n.Hidden)}}}
+		s.refresh(b)
+		acc = s.top()
+	}
+	return n.output(acc, b.WhiteToMove)
+}
+
+// output calcula la capa de salida a partir de los acumuladores.
+func (n *Network) output(acc *accumulator, whiteToMove bool) int {
+	us, them := acc[0], acc[1]
+	if !whiteToMove {
+		us, them = them, us
+	}
+	sum := int64(0)
+	for i, v := range us {
+		sum += int64(crelu(v)) * int64(n.OutputWeights[i])
+	}
+	for i, v := range them {
+		sum += int64(crelu(v)) * int64(n.OutputWeights[n.Hidden+i])
+	}
+	score := int((sum + int64(n.OutputBias)) * nnueScale / (nnueQA * nnueQB))
+	if !whiteToMove {
+		score = -score
+	}
+	return score
+}
+
+func crelu(v int16) int16 {
+	if v < 0 {
+		return 0
+	}
+	if v > nnueQA {
+		return nnueQA
+	}
+	return v
+}
+
+// evaluateReference evalúa la posición de la forma más directa posible, sin acumuladores
+// ni actualizaciones incrementales. Sirve para validar en los tests que la inferencia
+// incremental es exacta.
+func (n *Network) evaluateReference(b *Board) int {
+	var hidden [2][]int16
+	for p := 0; p < 2; p++ {
+		hidden[p] = make([]int16, n.Hidden)
+		for i := 0; i < n.Hidden; i++ {
+			sum := int32(n.FeatureBias[i])
+			for sq := 0; sq < 64; sq++ {
+				piece, isWhite := b.PieceAtSquare(uint64(1) << sq)
+				if piece == 0 {
+					continue
+				}
+				f := nnueFeature(p == 0, isWhite, piece, sq)
+				sum += int32(n.FeatureWeights[f*n.Hidden+i])
+			}
+			// El acumulador incremental trabaja con int16; la suma modular es la misma
+			hidden[p][i] = int16(sum)
+		}
+	}
+	us, them := 0, 1
+	if !b.WhiteToMove {
+		us, them = 1, 0
+	}
+	sum := int64(n.OutputBias)
+	for i := 0; i < n.Hidden; i++ {
+		sum += int64(crelu(hidden[us][i])) * int64(n.OutputWeights[i])
+		sum += int64(crelu(hidden[them][i])) * int64(n.OutputWeights[n.Hidden+i])
+	}
+	score := int(sum * nnueScale / (nnueQA * nnueQB))
+	if !b.WhiteToMove {
+		score = -score
+	}
+	return score
+}
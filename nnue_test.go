@@ -0,0 +1,182 @@
+package melange
+
+import (
+	"bytes"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// randomNetwork crea una red con pesos pseudoaleatorios suficientemente grandes como para
+// que la CReLU recorte valores por ambos extremos.
+func randomNetwork(hidden int, seed int64) *Network {
+	rng := rand.New(rand.NewSource(seed))
+	n := NewNetwork(hidden)
+	for i := range n.FeatureWeights {
+		n.FeatureWeights[i] = int16(rng.Intn(81) - 40)
+	}
+	for i := range n.FeatureBias {
+		n.FeatureBias[i] = int16(rng.Intn(101))
+	}
+	for i := range n.OutputWeights {
+		n.OutputWeights[i] = int16(rng.Intn(129) - 64)
+	}
+	n.OutputBias = int32(rng.Intn(2001) - 1000)
+	return n
+}
+
+func TestNNUEReadWrite(t *testing.T) {
+	net := randomNetwork(16, 1)
+	var buf bytes.Buffer
+	assert.NilError(t, net.Write(&buf))
+	loaded, err := ReadNetwork(&buf)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, loaded, net)
+
+	_, err = ReadNetwork(bytes.NewReader([]byte("XXXX")))
+	assert.ErrorContains(t, err, "NNUE")
+}
+
+// legalMoves devuelve solo los movimientos que no dejan al rey propio en jaque.
+func legalMoves(b *Board) MoveList {
+	var moves MoveList
+	for _, m := range b.GetLegalMoves() {
+		if b.isMoveLegal(m) {
+			moves.Add(m)
+		}
+	}
+	return moves
+}
+
+func TestNNUEIncrementalMatchesReference(t *testing.T) {
+	net := randomNetwork(32, 2)
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	}
+	rng := rand.New(rand.NewSource(3))
+	for _, fen := range fens {
+		board := &Board{}
+		assert.NilError(t, board.SetFen(fen))
+		board.AttachNetwork(net)
+		initial := net.Evaluate(board)
+		assert.Equal(t, initial, net.evaluateReference(board), fen)
+
+		// Partida aleatoria con make/unmake comparando en cada paso con la referencia
+		var states []moveState
+		for ply := 0; ply < 60; ply++ {
+			moves := legalMoves(board)
+			if len(moves) == 0 {
+				break
+			}
+			states = append(states, board.perftMakeMove(moves[rng.Intn(len(moves))]))
+			assert.Equal(t, net.Evaluate(board), net.evaluateReference(board), "%s after %d plies", fen, ply+1)
+		}
+		for i := len(states) - 1; i >= 0; i-- {
+			board.unmakeMove(states[i])
+			assert.Equal(t, net.Evaluate(board), net.evaluateReference(board))
+		}
+		assert.Equal(t, net.Evaluate(board), initial)
+	}
+}
+
+func TestNNUECloneAndMovePiece(t *testing.T) {
+	net := randomNetwork(8, 4)
+	board := NewBoard()
+	board.AttachNetwork(net)
+	for _, uci := range []string{"e2e4", "d7d5", "e4d5", "d8d5", "b1c3", "d5a5", "g1f3", "c8g4", "f1e2", "b8c6", "e1g1", "e8c8"} {
+		mv, ok := parseUCIMove(board, uci)
+		assert.Assert(t, ok, uci)
+		child := board.Clone()
+		child.MovePiece(mv, board.WhiteToMove)
+		assert.Equal(t, net.Evaluate(child), net.evaluateReference(child), uci)
+		// El tablero original no debe verse afectado
+		assert.Equal(t, net.Evaluate(board), net.evaluateReference(board), uci)
+		board = child
+	}
+	// Sin acumuladores la red calcula desde cero y obtiene lo mismo
+	plain := board.Clone()
+	plain.AttachNetwork(nil)
+	assert.Equal(t, net.Evaluate(plain), net.Evaluate(board))
+}
+
+func TestNNUESearch(t *testing.T) {
+	net := randomNetwork(8, 5)
+	board := NewBoard()
+	res := NewSearcher(net).Search(board, SearchLimits{Depth: 2})
+	assert.Assert(t, slices.Contains(legalMoves(board), res.BestMove), res.BestMove.UCIString())
+	// La búsqueda trabaja sobre una copia con acumuladores y no modifica el tablero
+	assert.Assert(t, board.nnue == nil)
+	assert.Assert(t, board.Equal(NewBoard()))
+}
+
+// TestNNUESpecialMoves comprueba movimiento a movimiento las jugadas que tocan más de una
+// pieza: cada promoción, la captura al paso y los cuatro enroques.
+func TestNNUESpecialMoves(t *testing.T) {
+	net := randomNetwork(16, 6)
+	cases := []struct {
+		fen string
+		uci string
+	}{
+		{"1r5k/P7/8/8/8/8/8/K7 w - - 0 1", "a7a8n"},
+		{"1r5k/P7/8/8/8/8/8/K7 w - - 0 1", "a7a8b"},
+		{"1r5k/P7/8/8/8/8/8/K7 w - - 0 1", "a7a8r"},
+		{"1r5k/P7/8/8/8/8/8/K7 w - - 0 1", "a7a8q"},
+		{"1r5k/P7/8/8/8/8/8/K7 w - - 0 1", "a7b8q"},
+		{"k7/8/8/8/8/8/p7/1R5K b - - 0 1", "a2a1n"},
+		{"k7/8/8/8/8/8/p7/1R5K b - - 0 1", "a2a1b"},
+		{"k7/8/8/8/8/8/p7/1R5K b - - 0 1", "a2a1r"},
+		{"k7/8/8/8/8/8/p7/1R5K b - - 0 1", "a2b1q"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6"},
+		{"4k3/8/8/8/3Pp3/8/8/4K3 b - d3 0 1", "e4d3"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1c1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8g8"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8"},
+	}
+	for _, c := range cases {
+		board := &Board{}
+		assert.NilError(t, board.SetFen(c.fen))
+		board.AttachNetwork(net)
+		before := net.Evaluate(board)
+		mv, ok := parseUCIMove(board, c.uci)
+		assert.Assert(t, ok, c.uci)
+
+		// make/unmake
+		st := board.perftMakeMove(mv)
+		assert.Equal(t, net.Evaluate(board), net.evaluateReference(board), "%s %s", c.fen, c.uci)
+		board.unmakeMove(st)
+		assert.Equal(t, net.Evaluate(board), before, "%s %s", c.fen, c.uci)
+
+		// Clone + MovePiece
+		child := board.Clone()
+		child.MovePiece(mv, board.WhiteToMove)
+		assert.Equal(t, net.Evaluate(child), net.evaluateReference(child), "%s %s", c.fen, c.uci)
+		assert.Equal(t, net.Evaluate(board), before, "%s %s", c.fen, c.uci)
+	}
+}
+
+func TestNNUECloneKeepsOnlyTop(t *testing.T) {
+	net := randomNetwork(8, 7)
+	board := NewBoard()
+	board.AttachNetwork(net)
+	for _, uci := range []string{"e2e4", "e7e5", "g1f3"} {
+		mv, ok := parseUCIMove(board, uci)
+		assert.Assert(t, ok, uci)
+		board.perftMakeMove(mv)
+	}
+	clone := board.Clone()
+	assert.Equal(t, len(clone.nnue.stack), 1)
+	assert.Equal(t, cap(clone.nnue.stack), 1)
+	// push hace crecer la pila de la copia cuando hace falta
+	mv, ok := parseUCIMove(clone, "b8c6")
+	assert.Assert(t, ok)
+	st := clone.perftMakeMove(mv)
+	assert.Equal(t, net.Evaluate(clone), net.evaluateReference(clone))
+	clone.unmakeMove(st)
+	assert.Equal(t, net.Evaluate(clone), net.Evaluate(board))
+}
@@ -2,96 +2,130 @@ package melange
 
 import (
 	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
-var PerftMoveMap = make(map[Move]PerftResult)
-
 // Perft recursively counts the number of leaf nodes reachable within a given depth.
 // It generates only legal moves (i.e. moves that do not leave the moving side in check).
 // depth == 1 returns the number of legal moves in the current position.
-func (b *Board) Perft(depth int, start bool) PerftResult {
+func (b *Board) Perft(depth int) PerftResult {
+	return b.perft(depth, nil)
+}
+
+// perft is Perft with an optional cache of subtree results.
+func (b *Board) perft(depth int, cache *perftCache) PerftResult {
 	if depth == 0 {
 		return PerftResult{Nodes: 1}
 	}
-
-	moves := b.GetLegalMoves() // pseudo legal (king safety filtered partly for king moves but not for discovered checks)
+	var buf [maxMoves]Move
+	moves := b.GenerateMoves(buf[:0]) // pseudo legal (king safety filtered partly for king moves but not for discovered checks)
 	// We must filter moves that leave own king in check.
 	res := PerftResult{}
 	if depth == 1 {
 		for _, m := range moves {
 			if b.isMoveLegal(m) {
-				res.Nodes++
-				if m.IsCapture() {
-					res.Captures++
-					// Detect "en passant" capture
-					fromBB := m.GetFrom64()
-					piece, _ := b.PieceAtSquare(fromBB)
-					if piece == Pawn {
-						toBB := m.GetTo64()
-						// Si no hay pieza en el destino pero es captura, es en passant
-						if (b.AllPieces() & toBB) == 0 {
-							res.EnPassants++
-						}
-					}
-				}
-				// Detectar jaques
-				state := b.perftMakeMove(m)
-				// Tras hacer el movimiento, WhiteToMove indica el lado que debe responder.
-				// Si el rey de ese lado está siendo atacado, el movimiento ha dado jaque.
-				if b.IsKingInCheck(b.WhiteToMove) {
-					res.Checks++
-				}
-				// }
-				b.unmakeMove(state)
-
-				// Detectar promociones
-				fromBB := m.GetFrom64()
-				piece, _ := b.PieceAtSquare(fromBB)
-				if piece == Pawn {
-					toBB := m.GetTo64()
-					toSq := uint8(bits.TrailingZeros64(toBB))
-					toRow := toSq / 8
-					if toRow == 0 || toRow == 7 {
-						res.Promotions++
-					}
-				}
-				if m.Type == MoveKingCastle || m.Type == MoveQueenCastle {
-					res.Castles++
-				}
+				res.Add(b.perftMoveStats(m))
 			}
 		}
 		return res
 	}
+	var key uint64
+	if cache != nil {
+		key = b.hash
+		if cached, ok := cache.get(key, depth); ok {
+			return cached
+		}
+	}
 	for _, m := range moves {
 		if !b.isMoveLegal(m) {
 			continue
 		}
 		// Clone here is slover, so we use make/unmake
 		state := b.perftMakeMove(m)
-		deeperRes := b.Perft(depth-1, false)
-		res.Add(deeperRes)
+		res.Add(b.perft(depth-1, cache))
 		b.unmakeMove(state)
+	}
+	if cache != nil {
+		cache.put(key, depth, res)
+	}
+	return res
+}
 
-		if start {
-			PerftMoveMap[m] = deeperRes
+// perftMoveStats classifies a legal move as a perft leaf (depth 1).
+func (b *Board) perftMoveStats(m Move) PerftResult {
+	res := PerftResult{Nodes: 1}
+	piece := b.MovingPiece(m)
+	if m.IsCapture() {
+		res.Captures++
+		// Detect "en passant" capture
+		if piece == Pawn {
+			toBB := m.GetTo64()
+			// Si no hay pieza en el destino pero es captura, es en passant
+			if b.emptyAt(toBB) {
+				res.EnPassants++
+			}
+		}
+	}
+	// Detectar jaques
+	state := b.perftMakeMove(m)
+	// Tras hacer el movimiento, WhiteToMove indica el lado que debe responder.
+	// Si el rey de ese lado está siendo atacado, el movimiento ha dado jaque.
+	if b.IsKingInCheck(b.WhiteToMove) {
+		res.Checks++
+		king, attackers := b.BlackPieces.King, b.WhiteOccupiedSquares()
+		if b.WhiteToMove {
+			king, attackers = b.WhitePieces.King, b.BlackOccupiedSquares()
+		}
+		checkers := b.AttackersTo(uint8(bits.TrailingZeros64(king)), b.AllPieces()) & attackers
+		// Como en las tablas de la wiki, un jaque doble no cuenta además como descubierto,
+		// que es el que da una pieza distinta de la que ha movido (en el enroque también
+		// mueve la torre)
+		moved := m.GetTo64()
+		switch m.Type() {
+		case MoveKingCastle:
+			moved |= moved >> 1
+		case MoveQueenCastle:
+			moved |= moved << 1
 		}
+		if bits.OnesCount64(checkers) > 1 {
+			res.DoubleChecks++
+		} else if checkers&^moved != 0 {
+			res.DiscoveryChecks++
+		}
+		if !b.hasLegalMove() {
+			res.Checkmates++
+		}
+	}
+	b.unmakeMove(state)
 
-		// copy := b.Clone()
-		// copy.perftMakeMove(m)
-		// deeperRes := copy.Perft(depth - 1)
-		// res.Nodes += deeperRes.Nodes
-		// res.Captures += deeperRes.Captures
+	// Detectar promociones
+	if piece == Pawn {
+		toRow := m.To() / 8
+		if toRow == 0 || toRow == 7 {
+			res.Promotions++
+		}
+	}
+	if m.Type() == MoveKingCastle || m.Type() == MoveQueenCastle {
+		res.Castles++
 	}
 	return res
 }
 
+// PerftResult holds the perft counters in the order of the chessprogramming wiki tables.
+// Every counter except Nodes refers to the last move of each path.
 type PerftResult struct {
-	Nodes      int
-	Captures   int
-	EnPassants int
-	Castles    int
-	Promotions int
-	Checks     int
+	Nodes           int
+	Captures        int
+	EnPassants      int
+	Castles         int
+	Promotions      int
+	Checks          int
+	DiscoveryChecks int // Single checks given by a piece other than the one that moved
+	DoubleChecks    int
+	Checkmates      int
 }
 
 func (r *PerftResult) Add(other PerftResult) {
@@ -101,23 +135,164 @@ func (r *PerftResult) Add(other PerftResult) {
 	r.Castles += other.Castles
 	r.Promotions += other.Promotions
 	r.Checks += other.Checks
+	r.DiscoveryChecks += other.DiscoveryChecks
+	r.DoubleChecks += other.DoubleChecks
+	r.Checkmates += other.Checkmates
+}
+
+// hasLegalMove reports whether the side to move has at least one legal move.
+func (b *Board) hasLegalMove() bool {
+	var buf [maxMoves]Move
+	for _, m := range b.GenerateMoves(buf[:0]) {
+		if b.isMoveLegal(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// PerftMove is the perft result of the subtree below one root move.
+type PerftMove struct {
+	Move   Move
+	Result PerftResult
+}
+
+// PerftOptions configures PerftDivide.
+type PerftOptions struct {
+	Threads int // Goroutines splitting the root moves (0 = GOMAXPROCS)
+	HashMB  int // Size of the subtree cache shared by all goroutines (0 = no cache)
+}
+
+// PerftDivide runs perft to the given depth and returns the result below each legal root
+// move, in move generation order. Root moves are handed out to opts.Threads goroutines,
+// each working on its own copy of the board, so it is safe to call concurrently.
+func (b *Board) PerftDivide(depth int, opts PerftOptions) []PerftMove {
+	if depth < 1 {
+		return nil
+	}
+	// isMoveLegal hace y deshace el movimiento, así que la raíz también trabaja sobre una copia
+	root := b.Clone()
+	var moves []PerftMove
+	for _, m := range root.strictLegalMoves() {
+		moves = append(moves, PerftMove{Move: m})
+	}
+	var cache *perftCache
+	if opts.HashMB > 0 {
+		cache = newPerftCache(opts.HashMB)
+	}
+	threads := opts.Threads
+	if threads <= 0 {
+		threads = runtime.GOMAXPROCS(0)
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(threads, len(moves)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			board := b.Clone()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(moves) {
+					return
+				}
+				m := moves[i].Move
+				if depth == 1 {
+					moves[i].Result = board.perftMoveStats(m)
+					continue
+				}
+				state := board.perftMakeMove(m)
+				moves[i].Result = board.perft(depth-1, cache)
+				board.unmakeMove(state)
+			}
+		}()
+	}
+	wg.Wait()
+	return moves
 }
 
-// Perft helper for tests keeping previous API style.
+// PerftTotal adds up the results of PerftDivide.
+func PerftTotal(moves []PerftMove) PerftResult {
+	var total PerftResult
+	for _, m := range moves {
+		total.Add(m.Result)
+	}
+	return total
+}
+
+// Perft helper for tests keeping previous API style. It splits the root moves among all
+// available CPUs.
 func Perft(board *Board, depth int) PerftResult {
-	return board.Perft(depth, true)
+	if depth == 0 {
+		return PerftResult{Nodes: 1}
+	}
+	return PerftTotal(board.PerftDivide(depth, PerftOptions{}))
+}
+
+// perftCache stores perft results by (Zobrist key, depth). Entries are always replaced;
+// a striped set of mutexes lets all PerftDivide goroutines share it.
+type perftCache struct {
+	entries []perftEntry
+	mask    uint64
+	locks   [256]sync.Mutex
+}
+
+type perftEntry struct {
+	key    uint64
+	depth  int // 0 = empty
+	result PerftResult
+}
+
+func newPerftCache(sizeMB int) *perftCache {
+	entrySize := uint64(unsafe.Sizeof(perftEntry{}))
+	n := uint64(1)
+	for n*2*entrySize <= uint64(sizeMB)<<20 {
+		n *= 2
+	}
+	return &perftCache{entries: make([]perftEntry, n), mask: n - 1}
 }
 
-// moveState stores the information needed to undo a move quickly.
+// slot mixes the depth into the key so the same position at different depths does not
+// always compete for one entry.
+func (c *perftCache) slot(key uint64, depth int) uint64 {
+	return (key ^ uint64(depth)*0x9e3779b97f4a7c15) & c.mask
+}
+
+func (c *perftCache) get(key uint64, depth int) (PerftResult, bool) {
+	i := c.slot(key, depth)
+	lock := &c.locks[i%uint64(len(c.locks))]
+	lock.Lock()
+	e := c.entries[i]
+	lock.Unlock()
+	return e.result, e.key == key && e.depth == depth
+}
+
+func (c *perftCache) put(key uint64, depth int, r PerftResult) {
+	i := c.slot(key, depth)
+	lock := &c.locks[i%uint64(len(c.locks))]
+	lock.Lock()
+	c.entries[i] = perftEntry{key: key, depth: depth, result: r}
+	lock.Unlock()
+}
+
+// moveState stores the information needed to undo a move quickly, including the board state
+// that the move itself does not carry (castling rights, en passant square, ...).
 type moveState struct {
-	move            Move
-	captured        Piece // 0 if none
-	capturedIsWhite bool
-	whitePieces     Pieces
-	blackPieces     Pieces
-	castling        CastleRights
-	enPassant       uint8
-	whiteToMove     bool
+	move        Move
+	whitePieces Pieces
+	blackPieces Pieces
+	hash        uint64
+	touched     [4]uint8 // Squares the move changes in the mailbox: from, to, en passant pawn or castling rook
+	codes       [4]uint8 // Contents of the touched squares before the move
+	castling    CastleRights
+	enPassant   uint8
+	whiteToMove bool
+	checks      [2]uint8
+	hands       [2]Hand
+	promoted    uint64
+	halfMove    uint32
+	fullMove    uint32
 }
 
 // perftMakeMove applies a move (already assumed pseudo-legal) and returns the previous state.
@@ -126,37 +301,41 @@ func (b *Board) perftMakeMove(m Move) moveState {
 		move:        m,
 		whitePieces: b.WhitePieces,
 		blackPieces: b.BlackPieces,
+		hash:        b.hash,
 		castling:    b.Castling,
 		enPassant:   b.EnPassant,
 		whiteToMove: b.WhiteToMove,
+		checks:      b.Checks,
+		hands:       b.Hands,
+		promoted:    b.Promoted,
+		halfMove:    b.HalfMove,
+		fullMove:    b.FullMove,
 	}
 
-	fromBB := m.GetFrom64()
-	toBB := m.GetTo64()
-	piece, isWhite := b.PieceAtSquare(fromBB)
-	// Detect capture (including en passant) before modifying piece sets
-	var capturedPiece Piece
-	var capturedIsWhite bool
-	if m.IsCapture() {
-		// Normal capture: piece sitting on destination
-		if (b.AllPieces() & toBB) != 0 {
-			capturedPiece, capturedIsWhite = b.PieceAtSquare(toBB)
-		} else if piece == Pawn { // possible en passant
-			if isWhite { // capture black pawn behind
-				capturedPiece = Pawn
-				capturedIsWhite = false
-			} else {
-				capturedPiece = Pawn
-				capturedIsWhite = true
-			}
+	// Only these squares change in the mailbox; repeating one is harmless
+	from, to := m.From(), m.To()
+	if m.IsDrop() {
+		from = to
+	}
+	st.touched = [4]uint8{from, to, to, to}
+	if rookFrom, rookTo, ok := b.castlingRookSquares(m.Type(), b.WhiteToMove); ok {
+		st.touched[2] = uint8(bits.TrailingZeros64(rookFrom))
+		st.touched[3] = uint8(bits.TrailingZeros64(rookTo))
+	} else if m.IsCapture() && b.squares[to] == 0 { // en passant: the pawn behind the destination
+		if b.WhiteToMove {
+			st.touched[2] = to - 8
+		} else {
+			st.touched[2] = to + 8
 		}
 	}
+	for i, sq := range st.touched {
+		st.codes[i] = b.squares[sq]
+	}
 
-	// Move the piece
-	b.MovePiece(m, isWhite)
-
-	st.captured = capturedPiece
-	st.capturedIsWhite = capturedIsWhite
+	if b.nnue != nil {
+		b.nnue.push()
+	}
+	b.MovePiece(m, b.WhiteToMove)
 	return st
 }
 
@@ -165,30 +344,49 @@ func (b *Board) unmakeMove(st moveState) {
 	// Restore bulk state first
 	b.WhitePieces = st.whitePieces
 	b.BlackPieces = st.blackPieces
+	b.hash = st.hash
+	for i, sq := range st.touched {
+		b.squares[sq] = st.codes[i]
+	}
 	b.Castling = st.castling
 	b.EnPassant = st.enPassant
 	b.WhiteToMove = st.whiteToMove
+	b.Checks = st.checks
+	b.Hands = st.hands
+	b.Promoted = st.promoted
+	b.HalfMove = st.halfMove
+	b.FullMove = st.fullMove
+	if b.nnue != nil {
+		b.nnue.pop()
+	}
 }
 
-// isMoveLegal checks if executing m leaves own king in check.
+// makeNullMove passes the turn without moving (used by null-move pruning) and returns the
+// en passant square it clears, to be restored with unmakeNullMove.
+func (b *Board) makeNullMove() uint8 {
+	ep := b.EnPassant
+	b.hash ^= b.enPassantKey() ^ zobristBlack
+	b.EnPassant = 0
+	b.WhiteToMove = !b.WhiteToMove
+	return ep
+}
+
+// unmakeNullMove undoes makeNullMove.
+func (b *Board) unmakeNullMove(ep uint8) {
+	b.EnPassant = ep
+	b.hash ^= b.enPassantKey() ^ zobristBlack
+	b.WhiteToMove = !b.WhiteToMove
+}
+
+// isMoveLegal checks if executing m leaves own king in check. The move is made and unmade
+// on b itself, without touching the NNUE accumulators.
 func (b *Board) isMoveLegal(m Move) bool {
-	copy := b.Clone()
-	st := copy.perftMakeMove(m)
+	nnue := b.nnue
+	b.nnue = nil
+	st := b.perftMakeMove(m)
 	// Check if previous side's king is attacked.
-	var king uint64
-	if st.whiteToMove { // white moved
-		king = copy.WhitePieces.King
-		kingSq := uint8(bits.TrailingZeros64(king))
-		row := int8(kingSq / 8)
-		col := int8(kingSq % 8)
-		inCheck := copy.SquareAttacked(row, col, true)
-		return !inCheck
-	} else { // black moved
-		king = copy.BlackPieces.King
-		kingSq := uint8(bits.TrailingZeros64(king))
-		row := int8(kingSq / 8)
-		col := int8(kingSq % 8)
-		inCheck := copy.SquareAttacked(row, col, false)
-		return !inCheck
-	}
+	legal := !b.IsKingInCheck(st.whiteToMove)
+	b.unmakeMove(st)
+	b.nnue = nnue
+	return legal
 }
@@ -0,0 +1,224 @@
+package melange
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// startFEN es la posición inicial; las partidas que empiezan en otra llevan la etiqueta FEN.
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// Opening es una apertura para una partida: una posición inicial y los movimientos que
+// se juegan desde ella antes de que empiecen a pensar los motores.
+type Opening struct {
+	Fen   string
+	Moves []Move
+}
+
+// LoadOpenings lee un fichero de aperturas: PGN si la extensión es .pgn (cada partida es
+// una apertura) y si no FEN o EPD, una posición por línea como en LoadBook.
+func LoadOpenings(path string) ([]Opening, error) {
+	if strings.EqualFold(filepath.Ext(path), ".pgn") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		openings, err := ParsePGN(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return openings, nil
+	}
+	book, err := LoadBook(path)
+	if err != nil {
+		return nil, err
+	}
+	openings := make([]Opening, len(book))
+	for i, fen := range book {
+		openings[i] = Opening{Fen: fen + " 0 1"}
+	}
+	return openings, nil
+}
+
+// ParsePGN lee las partidas de un texto PGN. De cada una se toma la posición inicial
+// (etiqueta FEN o la posición de salida) y la línea principal; se ignoran los
+// comentarios, las variantes, los NAG y el resultado.
+func ParsePGN(text string) ([]Opening, error) {
+	var openings []Opening
+	var current *Opening
+	var board *Board
+	finish := func() {
+		if current != nil {
+			openings = append(openings, *current)
+			current, board = nil, nil
+		}
+	}
+	start := func(fen string) error {
+		board = &Board{}
+		if err := board.SetFen(fen); err != nil {
+			return err
+		}
+		current = &Opening{Fen: fen}
+		return nil
+	}
+
+	depth := 0 // Anidamiento de variantes
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			// Una etiqueta después de movimientos empieza otra partida
+			if current != nil && len(current.Moves) > 0 {
+				finish()
+			}
+			if fen, ok := pgnTag(line, "FEN"); ok {
+				if err := start(fen); err != nil {
+					return nil, fmt.Errorf("FEN inválido: %s: %w", fen, err)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "%") {
+			continue
+		}
+		for len(line) > 0 {
+			var token string
+			switch line[0] {
+			case '{':
+				end := strings.IndexByte(line, '}')
+				if end < 0 {
+					return nil, fmt.Errorf("comentario sin cerrar: %s", line)
+				}
+				line = line[end+1:]
+				continue
+			case ';':
+				line = ""
+				continue
+			case '(':
+				depth++
+				line = line[1:]
+				continue
+			case ')':
+				depth--
+				line = line[1:]
+				continue
+			case ' ', '\t':
+				line = line[1:]
+				continue
+			}
+			end := strings.IndexAny(line, " \t{}();")
+			if end < 0 {
+				end = len(line)
+			}
+			token, line = line[:end], line[end:]
+			if depth > 0 || strings.HasPrefix(token, "$") {
+				continue
+			}
+			switch token {
+			case "1-0", "0-1", "1/2-1/2", "*":
+				finish()
+				continue
+			}
+			// Números de jugada: "1." "1..." o pegados al movimiento, "1.e4"
+			if i := strings.LastIndexByte(token, '.'); i >= 0 {
+				token = token[i+1:]
+			}
+			if token == "" {
+				continue
+			}
+			if current == nil {
+				if err := start(startFEN); err != nil {
+					return nil, err
+				}
+			}
+			m, err := board.ParseSAN(token)
+			if err != nil {
+				return nil, fmt.Errorf("partida %d: %w", len(openings)+1, err)
+			}
+			current.Moves = append(current.Moves, m)
+			board.MovePiece(m, board.WhiteToMove)
+		}
+	}
+	finish()
+	return openings, nil
+}
+
+// pgnTag devuelve el valor de la etiqueta name si line es [name "valor"].
+func pgnTag(line, name string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, "["+name+" ")
+	if !ok {
+		return "", false
+	}
+	rest = strings.TrimSuffix(strings.TrimSpace(rest), "]")
+	return strings.Trim(strings.TrimSpace(rest), "\""), true
+}
+
+// PGNGame es una partida terminada lista para escribir en PGN.
+type PGNGame struct {
+	Tags   [][2]string // Etiquetas tras las siete obligatorias, en orden
+	Event  string
+	Round  string
+	White  string
+	Black  string
+	Date   string // AAAA.MM.DD
+	Fen    string // Posición inicial
+	Moves  []Move
+	Result GameResult
+}
+
+// WritePGN escribe la partida con las siete etiquetas obligatorias, SetUp y FEN si no
+// empieza en la posición de salida, las etiquetas extra y los movimientos en SAN en
+// líneas de como mucho 80 caracteres.
+func (g *PGNGame) WritePGN(w io.Writer) error {
+	b := &Board{}
+	if err := b.SetFen(g.Fen); err != nil {
+		return err
+	}
+	tags := [][2]string{
+		{"Event", g.Event}, {"Site", "?"}, {"Date", g.Date}, {"Round", g.Round},
+		{"White", g.White}, {"Black", g.Black}, {"Result", g.Result.String()},
+	}
+	if g.Fen != startFEN {
+		tags = append(tags, [2]string{"SetUp", "1"}, [2]string{"FEN", g.Fen})
+	}
+	var sb strings.Builder
+	for _, tag := range append(tags, g.Tags...) {
+		value := strings.ReplaceAll(tag[1], `"`, `\"`)
+		if value == "" {
+			value = "?"
+		}
+		fmt.Fprintf(&sb, "[%s \"%s\"]\n", tag[0], value)
+	}
+	sb.WriteString("\n")
+
+	var tokens []string
+	game := NewGame(b)
+	for i, m := range g.Moves {
+		b := game.Board
+		if b.WhiteToMove {
+			tokens = append(tokens, fmt.Sprintf("%d.", b.FullMove))
+		} else if i == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d...", b.FullMove))
+		}
+		tokens = append(tokens, b.SAN(m))
+		game.Play(m)
+	}
+	tokens = append(tokens, g.Result.String())
+	lineLen := 0
+	for _, token := range tokens {
+		if lineLen > 0 && lineLen+1+len(token) > 80 {
+			sb.WriteString("\n")
+			lineLen = 0
+		} else if lineLen > 0 {
+			sb.WriteString(" ")
+			lineLen++
+		}
+		sb.WriteString(token)
+		lineLen += len(token)
+	}
+	sb.WriteString("\n\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,98 @@
+package melange
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const pgnOpenings = `[Event "Aperturas"]
+[White "?"]
+
+1. e4 {Rey} e5 (1... c5 2. Nf3) 2. Nf3 $1 Nc6 3.Bb5 a6 *
+
+[Event "Desde FEN"]
+[SetUp "1"]
+[FEN "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1"]
+
+1... O-O 2. 0-0-0 ; comentario hasta el final
+1/2-1/2
+`
+
+func TestParsePGN(t *testing.T) {
+	openings, err := ParsePGN(pgnOpenings)
+	assert.NilError(t, err)
+	assert.Equal(t, len(openings), 2)
+	assert.Equal(t, openings[0].Fen, startFEN)
+	var moves []string
+	for _, m := range openings[0].Moves {
+		moves = append(moves, m.UCIString())
+	}
+	assert.DeepEqual(t, moves, []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1b5", "a7a6"})
+
+	assert.Equal(t, openings[1].Fen, "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1")
+	assert.Equal(t, len(openings[1].Moves), 2)
+	assert.Equal(t, openings[1].Moves[0].Type(), MoveKingCastle)
+	assert.Equal(t, openings[1].Moves[1].Type(), MoveQueenCastle)
+
+	_, err = ParsePGN("1. e4 e4")
+	assert.ErrorContains(t, err, "e4")
+	_, err = ParsePGN("1. e4 {sin cerrar")
+	assert.ErrorContains(t, err, "comentario")
+}
+
+func TestWritePGNRoundTrip(t *testing.T) {
+	openings, err := ParsePGN(pgnOpenings)
+	assert.NilError(t, err)
+	for _, o := range openings {
+		g := PGNGame{Event: "Prueba", Round: "1", White: "A", Black: "B \"el bueno\"", Fen: o.Fen, Moves: o.Moves,
+			Result: Draw, Tags: [][2]string{{"Termination", "adjudication"}}}
+		var out bytes.Buffer
+		assert.NilError(t, g.WritePGN(&out))
+		text := out.String()
+		assert.Assert(t, strings.Contains(text, `[Black "B \"el bueno\""]`), text)
+		assert.Assert(t, strings.Contains(text, `[Termination "adjudication"]`), text)
+		assert.Equal(t, strings.Contains(text, "[FEN "), o.Fen != startFEN, text)
+		assert.Assert(t, strings.HasSuffix(text, "1/2-1/2\n\n"), text)
+
+		parsed, err := ParsePGN(text)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, parsed, []Opening{o})
+	}
+
+	// Movimientos de las negras al principio y líneas de como mucho 80 caracteres
+	var out bytes.Buffer
+	g := PGNGame{Fen: openings[1].Fen, Moves: openings[1].Moves}
+	assert.NilError(t, g.WritePGN(&out))
+	assert.Assert(t, strings.Contains(out.String(), "\n1... O-O 2. O-O-O *\n"), out.String())
+
+	game := NewGame(NewBoard())
+	for range 30 {
+		game.Play(game.LegalMoves()[0])
+	}
+	out.Reset()
+	g = PGNGame{Fen: startFEN, Moves: game.Moves}
+	assert.NilError(t, g.WritePGN(&out))
+	for _, line := range strings.Split(out.String(), "\n") {
+		assert.Assert(t, len(line) <= 80, line)
+	}
+}
+
+func TestLoadOpenings(t *testing.T) {
+	dir := t.TempDir()
+	pgnPath := filepath.Join(dir, "book.PGN")
+	assert.NilError(t, os.WriteFile(pgnPath, []byte(pgnOpenings), 0o644))
+	openings, err := LoadOpenings(pgnPath)
+	assert.NilError(t, err)
+	assert.Equal(t, len(openings), 2)
+
+	epdPath := filepath.Join(dir, "book.epd")
+	assert.NilError(t, os.WriteFile(epdPath, []byte("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 bm e5;\n"), 0o644))
+	openings, err = LoadOpenings(epdPath)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, openings, []Opening{{Fen: "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"}})
+}
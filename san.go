@@ -0,0 +1,103 @@
+package melange
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SAN devuelve el movimiento legal m en notación algebraica estándar (Nf3, exd5, O-O,
+// e8=Q+, Raxd1#), con la desambiguación mínima y la marca de jaque o mate.
+func (b *Board) SAN(m Move) string {
+	san := b.sanWithoutCheck(m, b.strictLegalMoves())
+	after := b.Clone()
+	after.MovePiece(m, b.WhiteToMove)
+	if after.IsKingInCheck(after.WhiteToMove) {
+		if after.hasLegalMove() {
+			san += "+"
+		} else {
+			san += "#"
+		}
+	}
+	return san
+}
+
+// sanWithoutCheck es SAN sin la marca de jaque; legal son los movimientos legales de la
+// posición, necesarios para desambiguar.
+func (b *Board) sanWithoutCheck(m Move, legal MoveList) string {
+	switch m.Type() {
+	case MoveKingCastle:
+		return "O-O"
+	case MoveQueenCastle:
+		return "O-O-O"
+	case MoveDrop:
+		return m.ToSimpleString()
+	}
+	to := squareToString(m.To())
+	piece := b.MovingPiece(m)
+	var san string
+	if piece == Pawn {
+		if m.IsCapture() {
+			san = squareToString(m.From())[:1] + "x"
+		}
+		san += to
+		if promo := m.PromotionPiece(); promo != 0 {
+			san += "=" + string(" PNBRQK"[promo])
+		}
+		return san
+	}
+
+	san = string(" PNBRQK"[piece])
+	// Desambiguación: columna si basta, si no fila, y si no ambas
+	sameFile, sameRank, ambiguous := false, false, false
+	for _, other := range legal {
+		if other.To() != m.To() || other.From() == m.From() || other.IsDrop() || b.MovingPiece(other) != piece {
+			continue
+		}
+		ambiguous = true
+		if other.From()%8 == m.From()%8 {
+			sameFile = true
+		}
+		if other.From()/8 == m.From()/8 {
+			sameRank = true
+		}
+	}
+	from := squareToString(m.From())
+	switch {
+	case !ambiguous:
+	case !sameFile:
+		san += from[:1]
+	case !sameRank:
+		san += from[1:]
+	default:
+		san += from
+	}
+	if m.IsCapture() {
+		san += "x"
+	}
+	return san + to
+}
+
+// ParseSAN devuelve el movimiento legal que corresponde a san. Admite las variantes
+// habituales en los ficheros EPD y PGN: sin '=' en las promociones, enroques con ceros,
+// marcas de jaque y anotaciones (!, ?) opcionales, peones soltados sin la P (@e4), y
+// también movimientos en notación UCI (en Chess960, con los enroques como captura de la
+// torre).
+func (b *Board) ParseSAN(san string) (Move, error) {
+	want := normalizeSAN(san)
+	legal := b.strictLegalMoves()
+	for _, m := range legal {
+		if normalizeSAN(b.sanWithoutCheck(m, legal)) == want || b.UCIMove(m) == san {
+			return m, nil
+		}
+	}
+	return NoMove, fmt.Errorf("movimiento ilegal o desconocido: %s", san)
+}
+
+func normalizeSAN(san string) string {
+	san = strings.TrimRight(san, "+#!?")
+	san = strings.ReplaceAll(san, "0", "O")
+	if strings.HasPrefix(san, "@") {
+		san = "P" + san
+	}
+	return strings.ReplaceAll(san, "=", "")
+}
@@ -0,0 +1,61 @@
+package melange
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSAN(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move string
+		san  string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "g1f3", "Nf3"},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2", "e4d5", "exd5"},
+		// Desambiguación por columna, por fila y por ambas
+		{"4k3/8/8/8/8/8/8/R4RK1 w - - 0 1", "a1d1", "Rad1"},
+		{"4k3/8/8/R7/8/8/8/R3K3 w Q - 0 1", "a1a3", "R1a3"},
+		{"7k/8/8/8/2Q1Q3/8/2Q5/4K3 w - - 0 1", "c4d3", "Qc4d3"},
+		// Un caballo clavado no cuenta para desambiguar
+		{"4k3/4r3/8/8/8/2N3N1/8/4K3 w - - 0 1", "c3e4", "Nce4"},
+		{"4k3/4r3/8/8/8/2N5/4N3/4K3 w - - 0 1", "c3d5", "Nd5"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"3k4/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8q", "b8=Q+"},
+		{"2r1k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7c8n", "bxc8=N"},
+		{"6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1", "a1a8", "Ra8#"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", "exd6"},
+	}
+	for _, tt := range tests {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(tt.fen))
+		m := findMove(t, b, tt.move)
+		assert.Equal(t, b.SAN(m), tt.san, tt.fen)
+		parsed, err := b.ParseSAN(tt.san)
+		assert.NilError(t, err, tt.fen)
+		assert.Equal(t, parsed, m, tt.fen)
+	}
+}
+
+func TestParseSANVariants(t *testing.T) {
+	b := &Board{}
+	assert.NilError(t, b.SetFen("r3k2r/1P6/8/8/8/8/8/R3K2R w KQkq - 0 1"))
+	for san, uci := range map[string]string{
+		"0-0":    "e1g1",
+		"O-O-O":  "e1c1",
+		"b8Q":    "b7b8q",
+		"bxa8=R": "b7a8r",
+		"Rxa8!?": "a1a8",
+		"h1h8":   "h1h8",
+	} {
+		m, err := b.ParseSAN(san)
+		assert.NilError(t, err, san)
+		assert.Equal(t, m.UCIString(), uci, san)
+	}
+	for _, san := range []string{"Nf3", "e4", "Kd3", "b8"} {
+		_, err := b.ParseSAN(san)
+		assert.ErrorContains(t, err, "ilegal", san)
+	}
+}
@@ -0,0 +1,809 @@
+package melange
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxPly limita la profundidad máxima de búsqueda (incluida la quiescencia)
+	maxPly = 128
+	// infinityScore es mayor que cualquier evaluación posible
+	infinityScore = 32000
+	// mateScore es la puntuación de un mate en la raíz para el bando que da mate. Los mates
+	// más lejanos valen un punto menos por ply (ver mateIn y matedIn)
+	mateScore = 30000
+	// tbWinScore es la puntuación de una victoria según las tablas de finales, por debajo
+	// de cualquier mate encontrado por la búsqueda
+	tbWinScore = mateScore - 2*maxPly
+)
+
+// SearchLimits indica cuándo debe detenerse la búsqueda. Un valor cero significa sin límite;
+// si no se indica ninguno se busca a profundidad 1.
+type SearchLimits struct {
+	Depth    int
+	Nodes    int64
+	MoveTime time.Duration
+
+	// Stop, si no es nil, interrumpe la búsqueda al cerrarse. Sin otros límites se busca
+	// hasta entonces (análisis infinito).
+	Stop <-chan struct{}
+}
+
+// SearchResult es el resultado de la última iteración completa de la búsqueda.
+type SearchResult struct {
+	BestMove Move
+	Score    int // Desde el punto de vista del bando al mover
+	Depth    int
+	Nodes    int64
+	TBHits   int64 // Consultas a las tablas de finales con resultado
+	PV       MoveList
+
+	// LowerBound o UpperBound indican que Score es solo una cota, porque la búsqueda se
+	// salió de la ventana de aspiración y se está repitiendo con una más amplia
+	LowerBound bool
+	UpperBound bool
+}
+
+// Searcher implementa una búsqueda alfa-beta (negamax) con profundización iterativa y
+// búsqueda de quiescencia sobre capturas. Cada Searcher tiene su propio estado, así que
+// se pueden usar varios en paralelo (uno por goroutine). Con Threads > 1 la propia
+// búsqueda reparte el trabajo en varios hilos (Lazy SMP).
+type Searcher struct {
+	eval Evaluator
+
+	// Threads es el número de hilos de búsqueda (0 o 1: solo el que llama a Search). Los
+	// hilos auxiliares buscan la misma raíz con su propio tablero e historial y comparten
+	// la tabla de transposición, que es lo que les hace repartirse el árbol.
+	Threads int
+
+	// OnIteration, si no es nil, se llama al terminar cada iteración completa y cada vez
+	// que una iteración se sale de la ventana de aspiración (con LowerBound o UpperBound)
+	OnIteration func(SearchResult)
+
+	// Tablebase, si no es nil, se usa en la raíz para quedarse con las jugadas que mantienen
+	// el mejor resultado y dentro del árbol para cortar con el resultado WDL a partir de
+	// TBProbeDepth (o a cualquier profundidad con menos piezas que las tablas)
+	Tablebase    *Tablebase
+	TBProbeDepth int
+
+	// Params activa y ajusta las técnicas de búsqueda selectiva
+	Params SearchParams
+
+	// GameHistory son las claves Zobrist (Board.Hash) de las posiciones de la partida
+	// anteriores a la raíz, de la más antigua a la más reciente. Sin ellas solo se
+	// detectan las repeticiones dentro del árbol de búsqueda.
+	GameHistory []uint64
+
+	// Contempt es, en centipawns, cuánto peor que 0 valora el bando que busca unas tablas
+	// por repetición: con un valor positivo las evita y con uno negativo las busca.
+	Contempt int
+
+	board    *Board
+	limits   SearchLimits
+	deadline time.Time
+	nodes    atomic.Int64 // Los demás hilos los leen para sumar el total
+	tbHits   atomic.Int64
+	stopped  bool
+
+	id      int         // 0 en el hilo principal, 1.. en los auxiliares
+	helpers []*Searcher // Hilos auxiliares, que se conservan entre búsquedas
+	shared  *smpShared
+
+	rootDepth    int  // Profundidad de la iteración en curso
+	nullDisabled bool // Durante la verificación del movimiento nulo no se vuelve a pasar
+
+	rootMoves MoveList // Jugadas permitidas en la raíz (todas si está vacía)
+
+	// TT es la tabla de transposición; NewSearcher crea una propia, pero se puede
+	// sustituir por una compartida
+	TT *TranspositionTable
+
+	// Heurísticas de ordenación de movimientos tranquilos
+	killers   [maxPly][2]Move
+	counter   [64][64]Move   // Respuesta que refutó el movimiento [origen][destino]
+	history   [2][64][64]int // [bando][origen][destino]
+	moveStack [maxPly]Move   // Movimiento jugado en cada ply de la rama actual
+	keys      [maxPly]uint64 // Clave de la posición en cada ply de la rama actual
+
+	pv    [maxPly][maxPly]Move
+	pvLen [maxPly]int
+}
+
+// NewSearcher crea un buscador que usa el evaluador indicado.
+func NewSearcher(eval Evaluator) *Searcher {
+	s := &Searcher{eval: eval, TT: NewTranspositionTable(DefaultHashMB), Params: DefaultSearchParams()}
+	s.shared = &smpShared{workers: []*Searcher{s}}
+	return s
+}
+
+// newGame borra la tabla de transposición y las heurísticas de ordenación para que la
+// siguiente búsqueda no dependa de las partidas anteriores.
+func (s *Searcher) newGame() {
+	s.TT.Clear()
+	for _, w := range append([]*Searcher{s}, s.helpers...) {
+		w.killers = [maxPly][2]Move{}
+		w.counter = [64][64]Move{}
+		w.history = [2][64][64]int{}
+	}
+}
+
+// smpShared es el estado común a los hilos de una búsqueda.
+type smpShared struct {
+	stop    atomic.Bool
+	workers []*Searcher // El principal y los auxiliares
+}
+
+// Search busca el mejor movimiento de la posición. El tablero no se modifica.
+// Si la posición no tiene movimientos legales, BestMove queda vacío (From == To == 0).
+// Con varios hilos el resultado es el del hilo más votado (ver vote) y Nodes y TBHits
+// suman los de todos.
+func (s *Searcher) Search(b *Board, limits SearchLimits) SearchResult {
+	for len(s.helpers) < s.Threads-1 {
+		s.helpers = append(s.helpers, &Searcher{eval: s.eval, id: len(s.helpers) + 1})
+	}
+	s.helpers = s.helpers[:max(s.Threads-1, 0)]
+	shared := &smpShared{workers: append([]*Searcher{s}, s.helpers...)}
+
+	s.prepare(b, limits, shared)
+	if moves, _, ok := s.Tablebase.ProbeRoot(s.board); ok {
+		s.rootMoves = moves
+		s.tbHits.Add(1)
+	}
+
+	results := make([]SearchResult, len(shared.workers))
+	var wg sync.WaitGroup
+	for i, h := range s.helpers {
+		h.TT, h.Params = s.TT, s.Params
+		h.Tablebase, h.TBProbeDepth = s.Tablebase, s.TBProbeDepth
+		h.GameHistory, h.Contempt = s.GameHistory, s.Contempt
+		h.prepare(b, limits, shared)
+		h.rootMoves = s.rootMoves
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i+1] = h.iterate()
+		}()
+	}
+	results[0] = s.iterate()
+	shared.stop.Store(true)
+	wg.Wait()
+
+	result := vote(results)
+	result.Nodes = s.totalNodes()
+	result.TBHits = 0
+	for _, w := range shared.workers {
+		result.TBHits += w.tbHits.Load()
+	}
+	return result
+}
+
+// prepare deja el hilo listo para buscar la posición b desde cero.
+func (s *Searcher) prepare(b *Board, limits SearchLimits, shared *smpShared) {
+	s.board = prepareEvaluator(b.Clone(), s.eval)
+	s.limits = limits
+	s.shared = shared
+	s.nodes.Store(0)
+	s.tbHits.Store(0)
+	s.stopped = false
+	s.nullDisabled = false
+	s.rootMoves = nil
+	s.killers = [maxPly][2]Move{}
+	// El historial se conserva entre búsquedas, pero pierde peso
+	for c := range s.history {
+		for from := range s.history[c] {
+			for to := range s.history[c][from] {
+				s.history[c][from][to] /= 2
+			}
+		}
+	}
+	if limits.MoveTime > 0 {
+		s.deadline = time.Now().Add(limits.MoveTime)
+	} else {
+		s.deadline = time.Time{}
+	}
+}
+
+// Los hilos auxiliares se saltan algunas profundidades para no buscar todos lo mismo: el
+// auxiliar i busca la profundidad d solo si (d + skipPhase[i]) / skipSize[i] es par.
+var (
+	skipSize  = [20]int{1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 3, 3, 4, 4, 4, 4, 4, 4, 4, 4}
+	skipPhase = [20]int{0, 1, 0, 1, 2, 3, 0, 1, 2, 3, 4, 5, 0, 1, 2, 3, 4, 5, 6, 7}
+)
+
+// iterate hace la profundización iterativa y devuelve el resultado de la última
+// iteración completa.
+func (s *Searcher) iterate() SearchResult {
+	maxDepth := s.limits.Depth
+	if maxDepth <= 0 {
+		maxDepth = maxPly - 1
+		if s.limits.Nodes == 0 && s.limits.MoveTime == 0 && s.limits.Stop == nil {
+			maxDepth = 1
+		}
+	}
+
+	result := SearchResult{}
+	for depth := 1; depth <= maxDepth; depth++ {
+		if i := (s.id - 1) % len(skipSize); s.id > 0 && depth > 1 && (depth+skipPhase[i])/skipSize[i]%2 != 0 {
+			continue
+		}
+		s.rootDepth = depth
+		score := s.aspiration(depth, result)
+		// Una iteración interrumpida no es fiable salvo que sea la primera del hilo principal
+		if s.stopped && (depth > 1 || s.id > 0) {
+			break
+		}
+		result.Depth = depth
+		result.Score = score
+		result.PV = append(MoveList{}, s.pv[0][:s.pvLen[0]]...)
+		if len(result.PV) > 0 {
+			result.BestMove = result.PV[0]
+		}
+		if s.OnIteration != nil {
+			result.Nodes = s.totalNodes()
+			result.TBHits = s.tbHits.Load()
+			s.OnIteration(result)
+		}
+		if s.stopped {
+			break
+		}
+	}
+	return result
+}
+
+// vote elige entre los resultados de los hilos: cada uno vota por su mejor jugada con un
+// peso que crece con la profundidad alcanzada y con la puntuación, y gana el resultado
+// más profundo de la jugada más votada. Un mate encontrado por la búsqueda se prefiere
+// siempre. Los hilos sin ninguna iteración completa no votan.
+func vote(results []SearchResult) SearchResult {
+	minScore := infinityScore
+	for _, r := range results {
+		if r.Depth > 0 {
+			minScore = min(minScore, r.Score)
+		}
+	}
+	votes := make(map[Move]int)
+	for _, r := range results {
+		if r.Depth > 0 {
+			votes[r.BestMove] += (r.Score - minScore + 14) * r.Depth
+		}
+	}
+	best := results[0]
+	for _, r := range results[1:] {
+		switch {
+		case r.Depth == 0:
+		case best.Depth == 0,
+			r.Score >= mateScore-maxPly && r.Score > best.Score,
+			best.Score < mateScore-maxPly && votes[r.BestMove] > votes[best.BestMove],
+			r.BestMove == best.BestMove && r.Depth > best.Depth:
+			best = r
+		}
+	}
+	return best
+}
+
+// totalNodes suma los nodos de todos los hilos de la búsqueda.
+func (s *Searcher) totalNodes() int64 {
+	var total int64
+	for _, w := range s.shared.workers {
+		total += w.nodes.Load()
+	}
+	return total
+}
+
+// aspiration busca a la profundidad depth con una ventana estrecha alrededor de la
+// puntuación de la iteración anterior (prev). Si el resultado cae fuera, informa de la
+// cota obtenida y repite con la ventana ampliada por ese lado.
+func (s *Searcher) aspiration(depth int, prev SearchResult) int {
+	p := &s.Params
+	alpha, beta := -infinityScore, infinityScore
+	delta := p.AspirationDelta
+	if p.Aspiration && depth >= p.AspirationMinDepth && abs(prev.Score) < tbWinScore-maxPly {
+		alpha, beta = prev.Score-delta, prev.Score+delta
+	}
+	for {
+		score := s.negamax(depth, 0, alpha, beta)
+		if s.stopped {
+			return score
+		}
+		report := prev
+		report.Depth, report.Score = depth, score
+		switch {
+		case score <= alpha && alpha > -infinityScore:
+			// Fail low: se mantiene la variante anterior, que ya no es fiable
+			report.UpperBound = true
+			beta = (alpha + beta) / 2
+			alpha = max(score-delta, -infinityScore)
+		case score >= beta && beta < infinityScore:
+			report.LowerBound = true
+			report.PV = append(MoveList{}, s.pv[0][:s.pvLen[0]]...)
+			beta = min(score+delta, infinityScore)
+		default:
+			return score
+		}
+		if s.OnIteration != nil {
+			report.Nodes = s.totalNodes()
+			report.TBHits = s.tbHits.Load()
+			s.OnIteration(report)
+		}
+		delta *= 2
+	}
+}
+
+// isMateScore indica si una puntuación corresponde a un mate encontrado por la búsqueda.
+func isMateScore(score int) bool {
+	return score >= mateScore-maxPly || score <= -mateScore+maxPly
+}
+
+// mateIn es la puntuación de dar mate a ply plies de la raíz y matedIn la de recibirlo:
+// cuanto más cerca el mate, mayor el valor absoluto, así que la búsqueda prefiere los
+// mates más cortos y, si pierde, los más largos.
+func mateIn(ply int) int {
+	return mateScore - ply
+}
+
+func matedIn(ply int) int {
+	return -mateScore + ply
+}
+
+// mateMoves convierte una puntuación de mate en movimientos hasta el mate, positivos si
+// da mate el bando al mover y negativos si lo recibe.
+func mateMoves(score int) int {
+	if score > 0 {
+		return (mateScore - score + 1) / 2
+	}
+	return -(mateScore + score) / 2
+}
+
+// scoreToTT pasa una puntuación de mate o de tablas de finales, relativa a la raíz, a la
+// distancia desde el nodo del ply indicado, que es la que vale al encontrar la misma
+// posición en otra rama. scoreFromTT hace la conversión inversa.
+func scoreToTT(score, ply int) int {
+	switch {
+	case score >= tbWinScore-maxPly:
+		return score + ply
+	case score <= -tbWinScore+maxPly:
+		return score - ply
+	}
+	return score
+}
+
+func scoreFromTT(score, ply int) int {
+	switch {
+	case score >= tbWinScore-maxPly:
+		return score - ply
+	case score <= -tbWinScore+maxPly:
+		return score + ply
+	}
+	return score
+}
+
+// checkLimits marca la búsqueda como detenida si se ha alcanzado el límite de nodos (de
+// todos los hilos) o de tiempo, o si otro hilo ya la ha detenido.
+func (s *Searcher) checkLimits() {
+	if s.shared.stop.Load() {
+		s.stopped = true
+		return
+	}
+	if s.limits.Nodes > 0 && s.totalNodes() >= s.limits.Nodes {
+		s.stopped = true
+	}
+	if !s.deadline.IsZero() && s.nodes.Load()&1023 == 0 && time.Now().After(s.deadline) {
+		s.stopped = true
+	}
+	if s.limits.Stop != nil && s.nodes.Load()&1023 == 0 {
+		select {
+		case <-s.limits.Stop:
+			s.stopped = true
+		default:
+		}
+	}
+	if s.stopped {
+		s.shared.stop.Store(true)
+	}
+}
+
+// evaluate devuelve la evaluación estática desde el punto de vista del bando al mover.
+func (s *Searcher) evaluate() int {
+	score := s.board.Evaluate(s.eval)
+	if !s.board.WhiteToMove {
+		return -score
+	}
+	return score
+}
+
+func (s *Searcher) negamax(depth, ply int, alpha, beta int) int {
+	s.pvLen[ply] = 0
+	b := s.board
+	p := &s.Params
+	mover := b.WhiteToMove
+	inCheck := b.IsKingInCheck(mover)
+	// En jaque se busca un ply más (la quiescencia no genera evasiones). El límite de la
+	// distancia a la raíz evita que una serie de jaques alargue la rama sin fin.
+	if inCheck && p.CheckExtensions && ply < 2*s.rootDepth {
+		depth++
+	}
+	if b.Variant != Standard {
+		if score, over := b.variantScore(ply); over {
+			return score
+		}
+	}
+	key := b.hash
+	s.keys[ply] = key
+	// Tablas por repetición o por la regla de los cincuenta movimientos, salvo que el
+	// último movimiento haya dado mate
+	if ply > 0 && (s.isRepetition(ply) || b.HalfMove >= 100 && (!inCheck || b.hasLegalMove())) {
+		return s.drawScore(ply)
+	}
+	if depth <= 0 || ply >= maxPly-1 {
+		return s.quiescence(ply, alpha, beta)
+	}
+	if s.stopped {
+		return 0
+	}
+	s.nodes.Add(1)
+	s.checkLimits()
+
+	if ply > 0 {
+		if score, ok := s.probeTablebase(depth, ply); ok {
+			return score
+		}
+	}
+
+	pvNode := beta-alpha > 1
+	// Poda por distancia de mate: ni dando mate en el siguiente movimiento ni recibiéndolo
+	// aquí se mejoraría un mate más corto ya encontrado en otra rama
+	if ply > 0 {
+		alpha = max(alpha, matedIn(ply))
+		beta = min(beta, mateIn(ply+1))
+		if alpha >= beta {
+			return alpha
+		}
+	}
+
+	var ttMove Move
+	if e, ok := s.TT.probe(key); ok {
+		ttMove = e.move
+		score := scoreFromTT(int(e.score), ply)
+		if !pvNode && int(e.depth) >= depth {
+			switch {
+			case e.bound == boundExact,
+				e.bound == boundLower && score >= beta,
+				e.bound == boundUpper && score <= alpha:
+				return score
+			}
+		}
+	}
+
+	// Poda selectiva antes de generar movimientos. Con puntuaciones de mate o de tablas de
+	// finales en la ventana los márgenes no tienen sentido.
+	staticEval := 0
+	if !inCheck {
+		staticEval = s.evaluate()
+	}
+	canPrune := !pvNode && !inCheck && abs(beta) < tbWinScore-maxPly
+	if canPrune && p.ReverseFutility && depth <= p.ReverseFutilityDepth &&
+		staticEval-p.ReverseFutilityMargin*depth >= beta {
+		return staticEval
+	}
+	if canPrune && p.Razoring && depth <= p.RazoringDepth && staticEval+p.RazoringMargin*depth < alpha {
+		if score := s.quiescence(ply, alpha-1, alpha); score < alpha {
+			return score
+		}
+	}
+	if canPrune && p.NullMove && depth >= p.NullMoveMinDepth && staticEval >= beta &&
+		!s.nullDisabled && s.moveStack[ply-1] != NoMove && b.hasNonPawnMaterial(mover) && b.Variant != Antichess {
+		if score, ok := s.nullMove(depth, ply, beta, staticEval); ok {
+			return score
+		}
+	}
+
+	next := newMovePicker(s, ttMove, ply).next
+	if b.Variant == Antichess {
+		next = s.antichessMoves(ttMove)
+	}
+	if ply == 0 && len(s.rootMoves) > 0 {
+		i := 0
+		next = func() (Move, bool) {
+			if i == len(s.rootMoves) {
+				return NoMove, false
+			}
+			i++
+			return s.rootMoves[i-1], true
+		}
+	}
+
+	origAlpha := alpha
+	var bestMove Move
+	var quiets []Move
+	legal, quietCount := 0, 0
+	for m, ok := next(); ok; m, ok = next() {
+		quiet := !m.IsCapture() && m.Type()&MovePromotion == 0
+		st := b.perftMakeMove(m)
+		if b.IsKingInCheck(mover) {
+			b.unmakeMove(st)
+			continue
+		}
+		legal++
+		givesCheck := b.IsKingInCheck(!mover)
+		if quiet {
+			quietCount++
+		}
+		// Cerca de las hojas se descartan los tranquilos tardíos o que no pueden llegar a
+		// alfa, siempre que ya se haya buscado algún movimiento
+		if ply > 0 && quiet && !inCheck && !givesCheck && legal > 1 && alpha > -tbWinScore+maxPly {
+			if p.LateMovePruning && depth <= p.LateMovePruningDepth && quietCount > p.LateMovePruningBase+depth*depth ||
+				p.Futility && depth <= p.FutilityDepth && staticEval+p.FutilityMargin*depth <= alpha {
+				b.unmakeMove(st)
+				continue
+			}
+		}
+		s.moveStack[ply] = m
+		reduction := 0
+		if p.LMR && quiet && !inCheck && !givesCheck && depth >= p.LMRMinDepth && legal >= p.LMRMinMoves {
+			reduction = max(0, min(p.lmrReduction(depth, legal), depth-2))
+		}
+		// PVS: el primer movimiento se busca con la ventana completa y el resto con ventana
+		// nula, solo para comprobar que no mejoran alfa. Si alguno la mejora se repite sin
+		// reducción y, en los nodos PV, con la ventana completa para obtener su puntuación.
+		var score int
+		if legal == 1 {
+			score = -s.negamax(depth-1, ply+1, -beta, -alpha)
+		} else {
+			score = -s.negamax(depth-1-reduction, ply+1, -alpha-1, -alpha)
+			if reduction > 0 && score > alpha && !s.stopped {
+				score = -s.negamax(depth-1, ply+1, -alpha-1, -alpha)
+			}
+			if pvNode && score > alpha && score < beta && !s.stopped {
+				score = -s.negamax(depth-1, ply+1, -beta, -alpha)
+			}
+		}
+		b.unmakeMove(st)
+		if s.stopped {
+			if ply > 0 {
+				return 0
+			}
+			// En la raíz se conserva lo ya buscado en esta iteración
+			if legal > 1 {
+				break
+			}
+		}
+		if score > alpha {
+			alpha = score
+			bestMove = m
+			s.updatePV(ply, m)
+			if alpha >= beta {
+				if quiet {
+					s.updateQuietStats(ply, depth, m, quiets)
+				}
+				break
+			}
+		}
+		if quiet {
+			quiets = append(quiets, m)
+		}
+	}
+	if legal == 0 {
+		switch {
+		case b.Variant == Antichess: // Quedarse sin movimientos es ganar
+			return mateIn(ply)
+		case inCheck:
+			return matedIn(ply)
+		}
+		return s.drawScore(ply) // Ahogado
+	}
+	if !s.stopped {
+		bound := boundUpper
+		switch {
+		case alpha >= beta:
+			bound = boundLower
+		case alpha > origAlpha:
+			bound = boundExact
+		}
+		s.TT.store(key, bestMove, scoreToTT(alpha, ply), depth, bound)
+	}
+	return alpha
+}
+
+// isRepetition indica si la posición del ply se puede dar por tablas: basta con que se
+// repita una posición de la rama posterior a la raíz, porque el bando que repite podría
+// volver a hacerlo, pero las anteriores (la raíz y las de la partida) tienen que sumar
+// una triple repetición. Solo se mira hasta la última captura o movimiento de peón, según
+// el reloj de cincuenta movimientos, y un movimiento nulo corta la búsqueda hacia atrás.
+func (s *Searcher) isRepetition(ply int) bool {
+	key := s.keys[ply]
+	halfMove := int(s.board.HalfMove)
+	count := 0
+	for p := ply - 1; p >= 0 && ply-p <= halfMove; p-- {
+		if s.moveStack[p] == NoMove {
+			return false
+		}
+		if (ply-p)%2 == 0 && s.keys[p] == key {
+			if p > 0 {
+				return true
+			}
+			count++
+		}
+	}
+	h := s.GameHistory
+	n := len(h)
+	for i := n - 1; i >= 0 && ply+n-i <= halfMove; i-- {
+		if (ply+n-i)%2 == 0 && h[i] == key {
+			count++
+			if count >= 2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// drawScore es la puntuación de unas tablas en el ply indicado, desde el punto de vista
+// del bando al mover: -Contempt para el bando que busca y Contempt para su rival.
+func (s *Searcher) drawScore(ply int) int {
+	if ply%2 == 0 {
+		return -s.Contempt
+	}
+	return s.Contempt
+}
+
+// nullMove pasa el turno y busca con profundidad reducida y ventana nula alrededor de
+// beta. Si ni así el rival consigue bajar de beta, la posición es lo bastante buena para
+// cortar. A partir de NullMoveVerifyDepth el corte se confirma con una búsqueda reducida
+// sin movimiento nulo, por si la posición es de zugzwang.
+func (s *Searcher) nullMove(depth, ply, beta, staticEval int) (int, bool) {
+	p := &s.Params
+	b := s.board
+	r := p.NullMoveReduction + depth/p.NullMoveDepthDivisor + min((staticEval-beta)/p.NullMoveEvalDivisor, 3)
+	ep := b.makeNullMove()
+	s.moveStack[ply] = NoMove
+	score := -s.negamax(depth-1-r, ply+1, -beta, -beta+1)
+	b.unmakeNullMove(ep)
+	if s.stopped || score < beta {
+		return 0, false
+	}
+	// Un mate tras pasar el turno no es un mate de verdad
+	if score >= tbWinScore-maxPly {
+		score = beta
+	}
+	if depth < p.NullMoveVerifyDepth {
+		return score, true
+	}
+	s.nullDisabled = true
+	verified := s.negamax(depth-r, ply, beta-1, beta)
+	s.nullDisabled = false
+	s.pvLen[ply] = 0
+	return score, !s.stopped && verified >= beta
+}
+
+// updateQuietStats premia el movimiento tranquilo que ha producido un corte beta (killer,
+// contraataque al movimiento anterior e historial) y penaliza en el historial los
+// tranquilos que se probaron antes sin éxito.
+func (s *Searcher) updateQuietStats(ply, depth int, m Move, tried []Move) {
+	if s.killers[ply][0] != m {
+		s.killers[ply][1] = s.killers[ply][0]
+		s.killers[ply][0] = m
+	}
+	if ply > 0 {
+		prev := s.moveStack[ply-1]
+		s.counter[prev.From()][prev.To()] = m
+	}
+	color := colorIndex(s.board.WhiteToMove)
+	bonus := min(depth*depth, 400)
+	s.addHistory(color, m, bonus)
+	for _, q := range tried {
+		s.addHistory(color, q, -bonus)
+	}
+}
+
+// addHistory suma bonus al historial de m manteniéndolo entre -maxHistory y maxHistory.
+func (s *Searcher) addHistory(color int, m Move, bonus int) {
+	h := &s.history[color][m.From()][m.To()]
+	*h += bonus - *h*abs(bonus)/maxHistory
+}
+
+// probeTablebase consulta el resultado WDL de la posición si hay tablas para su material.
+func (s *Searcher) probeTablebase(depth, ply int) (int, bool) {
+	tb := s.Tablebase
+	if !tb.canProbe(s.board) {
+		return 0, false
+	}
+	pieces := bits.OnesCount64(s.board.AllPieces())
+	if pieces == tb.MaxPieces() && depth < s.TBProbeDepth {
+		return 0, false
+	}
+	wdl, ok := tb.ProbeWDL(s.board)
+	if !ok {
+		return 0, false
+	}
+	s.tbHits.Add(1)
+	switch wdl {
+	case WDLWin:
+		return tbWinScore - ply, true
+	case WDLLoss:
+		return -tbWinScore + ply, true
+	}
+	// Las victorias y derrotas que anula la regla de los cincuenta movimientos son tablas
+	return 0, true
+}
+
+// quiescence extiende la búsqueda con capturas hasta llegar a una posición tranquila,
+// para no evaluar en mitad de un intercambio.
+func (s *Searcher) quiescence(ply int, alpha, beta int) int {
+	if s.stopped {
+		return 0
+	}
+	s.nodes.Add(1)
+	s.checkLimits()
+	b := s.board
+	if b.Variant != Standard {
+		if score, over := b.variantScore(ply); over {
+			return score
+		}
+		if b.Variant == Antichess {
+			return s.antichessQuiescence(ply, alpha, beta)
+		}
+	}
+	standPat := s.evaluate()
+	if standPat >= beta || ply >= maxPly-1 {
+		return standPat
+	}
+	if standPat > alpha {
+		alpha = standPat
+	}
+
+	mover := b.WhiteToMove
+	var buf [maxMoves]Move
+	for _, m := range goodCaptures(b, b.GenerateMoves(buf[:0])) {
+		st := b.perftMakeMove(m)
+		if b.IsKingInCheck(mover) {
+			b.unmakeMove(st)
+			continue
+		}
+		score := -s.quiescence(ply+1, -beta, -alpha)
+		b.unmakeMove(st)
+		if s.stopped {
+			return 0
+		}
+		if score > alpha {
+			alpha = score
+			if alpha >= beta {
+				break
+			}
+		}
+	}
+	return alpha
+}
+
+// updatePV guarda m seguido de la variante principal del nodo hijo.
+func (s *Searcher) updatePV(ply int, m Move) {
+	s.pv[ply][0] = m
+	n := copy(s.pv[ply][1:], s.pv[ply+1][:s.pvLen[ply+1]])
+	s.pvLen[ply] = n + 1
+}
+
+// goodCaptures devuelve las capturas que no pierden material según SEE, de mejor a peor.
+// Las perdedoras no mejoran el stand pat de la quiescencia. Reutiliza la memoria de moves.
+func goodCaptures(b *Board, moves MoveList) MoveList {
+	var see [maxMoves]int
+	n := 0
+	for _, m := range moves {
+		if !m.IsCapture() || n == len(see) {
+			continue
+		}
+		v := b.SEE(m)
+		if v < 0 {
+			continue
+		}
+		// Inserción estable: las de igual SEE mantienen el orden de generación
+		i := n
+		for ; i > 0 && see[i-1] < v; i-- {
+			moves[i], see[i] = moves[i-1], see[i-1]
+		}
+		moves[i], see[i] = m, v
+		n++
+	}
+	return moves[:n]
+}
@@ -0,0 +1,343 @@
+package melange
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSearchFindsMateInOne(t *testing.T) {
+	board := &Board{}
+	assert.NilError(t, board.SetFen("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1"))
+	res := NewSearcher(DefaultEvalParams()).Search(board, SearchLimits{Depth: 2})
+	assert.Equal(t, res.BestMove.ToSimpleString(), "a1a8")
+	assert.Equal(t, res.Score, mateIn(1))
+	assert.Equal(t, res.Depth, 2)
+}
+
+func TestSearchPV(t *testing.T) {
+	board := NewBoard()
+	res := NewSearcher(DefaultEvalParams()).Search(board, SearchLimits{Depth: 2})
+	assert.Equal(t, res.Depth, 2)
+	assert.Equal(t, len(res.PV), 2)
+	assert.Equal(t, res.PV[0], res.BestMove)
+	// La variante principal es una sucesión de jugadas legales
+	b := board.Clone()
+	for _, m := range res.PV {
+		assert.Assert(t, slices.Contains(legalMoves(b), m), "%s en %s", m.UCIString(), b.Fen())
+		b.MovePiece(m, b.WhiteToMove)
+	}
+}
+
+func TestSearchMateScores(t *testing.T) {
+	assert.Equal(t, mateMoves(mateIn(1)), 1)
+	assert.Equal(t, mateMoves(mateIn(3)), 2)
+	assert.Equal(t, mateMoves(matedIn(2)), -1)
+	assert.Equal(t, mateMoves(matedIn(0)), 0)
+	// En la tabla de transposición se guardan como distancia desde el nodo
+	assert.Equal(t, scoreToTT(mateIn(7), 4), mateIn(3))
+	assert.Equal(t, scoreToTT(matedIn(6), 4), matedIn(2))
+	assert.Equal(t, scoreToTT(-tbWinScore+5, 4), -tbWinScore+1)
+	assert.Equal(t, scoreToTT(250, 4), 250)
+	for _, score := range []int{mateIn(7), matedIn(6), tbWinScore - 9, -tbWinScore + 5, 250, -250} {
+		assert.Equal(t, scoreFromTT(scoreToTT(score, 4), 4), score)
+	}
+}
+
+func TestSearchConvertsWin(t *testing.T) {
+	// Con dama de ventaja el mate se acerca en cada jugada en lugar de dar vueltas
+	board := &Board{}
+	assert.NilError(t, board.SetFen("8/8/8/4k3/8/8/8/4K2Q w - - 0 1"))
+	g := NewGame(board)
+	s := NewSearcher(DefaultEvalParams())
+	lastMate := 0
+	for len(g.Moves) < 40 {
+		if result, _ := g.Status(); result != Ongoing {
+			break
+		}
+		s.GameHistory = g.History()
+		res := s.Search(g.Board, SearchLimits{Depth: 7})
+		if g.Board.WhiteToMove && isMateScore(res.Score) {
+			n := mateMoves(res.Score)
+			assert.Assert(t, lastMate == 0 || n == lastMate-1, "mate %d tras mate %d", n, lastMate)
+			lastMate = n
+		}
+		g.Play(res.BestMove)
+	}
+	result, reason := g.Status()
+	assert.Equal(t, result, WhiteWins)
+	assert.Equal(t, reason, "checkmate")
+}
+
+func TestSearchWinsHangingQueen(t *testing.T) {
+	board := &Board{}
+	assert.NilError(t, board.SetFen("4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1"))
+	res := NewSearcher(DefaultEvalParams()).Search(board, SearchLimits{Depth: 1})
+	assert.Equal(t, res.BestMove.ToSimpleString(), "d2d5")
+}
+
+func TestSearchNodeLimit(t *testing.T) {
+	board := NewBoard()
+	res := NewSearcher(DefaultEvalParams()).Search(board, SearchLimits{Nodes: 5000})
+	assert.Assert(t, res.Depth >= 1)
+	assert.Assert(t, res.BestMove.From() != res.BestMove.To())
+	// El límite se comprueba en cada nodo, así que apenas se supera
+	assert.Assert(t, res.Nodes <= 5000, "nodes %d", res.Nodes)
+	// La búsqueda no modifica el tablero
+	assert.Assert(t, board.Equal(NewBoard()))
+}
+
+func TestSearchStopChannel(t *testing.T) {
+	stop := make(chan struct{})
+	done := make(chan SearchResult)
+	go func() {
+		done <- NewSearcher(DefaultEvalParams()).Search(NewBoard(), SearchLimits{Stop: stop})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+	select {
+	case res := <-done:
+		// Sin más límites sigue hasta que se cierra el canal y devuelve la última iteración
+		assert.Assert(t, res.Depth >= 1)
+		assert.Assert(t, res.BestMove.From() != res.BestMove.To())
+	case <-time.After(10 * time.Second):
+		t.Fatal("la búsqueda no se ha detenido")
+	}
+}
+
+func TestSearchNoLegalMoves(t *testing.T) {
+	board := &Board{}
+	assert.NilError(t, board.SetFen("7k/5Q2/6K1/8/8/8/8/8 b - - 0 1"))
+	res := NewSearcher(DefaultEvalParams()).Search(board, SearchLimits{Depth: 3})
+	assert.Equal(t, len(res.PV), 0)
+	assert.Equal(t, res.Score, 0) // Ahogado
+}
+
+func TestSearchRepetition(t *testing.T) {
+	// Las blancas solo pueden jugar Kb1, que repite por tercera vez una posición de la
+	// partida: son tablas aunque vayan perdiendo una torre
+	board := &Board{}
+	assert.NilError(t, board.SetFen("2r4k/8/8/8/8/p7/P7/K7 w - - 10 40"))
+	s := NewSearcher(DefaultEvalParams())
+	res := s.Search(board, SearchLimits{Depth: 4})
+	assert.Assert(t, res.Score < -300, res.Score)
+
+	after := board.Clone()
+	after.MovePiece(res.BestMove, true)
+	s.GameHistory = []uint64{1, after.Hash(), 2, after.Hash()}
+	for _, contempt := range []int{0, 25} {
+		s.Contempt = contempt
+		res = s.Search(board, SearchLimits{Depth: 4})
+		assert.Equal(t, res.Score, -contempt)
+	}
+	// Fuera del alcance del reloj de cincuenta movimientos no cuentan
+	board.HalfMove = 2
+	res = s.Search(board, SearchLimits{Depth: 4})
+	assert.Assert(t, res.Score < -300, res.Score)
+}
+
+func TestSearchFiftyMoveRule(t *testing.T) {
+	// Cualquier movimiento de la dama llega a los cien medios movimientos
+	board := &Board{}
+	assert.NilError(t, board.SetFen("7k/8/8/8/8/8/8/Q5K1 w - - 99 80"))
+	res := NewSearcher(DefaultEvalParams()).Search(board, SearchLimits{Depth: 3})
+	assert.Equal(t, res.Score, 0)
+	// Pero un mate en ese movimiento sigue siendo mate
+	assert.NilError(t, board.SetFen("6k1/5ppp/8/8/8/8/8/R5K1 w - - 99 80"))
+	res = NewSearcher(DefaultEvalParams()).Search(board, SearchLimits{Depth: 2})
+	assert.Equal(t, res.BestMove.ToSimpleString(), "a1a8")
+	assert.Equal(t, res.Score, mateIn(1))
+}
+
+func TestSearchIsRepetition(t *testing.T) {
+	m := newMove(6, 21, MoveNormal)
+	for _, tc := range []struct {
+		keys    []uint64
+		history []uint64
+		null    int // Ply con movimiento nulo (-1 si no hay)
+		want    bool
+	}{
+		// Dentro del árbol basta con una repetición
+		{keys: []uint64{1, 2, 3, 4, 3}, null: -1, want: true},
+		// Solo cuentan las posiciones con el mismo bando al mover
+		{keys: []uint64{1, 2, 3, 2, 3, 2}, null: -1, want: true},
+		{keys: []uint64{1, 2, 3, 4, 5, 3}, null: -1, want: false},
+		// La raíz necesita otra repetición en la partida
+		{keys: []uint64{1, 2, 3, 4, 1}, null: -1, want: false},
+		{keys: []uint64{1, 2, 3, 4, 1}, history: []uint64{1, 7}, null: -1, want: true},
+		{keys: []uint64{1, 2}, history: []uint64{9, 2, 9, 2, 9}, null: -1, want: false},
+		{keys: []uint64{1, 2}, history: []uint64{9, 2, 9, 2}, null: -1, want: true},
+		// No se mira más allá de un movimiento nulo
+		{keys: []uint64{1, 2, 3, 4, 3}, null: 2, want: false},
+	} {
+		s := NewSearcher(DefaultEvalParams())
+		s.GameHistory = tc.history
+		s.board = &Board{HalfMove: 100}
+		ply := len(tc.keys) - 1
+		copy(s.keys[:], tc.keys)
+		for p := 0; p < ply; p++ {
+			s.moveStack[p] = m
+		}
+		if tc.null >= 0 {
+			s.moveStack[tc.null] = NoMove
+		}
+		assert.Equal(t, s.isRepetition(ply), tc.want, "%v %v", tc.keys, tc.history)
+	}
+}
+
+func TestSearchUsesTablebase(t *testing.T) {
+	dir := t.TempDir()
+	writeKQvKTable(t, dir, func(q, wk, bk int) bool { return true }, WDLLoss)
+	tb, err := OpenTablebase(dir)
+	assert.NilError(t, err)
+	board := &Board{}
+	assert.NilError(t, board.SetFen("8/8/8/4k3/8/8/1Q6/K7 w - - 0 1"))
+	s := NewSearcher(DefaultEvalParams())
+	s.Tablebase = tb
+	res := s.Search(board, SearchLimits{Depth: 2})
+	// Tras cualquier jugada que no entregue la dama las negras pierden según la tabla
+	assert.Equal(t, res.Score, tbWinScore-1)
+	assert.Assert(t, res.TBHits > 0)
+
+	// Los hilos auxiliares comparten las tablas y suman sus consultas
+	s.Threads = 3
+	threaded := s.Search(board, SearchLimits{Depth: 3})
+	assert.Equal(t, threaded.Score, tbWinScore-1)
+	assert.Assert(t, threaded.TBHits > res.TBHits)
+}
+
+func TestSearchAspirationWindows(t *testing.T) {
+	board := &Board{}
+	assert.NilError(t, board.SetFen("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3"))
+	s := NewSearcher(DefaultEvalParams())
+	// Con una ventana mínima casi todas las iteraciones se salen y hay que repetirlas
+	s.Params = withoutSelectivity()
+	s.Params.Aspiration = true
+	s.Params.AspirationMinDepth = 2
+	s.Params.AspirationDelta = 1
+	var reports []SearchResult
+	s.OnIteration = func(r SearchResult) { reports = append(reports, r) }
+	res := s.Search(board, SearchLimits{Depth: 4})
+
+	bounds := 0
+	for i, r := range reports {
+		if r.LowerBound || r.UpperBound {
+			bounds++
+			assert.Assert(t, !(r.LowerBound && r.UpperBound))
+			// Tras una cota siempre llega otro informe de la misma profundidad
+			assert.Equal(t, reports[i+1].Depth, r.Depth)
+		}
+	}
+	assert.Assert(t, bounds > 0)
+	last := reports[len(reports)-1]
+	assert.Assert(t, !last.LowerBound && !last.UpperBound)
+	assert.Equal(t, last.Score, res.Score)
+	// Las repeticiones acaban dando la puntuación exacta
+	assert.Equal(t, res.Score, alphaBeta(board, DefaultEvalParams(), 4))
+}
+
+func TestSearchPVSMatchesFullWindow(t *testing.T) {
+	// Sin poda selectiva, PVS y las ventanas de aspiración no cambian la puntuación
+	for _, fen := range []string{
+		"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r1b2k1r/ppp1bppp/8/1B1Q4/5q2/2P5/PPP2PPP/R3R1K1 w - - 1 1",
+	} {
+		board := &Board{}
+		assert.NilError(t, board.SetFen(fen))
+		s := NewSearcher(DefaultEvalParams())
+		s.Params = withoutSelectivity()
+		pvs := s.Search(board, SearchLimits{Depth: 4})
+		assert.Equal(t, pvs.Score, alphaBeta(board, DefaultEvalParams(), 4), fen)
+	}
+}
+
+// alphaBeta es la búsqueda de referencia para los tests: alfa-beta con ventana completa,
+// sin PVS, poda selectiva ni tabla de transposición, y con la misma quiescencia que
+// Searcher en las hojas. Da el valor minimax exacto de la raíz.
+func alphaBeta(board *Board, eval Evaluator, depth int) int {
+	s := NewSearcher(eval)
+	s.board = prepareEvaluator(board.Clone(), eval)
+	var search func(depth, ply, alpha, beta int) int
+	search = func(depth, ply, alpha, beta int) int {
+		if depth == 0 {
+			return s.quiescence(ply, alpha, beta)
+		}
+		b := s.board
+		mover := b.WhiteToMove
+		legal := 0
+		for _, m := range b.GetLegalMoves() {
+			st := b.perftMakeMove(m)
+			if !b.IsKingInCheck(mover) {
+				legal++
+				alpha = max(alpha, -search(depth-1, ply+1, -beta, -alpha))
+			}
+			b.unmakeMove(st)
+			if alpha >= beta {
+				return alpha
+			}
+		}
+		if legal == 0 {
+			if b.IsKingInCheck(mover) {
+				return max(alpha, matedIn(ply))
+			}
+			return max(alpha, 0)
+		}
+		return alpha
+	}
+	return search(depth, 0, -infinityScore, infinityScore)
+}
+
+func TestSearchThreads(t *testing.T) {
+	board := &Board{}
+	assert.NilError(t, board.SetFen("r1b2k1r/ppp1bppp/8/1B1Q4/5q2/2P5/PPP2PPP/R3R1K1 w - - 1 1"))
+	s := NewSearcher(DefaultEvalParams())
+	s.Threads = 4
+	var reported []int64
+	s.OnIteration = func(r SearchResult) { reported = append(reported, r.Nodes) }
+	res := s.Search(board, SearchLimits{Depth: 5})
+	assert.Equal(t, res.BestMove.ToSimpleString(), "d5d8")
+	assert.Equal(t, res.Score, mateIn(3))
+	assert.Equal(t, len(s.helpers), 3)
+
+	// Los nodos son la suma de todos los hilos
+	var sum int64
+	for _, w := range s.shared.workers {
+		sum += w.nodes.Load()
+	}
+	assert.Equal(t, res.Nodes, sum)
+	assert.Assert(t, res.Nodes > s.nodes.Load())
+	assert.Assert(t, reported[len(reported)-1] <= res.Nodes)
+
+	// El límite de nodos cuenta los de todos los hilos
+	res = s.Search(NewBoard(), SearchLimits{Nodes: 20000})
+	assert.Assert(t, res.Nodes < 20000+int64(s.Threads), "nodes %d", res.Nodes)
+	assert.Assert(t, res.BestMove.From() != res.BestMove.To())
+
+	// Con menos hilos sobran auxiliares
+	s.Threads = 2
+	s.Search(board, SearchLimits{Depth: 2})
+	assert.Equal(t, len(s.helpers), 1)
+}
+
+func TestSearchVote(t *testing.T) {
+	e2e4 := newMove(12, 28, MoveNormal)
+	d2d4 := newMove(11, 27, MoveNormal)
+	results := []SearchResult{
+		{BestMove: e2e4, Score: 30, Depth: 10},
+		{BestMove: d2d4, Score: 35, Depth: 10},
+		{BestMove: d2d4, Score: 25, Depth: 11},
+		{}, // Sin ninguna iteración completa
+	}
+	best := vote(results)
+	assert.Equal(t, best.BestMove, d2d4)
+	assert.Equal(t, best.Depth, 11)
+
+	// Un mate encontrado por cualquier hilo gana
+	results[0].Score = mateScore
+	assert.Equal(t, vote(results).BestMove, e2e4)
+	// Con un solo resultado válido se devuelve ese
+	assert.Equal(t, vote([]SearchResult{{}, {BestMove: d2d4, Depth: 3}}).BestMove, d2d4)
+}
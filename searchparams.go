@@ -0,0 +1,156 @@
+package melange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// SearchParams activa y ajusta las técnicas de búsqueda selectiva. Todas se pueden
+// desactivar por separado para medir con partidas de regresión cuánto aporta cada una.
+// Los márgenes están en centipawns y se multiplican por la profundidad restante.
+type SearchParams struct {
+	// Movimiento nulo: si pasando el turno la búsqueda reducida sigue superando beta, se
+	// corta. La reducción es NullMoveReduction + depth/NullMoveDepthDivisor, más un ply
+	// por cada NullMoveEvalDivisor centipawns que la evaluación supere a beta (hasta 3).
+	// No se intenta sin piezas (solo peones y rey), donde el zugzwang es habitual, y a
+	// partir de NullMoveVerifyDepth el corte se confirma con una búsqueda sin movimiento nulo.
+	NullMove             bool `json:"null_move"`
+	NullMoveMinDepth     int  `json:"null_move_min_depth"`
+	NullMoveReduction    int  `json:"null_move_reduction"`
+	NullMoveDepthDivisor int  `json:"null_move_depth_divisor"`
+	NullMoveEvalDivisor  int  `json:"null_move_eval_divisor"`
+	NullMoveVerifyDepth  int  `json:"null_move_verify_depth"`
+
+	// Reducción de movimientos tardíos: los tranquilos a partir del LMRMinMoves-ésimo se
+	// buscan con LMRBase + ln(depth)*ln(n)/LMRDivisor plies menos, y se repiten a
+	// profundidad completa si superan alfa.
+	LMR         bool    `json:"lmr"`
+	LMRMinDepth int     `json:"lmr_min_depth"`
+	LMRMinMoves int     `json:"lmr_min_moves"`
+	LMRBase     float64 `json:"lmr_base"`
+	LMRDivisor  float64 `json:"lmr_divisor"`
+
+	// Futilidad inversa: cerca de las hojas, si la evaluación supera beta por más del
+	// margen se devuelve sin buscar.
+	ReverseFutility       bool `json:"reverse_futility"`
+	ReverseFutilityDepth  int  `json:"reverse_futility_depth"`
+	ReverseFutilityMargin int  `json:"reverse_futility_margin"`
+
+	// Futilidad: cerca de las hojas no se prueban los movimientos tranquilos si la
+	// evaluación más el margen no llega a alfa.
+	Futility       bool `json:"futility"`
+	FutilityDepth  int  `json:"futility_depth"`
+	FutilityMargin int  `json:"futility_margin"`
+
+	// Razoring: si la evaluación queda muy por debajo de alfa se comprueba con la
+	// quiescencia y, si esta tampoco llega, se devuelve su resultado.
+	Razoring       bool `json:"razoring"`
+	RazoringDepth  int  `json:"razoring_depth"`
+	RazoringMargin int  `json:"razoring_margin"`
+
+	// Poda de movimientos tardíos: cerca de las hojas solo se prueban los primeros
+	// LateMovePruningBase + depth² movimientos tranquilos.
+	LateMovePruning      bool `json:"late_move_pruning"`
+	LateMovePruningDepth int  `json:"late_move_pruning_depth"`
+	LateMovePruningBase  int  `json:"late_move_pruning_base"`
+
+	// Extensión de jaque: los nodos en jaque se buscan un ply más.
+	CheckExtensions bool `json:"check_extensions"`
+
+	// Ventanas de aspiración: desde AspirationMinDepth cada iteración empieza con una
+	// ventana de ±AspirationDelta alrededor de la puntuación anterior, que se duplica
+	// en cada repetición.
+	Aspiration         bool `json:"aspiration"`
+	AspirationMinDepth int  `json:"aspiration_min_depth"`
+	AspirationDelta    int  `json:"aspiration_delta"`
+}
+
+// DefaultSearchParams devuelve los valores de referencia del motor, con todas las
+// técnicas activadas.
+func DefaultSearchParams() SearchParams {
+	return SearchParams{
+		NullMove:             true,
+		NullMoveMinDepth:     3,
+		NullMoveReduction:    3,
+		NullMoveDepthDivisor: 3,
+		NullMoveEvalDivisor:  200,
+		NullMoveVerifyDepth:  10,
+
+		LMR:         true,
+		LMRMinDepth: 3,
+		LMRMinMoves: 4,
+		LMRBase:     0.75,
+		LMRDivisor:  2.25,
+
+		ReverseFutility:       true,
+		ReverseFutilityDepth:  6,
+		ReverseFutilityMargin: 80,
+
+		Futility:       true,
+		FutilityDepth:  4,
+		FutilityMargin: 120,
+
+		Razoring:       true,
+		RazoringDepth:  2,
+		RazoringMargin: 300,
+
+		LateMovePruning:      true,
+		LateMovePruningDepth: 4,
+		LateMovePruningBase:  4,
+
+		CheckExtensions: true,
+
+		Aspiration:         true,
+		AspirationMinDepth: 4,
+		AspirationDelta:    25,
+	}
+}
+
+// lmrReduction devuelve cuántos plies se reduce el movimiento número moveNumber (desde 1)
+// a la profundidad depth.
+func (p *SearchParams) lmrReduction(depth, moveNumber int) int {
+	r := p.LMRBase + math.Log(float64(depth))*math.Log(float64(moveNumber))/p.LMRDivisor
+	return int(r)
+}
+
+// LoadSearchParams lee un fichero JSON con parámetros de búsqueda. Como con
+// LoadEvalParams, los campos ausentes conservan el valor por defecto.
+func LoadSearchParams(path string) (SearchParams, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SearchParams{}, err
+	}
+	defer f.Close()
+	return ReadSearchParams(f)
+}
+
+// ReadSearchParams decodifica parámetros de búsqueda en JSON partiendo de los valores por defecto.
+func ReadSearchParams(r io.Reader) (SearchParams, error) {
+	p := DefaultSearchParams()
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return SearchParams{}, fmt.Errorf("parámetros de búsqueda inválidos: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return SearchParams{}, err
+	}
+	return p, nil
+}
+
+// Validate comprueba que los divisores y la ventana de aspiración sean positivos.
+func (p *SearchParams) Validate() error {
+	if p.NullMoveDepthDivisor <= 0 || p.NullMoveEvalDivisor <= 0 {
+		return fmt.Errorf("parámetros de búsqueda inválidos: los divisores del movimiento nulo deben ser positivos")
+	}
+	if p.LMRDivisor <= 0 {
+		return fmt.Errorf("parámetros de búsqueda inválidos: lmr_divisor debe ser positivo")
+	}
+	if p.Aspiration && p.AspirationDelta <= 0 {
+		return fmt.Errorf("parámetros de búsqueda inválidos: aspiration_delta debe ser positivo")
+	}
+	return nil
+}
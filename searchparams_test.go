@@ -0,0 +1,103 @@
+package melange
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSearchParamsPartialJSON(t *testing.T) {
+	p, err := ReadSearchParams(strings.NewReader(`{"null_move": false, "lmr_divisor": 3}`))
+	assert.NilError(t, err)
+	assert.Assert(t, !p.NullMove)
+	assert.Equal(t, p.LMRDivisor, 3.0)
+	assert.Equal(t, p.FutilityMargin, DefaultSearchParams().FutilityMargin)
+
+	_, err = ReadSearchParams(strings.NewReader(`{"nul_move": false}`))
+	assert.ErrorContains(t, err, "nul_move")
+	_, err = ReadSearchParams(strings.NewReader(`{"lmr_divisor": 0}`))
+	assert.ErrorContains(t, err, "lmr_divisor")
+}
+
+func TestLMRReduction(t *testing.T) {
+	p := DefaultSearchParams()
+	assert.Equal(t, p.lmrReduction(3, 4), 1)
+	// Crece con la profundidad y con el número de movimiento
+	assert.Assert(t, p.lmrReduction(12, 30) > p.lmrReduction(6, 30))
+	assert.Assert(t, p.lmrReduction(12, 30) > p.lmrReduction(12, 6))
+}
+
+// withoutSelectivity devuelve los parámetros con todas las técnicas desactivadas.
+func withoutSelectivity() SearchParams {
+	p := DefaultSearchParams()
+	p.NullMove = false
+	p.LMR = false
+	p.ReverseFutility = false
+	p.Futility = false
+	p.Razoring = false
+	p.LateMovePruning = false
+	p.CheckExtensions = false
+	return p
+}
+
+func TestSelectiveSearchToggles(t *testing.T) {
+	toggles := map[string]func(*SearchParams){
+		"null_move":         func(p *SearchParams) { p.NullMove = false },
+		"lmr":               func(p *SearchParams) { p.LMR = false },
+		"reverse_futility":  func(p *SearchParams) { p.ReverseFutility = false },
+		"futility":          func(p *SearchParams) { p.Futility = false },
+		"razoring":          func(p *SearchParams) { p.Razoring = false },
+		"late_move_pruning": func(p *SearchParams) { p.LateMovePruning = false },
+		"check_extensions":  func(p *SearchParams) { p.CheckExtensions = false },
+		"all":               func(p *SearchParams) { *p = withoutSelectivity() },
+	}
+	for name, disable := range toggles {
+		s := NewSearcher(DefaultEvalParams())
+		disable(&s.Params)
+		// Con cualquier combinación se siguen viendo el mate en dos y la dama colgada
+		board := &Board{}
+		assert.NilError(t, board.SetFen("r1b2k1r/ppp1bppp/8/1B1Q4/5q2/2P5/PPP2PPP/R3R1K1 w - - 1 1"))
+		res := s.Search(board, SearchLimits{Depth: 5})
+		assert.Equal(t, res.BestMove.ToSimpleString(), "d5d8", name)
+		assert.Equal(t, res.Score, mateIn(3), name)
+		assert.NilError(t, board.SetFen("4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1"))
+		res = s.Search(board, SearchLimits{Depth: 4})
+		assert.Equal(t, res.BestMove.ToSimpleString(), "d2d5", name)
+	}
+}
+
+func TestSelectiveSearchSavesNodes(t *testing.T) {
+	board := &Board{}
+	assert.NilError(t, board.SetFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"))
+	full := NewSearcher(DefaultEvalParams())
+	full.Params = withoutSelectivity()
+	selective := NewSearcher(DefaultEvalParams())
+	a := full.Search(board, SearchLimits{Depth: 5})
+	b := selective.Search(board, SearchLimits{Depth: 5})
+	assert.Assert(t, b.Nodes*2 < a.Nodes, "selectiva %d, completa %d", b.Nodes, a.Nodes)
+}
+
+func TestNullMoveNeedsPieces(t *testing.T) {
+	b := &Board{}
+	assert.NilError(t, b.SetFen("8/8/4k3/8/8/3PK3/8/8 w - - 0 1"))
+	assert.Assert(t, !b.hasNonPawnMaterial(true))
+	assert.NilError(t, b.SetFen("8/8/4k3/8/8/3PK3/8/7N w - - 0 1"))
+	assert.Assert(t, b.hasNonPawnMaterial(true) && !b.hasNonPawnMaterial(false))
+
+	ep := b.makeNullMove()
+	assert.Assert(t, !b.WhiteToMove)
+	b.unmakeNullMove(ep)
+	assert.Equal(t, b.Fen(), "8/8/4k3/8/8/3PK3/8/7N w - - 0 1")
+}
+
+func TestUCISetOptionSearchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.json")
+	assert.NilError(t, os.WriteFile(path, []byte(`{"lmr": false}`), 0o644))
+	ProcessUciCommand("setoption name SearchFile value " + path)
+	assert.Assert(t, !searchParams.LMR && searchParams.NullMove)
+	ProcessUciCommand("setoption name SearchFile value <empty>")
+	assert.DeepEqual(t, searchParams, DefaultSearchParams())
+}
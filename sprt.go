@@ -0,0 +1,107 @@
+package melange
+
+import (
+	"fmt"
+	"math"
+)
+
+// SPRT es un test secuencial de razón de probabilidades (Sequential Probability Ratio
+// Test) entre las hipótesis H0: la diferencia de Elo es Elo0 y H1: es Elo1, con
+// probabilidades de error Alpha (aceptar H1 siendo cierta H0) y Beta (al revés). Se usa
+// la aproximación GSPRT de fishtest y cutechess-cli: la razón de verosimilitud se calcula
+// con la media y la varianza observadas de la puntuación, por partida (modelo trinomial:
+// victoria, tablas, derrota) o por pareja de partidas con la misma apertura (modelo
+// pentanomial: de 0 a 2 puntos en medios puntos), que tiene en cuenta que las dos
+// partidas de una pareja no son independientes.
+type SPRT struct {
+	Elo0, Elo1  float64
+	Alpha, Beta float64
+	Pentanomial bool
+}
+
+// SPRTResult es la decisión del test.
+type SPRTResult int
+
+const (
+	SPRTContinue SPRTResult = iota
+	SPRTAcceptH0
+	SPRTAcceptH1
+)
+
+func (r SPRTResult) String() string {
+	switch r {
+	case SPRTAcceptH0:
+		return "H0"
+	case SPRTAcceptH1:
+		return "H1"
+	default:
+		return "-"
+	}
+}
+
+// Validate comprueba que los parámetros tienen sentido.
+func (t SPRT) Validate() error {
+	if t.Elo1 <= t.Elo0 {
+		return fmt.Errorf("SPRT: elo1 (%g) debe ser mayor que elo0 (%g)", t.Elo1, t.Elo0)
+	}
+	if t.Alpha <= 0 || t.Alpha >= 0.5 || t.Beta <= 0 || t.Beta >= 0.5 {
+		return fmt.Errorf("SPRT: alpha y beta deben estar entre 0 y 0.5")
+	}
+	return nil
+}
+
+// Bounds devuelve los límites de la razón de verosimilitud logarítmica (LLR): por debajo
+// de lower se acepta H0 y por encima de upper H1.
+func (t SPRT) Bounds() (lower, upper float64) {
+	return math.Log(t.Beta / (1 - t.Alpha)), math.Log((1 - t.Beta) / t.Alpha)
+}
+
+// LLR calcula la razón de verosimilitud logarítmica de los resultados. Como en fishtest,
+// a cada casilla se le suma una fracción de partida para que unos resultados sin
+// varianza (todo victorias, o todas las parejas 1-1) no dejen el test bloqueado.
+func (t SPRT) LLR(s MatchStats) float64 {
+	var counts []int
+	var scores []float64
+	if t.Pentanomial {
+		counts = s.Pentanomial[:]
+		scores = []float64{0, 0.25, 0.5, 0.75, 1}
+	} else {
+		counts = []int{s.Losses, s.Draws, s.Wins}
+		scores = []float64{0, 0.5, 1}
+	}
+	const regularization = 1e-3
+	n, mean := 0.0, 0.0
+	for i, c := range counts {
+		n += float64(c) + regularization
+		mean += (float64(c) + regularization) * scores[i]
+	}
+	if n < 1 {
+		return 0 // Sin resultados
+	}
+	mean /= n
+	variance := 0.0
+	for i, c := range counts {
+		variance += (float64(c) + regularization) * (scores[i] - mean) * (scores[i] - mean)
+	}
+	variance /= n
+	s0, s1 := eloToScore(t.Elo0), eloToScore(t.Elo1)
+	return 0.5 * n * (s1 - s0) * (2*mean - s0 - s1) / variance
+}
+
+// Test devuelve la LLR actual y la decisión.
+func (t SPRT) Test(s MatchStats) (float64, SPRTResult) {
+	llr := t.LLR(s)
+	lower, upper := t.Bounds()
+	switch {
+	case llr >= upper:
+		return llr, SPRTAcceptH1
+	case llr <= lower:
+		return llr, SPRTAcceptH0
+	}
+	return llr, SPRTContinue
+}
+
+// eloToScore es la inversa de scoreToElo: la puntuación media esperada con esa ventaja.
+func eloToScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
@@ -0,0 +1,76 @@
+package melange
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSPRTBounds(t *testing.T) {
+	lower, upper := SPRT{Elo0: 0, Elo1: 5, Alpha: 0.05, Beta: 0.05}.Bounds()
+	assert.Assert(t, math.Abs(lower+2.944) < 0.001, lower)
+	assert.Assert(t, math.Abs(upper-2.944) < 0.001, upper)
+
+	assert.NilError(t, SPRT{Elo0: -3, Elo1: 1, Alpha: 0.05, Beta: 0.1}.Validate())
+	assert.ErrorContains(t, SPRT{Elo0: 5, Elo1: 0, Alpha: 0.05, Beta: 0.05}.Validate(), "elo1")
+	assert.ErrorContains(t, SPRT{Elo0: 0, Elo1: 5, Alpha: 0, Beta: 0.05}.Validate(), "alpha")
+}
+
+func TestSPRTLLR(t *testing.T) {
+	for _, pentanomial := range []bool{false, true} {
+		test := SPRT{Elo0: 0, Elo1: 10, Alpha: 0.05, Beta: 0.05, Pentanomial: pentanomial}
+		assert.Equal(t, test.LLR(MatchStats{}), 0.0)
+
+		// Igualados: la LLR baja con el número de partidas hasta aceptar H0
+		even := MatchStats{Wins: 300, Draws: 400, Losses: 300, Pentanomial: [5]int{50, 100, 200, 100, 50}}
+		llr, result := test.Test(even)
+		assert.Assert(t, llr < 0 && result == SPRTContinue, llr)
+		even = MatchStats{Wins: 3000, Draws: 4000, Losses: 3000, Pentanomial: [5]int{500, 1000, 2000, 1000, 500}}
+		llr, result = test.Test(even)
+		assert.Assert(t, result == SPRTAcceptH0, llr)
+
+		// Una ventaja clara acepta H1
+		strong := MatchStats{Wins: 400, Draws: 400, Losses: 200, Pentanomial: [5]int{20, 80, 150, 150, 100}}
+		llr, result = test.Test(strong)
+		assert.Assert(t, result == SPRTAcceptH1, llr)
+
+		// Con resultados sin varianza el test también avanza
+		perfect := MatchStats{Wins: 20, Pentanomial: [5]int{0, 0, 0, 0, 10}}
+		_, result = test.Test(perfect)
+		assert.Equal(t, result, SPRTAcceptH1)
+	}
+
+	// Las parejas 1-1 (cada motor gana con blancas) no dicen nada sobre la diferencia,
+	// aunque como partidas sueltas parezcan muchas decisivas
+	pairs := MatchStats{Wins: 50, Losses: 50, Pentanomial: [5]int{0, 0, 50, 0, 0}}
+	tri := SPRT{Elo0: 0, Elo1: 10, Alpha: 0.05, Beta: 0.05}
+	penta := tri
+	penta.Pentanomial = true
+	assert.Assert(t, penta.LLR(pairs) < tri.LLR(pairs))
+}
+
+func TestMatchSPRTStopsEarly(t *testing.T) {
+	broken := brokenEngine(t)
+	engine := buildEngine(t)
+	cfg := MatchConfig{
+		Engines: [2]MatchEngine{{Path: engine}, {Path: broken}},
+		Games:   200,
+		Depth:   1,
+		SPRT:    &SPRT{Elo0: 0, Elo1: 10, Alpha: 0.05, Beta: 0.05, Pentanomial: true},
+	}
+	var log bytes.Buffer
+	stats, err := RunMatch(cfg, nil, &log)
+	assert.NilError(t, err)
+	assert.Equal(t, stats.SPRT, SPRTAcceptH1)
+	assert.Assert(t, stats.Games() < 200 && stats.Games()%2 == 0, stats.Games())
+	assert.Equal(t, stats.Pentanomial[4], stats.Games()/2)
+	assert.Assert(t, strings.Contains(log.String(), "Ptnml(0-2): 0, 0, 0, 0, "), log.String())
+	assert.Assert(t, strings.Contains(log.String(), "SPRT: H1 was accepted"), log.String())
+
+	cfg.SPRT = &SPRT{Elo0: 5, Elo1: 0}
+	_, err = RunMatch(cfg, nil, &log)
+	assert.ErrorContains(t, err, "elo1")
+}
@@ -0,0 +1,1105 @@
+package melange
+
+// Lectura de tablas de finales Syzygy (WDL .rtbw y DTZ .rtbz) en Go puro.
+//
+// El formato es el de Ronald de Man: cada tabla guarda, para cada posición de un material
+// dado, un valor comprimido con "recursive pairing" y códigos de Huffman canónicos. La
+// posición se convierte en un índice agrupando las piezas del mismo tipo y aprovechando
+// las simetrías del tablero (sin peones: 8 simetrías; con peones: espejo horizontal).
+// Las tablas no guardan derechos de enroque ni casilla al paso, así que las capturas se
+// buscan siempre explícitamente antes de consultar la tabla.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WDL es el resultado de una posición en las tablas desde el punto de vista del bando al
+// mover. Las victorias y derrotas "cursed"/"blessed" son las que la regla de los cincuenta
+// movimientos convierte en tablas.
+type WDL int
+
+const (
+	WDLLoss        WDL = -2
+	WDLBlessedLoss WDL = -1
+	WDLDraw        WDL = 0
+	WDLCursedWin   WDL = 1
+	WDLWin         WDL = 2
+)
+
+const (
+	tbMaxPieces = 7
+	wdlSuffix   = ".rtbw"
+	dtzSuffix   = ".rtbz"
+)
+
+var (
+	wdlMagic = []byte{0x71, 0xE8, 0x23, 0x5D}
+	dtzMagic = []byte{0xD7, 0x66, 0x0C, 0xA5}
+)
+
+// Flags de cada tabla (PairsData)
+const (
+	tbFlagSTM         = 1
+	tbFlagMapped      = 2
+	tbFlagWinPlies    = 4
+	tbFlagLossPlies   = 8
+	tbFlagWide        = 16
+	tbFlagSingleValue = 128
+)
+
+// tbState indica cómo ha terminado una consulta.
+type tbState int
+
+const (
+	tbFail tbState = iota
+	tbOK
+	tbChangeSTM       // La tabla DTZ solo guarda el otro bando al mover
+	tbZeroingBestMove // La mejor jugada es una captura o un movimiento de peón
+)
+
+// Tablebase da acceso a las tablas Syzygy encontradas en uno o varios directorios. Las
+// tablas se leen de disco la primera vez que se consultan. Es seguro usarla desde varias
+// goroutines.
+type Tablebase struct {
+	entries   map[string]*tbEntry // Por clave de material, en ambos sentidos ("KRvK" y "KvKR")
+	maxPieces int
+}
+
+// tbEntry describe un material (por ejemplo KRPvKR) y sus dos tablas.
+type tbEntry struct {
+	key, key2       string // Material con las blancas como bando fuerte y con los colores cambiados
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // Peones del bando de referencia y del otro
+	wdl             tbTable
+	dtz             tbTable
+}
+
+// tbTable es un fichero .rtbw o .rtbz cargado en memoria.
+type tbTable struct {
+	path   string
+	isDTZ  bool
+	once   sync.Once
+	err    error
+	data   []byte
+	pairs  [2][4]*tbPairs // [bando][columna del peón guía]
+	dtzMap int            // Inicio de la tabla de traducción de valores DTZ
+}
+
+// tbPairs son los datos de compresión de una subtabla.
+type tbPairs struct {
+	flags           byte
+	maxSymLen       int
+	minSymLen       int
+	numBlocks       uint64
+	blockSize       uint64
+	span            uint64
+	lowestSym       []byte // uint16 little endian por longitud de símbolo
+	base64          []uint64
+	symlen          []byte
+	btree           []byte // 3 bytes por símbolo: dos valores de 12 bits (izquierdo y derecho)
+	blockLength     []byte // uint16 little endian por bloque
+	blockLengthSize uint64
+	sparseIndex     []byte // Entradas de 6 bytes: uint32 bloque, uint16 desplazamiento
+	sparseIndexSize uint64
+	data            []byte
+	pieces          [tbMaxPieces]byte
+	groupIdx        [tbMaxPieces + 1]uint64
+	groupLen        [tbMaxPieces + 1]int
+	mapIdx          [4]int
+}
+
+// Tablas de codificación, comunes a todos los ficheros
+var (
+	tbInitOnce    sync.Once
+	tbBinomial    [tbMaxPieces][64]uint64 // tbBinomial[k][n] = C(n, k)
+	tbMapB1H1H7   [64]int
+	tbMapA1D1D4   [64]int
+	tbMapKK       [10][64]int
+	tbMapPawns    [64]int
+	tbLeadPawnIdx [tbMaxPieces][64]uint64
+	tbLeadPawnsSz [tbMaxPieces][4]uint64
+)
+
+func offA1H8(sq int) int {
+	return sq>>3 - sq&7
+}
+
+// initTablebaseIndices calcula las tablas que convierten casillas en índices.
+func initTablebaseIndices() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if offA1H8(sq) < 0 {
+			tbMapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	// Triángulo a1-d1-d4: primero las casillas bajo la diagonal, después la diagonal
+	var diagonal []int
+	code = 0
+	for sq := 0; sq <= 27; sq++ {
+		if offA1H8(sq) < 0 && sq&7 <= 3 {
+			tbMapA1D1D4[sq] = code
+			code++
+		} else if offA1H8(sq) == 0 && sq&7 <= 3 {
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		tbMapA1D1D4[sq] = code
+		code++
+	}
+
+	// Las 462 posiciones legales de dos reyes con el primero en el triángulo a1-d1-d4.
+	// Si el primero está en la diagonal, el segundo no puede estar por encima de ella.
+	type kk struct{ idx, sq int }
+	var bothOnDiagonal []kk
+	code = 0
+	for idx := 0; idx < 10; idx++ {
+		for s1 := 0; s1 <= 27; s1++ {
+			if tbMapA1D1D4[s1] != idx || (idx == 0 && s1 != 1) { // B1 es el 0
+				continue
+			}
+			for s2 := 0; s2 < 64; s2++ {
+				df, dr := s1&7-s2&7, s1>>3-s2>>3
+				switch {
+				case df >= -1 && df <= 1 && dr >= -1 && dr <= 1:
+					continue // Reyes adyacentes o en la misma casilla
+				case offA1H8(s1) == 0 && offA1H8(s2) > 0:
+					continue
+				case offA1H8(s1) == 0 && offA1H8(s2) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, kk{idx, s2})
+				default:
+					tbMapKK[idx][s2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, p := range bothOnDiagonal {
+		tbMapKK[p.idx][p.sq] = code
+		code++
+	}
+
+	tbBinomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < tbMaxPieces && k <= n; k++ {
+			if k > 0 {
+				tbBinomial[k][n] += tbBinomial[k-1][n-1]
+			}
+			if k < n {
+				tbBinomial[k][n] += tbBinomial[k][n-1]
+			}
+		}
+	}
+
+	// tbMapPawns numera las casillas a2-h7 de modo que el peón guía (el más cercano a
+	// la banda y, a igualdad, el de menor fila) es el de mayor valor.
+	available := 47
+	for lead := 1; lead < tbMaxPieces-1; lead++ {
+		for f := 0; f < 4; f++ {
+			idx := uint64(0)
+			for r := 1; r <= 6; r++ {
+				sq := r*8 + f
+				if lead == 1 {
+					tbMapPawns[sq] = available
+					available--
+					tbMapPawns[sq^7] = available
+					available--
+				}
+				tbLeadPawnIdx[lead][sq] = idx
+				idx += tbBinomial[lead-1][tbMapPawns[sq]]
+			}
+			tbLeadPawnsSz[lead][f] = idx
+		}
+	}
+}
+
+// OpenTablebase busca tablas Syzygy en los directorios indicados (separados como en
+// PATH). Solo se registran los materiales cuyo fichero WDL existe; la tabla DTZ es
+// opcional. No se lee el contenido de los ficheros hasta que se consultan.
+func OpenTablebase(paths string) (*Tablebase, error) {
+	tbInitOnce.Do(initTablebaseIndices)
+	tb := &Tablebase{entries: make(map[string]*tbEntry)}
+	for _, dir := range filepath.SplitList(paths) {
+		if dir == "" {
+			continue
+		}
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			name := f.Name()
+			if !strings.HasSuffix(name, wdlSuffix) {
+				continue
+			}
+			code := strings.TrimSuffix(name, wdlSuffix)
+			if _, ok := tb.entries[code]; ok {
+				continue // Ya encontrada en un directorio anterior
+			}
+			e, err := newTBEntry(code)
+			if err != nil {
+				continue // No es una tabla de ajedrez estándar
+			}
+			e.wdl.path = filepath.Join(dir, name)
+			if _, err := os.Stat(filepath.Join(dir, code+dtzSuffix)); err == nil {
+				e.dtz.path = filepath.Join(dir, code+dtzSuffix)
+			}
+			tb.entries[e.key] = e
+			tb.entries[e.key2] = e
+			tb.maxPieces = max(tb.maxPieces, e.pieceCount)
+		}
+	}
+	return tb, nil
+}
+
+// MaxPieces devuelve el mayor número de piezas (reyes incluidos) de las tablas disponibles.
+func (tb *Tablebase) MaxPieces() int {
+	if tb == nil {
+		return 0
+	}
+	return tb.maxPieces
+}
+
+// newTBEntry interpreta un nombre de tabla como "KRPvKP" (blancas a la izquierda).
+func newTBEntry(code string) (*tbEntry, error) {
+	sides := strings.Split(code, "v")
+	if len(sides) != 2 || len(code)-1 > tbMaxPieces {
+		return nil, fmt.Errorf("syzygy: nombre de tabla inválido %q", code)
+	}
+	var counts [2][7]int
+	for c, side := range sides {
+		for _, ch := range side {
+			p := strings.IndexRune(" PNBRQK", ch)
+			if p <= 0 {
+				return nil, fmt.Errorf("syzygy: nombre de tabla inválido %q", code)
+			}
+			counts[c][p]++
+		}
+		if counts[c][King] != 1 {
+			return nil, fmt.Errorf("syzygy: nombre de tabla inválido %q", code)
+		}
+	}
+	e := &tbEntry{
+		key:        code,
+		key2:       sides[1] + "v" + sides[0],
+		pieceCount: len(code) - 1,
+		hasPawns:   counts[0][Pawn]+counts[1][Pawn] > 0,
+	}
+	e.dtz.isDTZ = true
+	for c := 0; c < 2; c++ {
+		for p := Pawn; p < King; p++ {
+			if counts[c][p] == 1 {
+				e.hasUniquePieces = true
+			}
+		}
+	}
+	// El bando de referencia de los peones es el que tiene menos (y al menos uno)
+	white, black := counts[0][Pawn], counts[1][Pawn]
+	if black == 0 || (white > 0 && black >= white) {
+		e.pawnCount = [2]int{white, black}
+	} else {
+		e.pawnCount = [2]int{black, white}
+	}
+	return e, nil
+}
+
+// tbMaterialKey devuelve la clave de material de la posición con las blancas a la izquierda.
+func tbMaterialKey(b *Board) string {
+	var sb strings.Builder
+	for _, pieces := range []*Pieces{&b.WhitePieces, &b.BlackPieces} {
+		if pieces == &b.BlackPieces {
+			sb.WriteByte('v')
+		}
+		for _, p := range []Piece{King, Queen, Rook, Bishop, Knight, Pawn} {
+			for n := bits.OnesCount64(pieces.Get(p)); n > 0; n-- {
+				sb.WriteByte(" PNBRQK"[p])
+			}
+		}
+	}
+	return sb.String()
+}
+
+// load lee el fichero y prepara las subtablas. Solo se ejecuta una vez por tabla.
+func (t *tbTable) load(e *tbEntry) error {
+	t.once.Do(func() {
+		if t.path == "" {
+			t.err = errors.New("syzygy: tabla no disponible")
+			return
+		}
+		data, err := os.ReadFile(t.path)
+		if err != nil {
+			t.err = err
+			return
+		}
+		magic := wdlMagic
+		if t.isDTZ {
+			magic = dtzMagic
+		}
+		if len(data) < 5 || string(data[:4]) != string(magic) {
+			t.err = fmt.Errorf("syzygy: %s no es una tabla válida", t.path)
+			return
+		}
+		// Un fichero corrupto no debe tumbar el motor
+		defer func() {
+			if r := recover(); r != nil {
+				t.err = fmt.Errorf("syzygy: %s está dañado", t.path)
+			}
+		}()
+		t.data = data
+		t.err = t.setup(e)
+	})
+	return t.err
+}
+
+func (t *tbTable) sides(e *tbEntry) int {
+	if !t.isDTZ && e.key != e.key2 {
+		return 2
+	}
+	return 1
+}
+
+// get devuelve la subtabla del bando al mover y la columna del peón guía.
+func (t *tbTable) get(e *tbEntry, stm, file int) *tbPairs {
+	if !e.hasPawns {
+		file = 0
+	}
+	return t.pairs[stm%t.sides(e)][file]
+}
+
+func (t *tbTable) setup(e *tbEntry) error {
+	data := t.data
+	pos := 4
+	const hasPawns = 2 // El bit 0 indica si hay tablas para ambos bandos
+	if (data[pos]&hasPawns != 0) != e.hasPawns {
+		return fmt.Errorf("syzygy: %s no corresponde a su material", t.path)
+	}
+	pos++
+
+	sides := t.sides(e)
+	maxFile := 0
+	if e.hasPawns {
+		maxFile = 3
+	}
+	pp := e.hasPawns && e.pawnCount[1] > 0 // Peones en ambos bandos
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			t.pairs[i][f] = &tbPairs{}
+		}
+		order := [2][2]int{{int(data[pos] & 0xF), 0xF}, {int(data[pos] >> 4), 0xF}}
+		if pp {
+			order[0][1] = int(data[pos+1] & 0xF)
+			order[1][1] = int(data[pos+1] >> 4)
+			pos++
+		}
+		pos++
+		for k := 0; k < e.pieceCount; k++ {
+			t.pairs[0][f].pieces[k] = data[pos] & 0xF
+			if sides == 2 {
+				t.pairs[1][f].pieces[k] = data[pos] >> 4
+			}
+			pos++
+		}
+		for i := 0; i < sides; i++ {
+			t.pairs[i][f].setGroups(e, order[i], f)
+		}
+	}
+	pos += pos & 1
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			pos = t.pairs[i][f].setSizes(data, pos)
+		}
+	}
+	if t.isDTZ {
+		pos = t.setDTZMap(pos, maxFile)
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := t.pairs[i][f]
+			d.sparseIndex = data[pos:]
+			pos += int(d.sparseIndexSize) * 6
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := t.pairs[i][f]
+			d.blockLength = data[pos:]
+			pos += int(d.blockLengthSize) * 2
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			d := t.pairs[i][f]
+			pos = (pos + 0x3F) &^ 0x3F
+			if pos > len(data) {
+				return fmt.Errorf("syzygy: %s está truncado", t.path)
+			}
+			d.data = data[pos:]
+			pos += int(d.numBlocks * d.blockSize)
+		}
+	}
+	if pos > len(data) {
+		return fmt.Errorf("syzygy: %s está truncado", t.path)
+	}
+	return nil
+}
+
+// setGroups agrupa las piezas que se codifican juntas y calcula el factor de cada grupo.
+// El primer grupo son los peones guía, o sin peones tres piezas únicas (o los dos reyes).
+func (d *tbPairs) setGroups(e *tbEntry, order [2]int, file int) {
+	n := 0
+	firstLen := 2
+	if e.hasPawns {
+		firstLen = 0
+	} else if e.hasUniquePieces {
+		firstLen = 3
+	}
+	d.groupLen[0] = 1
+	for i := 1; i < e.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := e.hasPawns && e.pawnCount[1] > 0
+	next := 1
+	freeSquares := 64 - d.groupLen[0]
+	if pp {
+		next = 2
+		freeSquares -= d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]: // Peones o piezas guía
+			d.groupIdx[0] = idx
+			switch {
+			case e.hasPawns:
+				idx *= tbLeadPawnsSz[d.groupLen[0]][file]
+			case e.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case k == order[1]: // Resto de peones
+			d.groupIdx[1] = idx
+			idx *= tbBinomial[d.groupLen[1]][48-d.groupLen[0]]
+		default: // Resto de piezas
+			d.groupIdx[next] = idx
+			idx *= tbBinomial[d.groupLen[next]][freeSquares]
+			freeSquares -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+// setSizes lee la cabecera de compresión de la subtabla y devuelve la posición siguiente.
+func (d *tbPairs) setSizes(data []byte, pos int) int {
+	d.flags = data[pos]
+	pos++
+	if d.flags&tbFlagSingleValue != 0 {
+		d.minSymLen = int(data[pos]) // Aquí se guarda el único valor
+		return pos + 1
+	}
+
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	tbSize := d.groupIdx[n]
+
+	d.blockSize = 1 << data[pos]
+	d.span = 1 << data[pos+1]
+	d.sparseIndexSize = (tbSize + d.span - 1) / d.span
+	padding := uint64(data[pos+2])
+	d.numBlocks = uint64(binary.LittleEndian.Uint32(data[pos+3:]))
+	d.blockLengthSize = d.numBlocks + padding
+	d.maxSymLen = int(data[pos+7])
+	d.minSymLen = int(data[pos+8])
+	pos += 9
+	d.lowestSym = data[pos:]
+
+	// Códigos de Huffman canónicos: base64[l] es el menor código de longitud
+	// l+minSymLen alineado a la izquierda en 64 bits
+	d.base64 = make([]uint64, d.maxSymLen-d.minSymLen+1)
+	for i := len(d.base64) - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowest(i)) - uint64(d.lowest(i+1))) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= 64 - i - d.minSymLen
+	}
+	pos += len(d.base64) * 2
+
+	numSyms := int(binary.LittleEndian.Uint16(data[pos:]))
+	pos += 2
+	d.btree = data[pos:]
+	d.symlen = make([]byte, numSyms)
+	visited := make([]bool, numSyms)
+	for s := 0; s < numSyms; s++ {
+		if !visited[s] {
+			d.symlen[s] = d.setSymlen(s, visited)
+		}
+	}
+	return pos + numSyms*3 + numSyms&1
+}
+
+func (d *tbPairs) lowest(l int) uint16 {
+	return binary.LittleEndian.Uint16(d.lowestSym[2*l:])
+}
+
+func (d *tbPairs) left(s int) int {
+	return int(d.btree[3*s+1]&0xF)<<8 | int(d.btree[3*s])
+}
+
+func (d *tbPairs) right(s int) int {
+	return int(d.btree[3*s+2])<<4 | int(d.btree[3*s+1]>>4)
+}
+
+// setSymlen calcula cuántos valores (menos uno) representa un símbolo.
+func (d *tbPairs) setSymlen(s int, visited []bool) byte {
+	visited[s] = true
+	sr := d.right(s)
+	if sr == 0xFFF {
+		return 0
+	}
+	sl := d.left(s)
+	if !visited[sl] {
+		d.symlen[sl] = d.setSymlen(sl, visited)
+	}
+	if !visited[sr] {
+		d.symlen[sr] = d.setSymlen(sr, visited)
+	}
+	return d.symlen[sl] + d.symlen[sr] + 1
+}
+
+// setDTZMap localiza las tablas que traducen los valores DTZ almacenados.
+func (t *tbTable) setDTZMap(pos, maxFile int) int {
+	data := t.data
+	t.dtzMap = pos
+	for f := 0; f <= maxFile; f++ {
+		d := t.pairs[0][f]
+		if d.flags&tbFlagMapped == 0 {
+			continue
+		}
+		if d.flags&tbFlagWide != 0 {
+			pos += pos & 1
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = (pos-t.dtzMap)/2 + 1
+				pos += 2*int(binary.LittleEndian.Uint16(data[pos:])) + 2
+			}
+		} else {
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = pos - t.dtzMap + 1
+				pos += int(data[pos]) + 1
+			}
+		}
+	}
+	return pos + pos&1
+}
+
+// decompress devuelve el valor almacenado en el índice idx.
+func (d *tbPairs) decompress(idx uint64) int {
+	if d.flags&tbFlagSingleValue != 0 {
+		return d.minSymLen
+	}
+	blockLen := func(block int64) int64 {
+		return int64(binary.LittleEndian.Uint16(d.blockLength[2*block:]))
+	}
+
+	// El índice disperso apunta al bloque que contiene k*span + span/2
+	k := idx / d.span
+	block := int64(binary.LittleEndian.Uint32(d.sparseIndex[6*k:]))
+	offset := int64(binary.LittleEndian.Uint16(d.sparseIndex[6*k+4:]))
+	offset += int64(idx%d.span) - int64(d.span/2)
+	for offset < 0 {
+		block--
+		offset += blockLen(block) + 1
+	}
+	for offset > blockLen(block) {
+		offset -= blockLen(block) + 1
+		block++
+	}
+
+	ptr := d.data[uint64(block)*d.blockSize:]
+	buf64 := readBE64(ptr, 0)
+	next := 8
+	bufSize := 64
+	var sym int
+	for {
+		l := 0
+		for buf64 < d.base64[l] {
+			l++
+		}
+		sym = int((buf64-d.base64[l])>>(64-l-d.minSymLen)) + int(d.lowest(l))
+		if offset < int64(d.symlen[sym])+1 {
+			break
+		}
+		offset -= int64(d.symlen[sym]) + 1
+		l += d.minSymLen
+		buf64 <<= l
+		bufSize -= l
+		if bufSize <= 32 {
+			bufSize += 32
+			buf64 |= uint64(readBE32(ptr, next)) << (64 - bufSize)
+			next += 4
+		}
+	}
+
+	// El símbolo se expande en symlen+1 valores: bajar por el árbol hasta una hoja
+	for d.symlen[sym] != 0 {
+		left := d.left(sym)
+		if offset < int64(d.symlen[left])+1 {
+			sym = left
+		} else {
+			offset -= int64(d.symlen[left]) + 1
+			sym = d.right(sym)
+		}
+	}
+	return d.left(sym)
+}
+
+// readBE64 y readBE32 leen enteros big endian completando con ceros al final del fichero.
+func readBE64(b []byte, off int) uint64 {
+	return uint64(readBE32(b, off))<<32 | uint64(readBE32(b, off+4))
+}
+
+func readBE32(b []byte, off int) uint32 {
+	var buf [4]byte
+	if off < len(b) {
+		copy(buf[:], b[off:])
+	}
+	return binary.BigEndian.Uint32(buf[:])
+}
+
+// probeTable consulta directamente la tabla WDL o DTZ del material de la posición.
+// Para DTZ, wdl es el resultado ya conocido de la posición.
+func (tb *Tablebase) probeTable(b *Board, dtz bool, wdl WDL) (int, tbState) {
+	if bits.OnesCount64(b.AllPieces()) == 2 {
+		return int(WDLDraw), tbOK // Rey contra rey
+	}
+	key := tbMaterialKey(b)
+	e := tb.entries[key]
+	if e == nil {
+		return 0, tbFail
+	}
+	t := &e.wdl
+	if dtz {
+		t = &e.dtz
+	}
+	if t.load(e) != nil {
+		return 0, tbFail
+	}
+
+	// Las tablas tienen a las blancas como bando fuerte y, si el material es simétrico,
+	// solo guardan las blancas al mover: en otro caso se cambian los colores
+	flip := (e.key == e.key2 && !b.WhiteToMove) || key != e.key
+	var flipColor byte
+	flipSquares := 0
+	stm := 0
+	if !b.WhiteToMove {
+		stm = 1
+	}
+	if flip {
+		flipColor, flipSquares = 8, 56
+		stm ^= 1
+	}
+
+	var squares [tbMaxPieces]int
+	var pieces [tbMaxPieces]byte
+	size, leadPawnsCnt, file := 0, 0, 0
+	var leadPawns uint64
+	if e.hasPawns {
+		// Los peones guía son los del color de la primera pieza de la tabla
+		pc := t.pairs[0][0].pieces[0] ^ flipColor
+		leadPawns = b.WhitePieces.Pawns
+		if pc&8 != 0 {
+			leadPawns = b.BlackPieces.Pawns
+		}
+		for bb := leadPawns; bb != 0; bb &= bb - 1 {
+			squares[size] = bits.TrailingZeros64(bb) ^ flipSquares
+			size++
+		}
+		leadPawnsCnt = size
+		best := 0
+		for i := 1; i < leadPawnsCnt; i++ {
+			if tbMapPawns[squares[i]] > tbMapPawns[squares[best]] {
+				best = i
+			}
+		}
+		squares[0], squares[best] = squares[best], squares[0]
+		file = min(squares[0]&7, 7-squares[0]&7)
+	}
+
+	if dtz {
+		d := t.get(e, stm, file)
+		if int(d.flags&tbFlagSTM) != stm && (e.key != e.key2 || e.hasPawns) {
+			return 0, tbChangeSTM
+		}
+	}
+
+	for bb := b.AllPieces() &^ leadPawns; bb != 0; bb &= bb - 1 {
+		sq := bb & -bb
+		piece, isWhite := b.PieceAtSquare(sq)
+		code := byte(piece)
+		if !isWhite {
+			code |= 8
+		}
+		squares[size] = bits.TrailingZeros64(sq) ^ flipSquares
+		pieces[size] = code ^ flipColor
+		size++
+	}
+
+	d := t.get(e, stm, file)
+	// Ordenar las piezas como en la tabla
+	for i := leadPawnsCnt; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	idx := d.encode(e, squares[:size], leadPawnsCnt)
+	value := d.decompress(idx)
+	if !dtz {
+		return value - 2, tbOK
+	}
+	return t.mapDTZ(d, value, wdl), tbOK
+}
+
+// encode calcula el índice de la posición en la subtabla d. squares contiene las casillas
+// en el orden de piezas de la tabla, empezando por los leadPawnsCnt peones guía.
+func (d *tbPairs) encode(e *tbEntry, squares []int, leadPawnsCnt int) uint64 {
+	var idx uint64
+	// Llevar la pieza guía a las columnas a-d
+	if squares[0]&7 > 3 {
+		for i := 0; i < len(squares); i++ {
+			squares[i] ^= 7
+		}
+	}
+
+	if e.hasPawns {
+		idx = tbLeadPawnIdx[leadPawnsCnt][squares[0]]
+		rest := squares[1:leadPawnsCnt]
+		sort.SliceStable(rest, func(i, j int) bool { return tbMapPawns[rest[i]] < tbMapPawns[rest[j]] })
+		for i := 1; i < leadPawnsCnt; i++ {
+			idx += tbBinomial[i][tbMapPawns[squares[i]]]
+		}
+	} else {
+		// Sin peones, la pieza guía además en las filas 1-4 y bajo la diagonal a1-h8
+		if squares[0]>>3 > 3 {
+			for i := 0; i < len(squares); i++ {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < d.groupLen[0]; i++ {
+			if offA1H8(squares[i]) == 0 {
+				continue
+			}
+			if offA1H8(squares[i]) > 0 {
+				for j := i; j < len(squares); j++ {
+					squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+				}
+			}
+			break
+		}
+		if e.hasUniquePieces {
+			s0, s1, s2 := squares[0], squares[1], squares[2]
+			adjust1 := b2i(s1 > s0)
+			adjust2 := b2i(s2 > s0) + b2i(s2 > s1)
+			switch {
+			case offA1H8(s0) != 0:
+				idx = uint64((tbMapA1D1D4[s0]*63+(s1-adjust1))*62 + s2 - adjust2)
+			case offA1H8(s1) != 0:
+				idx = uint64((6*63+(s0>>3)*28+tbMapB1H1H7[s1])*62 + s2 - adjust2)
+			case offA1H8(s2) != 0:
+				idx = uint64(6*63*62 + 4*28*62 + (s0>>3)*7*28 + (s1>>3-adjust1)*28 + tbMapB1H1H7[s2])
+			default:
+				idx = uint64(6*63*62 + 4*28*62 + 4*7*28 + (s0>>3)*7*6 + (s1>>3-adjust1)*6 + (s2>>3 - adjust2))
+			}
+		} else {
+			idx = uint64(tbMapKK[tbMapA1D1D4[squares[0]]][squares[1]])
+		}
+	}
+
+	// Resto de grupos: combinaciones de casillas libres en orden ascendente
+	idx *= d.groupIdx[0]
+	start := d.groupLen[0]
+	remainingPawns := e.hasPawns && e.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		sort.Ints(group)
+		n := uint64(0)
+		for i, sq := range group {
+			adjust := 0
+			for _, prev := range squares[:start] {
+				if sq > prev {
+					adjust++
+				}
+			}
+			n += tbBinomial[i+1][sq-adjust-8*b2i(remainingPawns)]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += d.groupLen[next]
+	}
+
+	return idx
+}
+
+// mapDTZ convierte el valor almacenado en DTZ (en medias jugadas).
+func (t *tbTable) mapDTZ(d *tbPairs, value int, wdl WDL) int {
+	wdlMap := [5]int{1, 3, 0, 2, 0}
+	if d.flags&tbFlagMapped != 0 {
+		i := d.mapIdx[wdlMap[wdl+2]] + value
+		if d.flags&tbFlagWide != 0 {
+			value = int(binary.LittleEndian.Uint16(t.data[t.dtzMap+2*i:]))
+		} else {
+			value = int(t.data[t.dtzMap+i])
+		}
+	}
+	if (wdl == WDLWin && d.flags&tbFlagWinPlies == 0) || (wdl == WDLLoss && d.flags&tbFlagLossPlies == 0) ||
+		wdl == WDLCursedWin || wdl == WDLBlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// tbSearch resuelve las capturas (y, para DTZ, los movimientos de peón) antes de consultar
+// la tabla, que guarda valores "da igual" cuando la mejor jugada es una de ellas.
+func (tb *Tablebase) tbSearch(b *Board, zeroing bool) (WDL, tbState) {
+	best := WDLLoss
+	moves := b.strictLegalMoves()
+	count := 0
+	for _, m := range moves {
+		if !m.IsCapture() && (!zeroing || b.MovingPiece(m) != Pawn) {
+			continue
+		}
+		count++
+		st := b.perftMakeMove(m)
+		v, state := tb.tbSearch(b, false)
+		b.unmakeMove(st)
+		if state == tbFail {
+			return WDLDraw, tbFail
+		}
+		if -v > best {
+			best = -v
+			if best >= WDLWin {
+				return best, tbZeroingBestMove
+			}
+		}
+	}
+
+	// Si ya se han visto todas las jugadas no hace falta (ni se debe) consultar la tabla
+	noMoreMoves := count > 0 && count == len(moves)
+	value := best
+	if !noMoreMoves {
+		v, state := tb.probeTable(b, false, WDLDraw)
+		if state == tbFail {
+			return WDLDraw, tbFail
+		}
+		value = WDL(v)
+	}
+	if best >= value {
+		if best > WDLDraw || noMoreMoves {
+			return best, tbZeroingBestMove
+		}
+		return best, tbOK
+	}
+	return value, tbOK
+}
+
+// canProbe indica si la posición puede consultarse: material disponible y sin enroques.
+func (tb *Tablebase) canProbe(b *Board) bool {
+	return tb != nil && b.Variant == Standard && b.Castling == 0 && bits.OnesCount64(b.AllPieces()) <= tb.maxPieces
+}
+
+// ProbeWDL devuelve el resultado de la posición desde el punto de vista del bando al mover.
+// ok es false si no hay tabla para el material o la posición tiene derechos de enroque.
+func (tb *Tablebase) ProbeWDL(b *Board) (wdl WDL, ok bool) {
+	if !tb.canProbe(b) {
+		return WDLDraw, false
+	}
+	c := b.Clone()
+	c.nnue = nil
+	v, state := tb.tbSearch(c, false)
+	return v, state != tbFail
+}
+
+// dtzBeforeZeroing es la DTZ de una posición cuya mejor jugada es una captura o un
+// movimiento de peón.
+func dtzBeforeZeroing(wdl WDL) int {
+	switch wdl {
+	case WDLWin:
+		return 1
+	case WDLCursedWin:
+		return 101
+	case WDLBlessedLoss:
+		return -101
+	case WDLLoss:
+		return -1
+	}
+	return 0
+}
+
+// ProbeDTZ devuelve la distancia (en medias jugadas) hasta el siguiente movimiento que
+// reinicia la regla de los cincuenta movimientos jugando de forma óptima: positiva si el
+// bando al mover gana, negativa si pierde y 0 en tablas. Sin mucha precisión alrededor de
+// 100, donde importa la regla de los cincuenta movimientos.
+func (tb *Tablebase) ProbeDTZ(b *Board) (dtz int, ok bool) {
+	if !tb.canProbe(b) {
+		return 0, false
+	}
+	c := b.Clone()
+	c.nnue = nil
+	dtz, state := tb.probeDTZ(c)
+	return dtz, state != tbFail
+}
+
+func (tb *Tablebase) probeDTZ(b *Board) (int, tbState) {
+	wdl, state := tb.tbSearch(b, true)
+	if state == tbFail || wdl == WDLDraw {
+		return 0, state
+	}
+	if state == tbZeroingBestMove {
+		return dtzBeforeZeroing(wdl), tbOK
+	}
+	dtz, state := tb.probeTable(b, true, wdl)
+	if state == tbFail {
+		return 0, tbFail
+	}
+	if state != tbChangeSTM {
+		if wdl == WDLBlessedLoss || wdl == WDLCursedWin {
+			dtz += 100
+		}
+		if wdl < 0 {
+			dtz = -dtz
+		}
+		return dtz, tbOK
+	}
+
+	// La tabla guarda el otro bando al mover: búsqueda a una jugada
+	minDTZ := 0xFFFF
+	for _, m := range b.strictLegalMoves() {
+		zeroing := m.IsCapture() || b.MovingPiece(m) == Pawn
+		st := b.perftMakeMove(m)
+		var v int
+		if zeroing {
+			w, s := tb.tbSearch(b, false)
+			v, state = -dtzBeforeZeroing(w), s
+		} else {
+			v, state = tb.probeDTZ(b)
+			v = -v
+		}
+		if v == 1 && b.IsKingInCheck(b.WhiteToMove) && len(b.strictLegalMoves()) == 0 {
+			minDTZ = 1 // Mate
+		}
+		if !zeroing {
+			v += sign(v)
+		}
+		if v < minDTZ && sign(v) == sign(int(wdl)) {
+			minDTZ = v
+		}
+		b.unmakeMove(st)
+		if state == tbFail {
+			return 0, tbFail
+		}
+	}
+	if minDTZ == 0xFFFF {
+		return -1, tbOK // Sin jugadas legales: mate
+	}
+	return minDTZ, tbOK
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// ProbeRoot usa las tablas DTZ para elegir entre las jugadas legales de la raíz. Devuelve
+// las que mantienen el mejor resultado: si se gana, las que antes llevan a capturar o mover
+// un peón (para progresar); si se pierde, las que más lo retrasan; si son tablas, todas las
+// que no pierden. wdl es el resultado de la posición para el bando al mover.
+func (tb *Tablebase) ProbeRoot(b *Board) (moves MoveList, wdl WDL, ok bool) {
+	if !tb.canProbe(b) {
+		return nil, WDLDraw, false
+	}
+	c := b.Clone()
+	c.nnue = nil
+	halfMove := int(b.HalfMove)
+	bestRank := 0
+	for _, m := range c.strictLegalMoves() {
+		zeroing := m.IsCapture() || c.MovingPiece(m) == Pawn
+		st := c.perftMakeMove(m)
+		var dtz int
+		state := tbOK
+		if zeroing {
+			var w WDL
+			w, state = tb.tbSearch(c, false)
+			dtz = dtzBeforeZeroing(-w)
+		} else {
+			dtz, state = tb.probeDTZ(c)
+			dtz = -dtz
+			dtz += sign(dtz)
+		}
+		if dtz == 2 && c.IsKingInCheck(c.WhiteToMove) && len(c.strictLegalMoves()) == 0 {
+			dtz = 1 // Mate
+		}
+		c.unmakeMove(st)
+		if state == tbFail {
+			return nil, WDLDraw, false
+		}
+
+		rank, w := 0, WDLDraw
+		switch {
+		case dtz > 0 && dtz+halfMove <= 100:
+			rank, w = 1_000_000-dtz, WDLWin
+		case dtz > 0:
+			rank, w = 500_000-dtz, WDLCursedWin
+		case dtz < 0 && -dtz+halfMove <= 100:
+			rank, w = -1_000_000-dtz, WDLLoss
+		case dtz < 0:
+			rank, w = -500_000-dtz, WDLBlessedLoss
+		}
+		if len(moves) == 0 || rank > bestRank {
+			moves, bestRank, wdl = MoveList{m}, rank, w
+		} else if rank == bestRank {
+			moves.Add(m)
+		}
+	}
+	return moves, wdl, len(moves) > 0
+}
@@ -0,0 +1,562 @@
+package melange
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTablebaseIndexTables(t *testing.T) {
+	tbInitOnce.Do(initTablebaseIndices)
+	maxKK := 0
+	for i := 0; i < 10; i++ {
+		for sq := 0; sq < 64; sq++ {
+			maxKK = max(maxKK, tbMapKK[i][sq])
+		}
+	}
+	assert.Equal(t, maxKK, 461)
+	assert.Equal(t, tbBinomial[2][5], uint64(10))
+	assert.Equal(t, tbBinomial[3][62], uint64(37820))
+	seen := map[int]bool{}
+	for sq := 8; sq < 56; sq++ {
+		assert.Assert(t, tbMapPawns[sq] >= 0 && tbMapPawns[sq] < 48)
+		seen[tbMapPawns[sq]] = true
+	}
+	assert.Equal(t, len(seen), 48)
+	// Un peón guía: seis filas por columna
+	assert.Equal(t, tbLeadPawnsSz[1][0], uint64(6))
+}
+
+func TestTablebaseEntryKeys(t *testing.T) {
+	e, err := newTBEntry("KRPvKP")
+	assert.NilError(t, err)
+	assert.Equal(t, e.key2, "KPvKRP")
+	assert.Equal(t, e.pieceCount, 5)
+	assert.Assert(t, e.hasPawns && e.hasUniquePieces)
+	assert.Equal(t, e.pawnCount, [2]int{1, 1})
+
+	e, err = newTBEntry("KRRvK")
+	assert.NilError(t, err)
+	assert.Assert(t, !e.hasUniquePieces)
+
+	_, err = newTBEntry("KKvK")
+	assert.ErrorContains(t, err, "inválido")
+
+	b := &Board{}
+	assert.NilError(t, b.SetFen("8/8/4k3/3p4/8/2R5/3P4/4K3 w - - 0 1"))
+	assert.Equal(t, tbMaterialKey(b), "KRPvKP")
+}
+
+// tbSymmetries devuelve las ocho transformaciones del tablero aplicadas a sq.
+func tbSymmetries(sq int) [8]int {
+	var out [8]int
+	for s := 0; s < 8; s++ {
+		x := sq
+		if s&1 != 0 {
+			x ^= 7
+		}
+		if s&2 != 0 {
+			x ^= 56
+		}
+		if s&4 != 0 {
+			x = (x>>3 | x<<3) & 63
+		}
+		out[s] = x
+	}
+	return out
+}
+
+func kingsTouch(a, b int) bool {
+	df, dr := a&7-b&7, a>>3-b>>3
+	return df >= -1 && df <= 1 && dr >= -1 && dr <= 1
+}
+
+// El índice de una posición sin peones debe ser el mismo para las posiciones simétricas
+// y distinto para las que no lo son.
+func TestTablebaseEncodeSymmetries(t *testing.T) {
+	tbInitOnce.Do(initTablebaseIndices)
+	e, err := newTBEntry("KQvK")
+	assert.NilError(t, err)
+	d := &tbPairs{pieces: [tbMaxPieces]byte{5, 6, 14}}
+	d.setGroups(e, [2]int{0, 0xF}, 0)
+	assert.Equal(t, d.groupIdx[1], uint64(31332))
+
+	canonical := map[uint64][3]int{}
+	for q := 0; q < 64; q++ {
+		for wk := 0; wk < 64; wk++ {
+			for bk := 0; bk < 64; bk++ {
+				if q == wk || q == bk || kingsTouch(wk, bk) {
+					continue
+				}
+				sq, swk, sbk := tbSymmetries(q), tbSymmetries(wk), tbSymmetries(bk)
+				c := [3]int{sq[0], swk[0], sbk[0]}
+				for s := 1; s < 8; s++ {
+					if p := [3]int{sq[s], swk[s], sbk[s]}; p[0] < c[0] || p[0] == c[0] && (p[1] < c[1] || p[1] == c[1] && p[2] < c[2]) {
+						c = p
+					}
+				}
+				idx := d.encode(e, []int{q, wk, bk}, 0)
+				assert.Assert(t, idx < 31332, "índice %d fuera de rango", idx)
+				if prev, ok := canonical[idx]; ok {
+					assert.Equal(t, prev, c, "colisión en el índice %d", idx)
+				} else {
+					canonical[idx] = c
+				}
+			}
+		}
+	}
+}
+
+// Sin piezas únicas el primer grupo son los dos reyes, con 462 posiciones distintas.
+func TestTablebaseEncodeKings(t *testing.T) {
+	tbInitOnce.Do(initTablebaseIndices)
+	e, err := newTBEntry("KvK")
+	assert.NilError(t, err)
+	d := &tbPairs{pieces: [tbMaxPieces]byte{6, 14}}
+	d.setGroups(e, [2]int{0, 0xF}, 0)
+	assert.Equal(t, d.groupIdx[1], uint64(462))
+	canonical := map[uint64][2]int{}
+	for wk := 0; wk < 64; wk++ {
+		for bk := 0; bk < 64; bk++ {
+			if kingsTouch(wk, bk) {
+				continue
+			}
+			swk, sbk := tbSymmetries(wk), tbSymmetries(bk)
+			c := [2]int{swk[0], sbk[0]}
+			for s := 1; s < 8; s++ {
+				if swk[s] < c[0] || swk[s] == c[0] && sbk[s] < c[1] {
+					c = [2]int{swk[s], sbk[s]}
+				}
+			}
+			idx := d.encode(e, []int{wk, bk}, 0)
+			assert.Assert(t, idx < 462, "índice %d fuera de rango", idx)
+			if prev, ok := canonical[idx]; ok {
+				assert.Equal(t, prev, c, "colisión en el índice %d", idx)
+			} else {
+				canonical[idx] = c
+			}
+		}
+	}
+	assert.Equal(t, len(canonical), 462)
+}
+
+func TestTablebaseEncodePawns(t *testing.T) {
+	tbInitOnce.Do(initTablebaseIndices)
+	e, err := newTBEntry("KPvK")
+	assert.NilError(t, err)
+	var files [4]*tbPairs
+	for f := range files {
+		files[f] = &tbPairs{pieces: [tbMaxPieces]byte{1, 6, 14}}
+		files[f].setGroups(e, [2]int{0, 0xF}, f)
+	}
+	seen := map[[2]uint64][3]int{}
+	for p := 8; p < 56; p++ {
+		file := min(p&7, 7-p&7)
+		d := files[file]
+		for wk := 0; wk < 64; wk++ {
+			for bk := 0; bk < 64; bk++ {
+				if p == wk || p == bk || wk == bk || kingsTouch(wk, bk) {
+					continue
+				}
+				// Con peones solo hay simetría horizontal
+				c := [3]int{p, wk, bk}
+				if p&7 > 3 {
+					c = [3]int{p ^ 7, wk ^ 7, bk ^ 7}
+				}
+				idx := d.encode(e, []int{p, wk, bk}, 1)
+				assert.Assert(t, idx < d.groupIdx[3], "índice %d fuera de rango", idx)
+				key := [2]uint64{uint64(file), idx}
+				if prev, ok := seen[key]; ok {
+					assert.Equal(t, prev, c, "colisión en el índice %d", idx)
+				} else {
+					seen[key] = c
+				}
+			}
+		}
+	}
+}
+
+// writeKQvKTable escribe una tabla WDL de KQvK con blancas al mover comprimida con dos
+// símbolos de un bit (tablas o victoria según win) y un valor único para las negras al
+// mover.
+func writeKQvKTable(t *testing.T, dir string, win func(q, wk, bk int) bool, black WDL) {
+	e, err := newTBEntry("KQvK")
+	assert.NilError(t, err)
+	d := &tbPairs{pieces: [tbMaxPieces]byte{5, 6, 14}}
+	d.setGroups(e, [2]int{0, 0xF}, 0)
+	block := make([]byte, 4096)
+	for q := 0; q < 64; q++ {
+		for wk := 0; wk < 64; wk++ {
+			for bk := 0; bk < 64; bk++ {
+				if q == wk || q == bk || kingsTouch(wk, bk) || !win(q, wk, bk) {
+					continue
+				}
+				idx := d.encode(e, []int{q, wk, bk}, 0)
+				block[idx/8] |= 0x80 >> (idx % 8)
+			}
+		}
+	}
+
+	data := append([]byte{}, wdlMagic...)
+	data = append(data, 1, 0x00, 0x55, 0x66, 0xEE, 0) // Dos bandos, orden y piezas
+	// Blancas al mover: bloques de 4096 bytes, span 32768, un bloque, símbolos de un bit
+	data = append(data, 0, 12, 15, 0)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = append(data, 1, 1, 0, 0)
+	data = binary.LittleEndian.AppendUint16(data, 2)
+	data = append(data, 2, 0xF0, 0xFF, 4, 0xF0, 0xFF)
+	// Negras al mover: siempre el mismo resultado
+	data = append(data, tbFlagSingleValue, byte(black+2))
+	// Índice disperso y longitud del bloque
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = binary.LittleEndian.AppendUint16(data, 16384)
+	data = binary.LittleEndian.AppendUint16(data, 31331)
+	for len(data)%64 != 0 {
+		data = append(data, 0)
+	}
+	data = append(data, block...)
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "KQvK.rtbw"), data, 0o644))
+}
+
+func sameColor(a, b int) bool {
+	return (a&7+a>>3)%2 == (b&7+b>>3)%2
+}
+
+func TestTablebaseProbeSyntheticWDL(t *testing.T) {
+	dir := t.TempDir()
+	// Una propiedad invariante por simetría: dama y rey negro en casillas del mismo color
+	writeKQvKTable(t, dir, func(q, wk, bk int) bool { return sameColor(q, bk) }, WDLDraw)
+	tb, err := OpenTablebase(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, tb.MaxPieces(), 3)
+
+	tests := []struct {
+		fen string
+		wdl WDL
+	}{
+		{"8/8/8/4k3/1Q6/8/8/K7 w - - 0 1", WDLWin},
+		{"8/8/8/4k3/8/1Q6/8/K7 w - - 0 1", WDLDraw},
+		{"7k/8/8/8/8/8/8/KQ6 w - - 0 1", WDLDraw},
+		{"7k/8/8/8/8/Q7/8/K7 w - - 0 1", WDLWin},
+		// Negras al mover: la tabla es de tablas
+		{"8/8/8/4k3/8/1Q6/8/K7 b - - 0 1", WDLDraw},
+		// Colores cambiados: se consulta la tabla KQvK con las blancas como bando fuerte
+		{"k7/8/q7/8/4K3/8/8/8 b - - 0 1", WDLWin},
+		{"k7/8/1q6/8/4K3/8/8/8 b - - 0 1", WDLDraw},
+	}
+	for _, tt := range tests {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(tt.fen))
+		wdl, ok := tb.ProbeWDL(b)
+		assert.Assert(t, ok, tt.fen)
+		assert.Equal(t, wdl, tt.wdl, tt.fen)
+	}
+
+	// Sin tabla para el material, con enroques o sin tabla DTZ no se puede consultar
+	b := &Board{}
+	assert.NilError(t, b.SetFen("8/8/8/4k3/8/1R6/8/K7 w - - 0 1"))
+	_, ok := tb.ProbeWDL(b)
+	assert.Assert(t, !ok)
+	assert.NilError(t, b.SetFen("4k3/8/8/8/8/1Q6/8/4K2R w K - 0 1"))
+	_, ok = tb.ProbeWDL(b)
+	assert.Assert(t, !ok)
+	assert.NilError(t, b.SetFen("8/8/8/4k3/1Q6/8/8/K7 w - - 0 1"))
+	_, ok = tb.ProbeDTZ(b)
+	assert.Assert(t, !ok)
+}
+
+func TestTablebaseKingCapturesQueen(t *testing.T) {
+	dir := t.TempDir()
+	writeKQvKTable(t, dir, func(q, wk, bk int) bool { return true }, WDLLoss)
+	tb, err := OpenTablebase(dir)
+	assert.NilError(t, err)
+	b := &Board{}
+	assert.NilError(t, b.SetFen("8/8/8/8/8/2k5/8/K1Q5 b - - 0 1"))
+	wdl, ok := tb.ProbeWDL(b)
+	assert.Assert(t, ok)
+	assert.Equal(t, wdl, WDLLoss)
+	// Las negras capturan la dama: KvK es tablas aunque la tabla diga otra cosa
+	assert.NilError(t, b.SetFen("8/8/8/8/8/2k5/2Q5/K7 b - - 0 1"))
+	wdl, ok = tb.ProbeWDL(b)
+	assert.Assert(t, ok)
+	assert.Equal(t, wdl, WDLDraw)
+}
+
+func TestTablebaseBadFiles(t *testing.T) {
+	tb, err := OpenTablebase(t.TempDir())
+	assert.NilError(t, err)
+	assert.Equal(t, tb.MaxPieces(), 0)
+	b := &Board{}
+	assert.NilError(t, b.SetFen("8/8/8/4k3/8/1Q6/8/K7 w - - 0 1"))
+	_, ok := tb.ProbeWDL(b)
+	assert.Assert(t, !ok)
+
+	_, err = OpenTablebase(filepath.Join(t.TempDir(), "no-existe"))
+	assert.Assert(t, err != nil)
+
+	// Un fichero con otra firma o truncado no debe tumbar el motor
+	for _, content := range [][]byte{[]byte("no es una tabla"), append(append([]byte{}, wdlMagic...), 1, 0)} {
+		dir := t.TempDir()
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "KQvK.rtbw"), content, 0o644))
+		tb, err := OpenTablebase(dir)
+		assert.NilError(t, err)
+		_, ok := tb.ProbeWDL(b)
+		assert.Assert(t, !ok)
+	}
+}
+
+// tbFen escribe la posición con las piezas indicadas por casilla (0 es a1).
+func tbFen(pieces map[int]byte, white bool) string {
+	var sb strings.Builder
+	for r := 7; r >= 0; r-- {
+		empty := 0
+		for f := 0; f < 8; f++ {
+			piece, ok := pieces[r*8+f]
+			if !ok {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteByte(piece)
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if r > 0 {
+			sb.WriteByte('/')
+		}
+	}
+	if white {
+		sb.WriteString(" w - - 0 1")
+	} else {
+		sb.WriteString(" b - - 0 1")
+	}
+	return sb.String()
+}
+
+// openTestTablebase abre las tablas de tres y cuatro piezas de testdata/syzygy, que
+// escribe testdata/syzygy/gen.go resolviendo los finales por análisis retrógrado.
+func openTestTablebase(t *testing.T) *Tablebase {
+	tb, err := OpenTablebase(filepath.Join("testdata", "syzygy"))
+	assert.NilError(t, err)
+	assert.Equal(t, tb.MaxPieces(), 4)
+	return tb
+}
+
+func TestTablebaseRealFiles(t *testing.T) {
+	tb := openTestTablebase(t)
+	tests := []struct {
+		fen  string
+		wdl  WDL
+		dtz  int
+		root []string // Jugadas que conserva ProbeRoot
+	}{
+		// Dc8 es mate y Dc7 ahoga
+		{"k7/8/1K6/8/8/8/8/2Q5 w - - 0 1", WDLWin, 1, []string{"c1c8"}},
+		// Las negras capturan la dama y solo eso salva la partida
+		{"8/8/8/8/8/2k5/2Q5/K7 b - - 0 1", WDLDraw, 0, []string{"c3c2"}},
+		{"8/8/8/4k3/8/8/2Q5/K7 b - - 0 1", WDLLoss, 0, nil},
+		// Rey en sexta delante del peón: gana juegue quien juegue y el peón solo avanza
+		// después de una jugada de rey
+		{"3k4/8/3K4/3P4/8/8/8/8 w - - 0 1", WDLWin, 3, []string{"d6c6", "d6e6"}},
+		{"3k4/8/3K4/3P4/8/8/8/8 b - - 0 1", WDLLoss, -4, []string{"d8c8", "d8e8"}},
+		// Peón de torre con el rey contrario en la esquina: nada pierde
+		{"7k/8/8/8/8/8/7P/7K w - - 0 1", WDLDraw, 0, []string{"h1g1", "h1g2", "h2h3", "h2h4"}},
+		// Regla del cuadrado: el peón corona solo
+		{"8/k7/8/8/8/8/7P/K7 w - - 0 1", WDLWin, 1, []string{"h2h3", "h2h4"}},
+		// Coronar dama o alfil ahoga: solo gana la torre
+		{"8/1P6/8/8/8/8/8/5K1k w - - 0 1", WDLWin, 1, []string{"b7b8r"}},
+		{"8/k1P5/2K5/8/8/8/8/8 w - - 0 1", WDLWin, 1, []string{"c7c8r"}},
+		// Colores cambiados: se consulta KRvK con las blancas como bando fuerte
+		{"1r6/8/8/8/3k4/8/K7/8 b - - 0 1", WDLWin, 0, nil},
+		{"1r6/8/8/8/3k4/8/K7/8 w - - 0 1", WDLLoss, 0, nil},
+		// Dos alfiles del mismo color no pueden dar mate; de distinto color, sí
+		{"7k/8/8/8/8/8/8/K1B1B3 w - - 0 1", WDLDraw, 0, nil},
+		{"7k/8/8/8/8/8/8/K1BB4 w - - 0 1", WDLWin, 0, nil},
+		{"7k/8/8/8/8/8/8/K1BB4 b - - 0 1", WDLLoss, 0, nil},
+		{"k7/B7/1K6/8/2B5/8/8/8 w - - 0 1", WDLWin, 1, []string{"c4d5"}},
+		// KQvKR: cinco mates en una y, con las negras al mover, la enfilada que gana la dama
+		{"k7/3Q4/1K6/8/8/8/8/7r w - - 0 1", WDLWin, 1, []string{"d7a7", "d7b7", "d7c8", "d7d8", "d7e8"}},
+		{"7r/8/8/2k5/K7/8/8/Q7 b - - 0 1", WDLWin, 3, []string{"h8a8"}},
+		// KPvKP: tras d7-d5, exd6 al paso deja un peón que el rey negro no alcanza; e6 también
+		// gana. Sin el derecho a capturar al paso solo queda e6
+		{"8/8/8/3pP3/8/8/8/K6k w - d6 0 1", WDLWin, 1, []string{"e5d6", "e5e6"}},
+		{"8/8/8/3pP3/8/8/8/K6k w - - 0 1", WDLWin, 1, []string{"e5e6"}},
+		// KPvKP: coronar dama o torre es mate; las negras, ahogadas
+		{"k7/2P5/1K6/8/8/8/7p/8 w - - 0 1", WDLWin, 1, []string{"c7c8q", "c7c8r"}},
+		{"k7/p1K5/P7/8/8/8/8/8 b - - 0 1", WDLDraw, 0, nil},
+	}
+	for _, tt := range tests {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(tt.fen))
+		wdl, ok := tb.ProbeWDL(b)
+		assert.Assert(t, ok, tt.fen)
+		assert.Equal(t, wdl, tt.wdl, tt.fen)
+		if tt.root == nil {
+			continue
+		}
+		dtz, ok := tb.ProbeDTZ(b)
+		assert.Assert(t, ok, tt.fen)
+		assert.Equal(t, dtz, tt.dtz, tt.fen)
+		moves, rootWDL, ok := tb.ProbeRoot(b)
+		assert.Assert(t, ok, tt.fen)
+		assert.Equal(t, rootWDL, tt.wdl, tt.fen)
+		var got []string
+		for _, m := range moves {
+			got = append(got, m.UCIString())
+		}
+		sort.Strings(got)
+		assert.DeepEqual(t, got, tt.root)
+	}
+}
+
+// Las victorias más largas de KQvK y KRvK son los mates en 10 y en 16; las de KBBvK y KQvKR,
+// el mate en 19 y 31 jugadas hasta capturar la torre (o dar mate).
+func TestTablebaseRealFilesLongestWins(t *testing.T) {
+	tb := openTestTablebase(t)
+	for piece, want := range map[byte]int{'Q': 19, 'R': 31} {
+		longest := 0
+		// Por simetría basta con el rey blanco en el triángulo a1-d1-d4
+		for _, wk := range []int{0, 1, 2, 3, 9, 10, 11, 18, 19, 27} {
+			for bk := 0; bk < 64; bk++ {
+				for x := 0; x < 64; x++ {
+					if bk == wk || x == wk || x == bk || kingsTouch(wk, bk) {
+						continue
+					}
+					b := &Board{}
+					assert.NilError(t, b.SetFen(tbFen(map[int]byte{wk: 'K', bk: 'k', x: piece}, true)))
+					if b.IsKingInCheck(false) {
+						continue
+					}
+					dtz, ok := tb.ProbeDTZ(b)
+					assert.Assert(t, ok, b.Fen())
+					longest = max(longest, dtz)
+				}
+			}
+		}
+		assert.Equal(t, longest, want, string(piece))
+	}
+
+	// Recorrer los finales de cuatro piezas tarda demasiado: se comprueban las posiciones
+	// más largas que da ese mismo recorrido
+	for fen, want := range map[string]int{
+		"8/8/8/8/7B/8/3k4/K2B4 w - - 0 1": 37,
+		"8/8/8/8/Q7/5k2/8/K3r3 w - - 0 1": 61,
+	} {
+		b := &Board{}
+		assert.NilError(t, b.SetFen(fen))
+		dtz, ok := tb.ProbeDTZ(b)
+		assert.Assert(t, ok, fen)
+		assert.Equal(t, dtz, want, fen)
+	}
+}
+
+// Cada valor de las tablas tiene que salir de las posiciones siguientes, generadas por el
+// motor: el WDL es el mejor de los hijos, y la DTZ la de la victoria más rápida o la
+// derrota más lenta (1 si la jugada captura, mueve un peón o da mate).
+func TestTablebaseRealFilesConsistent(t *testing.T) {
+	tb := openTestTablebase(t)
+	for _, piece := range []byte{'Q', 'R', 'P'} {
+		checked := 0
+		for i := 0; i < 2*64*64*64; i += 157 {
+			white, wk, bk, x := i < 64*64*64, i>>12&63, i>>6&63, i&63
+			if bk == wk || x == wk || x == bk || kingsTouch(wk, bk) || piece == 'P' && (x < 8 || x >= 56) {
+				continue
+			}
+			b := &Board{}
+			assert.NilError(t, b.SetFen(tbFen(map[int]byte{wk: 'K', bk: 'k', x: piece}, white)))
+			if b.IsKingInCheck(!white) {
+				continue
+			}
+			checked++
+			checkTablebaseConsistent(t, tb, b)
+		}
+		assert.Assert(t, checked > 1000, checked)
+	}
+
+	// Con cuatro piezas, posiciones al azar de cada tabla con DTZ. Las WDL de KQvKP, KRvKP,
+	// KBvKP y KNvKP se consultan al coronar en KPvKP
+	rng := rand.New(rand.NewSource(1))
+	for _, extra := range []string{"BB", "Qr", "Pp"} {
+		for checked := 0; checked < 300; {
+			pieces := map[int]byte{}
+			for _, p := range "Kk" + extra {
+				sq := rng.Intn(64)
+				for pieces[sq] != 0 || (p == 'P' || p == 'p') && (sq < 8 || sq >= 56) {
+					sq = rng.Intn(64)
+				}
+				pieces[sq] = byte(p)
+			}
+			white := rng.Intn(2) == 0
+			b := &Board{}
+			assert.NilError(t, b.SetFen(tbFen(pieces, white)))
+			wk := bits.TrailingZeros64(b.WhitePieces.King)
+			bk := bits.TrailingZeros64(b.BlackPieces.King)
+			if kingsTouch(wk, bk) || b.IsKingInCheck(!white) {
+				continue
+			}
+			checked++
+			checkTablebaseConsistent(t, tb, b)
+		}
+	}
+}
+
+func checkTablebaseConsistent(t *testing.T, tb *Tablebase, b *Board) {
+	t.Helper()
+	white := b.WhiteToMove
+	wdl, ok := tb.ProbeWDL(b)
+	assert.Assert(t, ok, b.Fen())
+	dtz, ok := tb.ProbeDTZ(b)
+	assert.Assert(t, ok, b.Fen())
+
+	moves := b.strictLegalMoves()
+	if len(moves) == 0 {
+		want := WDLDraw
+		if b.IsKingInCheck(white) {
+			want = WDLLoss
+		}
+		assert.Equal(t, wdl, want, b.Fen())
+		return
+	}
+	best, bestDTZ := WDLLoss, 0
+	for _, m := range moves {
+		zeroing := m.IsCapture() || b.MovingPiece(m) == Pawn
+		st := b.perftMakeMove(m)
+		w, ok := tb.ProbeWDL(b)
+		assert.Assert(t, ok, b.Fen())
+		d := 1
+		if !zeroing && (!b.IsKingInCheck(b.WhiteToMove) || len(b.strictLegalMoves()) > 0) {
+			childDTZ, ok := tb.ProbeDTZ(b)
+			assert.Assert(t, ok, b.Fen())
+			d += abs(childDTZ)
+		}
+		b.unmakeMove(st)
+		switch {
+		case -w > best:
+			best, bestDTZ = -w, d
+		case -w == best && best == WDLWin:
+			bestDTZ = min(bestDTZ, d)
+		case -w == best && best == WDLLoss:
+			bestDTZ = max(bestDTZ, d)
+		}
+	}
+	assert.Equal(t, wdl, best, b.Fen())
+	switch wdl {
+	case WDLWin:
+		assert.Equal(t, dtz, bestDTZ, b.Fen())
+	case WDLLoss:
+		assert.Equal(t, dtz, -bestDTZ, b.Fen())
+	default:
+		assert.Equal(t, dtz, 0, b.Fen())
+	}
+}
@@ -0,0 +1,1570 @@
+//go:build ignore
+
+// gen escribe las tablas Syzygy que usan las pruebas: WDL y DTZ de KQvK, KRvK, KBvK, KNvK,
+// KPvK, KBBvK, KQvKR y KPvKP, y WDL de KQvKP, KRvKP, KBvKP y KNvKP, a las que lleva coronar
+// en KPvKP. Resuelve cada final por análisis retrógrado y comprime los valores con el mismo
+// formato que las tablas oficiales (recursive pairing y códigos de Huffman canónicos). No
+// comparte código con syzygy.go: tiene su propio generador de movimientos y su propio
+// cálculo de índices, que comprueba contra una enumeración de los índices de tres piezas y
+// exigiendo que todas las posiciones que van a un mismo índice tengan el mismo valor.
+//
+//	cd testdata/syzygy && go run gen.go
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Códigos de pieza de Syzygy: las blancas del 1 (peón) al 6 (rey) y las negras sumando 8
+const (
+	pawn   byte = 1
+	knight byte = 2
+	bishop byte = 3
+	rook   byte = 4
+	queen  byte = 5
+	king   byte = 6
+	black  byte = 8
+)
+
+const (
+	win  int8 = 1
+	draw int8 = 0
+	loss int8 = -1
+	// Estados de las posiciones mientras se resuelve un final
+	unknown int8 = 2
+	illegal int8 = -2
+)
+
+var promotions = []byte{queen, rook, bishop, knight}
+
+func file(sq int) int        { return sq & 7 }
+func rank(sq int) int        { return sq >> 3 }
+func bit(sq int) uint64      { return 1 << uint(sq) }
+func colorOf(c byte) int     { return int(c >> 3) }
+func kindOf(c byte) byte     { return c & 7 }
+func offDiagonal(sq int) int { return rank(sq) - file(sq) }
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Ataques
+
+var (
+	straight = [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	diagonal = [][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+	jumps    = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+
+	kingAttacks, knightAttacks [64]uint64
+	pawnAttacks                [2][64]uint64
+)
+
+func step(sq int, d [2]int) uint64 {
+	if f, r := file(sq)+d[0], rank(sq)+d[1]; f >= 0 && f < 8 && r >= 0 && r < 8 {
+		return bit(r*8 + f)
+	}
+	return 0
+}
+
+func init() {
+	for sq := 0; sq < 64; sq++ {
+		for _, d := range append(append([][2]int{}, straight...), diagonal...) {
+			kingAttacks[sq] |= step(sq, d)
+		}
+		for _, d := range jumps {
+			knightAttacks[sq] |= step(sq, d)
+		}
+		pawnAttacks[0][sq] = step(sq, [2]int{1, 1}) | step(sq, [2]int{-1, 1})
+		pawnAttacks[1][sq] = step(sq, [2]int{1, -1}) | step(sq, [2]int{-1, -1})
+	}
+}
+
+func slide(sq int, occ uint64, dirs [][2]int) uint64 {
+	var out uint64
+	for _, d := range dirs {
+		for f, r := file(sq)+d[0], rank(sq)+d[1]; f >= 0 && f < 8 && r >= 0 && r < 8; f, r = f+d[0], r+d[1] {
+			out |= bit(r*8 + f)
+			if occ&bit(r*8+f) != 0 {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// attacksFrom devuelve las casillas que ataca la pieza code desde sq con las casillas
+// ocupadas occ.
+func attacksFrom(code byte, sq int, occ uint64) uint64 {
+	switch kindOf(code) {
+	case pawn:
+		return pawnAttacks[colorOf(code)][sq]
+	case knight:
+		return knightAttacks[sq]
+	case bishop:
+		return slide(sq, occ, diagonal)
+	case rook:
+		return slide(sq, occ, straight)
+	case queen:
+		return slide(sq, occ, diagonal) | slide(sq, occ, straight)
+	}
+	return kingAttacks[sq]
+}
+
+// Finales
+
+// material son los códigos de las piezas de un final: el rey blanco, el negro y el resto.
+// Las posiciones guardan la casilla de cada pieza en este orden.
+type material []byte
+
+func (m material) name() string {
+	var sb strings.Builder
+	for c := 0; c < 2; c++ {
+		if c == 1 {
+			sb.WriteByte('v')
+		}
+		sb.WriteByte('K')
+		for _, k := range []byte{queen, rook, bishop, knight, pawn} {
+			for _, code := range m {
+				if code == k|byte(c)*black {
+					sb.WriteByte(" PNBRQK"[k])
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+// without devuelve el material sin la pieza i o, si promo no es 0, con la pieza i
+// convertida en promo.
+func (m material) without(i int, promo byte) material {
+	var out material
+	for j, c := range m {
+		switch {
+		case j != i:
+			out = append(out, c)
+		case promo != 0:
+			out = append(out, promo)
+		}
+	}
+	return out
+}
+
+// solved es un final resuelto: el WDL de cada posición para el bando al mover y, si se
+// pide, la DTZ en medias jugadas (0 en los mates).
+type solved struct {
+	mat material
+	n   int
+	wdl []int8
+	dtz []int16
+}
+
+// tables guarda los finales resueltos por nombre.
+var tables = map[string]*solved{}
+
+func (s *solved) index(stm int, sq *[4]int) int {
+	idx := stm
+	for i := 0; i < s.n; i++ {
+		idx = idx<<6 | sq[i]
+	}
+	return idx
+}
+
+func (s *solved) decode(idx int) (stm int, sq [4]int) {
+	for i := s.n - 1; i >= 0; i-- {
+		sq[i] = idx & 63
+		idx >>= 6
+	}
+	return idx, sq
+}
+
+func (s *solved) occupancy(sq *[4]int) (occ uint64, byColor [2]uint64) {
+	for i := 0; i < s.n; i++ {
+		occ |= bit(sq[i])
+		byColor[colorOf(s.mat[i])] |= bit(sq[i])
+	}
+	return occ, byColor
+}
+
+// attacked indica si alguna pieza del bando by, salvo la que está en skip, ataca target.
+func (s *solved) attacked(target, by, skip int, sq *[4]int, occ uint64) bool {
+	for j := 0; j < s.n; j++ {
+		if j != skip && colorOf(s.mat[j]) == by && attacksFrom(s.mat[j], sq[j], occ)&bit(target) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *solved) legal(stm int, sq *[4]int) bool {
+	occ, _ := s.occupancy(sq)
+	if bits.OnesCount64(occ) != s.n {
+		return false
+	}
+	for i := 0; i < s.n; i++ {
+		if kindOf(s.mat[i]) == pawn && (rank(sq[i]) == 0 || rank(sq[i]) == 7) {
+			return false
+		}
+	}
+	// El rey del bando que no mueve no puede estar en jaque (ni tocar al otro rey)
+	return !s.attacked(sq[stm^1], stm, -1, sq, occ)
+}
+
+func (s *solved) pieceAt(sq *[4]int, target int) int {
+	for j := 0; j < s.n; j++ {
+		if sq[j] == target {
+			return j
+		}
+	}
+	return -1
+}
+
+// lookup devuelve el WDL para el bando al mover de la posición con las piezas codes en las
+// casillas sq, del final que sea. Si solo está resuelto el final con los colores cambiados,
+// consulta la posición simétrica.
+func lookup(codes []byte, sq []int, stm int) int8 {
+	if len(codes) == 2 {
+		return draw // Rey contra rey
+	}
+	t := tables[material(codes).name()]
+	flip := 0
+	if t == nil {
+		var flipped [4]byte
+		for i, c := range codes {
+			flipped[i] = c ^ black
+		}
+		t = tables[material(flipped[:len(codes)]).name()]
+		if t == nil {
+			panic("final sin resolver: " + material(codes).name())
+		}
+		flip = 1
+	}
+	// Colocar las piezas en el orden del final resuelto
+	var placed [4]int
+	used := 0
+	for i, want := range t.mat {
+		for j, c := range codes {
+			if c^byte(flip)*black == want && used&(1<<j) == 0 {
+				placed[i] = sq[j] ^ 56*flip
+				used |= 1 << j
+				break
+			}
+		}
+	}
+	v := t.wdl[t.index(stm^flip, &placed)]
+	if v == illegal {
+		panic("posición ilegal en " + t.mat.name())
+	}
+	return v
+}
+
+// move es una jugada: child es la posición resultante en el mismo final o -1 si sale de
+// él (captura o coronación), y entonces value es su resultado para el rival. floor es lo
+// mínimo que consigue el rival tras un doble avance capturando al paso (loss si no puede).
+type move struct {
+	child   int32
+	value   int8
+	floor   int8
+	zeroing bool
+}
+
+// moves llama a fn con cada jugada legal del bando stm.
+func (s *solved) moves(stm int, sq *[4]int, fn func(move)) {
+	m, n := s.mat, s.n
+	occ, byColor := s.occupancy(sq)
+	// play comprueba que la pieza i puede ir a to, capturando la pieza captured (o -1) y
+	// coronando en promo (o 0), sin dejar a su rey en jaque
+	play := func(i, to, captured int, promo byte, double bool) {
+		next := *sq
+		next[i] = to
+		nextOcc := occ&^bit(sq[i]) | bit(to)
+		if s.attacked(next[stm], stm^1, captured, &next, nextOcc) {
+			return
+		}
+		if captured < 0 && promo == 0 {
+			mv := move{child: int32(s.index(stm^1, &next)), floor: loss, zeroing: kindOf(m[i]) == pawn}
+			if double {
+				mv.floor = s.epFloor(stm^1, &next, i)
+			}
+			fn(mv)
+			return
+		}
+		var codes [4]byte
+		var squares [4]int
+		k := 0
+		for j := 0; j < n; j++ {
+			if j == captured {
+				continue
+			}
+			codes[k], squares[k] = m[j], next[j]
+			if j == i && promo != 0 {
+				codes[k] = promo
+			}
+			k++
+		}
+		fn(move{child: -1, value: lookup(codes[:k], squares[:k], stm^1), zeroing: true})
+	}
+	for i := 0; i < n; i++ {
+		code, from := m[i], sq[i]
+		if colorOf(code) != stm {
+			continue
+		}
+		enemy := byColor[stm^1] &^ bit(sq[stm^1])
+		if kindOf(code) != pawn {
+			for t := attacksFrom(code, from, occ) &^ byColor[stm] &^ bit(sq[stm^1]); t != 0; t &= t - 1 {
+				to := bits.TrailingZeros64(t)
+				captured := -1
+				if enemy&bit(to) != 0 {
+					captured = s.pieceAt(sq, to)
+				}
+				play(i, to, captured, 0, false)
+			}
+			continue
+		}
+		dir, start, last := 8, 1, 7
+		if stm == 1 {
+			dir, start, last = -8, 6, 0
+		}
+		promos := []byte{0}
+		if rank(from+dir) == last {
+			promos = promotions
+		}
+		for _, p := range promos {
+			if p != 0 {
+				p |= byte(stm) * black
+			}
+			if occ&bit(from+dir) == 0 {
+				play(i, from+dir, -1, p, false)
+				if rank(from) == start && occ&bit(from+2*dir) == 0 {
+					play(i, from+2*dir, -1, 0, true)
+				}
+			}
+			for t := pawnAttacks[stm][from] & enemy; t != 0; t &= t - 1 {
+				to := bits.TrailingZeros64(t)
+				play(i, to, s.pieceAt(sq, to), p, false)
+			}
+		}
+	}
+}
+
+// epFloor devuelve lo mínimo que consigue el bando al mover, stm, tras el doble avance del
+// peón i: el mejor resultado de capturarlo al paso, o loss si no puede.
+func (s *solved) epFloor(stm int, sq *[4]int, i int) int8 {
+	best := loss
+	pushed := sq[i]
+	target := pushed - 8
+	if stm == 0 {
+		target = pushed + 8
+	}
+	occ, _ := s.occupancy(sq)
+	for j := 0; j < s.n; j++ {
+		if s.mat[j] != pawn|byte(stm)*black || rank(sq[j]) != rank(pushed) || abs(file(sq[j])-file(pushed)) != 1 {
+			continue
+		}
+		next := *sq
+		next[j] = target
+		nextOcc := occ&^bit(sq[j])&^bit(pushed) | bit(target)
+		if s.attacked(next[stm], stm^1, i, &next, nextOcc) {
+			continue
+		}
+		var codes [4]byte
+		var squares [4]int
+		k := 0
+		for x := 0; x < s.n; x++ {
+			if x != i {
+				codes[k], squares[k] = s.mat[x], next[x]
+				k++
+			}
+		}
+		best = max(best, -lookup(codes[:k], squares[:k], stm^1))
+	}
+	return best
+}
+
+// unmoves llama a fn con cada posición legal del mismo final desde la que se llega a la
+// posición (stm, sq) con una jugada, indicando si la jugada reinicia la cuenta de los
+// cincuenta movimientos y, en los dobles avances, su epFloor.
+func (s *solved) unmoves(stm int, sq *[4]int, fn func(pred int32, zeroing bool, floor int8)) {
+	mover := stm ^ 1
+	occ, _ := s.occupancy(sq)
+	try := func(i, from int, zeroing, double bool) {
+		prev := *sq
+		prev[i] = from
+		prevOcc := occ&^bit(sq[i]) | bit(from)
+		if s.attacked(prev[stm], mover, -1, &prev, prevOcc) {
+			return
+		}
+		floor := loss
+		if double {
+			floor = s.epFloor(stm, sq, i)
+		}
+		fn(int32(s.index(mover, &prev)), zeroing, floor)
+	}
+	for i := 0; i < s.n; i++ {
+		code, to := s.mat[i], sq[i]
+		if colorOf(code) != mover {
+			continue
+		}
+		if kindOf(code) != pawn {
+			for t := attacksFrom(code, to, occ) &^ occ; t != 0; t &= t - 1 {
+				try(i, bits.TrailingZeros64(t), false, false)
+			}
+			continue
+		}
+		dir, start := 8, 1
+		if mover == 1 {
+			dir, start = -8, 6
+		}
+		from := to - dir
+		if rank(from) == 0 || rank(from) == 7 || occ&bit(from) != 0 {
+			continue
+		}
+		try(i, from, true, false)
+		if rank(from-dir) == start && occ&bit(from-dir) == 0 {
+			try(i, from-dir, true, true)
+		}
+	}
+}
+
+// ensure resuelve el final m si no lo está ya, con estos colores o con los cambiados.
+func ensure(m material) {
+	flipped := make(material, len(m))
+	for i, c := range m {
+		flipped[i] = c ^ black
+	}
+	if len(m) > 2 && tables[m.name()] == nil && tables[flipped.name()] == nil {
+		solve(m, false)
+	}
+}
+
+// solve resuelve el final m, después de los finales a los que se llega capturando o
+// coronando.
+func solve(m material, withDTZ bool) *solved {
+	for i := 2; i < len(m); i++ {
+		ensure(m.without(i, 0))
+		if kindOf(m[i]) == pawn {
+			for _, p := range promotions {
+				ensure(m.without(i, p|m[i]&black))
+			}
+		}
+	}
+	s := &solved{mat: m, n: len(m), wdl: make([]int8, 2<<(6*len(m)))}
+	tables[m.name()] = s
+
+	// WDL: las posiciones con una jugada que deja al rival perdido se ganan y las que solo
+	// tienen jugadas que le dan la victoria se pierden. count guarda las jugadas que faltan
+	// por resolver; lo que queda sin resolver son tablas.
+	count := make([]uint8, len(s.wdl))
+	var queue []int32
+	for idx := range s.wdl {
+		stm, sq := s.decode(idx)
+		if !s.legal(stm, &sq) {
+			s.wdl[idx] = illegal
+			continue
+		}
+		any, won, pending := false, false, 0
+		s.moves(stm, &sq, func(mv move) {
+			any = true
+			switch {
+			case mv.child >= 0:
+				if mv.floor != win {
+					pending++
+				}
+			case mv.value == loss:
+				won = true
+			case mv.value == draw:
+				pending++ // Nunca se resuelve: la posición no puede perderse
+			}
+		})
+		occ, _ := s.occupancy(&sq)
+		switch {
+		case won:
+			s.wdl[idx] = win
+		case !any && !s.attacked(sq[stm], stm^1, -1, &sq, occ):
+			s.wdl[idx] = draw // Ahogado
+			continue
+		case pending == 0:
+			s.wdl[idx] = loss
+		default:
+			s.wdl[idx], count[idx] = unknown, uint8(pending)
+			continue
+		}
+		queue = append(queue, int32(idx))
+	}
+	for head := 0; head < len(queue); head++ {
+		c := int(queue[head])
+		v := s.wdl[c]
+		stm, sq := s.decode(c)
+		s.unmoves(stm, &sq, func(p int32, _ bool, floor int8) {
+			// Los dobles avances que el rival gana capturando al paso no se cuentan
+			if s.wdl[p] != unknown || floor == win {
+				return
+			}
+			switch max(v, floor) {
+			case loss:
+				s.wdl[p] = win
+				queue = append(queue, p)
+			case win:
+				count[p]--
+				if count[p] == 0 {
+					s.wdl[p] = loss
+					queue = append(queue, p)
+				}
+			}
+		})
+	}
+	for idx, v := range s.wdl {
+		if v == unknown {
+			s.wdl[idx] = draw
+		}
+	}
+	if withDTZ {
+		s.solveDTZ(count)
+	}
+	return s
+}
+
+// solveDTZ calcula la DTZ por capas: el ganador busca la jugada que antes reinicia la
+// cuenta (o da mate) y el perdedor la que más la retrasa. Solo hace falta recorrer hacia
+// atrás las jugadas que no reinician la cuenta; count guarda las que faltan por resolver
+// de cada derrota.
+func (s *solved) solveDTZ(count []uint8) {
+	s.dtz = make([]int16, len(s.wdl))
+	var mates, first []int32
+	for idx, v := range s.wdl {
+		s.dtz[idx] = -1
+		if v != win && v != loss {
+			continue
+		}
+		stm, sq := s.decode(idx)
+		any, zeroingWin, pending := false, false, 0
+		s.moves(stm, &sq, func(mv move) {
+			any = true
+			switch {
+			case !mv.zeroing:
+				pending++
+			case mv.child < 0:
+				zeroingWin = zeroingWin || mv.value == loss
+			default:
+				zeroingWin = zeroingWin || max(s.wdl[mv.child], mv.floor) == loss
+			}
+		})
+		switch {
+		case v == loss && !any:
+			s.dtz[idx] = 0
+			mates = append(mates, int32(idx))
+		case v == win && zeroingWin, v == loss && pending == 0:
+			s.dtz[idx] = 1
+			first = append(first, int32(idx))
+		case v == loss:
+			count[idx] = uint8(pending)
+		}
+	}
+	queue := append(mates, first...)
+	for head := 0; head < len(queue); head++ {
+		c := int(queue[head])
+		stm, sq := s.decode(c)
+		d := s.dtz[c] + 1
+		s.unmoves(stm, &sq, func(p int32, zeroing bool, _ int8) {
+			if zeroing || s.dtz[p] >= 0 {
+				return
+			}
+			switch {
+			case s.wdl[c] == loss && s.wdl[p] == win:
+				s.dtz[p] = d
+				queue = append(queue, p)
+			case s.wdl[c] == win && s.wdl[p] == loss:
+				count[p]--
+				if count[p] == 0 {
+					s.dtz[p] = d
+					queue = append(queue, p)
+				}
+			}
+		})
+	}
+	for idx, v := range s.wdl {
+		if (v == win || v == loss) && s.dtz[idx] < 0 {
+			panic("DTZ sin resolver en " + s.mat.name())
+		}
+	}
+}
+
+// Índices del formato Syzygy
+
+var (
+	binomial    [8][64]int
+	mapA1D1D4   [64]int // Triángulo a1-d1-d4: primero bajo la diagonal, después la diagonal
+	triangle    []int   // Casillas del triángulo por orden de índice
+	mapB1H1H7   [64]int // Casillas bajo la diagonal a1-h8
+	mapKK       [10][64]int
+	mapPawns    [64]int
+	leadPawnIdx [6][64]int
+	leadPawnsSz [6][4]int
+)
+
+func init() {
+	for n := 0; n < 64; n++ {
+		binomial[0][n] = 1
+		for k := 1; k < 8 && k <= n; k++ {
+			binomial[k][n] = binomial[k-1][n-1] + binomial[k][n-1]
+		}
+	}
+
+	var onDiagonal []int
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if offDiagonal(sq) < 0 {
+			mapB1H1H7[sq] = code
+			code++
+		}
+		if file(sq) <= 3 && rank(sq) <= 3 && offDiagonal(sq) < 0 {
+			triangle = append(triangle, sq)
+		} else if file(sq) <= 3 && offDiagonal(sq) == 0 {
+			onDiagonal = append(onDiagonal, sq)
+		}
+	}
+	triangle = append(triangle, onDiagonal...)
+	for i, sq := range triangle {
+		mapA1D1D4[sq] = i
+	}
+
+	// Dos reyes con el primero en el triángulo: si está en la diagonal, el segundo no puede
+	// estar por encima de ella, y los pares con ambos en la diagonal van al final
+	var last [][2]int
+	code = 0
+	for t, s1 := range triangle {
+		for s2 := 0; s2 < 64; s2++ {
+			switch {
+			case s1 == s2 || kingAttacks[s1]&bit(s2) != 0:
+				mapKK[t][s2] = -1
+			case offDiagonal(s1) == 0 && offDiagonal(s2) > 0:
+				mapKK[t][s2] = -1
+			case offDiagonal(s1) == 0 && offDiagonal(s2) == 0:
+				last = append(last, [2]int{t, s2})
+			default:
+				mapKK[t][s2] = code
+				code++
+			}
+		}
+	}
+	for _, p := range last {
+		mapKK[p[0]][p[1]] = code
+		code++
+	}
+	if code != 462 {
+		panic("pares de reyes")
+	}
+
+	// Los peones de a2-h7, de mayor a menor valor: primero las columnas de la banda y, en
+	// cada columna, las filas más bajas
+	available := 47
+	for lead := 1; lead < 6; lead++ {
+		for f := 0; f < 4; f++ {
+			idx := 0
+			for r := 1; r <= 6; r++ {
+				sq := r*8 + f
+				if lead == 1 {
+					mapPawns[sq], mapPawns[sq^7] = available, available-1
+					available -= 2
+				}
+				leadPawnIdx[lead][sq] = idx
+				idx += binomial[lead-1][mapPawns[sq]]
+			}
+			leadPawnsSz[lead][f] = idx
+		}
+	}
+}
+
+// layout es el orden de las piezas de una subtabla, como lo guarda la cabecera del fichero,
+// y cómo se agrupan para calcular los índices.
+type layout struct {
+	pieces []byte
+	order  [2]int // Posición del grupo guía y de los peones del otro bando (0xF si no hay)
+	groups []int
+	pawns  bool
+	pp     bool // Peones en ambos bandos
+	unique bool // Sin peones, tres piezas distintas encabezan la tabla; si no, los reyes
+}
+
+func newLayout(pieces []byte, order, order2 int) *layout {
+	l := &layout{pieces: pieces, order: [2]int{order, order2}}
+	var whitePawns, blackPawns int
+	counts := map[byte]int{}
+	for _, c := range pieces {
+		counts[c]++
+		switch c {
+		case pawn:
+			whitePawns++
+		case pawn | black:
+			blackPawns++
+		}
+	}
+	l.pawns = whitePawns+blackPawns > 0
+	l.pp = whitePawns > 0 && blackPawns > 0
+	for c, n := range counts {
+		l.unique = l.unique || n == 1 && kindOf(c) != king
+	}
+	first := 1
+	switch {
+	case l.pawns:
+		for first < len(pieces) && pieces[first] == pieces[0] {
+			first++
+		}
+	case l.unique:
+		first = 3
+	default:
+		first = 2
+	}
+	l.groups = []int{first}
+	for i := first; i < len(pieces); i++ {
+		if i > first && pieces[i] == pieces[i-1] {
+			l.groups[len(l.groups)-1]++
+		} else {
+			l.groups = append(l.groups, 1)
+		}
+	}
+	if (order2 != 0xF) != l.pp {
+		panic("orden de los peones")
+	}
+	return l
+}
+
+// factors devuelve el factor de cada grupo y el tamaño de la subtabla de la columna f.
+func (l *layout) factors(f int) ([]int, int) {
+	factors := make([]int, len(l.groups))
+	free := 64 - l.groups[0]
+	next := 1
+	if l.pp {
+		free -= l.groups[1]
+		next = 2
+	}
+	size := 1
+	for k := 0; next < len(l.groups) || k == l.order[0] || k == l.order[1]; k++ {
+		switch {
+		case k == l.order[0]:
+			factors[0] = size
+			switch {
+			case l.pawns:
+				size *= leadPawnsSz[l.groups[0]][f]
+			case l.unique:
+				size *= 31332
+			default:
+				size *= 462
+			}
+		case k == l.order[1]:
+			factors[1] = size
+			size *= binomial[l.groups[1]][48-l.groups[0]]
+		default:
+			factors[next] = size
+			size *= binomial[l.groups[next]][free]
+			free -= l.groups[next]
+			next++
+		}
+	}
+	return factors, size
+}
+
+func (l *layout) files() int {
+	if l.pawns {
+		return 4
+	}
+	return 1
+}
+
+// index devuelve la columna y el índice de la posición con las piezas en las casillas sq
+// (en el orden de la tabla).
+func (l *layout) index(sq []int) (f, idx int) {
+	sq = append([]int(nil), sq...)
+	transform := func(op func(int) int) {
+		for i := range sq {
+			sq[i] = op(sq[i])
+		}
+	}
+	lead := sq[:l.groups[0]]
+	if l.pawns {
+		// El peón guía es el de mayor valor y los demás van por valor creciente
+		sort.Slice(lead, func(i, j int) bool { return mapPawns[lead[i]] > mapPawns[lead[j]] })
+		if file(sq[0]) > 3 {
+			transform(func(s int) int { return s ^ 7 })
+		}
+		sort.Slice(lead[1:], func(i, j int) bool { return mapPawns[lead[1+i]] < mapPawns[lead[1+j]] })
+		f = file(sq[0])
+		idx = leadPawnIdx[len(lead)][sq[0]]
+		for i := 1; i < len(lead); i++ {
+			idx += binomial[i][mapPawns[lead[i]]]
+		}
+	} else {
+		if file(sq[0]) > 3 {
+			transform(func(s int) int { return s ^ 7 })
+		}
+		if rank(sq[0]) > 3 {
+			transform(func(s int) int { return s ^ 56 })
+		}
+		for _, s := range lead {
+			if offDiagonal(s) > 0 {
+				transform(func(s int) int { return file(s)<<3 | rank(s) })
+			}
+			if offDiagonal(s) != 0 {
+				break
+			}
+		}
+		s0, s1 := sq[0], sq[1]
+		if !l.unique {
+			idx = mapKK[mapA1D1D4[s0]][s1]
+		} else {
+			s2 := sq[2]
+			a1, a2 := b2i(s1 > s0), b2i(s2 > s0)+b2i(s2 > s1)
+			switch {
+			case offDiagonal(s0) != 0:
+				idx = (mapA1D1D4[s0]*63+s1-a1)*62 + s2 - a2
+			case offDiagonal(s1) != 0:
+				idx = (6*63+rank(s0)*28+mapB1H1H7[s1])*62 + s2 - a2
+			case offDiagonal(s2) != 0:
+				idx = 6*63*62 + 4*28*62 + rank(s0)*7*28 + (rank(s1)-a1)*28 + mapB1H1H7[s2]
+			default:
+				idx = 6*63*62 + 4*28*62 + 4*7*28 + rank(s0)*7*6 + (rank(s1)-a1)*6 + rank(s2) - a2
+			}
+		}
+	}
+	if idx < 0 {
+		panic("posición sin índice")
+	}
+
+	// Resto de grupos: combinaciones de las casillas que dejan libres los anteriores
+	factors, _ := l.factors(f)
+	idx *= factors[0]
+	start := l.groups[0]
+	for g := 1; g < len(l.groups); g++ {
+		group := sq[start : start+l.groups[g]]
+		sort.Ints(group)
+		n := 0
+		for i, s := range group {
+			below := 0
+			for _, prev := range sq[:start] {
+				below += b2i(prev < s)
+			}
+			if g == 1 && l.pp {
+				below += 8 // Sin la primera fila
+			}
+			n += binomial[i+1][s-below]
+		}
+		idx += n * factors[g]
+		start += l.groups[g]
+	}
+	return f, idx
+}
+
+// checkTriples comprueba index contra la enumeración de los índices de tres piezas
+// distintas sin peones, que recorre las casillas en el orden del formato.
+func checkTriples() {
+	l := newLayout([]byte{queen, king, king | black}, 0, 0xF)
+	next := 0
+	check := func(s0, s1, s2 int) {
+		if _, idx := l.index([]int{s0, s1, s2}); idx != next {
+			panic(fmt.Sprintf("índice %d para %d, %d, %d; se esperaba %d", idx, s0, s1, s2, next))
+		}
+		next++
+	}
+	var diag8, below []int
+	for sq := 0; sq < 64; sq++ {
+		if offDiagonal(sq) == 0 {
+			diag8 = append(diag8, sq)
+		} else if offDiagonal(sq) < 0 {
+			below = append(below, sq)
+		}
+	}
+	for _, s0 := range triangle[:6] {
+		for s1 := 0; s1 < 64; s1++ {
+			for s2 := 0; s2 < 64; s2++ {
+				if s1 != s0 && s2 != s0 && s2 != s1 {
+					check(s0, s1, s2)
+				}
+			}
+		}
+	}
+	for _, s0 := range triangle[6:] {
+		for _, s1 := range below {
+			for s2 := 0; s2 < 64; s2++ {
+				if s2 != s0 && s2 != s1 {
+					check(s0, s1, s2)
+				}
+			}
+		}
+	}
+	for _, s0 := range triangle[6:] {
+		for _, s1 := range diag8 {
+			for _, s2 := range below {
+				if s1 != s0 {
+					check(s0, s1, s2)
+				}
+			}
+		}
+	}
+	for _, s0 := range triangle[6:] {
+		for _, s1 := range diag8 {
+			for _, s2 := range diag8 {
+				if s1 != s0 && s2 != s0 && s2 != s1 {
+					check(s0, s1, s2)
+				}
+			}
+		}
+	}
+	if next != 31332 {
+		panic("faltan índices de tres piezas")
+	}
+}
+
+// collect recorre las posiciones legales del final con las piezas en el orden de l y el
+// bando stm al mover, y devuelve por columna los valores que da value a cada índice.
+// Todas las posiciones de un mismo índice tienen que dar el mismo valor.
+func collect(l *layout, stm int, value func(sq []int) int) [][]int {
+	out := make([][]int, l.files())
+	for f := range out {
+		_, size := l.factors(f)
+		out[f] = make([]int, size)
+		for i := range out[f] {
+			out[f][i] = dontCare
+		}
+	}
+	n := len(l.pieces)
+	sq := make([]int, n)
+	for p := 0; p < 1<<(6*n); p++ {
+		for i := range sq {
+			sq[i] = p >> (6 * (n - 1 - i)) & 63
+		}
+		if lookupAny(l.pieces, sq, stm) == illegal {
+			continue
+		}
+		v := value(sq)
+		f, idx := l.index(sq)
+		if out[f][idx] != dontCare && out[f][idx] != v {
+			panic(fmt.Sprintf("índice %d de la columna %d con valores %d y %d", idx, f, out[f][idx], v))
+		}
+		out[f][idx] = v
+	}
+	return out
+}
+
+// lookupAny es lookup, pero devuelve illegal en lugar de fallar si la posición no es legal.
+func lookupAny(codes []byte, sq []int, stm int) (v int8) {
+	t := tables[material(codes).name()]
+	if t == nil {
+		panic("final sin resolver: " + material(codes).name())
+	}
+	var placed [4]int
+	used := 0
+	for i, want := range t.mat {
+		placed[i] = -1
+		for j, c := range codes {
+			if c == want && used&(1<<j) == 0 {
+				placed[i] = sq[j]
+				used |= 1 << j
+				break
+			}
+		}
+	}
+	return t.wdl[t.index(stm, &placed)]
+}
+
+// dtzOf devuelve la DTZ de la posición con las piezas codes en las casillas sq.
+func dtzOf(codes []byte, sq []int, stm int) int {
+	t := tables[material(codes).name()]
+	var placed [4]int
+	used := 0
+	for i, want := range t.mat {
+		for j, c := range codes {
+			if c == want && used&(1<<j) == 0 {
+				placed[i] = sq[j]
+				used |= 1 << j
+				break
+			}
+		}
+	}
+	return int(t.dtz[t.index(stm, &placed)])
+}
+
+// Compresión
+
+// dontCare marca los valores que el lector nunca consulta.
+const dontCare = -1
+
+type pairsData struct {
+	flags     byte
+	single    int
+	blockLog  byte
+	spanLog   byte
+	blocks    [][]byte
+	blockLens []uint16
+	sparse    [][2]int // Bloque y desplazamiento
+	minLen    int
+	maxLen    int
+	lowest    []int
+	btree     [][2]int
+}
+
+const (
+	flagSTM         = 1
+	flagMapped      = 2
+	flagWinPlies    = 4
+	flagLossPlies   = 8
+	flagSingleValue = 128
+)
+
+// compress comprime la secuencia de valores (dontCare donde da igual).
+func compress(values []int, flags byte) *pairsData {
+	d := &pairsData{flags: flags, blockLog: 6, spanLog: 9}
+	seq := make([]int, len(values))
+	prev := dontCare
+	for i, v := range values {
+		if v == dontCare {
+			v = prev
+		}
+		seq[i], prev = v, v
+	}
+	first := dontCare
+	for _, v := range seq {
+		if v != dontCare {
+			first = v
+			break
+		}
+	}
+	if first == dontCare {
+		first = 0
+	}
+	distinct := map[int]bool{}
+	for i, v := range seq {
+		if v == dontCare {
+			seq[i] = first
+		}
+		distinct[seq[i]] = true
+	}
+	if len(distinct) == 1 {
+		d.flags |= flagSingleValue
+		d.single = seq[0]
+		return d
+	}
+
+	// Símbolos: primero uno por valor y después parejas de símbolos frecuentes
+	var leaves []int
+	for v := range distinct {
+		leaves = append(leaves, v)
+	}
+	sort.Ints(leaves)
+	type symbol struct{ left, right, count int }
+	var syms []symbol
+	symOf := map[int]int{}
+	for _, v := range leaves {
+		symOf[v] = len(syms)
+		syms = append(syms, symbol{v, 0xFFF, 1})
+	}
+	for i, v := range seq {
+		seq[i] = symOf[v]
+	}
+	const maxSymbols = 1024
+	counts := make([]int32, maxSymbols*maxSymbols)
+	for len(syms) < maxSymbols {
+		clear(counts)
+		best, bestCount := [2]int{}, int32(0)
+		for i := 0; i+1 < len(seq); i++ {
+			a, b := seq[i], seq[i+1]
+			if syms[a].count+syms[b].count > 256 {
+				continue
+			}
+			c := &counts[a*maxSymbols+b]
+			*c++
+			if *c > bestCount {
+				best, bestCount = [2]int{a, b}, *c
+			}
+		}
+		if bestCount < 8 {
+			break
+		}
+		id := len(syms)
+		syms = append(syms, symbol{best[0], best[1], syms[best[0]].count + syms[best[1]].count})
+		out := seq[:0]
+		for i := 0; i < len(seq); i++ {
+			if i+1 < len(seq) && seq[i] == best[0] && seq[i+1] == best[1] {
+				out = append(out, id)
+				i++
+			} else {
+				out = append(out, seq[i])
+			}
+		}
+		seq = out
+	}
+
+	// Longitudes de Huffman, limitadas para que el lector nunca necesite más de 32 bits
+	freq := make([]int, len(syms))
+	for _, s := range seq {
+		freq[s]++
+	}
+	lengths := huffmanLengths(freq)
+
+	// Numeración canónica: los códigos más largos llevan los símbolos más bajos
+	order := make([]int, 0, len(syms))
+	for s := range syms {
+		order = append(order, s)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if (lengths[a] == 0) != (lengths[b] == 0) {
+			return lengths[a] != 0
+		}
+		return lengths[a] > lengths[b]
+	})
+	newID := make([]int, len(syms))
+	for n, s := range order {
+		newID[s] = n
+	}
+	d.btree = make([][2]int, len(syms))
+	for s, sym := range syms {
+		if sym.right == 0xFFF {
+			d.btree[newID[s]] = [2]int{sym.left, 0xFFF}
+		} else {
+			d.btree[newID[s]] = [2]int{newID[sym.left], newID[sym.right]}
+		}
+	}
+	d.minLen, d.maxLen = 64, 0
+	count := map[int]int{}
+	for _, l := range lengths {
+		if l > 0 {
+			d.minLen, d.maxLen = min(d.minLen, l), max(d.maxLen, l)
+			count[l]++
+		}
+	}
+	base := make([]uint64, d.maxLen+2)
+	d.lowest = make([]int, d.maxLen-d.minLen+1)
+	longer := 0
+	for l := d.maxLen; l >= d.minLen; l-- {
+		if l < d.maxLen {
+			base[l] = (base[l+1] + uint64(count[l+1])) / 2
+		}
+		d.lowest[l-d.minLen] = longer
+		longer += count[l]
+	}
+	if base[d.minLen]+uint64(count[d.minLen]) != 1<<d.minLen {
+		panic("código de Huffman incompleto")
+	}
+	code := func(s int) (uint64, int) {
+		l := lengths[order[s]]
+		return base[l] + uint64(s-d.lowest[l-d.minLen]), l
+	}
+
+	// Bloques: cada uno con símbolos enteros y como mucho 65536 valores
+	blockBits := 8 << d.blockLog
+	var block []byte
+	bits, valuesInBlock, start := 0, 0, 0
+	var starts []int
+	flush := func() {
+		d.blocks = append(d.blocks, append(block, make([]byte, blockBits/8-len(block))...))
+		d.blockLens = append(d.blockLens, uint16(valuesInBlock-1))
+		starts = append(starts, start)
+		start += valuesInBlock
+		block, bits, valuesInBlock = nil, 0, 0
+	}
+	for _, s := range seq {
+		c, l := code(newID[s])
+		n := syms[s].count
+		if bits+l > blockBits || valuesInBlock+n > 32768 {
+			flush()
+		}
+		for i := l - 1; i >= 0; i-- {
+			if bits%8 == 0 {
+				block = append(block, 0)
+			}
+			if c>>i&1 != 0 {
+				block[bits/8] |= 0x80 >> (bits % 8)
+			}
+			bits++
+		}
+		valuesInBlock += n
+	}
+	flush()
+
+	// Índice disperso: el bloque y el desplazamiento del valor central de cada tramo
+	span := 1 << d.spanLog
+	for k := 0; k*span < len(values); k++ {
+		mid := k*span + span/2
+		b := sort.SearchInts(starts, mid+1) - 1
+		if mid-starts[b] > 0xFFFF {
+			panic("desplazamiento fuera de rango")
+		}
+		d.sparse = append(d.sparse, [2]int{b, mid - starts[b]})
+	}
+	return d
+}
+
+// huffmanLengths calcula las longitudes de un código de Huffman (0 para los símbolos que
+// no aparecen) de como mucho 32 bits.
+func huffmanLengths(freq []int) []int {
+	for {
+		type node struct{ weight, id int }
+		var nodes []node
+		parent := map[int]int{}
+		next := len(freq)
+		for s, f := range freq {
+			if f > 0 {
+				nodes = append(nodes, node{f, s})
+			}
+		}
+		for len(nodes) < 2 {
+			// Con un solo símbolo hace falta otro para tener un código completo
+			for s := range freq {
+				if freq[s] == 0 {
+					freq[s] = 1
+					nodes = append(nodes, node{1, s})
+					break
+				}
+			}
+		}
+		for len(nodes) > 1 {
+			sort.Slice(nodes, func(i, j int) bool {
+				if nodes[i].weight != nodes[j].weight {
+					return nodes[i].weight < nodes[j].weight
+				}
+				return nodes[i].id < nodes[j].id
+			})
+			a, b := nodes[0], nodes[1]
+			parent[a.id], parent[b.id] = next, next
+			nodes = append(nodes[2:], node{a.weight + b.weight, next})
+			next++
+		}
+		lengths := make([]int, len(freq))
+		longest := 0
+		for s, f := range freq {
+			if f == 0 {
+				continue
+			}
+			for n := s; n != nodes[0].id; n = parent[n] {
+				lengths[s]++
+			}
+			longest = max(longest, lengths[s])
+		}
+		if longest <= 32 {
+			return lengths
+		}
+		for s := range freq {
+			if freq[s] > 0 {
+				freq[s] = (freq[s] + 1) / 2
+			}
+		}
+	}
+}
+
+// Ficheros
+
+type table struct {
+	name    string
+	dtz     bool
+	layouts []*layout // Por bando al mover (WDL) o del único bando (DTZ)
+	subs    [][]*pairsData
+	dtzMaps [][4][]byte
+}
+
+func (t *table) bytes() []byte {
+	var out []byte
+	if t.dtz {
+		out = append(out, 0xD7, 0x66, 0x0C, 0xA5)
+	} else {
+		out = append(out, 0x71, 0xE8, 0x23, 0x5D)
+	}
+	lay := t.layouts
+	if len(lay) == 1 {
+		lay = append(lay, &layout{pieces: make([]byte, len(lay[0].pieces)), order: [2]int{0, 0}})
+	}
+	var flags byte
+	if len(t.layouts) == 2 {
+		flags |= 1
+	}
+	if lay[0].pawns {
+		flags |= 2
+	}
+	out = append(out, flags)
+	for range t.subs {
+		out = append(out, byte(lay[0].order[0]|lay[1].order[0]<<4))
+		if lay[0].pp {
+			out = append(out, byte(lay[0].order[1]|lay[1].order[1]<<4))
+		}
+		for k := range lay[0].pieces {
+			out = append(out, lay[0].pieces[k]|lay[1].pieces[k]<<4)
+		}
+	}
+	if len(out)%2 != 0 {
+		out = append(out, 0)
+	}
+	for _, file := range t.subs {
+		for _, d := range file {
+			out = append(out, d.flags)
+			if d.flags&flagSingleValue != 0 {
+				out = append(out, byte(d.single))
+				continue
+			}
+			out = append(out, d.blockLog, d.spanLog, 0)
+			out = binary.LittleEndian.AppendUint32(out, uint32(len(d.blocks)))
+			out = append(out, byte(d.maxLen), byte(d.minLen))
+			for _, l := range d.lowest {
+				out = binary.LittleEndian.AppendUint16(out, uint16(l))
+			}
+			out = binary.LittleEndian.AppendUint16(out, uint16(len(d.btree)))
+			for _, lr := range d.btree {
+				out = append(out, byte(lr[0]), byte(lr[0]>>8&0xF|lr[1]<<4), byte(lr[1]>>4))
+			}
+			if len(d.btree)%2 != 0 {
+				out = append(out, 0)
+			}
+		}
+	}
+	if t.dtz {
+		for f, file := range t.subs {
+			if file[0].flags&flagMapped == 0 {
+				continue
+			}
+			for _, m := range t.dtzMaps[f] {
+				out = append(out, byte(len(m)))
+				out = append(out, m...)
+			}
+		}
+		if len(out)%2 != 0 {
+			out = append(out, 0)
+		}
+	}
+	for _, file := range t.subs {
+		for _, d := range file {
+			for _, e := range d.sparse {
+				out = binary.LittleEndian.AppendUint32(out, uint32(e[0]))
+				out = binary.LittleEndian.AppendUint16(out, uint16(e[1]))
+			}
+		}
+	}
+	for _, file := range t.subs {
+		for _, d := range file {
+			for _, l := range d.blockLens {
+				out = binary.LittleEndian.AppendUint16(out, l)
+			}
+		}
+	}
+	for _, file := range t.subs {
+		for _, d := range file {
+			for len(out)%64 != 0 {
+				out = append(out, 0)
+			}
+			for _, b := range d.blocks {
+				out = append(out, b...)
+			}
+		}
+	}
+	return out
+}
+
+func write(t *table) {
+	suffix := ".rtbw"
+	if t.dtz {
+		suffix = ".rtbz"
+	}
+	if err := os.WriteFile(t.name+suffix, t.bytes(), 0o644); err != nil {
+		panic(err)
+	}
+}
+
+// writeWDL escribe la tabla WDL del final con las piezas de cada bando al mover en el orden
+// de layouts (una sola si el material es simétrico y solo se guardan las blancas al mover).
+func writeWDL(name string, layouts ...*layout) {
+	t := &table{name: name, layouts: layouts}
+	sides := make([][][]int, len(layouts))
+	for stm, l := range layouts {
+		sides[stm] = collect(l, stm, func(sq []int) int {
+			return 2*int(lookupAny(l.pieces, sq, stm)) + 2 // 0 derrota, 2 tablas, 4 victoria
+		})
+	}
+	for f := range sides[0] {
+		var subs []*pairsData
+		for stm := range layouts {
+			subs = append(subs, compress(sides[stm][f], 0))
+		}
+		t.subs = append(t.subs, subs)
+	}
+	write(t)
+}
+
+// dtzEncoding describe cómo se guardan las DTZ de un final: del bando stm, con un mapa de
+// valores por columna o no, y en medias jugadas o en jugadas ((dtz-1)/2, que solo es exacto
+// si todas las DTZ son impares).
+type dtzEncoding struct {
+	stm    int
+	mapped bool
+	plies  bool
+}
+
+// writeDTZ escribe la tabla DTZ del final con las piezas en el orden de l. Los mates se
+// guardan como derrotas con DTZ 1, que es lo que espera la búsqueda a una jugada del lector
+// para reconocer el mate del rival.
+func writeDTZ(name string, l *layout, enc dtzEncoding) {
+	t := &table{name: name, dtz: true, layouts: []*layout{l}}
+	files := collect(l, enc.stm, func(sq []int) int {
+		wdl, dtz := lookupAny(l.pieces, sq, enc.stm), dtzOf(l.pieces, sq, enc.stm)
+		if wdl == draw {
+			return dontCare
+		}
+		dtz = max(dtz, 1)
+		v := dtz - 1
+		if !enc.plies {
+			if v%2 != 0 {
+				panic("DTZ par en " + name)
+			}
+			v /= 2
+		}
+		// Victorias y derrotas usan mapas distintos: se separan hasta numerarlos
+		if wdl == loss {
+			return -2 - v
+		}
+		return v
+	})
+	var flags byte
+	if enc.stm == 1 {
+		flags |= flagSTM
+	}
+	if enc.plies {
+		flags |= flagWinPlies | flagLossPlies
+	}
+	for _, raw := range files {
+		var maps [4][]byte
+		fileFlags := flags
+		if enc.mapped {
+			fileFlags |= flagMapped
+			// Los mapas de victorias (0) y derrotas (1) listan los valores de más a menos
+			// frecuente
+			freq := map[int]int{}
+			for _, v := range raw {
+				if v != dontCare {
+					freq[v]++
+				}
+			}
+			var vals []int
+			for v := range freq {
+				vals = append(vals, v)
+			}
+			sort.Slice(vals, func(i, j int) bool {
+				if freq[vals[i]] != freq[vals[j]] {
+					return freq[vals[i]] > freq[vals[j]]
+				}
+				return vals[i] < vals[j]
+			})
+			slot := map[int]int{}
+			for _, v := range vals {
+				m := 0
+				if v < 0 {
+					m = 1
+				}
+				slot[v] = len(maps[m])
+				maps[m] = append(maps[m], byte(max(v, -2-v)))
+			}
+			for i, v := range raw {
+				if v != dontCare {
+					raw[i] = slot[v]
+				}
+			}
+		} else {
+			for i, v := range raw {
+				if v < dontCare {
+					raw[i] = -2 - v
+				}
+			}
+		}
+		t.subs = append(t.subs, []*pairsData{compress(raw, fileFlags)})
+		t.dtzMaps = append(t.dtzMaps, maps)
+	}
+	write(t)
+}
+
+// report muestra la DTZ máxima de cada bando al mover.
+func report(s *solved) {
+	var longest [2]int16
+	for idx, v := range s.wdl {
+		if v == win || v == loss {
+			stm := idx >> (6 * s.n)
+			longest[stm] = max(longest[stm], s.dtz[idx])
+		}
+	}
+	fmt.Printf("%s: DTZ máxima %d con las blancas al mover, %d con las negras\n", s.mat.name(), longest[0], longest[1])
+	if longest[0] > 100 || longest[1] > 100 {
+		panic("las tablas no contemplan victorias malditas")
+	}
+}
+
+func main() {
+	checkTriples()
+	const (
+		K = king
+		k = king | black
+		P = pawn
+		p = pawn | black
+		Q = queen
+		q = queen | black
+		R = rook
+		r = rook | black
+		B = bishop
+		N = knight
+	)
+	none := 0xF
+
+	// Tres piezas: la pieza y los dos reyes; las negras nunca ganan y se guardan las DTZ
+	// con las blancas al mover
+	for _, e := range []struct {
+		m   material
+		enc dtzEncoding
+	}{
+		{material{K, k, Q}, dtzEncoding{plies: true}},
+		{material{K, k, R}, dtzEncoding{mapped: true}},
+		{material{K, k, B}, dtzEncoding{}},
+		{material{K, k, N}, dtzEncoding{}},
+		{material{K, k, P}, dtzEncoding{mapped: true, plies: true}},
+	} {
+		s := solve(e.m, true)
+		report(s)
+		l := newLayout([]byte{e.m[2], K, k}, 0, none)
+		if e.m[2] == P {
+			l = newLayout([]byte{P, K, k}, 0, none)
+		}
+		writeWDL(s.mat.name(), l, l)
+		writeDTZ(s.mat.name(), l, e.enc)
+	}
+
+	// KBBvK: sin piezas únicas, los reyes encabezan la tabla y los alfiles forman un grupo
+	s := solve(material{K, k, B, B}, true)
+	report(s)
+	writeWDL("KBBvK", newLayout([]byte{K, k, B, B}, 0, none), newLayout([]byte{k, K, B, B}, 1, none))
+	writeDTZ("KBBvK", newLayout([]byte{K, k, B, B}, 1, none), dtzEncoding{})
+
+	// KQvKR: cuatro piezas únicas en dos grupos; las DTZ se guardan con las negras al mover,
+	// con victorias y derrotas
+	s = solve(material{K, k, Q, r}, true)
+	report(s)
+	writeWDL("KQvKR", newLayout([]byte{Q, K, k, r}, 0, none), newLayout([]byte{K, r, k, Q}, 1, none))
+	writeDTZ("KQvKR", newLayout([]byte{r, k, Q, K}, 1, none), dtzEncoding{stm: 1, mapped: true, plies: true})
+
+	// KPvKP: peones en ambos bandos (el segundo grupo sin la primera fila) y en las cuatro
+	// columnas. Coronar lleva a KQvKP, KRvKP, KBvKP y KNvKP, que se resuelven antes
+	s = solve(material{K, k, P, p}, true)
+	report(s)
+	for _, x := range []byte{Q, R, B, N} {
+		m := material{K, k, x, p}
+		l := newLayout([]byte{p, K, k, x}, 0, none)
+		writeWDL(m.name(), l, l)
+	}
+	writeWDL("KPvKP", newLayout([]byte{P, p, K, k}, 0, 1))
+	writeDTZ("KPvKP", newLayout([]byte{P, p, k, K}, 2, 0), dtzEncoding{mapped: true, plies: true})
+}
@@ -0,0 +1,96 @@
+package melange
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Tipo de cota de la puntuación guardada en la tabla de transposición
+const (
+	boundNone  uint8 = iota
+	boundUpper       // La puntuación real es como mucho score (ningún movimiento superó alfa)
+	boundLower       // La puntuación real es al menos score (corte beta)
+	boundExact
+)
+
+// DefaultHashMB es el tamaño por defecto de la tabla de transposición en megabytes.
+const DefaultHashMB = 16
+
+type ttEntry struct {
+	key   uint64
+	move  Move
+	score int32
+	depth int8
+	bound uint8
+}
+
+// ttSlot guarda una entrada en dos palabras de 64 bits: los datos empaquetados y la clave
+// XOR los datos. Varios hilos pueden leer y escribir sin cerrojos: si una lectura mezcla
+// las dos palabras de escrituras distintas, la clave no cuadra y se trata como un fallo.
+type ttSlot struct {
+	check atomic.Uint64
+	data  atomic.Uint64
+}
+
+// TranspositionTable guarda, por clave Zobrist, el resultado de posiciones ya buscadas:
+// el mejor movimiento encontrado, la puntuación con su tipo de cota y la profundidad. Cada
+// clave tiene una única entrada posible, que se reemplaza siempre. Se puede compartir entre
+// los hilos de una búsqueda.
+type TranspositionTable struct {
+	slots []ttSlot
+	mask  uint64
+}
+
+// NewTranspositionTable crea una tabla de como mucho sizeMB megabytes (al menos una entrada).
+func NewTranspositionTable(sizeMB int) *TranspositionTable {
+	slotSize := uint64(unsafe.Sizeof(ttSlot{}))
+	n := uint64(1)
+	for n*2*slotSize <= uint64(sizeMB)<<20 {
+		n *= 2
+	}
+	return &TranspositionTable{slots: make([]ttSlot, n), mask: n - 1}
+}
+
+// Clear borra todas las entradas. No debe llamarse durante una búsqueda.
+func (tt *TranspositionTable) Clear() {
+	clear(tt.slots)
+}
+
+func (tt *TranspositionTable) probe(key uint64) (ttEntry, bool) {
+	slot := &tt.slots[key&tt.mask]
+	data := slot.data.Load()
+	if slot.check.Load()^data != key {
+		return ttEntry{}, false
+	}
+	e := unpackTTEntry(key, data)
+	return e, e.bound != boundNone
+}
+
+func (tt *TranspositionTable) store(key uint64, move Move, score, depth int, bound uint8) {
+	slot := &tt.slots[key&tt.mask]
+	// Sin movimiento nuevo se conserva el que hubiera para la misma posición
+	if move == NoMove {
+		if old := slot.data.Load(); slot.check.Load()^old == key {
+			move = unpackTTEntry(key, old).move
+		}
+	}
+	data := packTTEntry(ttEntry{move: move, score: int32(score), depth: int8(depth), bound: bound})
+	slot.data.Store(data)
+	slot.check.Store(key ^ data)
+}
+
+// packTTEntry empaqueta el movimiento (16 bits), la puntuación (16), la profundidad (8) y
+// la cota (2) en una palabra.
+func packTTEntry(e ttEntry) uint64 {
+	return uint64(e.move) | uint64(uint16(e.score))<<16 | uint64(uint8(e.depth))<<32 | uint64(e.bound)<<40
+}
+
+func unpackTTEntry(key, data uint64) ttEntry {
+	return ttEntry{
+		key:   key,
+		move:  Move(data),
+		score: int32(int16(data >> 16)),
+		depth: int8(data >> 32),
+		bound: uint8(data >> 40 & 3),
+	}
+}
@@ -0,0 +1,69 @@
+package melange
+
+import (
+	"testing"
+	"unsafe"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTranspositionTable(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	assert.Assert(t, uint64(len(tt.slots))*uint64(unsafe.Sizeof(ttSlot{})) <= 1<<20)
+	assert.Equal(t, len(tt.slots)&(len(tt.slots)-1), 0)
+
+	key := uint64(0x1234_5678_9abc_def0)
+	_, ok := tt.probe(key)
+	assert.Assert(t, !ok)
+
+	m := newMove(12, 28, MoveNormal)
+	tt.store(key, m, -35, 6, boundLower)
+	e, ok := tt.probe(key)
+	assert.Assert(t, ok)
+	assert.Equal(t, e.move, m)
+	assert.Equal(t, int(e.score), -35)
+	assert.Equal(t, int(e.depth), 6)
+	assert.Equal(t, e.bound, boundLower)
+
+	// Sin movimiento se conserva el anterior de la misma posición
+	tt.store(key, NoMove, 10, 7, boundUpper)
+	e, _ = tt.probe(key)
+	assert.Equal(t, e.move, m)
+	assert.Equal(t, e.bound, boundUpper)
+
+	// Otra clave en la misma entrada la reemplaza
+	other := key + uint64(len(tt.slots))
+	tt.store(other, NoMove, 0, 1, boundExact)
+	_, ok = tt.probe(key)
+	assert.Assert(t, !ok)
+	e, ok = tt.probe(other)
+	assert.Assert(t, ok && e.move == NoMove)
+
+	tt.Clear()
+	_, ok = tt.probe(other)
+	assert.Assert(t, !ok)
+}
+
+func TestTTEntryPacking(t *testing.T) {
+	b := &Board{}
+	assert.NilError(t, b.SetFen("r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1"))
+	moves := b.GetLegalMoves()
+	b.WhiteToMove = false
+	moves = append(moves, b.GetLegalMoves()...)
+	for _, score := range []int{0, -1, 1234, -mateScore, infinityScore} {
+		for _, m := range moves {
+			e := ttEntry{key: 42, move: m, score: int32(score), depth: -3, bound: boundExact}
+			assert.Equal(t, unpackTTEntry(42, packTTEntry(e)), e)
+		}
+	}
+}
+
+func TestTranspositionTableTornEntry(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	tt.store(7, newMove(1, 18, MoveNormal), 5, 3, boundExact)
+	// Unos datos de otra escritura con la clave de esta no validan
+	slot := &tt.slots[7&tt.mask]
+	slot.data.Store(packTTEntry(ttEntry{score: 99, depth: 9, bound: boundLower}))
+	_, ok := tt.probe(7)
+	assert.Assert(t, !ok)
+}
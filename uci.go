@@ -4,11 +4,74 @@ package melange
 
 import (
 	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+	"strconv"
+	"time"
 )
 
 // currentBoard holds the persistent board state across UCI commands
 var currentBoard *Board
 
+// searchStop and searchDone belong to the search started by 'go', which runs in the
+// background so that 'stop' can reach it: closing searchStop interrupts it and searchDone
+// is closed once it has printed its bestmove. Both are nil when no search is running.
+var searchStop, searchDone chan struct{}
+var searchInfinite bool
+
+// gameHistory holds the Zobrist keys of the positions before currentBoard, oldest first,
+// so that the search can detect repetitions of the moves given in 'position'
+var gameHistory []uint64
+
+// evalParams holds the evaluation weights used by the search, selected with the EvalFile option
+var evalParams = DefaultEvalParams()
+
+// searchParams holds the selective search settings, selected with the SearchFile option
+var searchParams = DefaultSearchParams()
+
+// nnueNetwork is the network loaded with the NNUEFile option; it is only used when UseNNUE is set
+var nnueNetwork *Network
+var useNNUE = false
+
+// tablebase holds the Syzygy tables found in SyzygyPath (nil if none)
+var tablebase *Tablebase
+var syzygyProbeDepth = 1
+
+// contempt is the Contempt option: how much worse than 0 the engine scores a draw by
+// repetition, in centipawns
+var contempt = 0
+
+// chess960 selects Fischer Random castling (UCI_Chess960): castling rooks on any file and
+// king-takes-rook move notation
+var chess960 = false
+
+// variant is the chess variant being played, selected with UCI_Variant
+var variant = Standard
+
+// threads is the number of search threads, selected with the Threads option
+var threads = 1
+
+// hashTable is the transposition table shared by every 'go', resized with the Hash option
+var hashTable = NewTranspositionTable(DefaultHashMB)
+var hashMB = DefaultHashMB
+
+// activeEvaluator returns the evaluator selected through the UCI options
+func activeEvaluator() Evaluator {
+	if useNNUE && nnueNetwork != nil {
+		return nnueNetwork
+	}
+	return evalParams
+}
+
+// newUCIBoard returns the starting position of the selected variant
+func newUCIBoard() *Board {
+	b := NewVariantBoard(variant)
+	b.Chess960 = chess960
+	return b
+}
+
 // GetCurrentBoard returns the board managed by the UCI interface (for tests/inspection)
 func GetCurrentBoard() *Board {
 	if currentBoard == nil {
@@ -27,14 +90,23 @@ func ProcessUciCommand(command string) bool {
 	}
 	tokens := tokenize(trimmed)
 	if len(tokens) > 0 {
+		// Only 'isready' may be answered while searching; anything else that uses the
+		// engine state ends the search first, as 'stop' does
+		if tokens[0] != "isready" {
+			stopSearch()
+		}
 		switch tokens[0] {
 		case "go":
 			handleGo(tokens)
+		case "stop":
+			// The search has already been stopped and has printed its bestmove
 		case "isready":
 			// Initializations done here
 			fmt.Println("readyok")
 		case "position":
 			handlePosition(tokens)
+		case "setoption":
+			handleSetOption(tokens)
 		case "quit":
 			fmt.Println("Exiting...")
 			return true
@@ -42,10 +114,29 @@ func ProcessUciCommand(command string) bool {
 			// Use fmt.Println for proper newline handling
 			fmt.Println("id name Melange v0.1")
 			fmt.Println("id author Jose R. Cabanes")
+			fmt.Println("option name EvalFile type string default <empty>")
+			fmt.Println("option name SearchFile type string default <empty>")
+			fmt.Println("option name UseNNUE type check default false")
+			fmt.Println("option name NNUEFile type string default <empty>")
+			fmt.Printf("option name Hash type spin default %d min 1 max 4096\n", DefaultHashMB)
+			fmt.Println("option name Threads type spin default 1 min 1 max 256")
+			fmt.Println("option name SyzygyPath type string default <empty>")
+			fmt.Println("option name SyzygyProbeDepth type spin default 1 min 1 max 100")
+			fmt.Println("option name Contempt type spin default 0 min -100 max 100")
+			fmt.Println("option name UCI_Chess960 type check default false")
+			fmt.Println("option name UCI_Variant type combo default chess var chess var kingofthehill var 3check var antichess var horde var crazyhouse")
 			fmt.Println("uciok")
+		case "perft", "divide":
+			handlePerft(os.Stdout, tokens)
+		case "bench":
+			handleBench(os.Stdout, tokens)
+		case "d":
+			printBoard(os.Stdout, GetCurrentBoard())
 		case "ucinewgame":
 			// Reset engine state for a new game
-			currentBoard = NewBoard()
+			currentBoard = newUCIBoard()
+			gameHistory = nil
+			hashTable.Clear()
 		default:
 			fmt.Println("Unknown command:", command)
 		}
@@ -90,7 +181,7 @@ func handlePosition(tokens []string) {
 	idx := 1
 	// Setup position base
 	if tokens[idx] == "startpos" {
-		currentBoard = NewBoard()
+		currentBoard = newUCIBoard()
 		idx++
 	} else if tokens[idx] == "fen" {
 		idx++
@@ -100,7 +191,7 @@ func handlePosition(tokens []string) {
 			idx++
 		}
 		fen := joinWithSpaces(tokens[fenStart:idx])
-		b := &Board{}
+		b := &Board{Chess960: chess960, Variant: variant}
 		if err := b.SetFen(fen); err != nil {
 			// On invalid FEN, keep previous board but report
 			fmt.Println("info string invalid FEN:", err)
@@ -111,6 +202,7 @@ func handlePosition(tokens []string) {
 		// Unknown base, ignore
 		return
 	}
+	gameHistory = gameHistory[:0]
 
 	// Apply moves if present
 	if idx < len(tokens) && tokens[idx] == "moves" {
@@ -123,38 +215,358 @@ func handlePosition(tokens []string) {
 				fmt.Println("info string invalid move:", mvStr)
 				return
 			}
-			// Apply move
-			fromBB := mv.GetFrom64()
-			_, isWhite := currentBoard.PieceAtSquare(fromBB)
-			currentBoard.MovePiece(mv, isWhite)
-			// Update clocks (best-effort): halfmove resets on pawn move or capture; fullmove after Black's move
-			if mv.Piece == Pawn || mv.IsCapture() {
-				currentBoard.HalfMove = 0
-			} else {
-				currentBoard.HalfMove++
-			}
-			if currentBoard.WhiteToMove { // after toggled in MovePiece; if now White to move, Black just moved
-				currentBoard.FullMove++
-			}
+			gameHistory = append(gameHistory, currentBoard.Hash())
+			// Apply move (MovePiece also updates the clocks)
+			currentBoard.MovePiece(mv, currentBoard.WhiteToMove)
 			idx++
 		}
 	}
 }
 
+// handleGo starts the search of the current position in the background. The search works
+// on its own copy of the board and history, so later commands cannot disturb it.
 func handleGo(tokens []string) {
-	// Handle the 'go' command
-	if len(tokens) < 2 {
-		fmt.Println("info string invalid go command", tokens)
-		return
-	}
 	if currentBoard == nil {
 		currentBoard = NewBoard()
 	}
-	// Start the search for the best move
-	root := currentBoard.GenerateSearchTree(5)
-	move := root.GetBestMove()
-	// fmt.Println(currentBoard.ToString())
-	fmt.Println("bestmove", move.ToSimpleString())
+	limits := parseGoLimits(tokens, currentBoard.WhiteToMove)
+	// 'go infinite' is the only way to get no limit at all
+	infinite := limits.Depth == 0 && limits.Nodes == 0 && limits.MoveTime == 0
+	stop, done := make(chan struct{}), make(chan struct{})
+	searchStop, searchDone, searchInfinite = stop, done, infinite
+	limits.Stop = stop
+
+	board := currentBoard.Clone()
+	start := time.Now()
+	searcher := newEngineSearcher()
+	searcher.GameHistory = append([]uint64(nil), gameHistory...)
+	searcher.OnIteration = func(r SearchResult) {
+		fmt.Println(formatInfo(board, r, time.Since(start)))
+	}
+	go func() {
+		defer close(done)
+		result := searcher.Search(board, limits)
+		// In infinite mode bestmove must wait for 'stop' even if the search ends earlier
+		if infinite {
+			<-stop
+		}
+		fmt.Println("bestmove", board.UCIMove(result.BestMove))
+	}()
+}
+
+// stopSearch interrupts the search started by 'go', if any, and waits until it has
+// printed its bestmove.
+func stopSearch() {
+	if searchDone == nil {
+		return
+	}
+	select {
+	case <-searchStop:
+	default:
+		close(searchStop)
+	}
+	waitSearch()
+}
+
+// FinishUciSearch lets the search started by 'go' end and print its bestmove before the
+// program exits at the end of the input. An infinite search is stopped.
+func FinishUciSearch() {
+	if searchInfinite {
+		stopSearch()
+	}
+	waitSearch()
+}
+
+// waitSearch waits for the search started by 'go', if any, to finish on its own.
+func waitSearch() {
+	if searchDone == nil {
+		return
+	}
+	<-searchDone
+	searchStop, searchDone = nil, nil
+}
+
+// handlePerft implements the non-standard commands 'perft N' and 'divide N' on the current
+// board, using the Threads and Hash options. 'divide' also prints the nodes below each root
+// move, sorted like the output of other engines so that both lists can be compared.
+func handlePerft(w io.Writer, tokens []string) {
+	depth := 0
+	if len(tokens) > 1 {
+		depth, _ = strconv.Atoi(tokens[1])
+	}
+	if depth < 1 {
+		fmt.Fprintf(w, "info string usage: %s <depth>\n", tokens[0])
+		return
+	}
+	start := time.Now()
+	b := GetCurrentBoard()
+	moves := b.PerftDivide(depth, PerftOptions{Threads: threads, HashMB: hashMB})
+	elapsed := time.Since(start)
+	if tokens[0] == "divide" {
+		sort.Slice(moves, func(i, j int) bool { return b.UCIMove(moves[i].Move) < b.UCIMove(moves[j].Move) })
+		for _, m := range moves {
+			fmt.Fprintf(w, "%s: %d\n", b.UCIMove(m.Move), m.Result.Nodes)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Moves:", len(moves))
+	}
+	nodes := PerftTotal(moves).Nodes
+	fmt.Fprintln(w, "Nodes:", nodes)
+	fmt.Fprintf(w, "Time: %d ms (%d nps)\n", elapsed.Milliseconds(), int64(nodes)*1000/max(elapsed.Milliseconds(), 1))
+}
+
+// handleBench implements the non-standard command 'bench [depth]'. It uses the selected
+// evaluator but ignores the current board and the search options, so that the node count
+// only depends on the code.
+func handleBench(w io.Writer, tokens []string) {
+	depth := DefaultBenchDepth
+	if len(tokens) > 1 {
+		d, err := strconv.Atoi(tokens[1])
+		if err != nil || d < 1 {
+			fmt.Fprintln(w, "info string invalid bench depth:", tokens[1])
+			return
+		}
+		depth = d
+	}
+	Bench(depth, activeEvaluator(), w)
+}
+
+// printBoard implements the non-standard command 'd': the board, its FEN and its Zobrist key.
+func printBoard(w io.Writer, b *Board) {
+	fmt.Fprint(w, b.ToString())
+	fmt.Fprintln(w, "Fen:", b.Fen())
+	fmt.Fprintf(w, "Key: %016X\n", b.Hash())
+}
+
+// newEngineSearcher returns a searcher configured with the engine options. It is shared by
+// the UCI and XBoard front-ends.
+func newEngineSearcher() *Searcher {
+	searcher := NewSearcher(activeEvaluator())
+	searcher.TT = hashTable
+	searcher.Params = searchParams
+	searcher.Threads = threads
+	searcher.Tablebase = tablebase
+	searcher.TBProbeDepth = syzygyProbeDepth
+	searcher.Contempt = contempt
+	return searcher
+}
+
+// defaultGoDepth is the depth searched when 'go' comes without any limit
+const defaultGoDepth = 5
+
+// parseGoLimits converts the arguments of 'go' into search limits. With a clock the
+// time for this move is given by timeBudget. 'infinite' leaves the search without limits,
+// to be ended with 'stop'.
+func parseGoLimits(tokens []string, whiteToMove bool) SearchLimits {
+	limits := SearchLimits{}
+	var wtime, btime, winc, binc, movestogo int64
+	infinite := false
+	for i := 1; i < len(tokens); i++ {
+		if tokens[i] == "infinite" {
+			infinite = true
+			continue
+		}
+		value := int64(0)
+		if i+1 < len(tokens) {
+			value, _ = strconv.ParseInt(tokens[i+1], 10, 64)
+		}
+		switch tokens[i] {
+		case "depth":
+			limits.Depth = int(value)
+		case "nodes":
+			limits.Nodes = value
+		case "movetime":
+			limits.MoveTime = time.Duration(value) * time.Millisecond
+		case "wtime":
+			wtime = value
+		case "btime":
+			btime = value
+		case "winc":
+			winc = value
+		case "binc":
+			binc = value
+		case "movestogo":
+			movestogo = value
+		default:
+			continue
+		}
+		i++
+	}
+	remaining, inc := wtime, winc
+	if !whiteToMove {
+		remaining, inc = btime, binc
+	}
+	if limits.MoveTime == 0 && remaining > 0 {
+		limits.MoveTime = timeBudget(remaining, inc, movestogo)
+	}
+	if !infinite && limits.Depth == 0 && limits.Nodes == 0 && limits.MoveTime == 0 {
+		limits.Depth = defaultGoDepth
+	}
+	return limits
+}
+
+// timeBudget returns the time for the next move: the time left on the clock divided among
+// the moves until the next time control (30 if unknown) plus half of the increment, all
+// in milliseconds.
+func timeBudget(remaining, inc, movestogo int64) time.Duration {
+	if movestogo <= 0 {
+		movestogo = 30
+	}
+	budget := remaining/movestogo + inc/2
+	// Never use more than the time left minus a small safety margin
+	budget = min(budget, remaining-50)
+	return time.Duration(max(budget, 1)) * time.Millisecond
+}
+
+// formatInfo builds the 'info' line sent after each completed iteration or aspiration
+// window failure for a search from b
+func formatInfo(b *Board, r SearchResult, elapsed time.Duration) string {
+	pv := make([]string, len(r.PV))
+	for i, m := range r.PV {
+		pv[i] = b.UCIMove(m)
+	}
+	ms := elapsed.Milliseconds()
+	nps := r.Nodes * 1000 / max(ms, 1)
+	tbhits := ""
+	if r.TBHits > 0 {
+		tbhits = fmt.Sprintf(" tbhits %d", r.TBHits)
+	}
+	bound := ""
+	if r.LowerBound {
+		bound = " lowerbound"
+	} else if r.UpperBound {
+		bound = " upperbound"
+	}
+	return fmt.Sprintf("info depth %d score %s%s nodes %d nps %d%s time %d pv %s",
+		r.Depth, uciScore(r.Score), bound, r.Nodes, nps, tbhits, ms, joinWithSpaces(pv))
+}
+
+// uciScore writes a search score as 'cp <x>', or as 'mate <moves>' for a mate found by the
+// search (negative moves when the engine is the one being mated)
+func uciScore(score int) string {
+	if isMateScore(score) {
+		return fmt.Sprintf("mate %d", mateMoves(score))
+	}
+	return fmt.Sprintf("cp %d", score)
+}
+
+// handleSetOption parses and applies the UCI 'setoption' command
+// Syntax: setoption name <id> [value <x>]
+func handleSetOption(tokens []string) {
+	if len(tokens) < 3 || tokens[1] != "name" {
+		fmt.Println("info string invalid setoption command", tokens)
+		return
+	}
+	// Option names and values may contain spaces
+	idx := 2
+	for idx < len(tokens) && tokens[idx] != "value" {
+		idx++
+	}
+	name := joinWithSpaces(tokens[2:idx])
+	value := ""
+	if idx < len(tokens) {
+		value = joinWithSpaces(tokens[idx+1:])
+	}
+
+	switch name {
+	case "EvalFile":
+		if value == "" || value == "<empty>" {
+			evalParams = DefaultEvalParams()
+			return
+		}
+		p, err := LoadEvalParams(value)
+		if err != nil {
+			// On error, keep previous parameters but report
+			fmt.Println("info string cannot load EvalFile:", err)
+			return
+		}
+		evalParams = p
+		fmt.Println("info string EvalFile loaded:", value)
+	case "SearchFile":
+		if value == "" || value == "<empty>" {
+			searchParams = DefaultSearchParams()
+			return
+		}
+		p, err := LoadSearchParams(value)
+		if err != nil {
+			fmt.Println("info string cannot load SearchFile:", err)
+			return
+		}
+		searchParams = p
+		fmt.Println("info string SearchFile loaded:", value)
+	case "UseNNUE":
+		useNNUE = value == "true"
+		if useNNUE && nnueNetwork == nil {
+			fmt.Println("info string UseNNUE set but no network loaded, using classical evaluation")
+		}
+	case "NNUEFile":
+		if value == "" || value == "<empty>" {
+			nnueNetwork = nil
+			return
+		}
+		net, err := LoadNetwork(value)
+		if err != nil {
+			fmt.Println("info string cannot load NNUEFile:", err)
+			return
+		}
+		nnueNetwork = net
+		fmt.Println("info string NNUEFile loaded:", value)
+	case "SyzygyPath":
+		if value == "" || value == "<empty>" {
+			tablebase = nil
+			return
+		}
+		tb, err := OpenTablebase(value)
+		if err != nil {
+			fmt.Println("info string cannot open SyzygyPath:", err)
+			return
+		}
+		tablebase = tb
+		fmt.Printf("info string found %d-piece Syzygy tables\n", tb.MaxPieces())
+	case "Hash":
+		mb, err := strconv.Atoi(value)
+		if err != nil || mb < 1 || mb > 4096 {
+			fmt.Println("info string invalid Hash:", value)
+			return
+		}
+		hashTable = NewTranspositionTable(mb)
+		hashMB = mb
+	case "Threads":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 256 {
+			fmt.Println("info string invalid Threads:", value)
+			return
+		}
+		threads = n
+	case "UCI_Chess960":
+		chess960 = value == "true"
+		GetCurrentBoard().Chess960 = chess960
+	case "UCI_Variant":
+		v, err := ParseVariant(value)
+		if err != nil {
+			fmt.Println("info string invalid UCI_Variant:", value)
+			return
+		}
+		variant = v
+		currentBoard = newUCIBoard()
+		gameHistory = nil
+	case "Contempt":
+		c, err := strconv.Atoi(value)
+		if err != nil || c < -100 || c > 100 {
+			fmt.Println("info string invalid Contempt:", value)
+			return
+		}
+		contempt = c
+	case "SyzygyProbeDepth":
+		depth, err := strconv.Atoi(value)
+		if err != nil || depth < 1 {
+			fmt.Println("info string invalid SyzygyProbeDepth:", value)
+			return
+		}
+		syzygyProbeDepth = depth
+	default:
+		fmt.Println("info string unknown option:", name)
+	}
 }
 
 func joinWithSpaces(parts []string) string {
@@ -169,11 +581,21 @@ func joinWithSpaces(parts []string) string {
 	return out
 }
 
-// parseUCIMove finds and returns the legal move matching the UCI long algebraic string (e2e4[,qrbn])
+// parseUCIMove finds and returns the legal move matching the UCI long algebraic string (e2e4[,qrbn]).
+// On a Chess960 board castling is written as the king taking its own rook (e1h1), and
+// Crazyhouse drops are written as the piece and the square (P@e4)
 func parseUCIMove(b *Board, uci string) (Move, bool) {
 	// Expect 4 or 5 chars
 	if len(uci) < 4 {
-		return Move{}, false
+		return NoMove, false
+	}
+	if uci[1] == '@' {
+		for _, m := range b.strictLegalMoves() {
+			if m.IsDrop() && m.UCIString() == uci {
+				return m, true
+			}
+		}
+		return NoMove, false
 	}
 	fromStr := uci[0:2]
 	toStr := uci[2:4]
@@ -183,11 +605,11 @@ func parseUCIMove(b *Board, uci string) (Move, bool) {
 	}
 	fromIdx, ok := parseSquareToIndex(fromStr)
 	if !ok {
-		return Move{}, false
+		return NoMove, false
 	}
 	toIdx, ok := parseSquareToIndex(toStr)
 	if !ok {
-		return Move{}, false
+		return NoMove, false
 	}
 
 	moves := b.GetLegalMoves()
@@ -196,30 +618,38 @@ func parseUCIMove(b *Board, uci string) (Move, bool) {
 		if !b.isMoveLegal(m) {
 			continue
 		}
-		if int(m.From) != fromIdx || int(m.To) != toIdx {
+		to := int(m.To())
+		if rookFrom, _, ok := b.castlingRookSquares(m.Type(), b.WhiteToMove); ok && b.Chess960 {
+			to = bits.TrailingZeros64(rookFrom)
+		}
+		if int(m.From()) != fromIdx || to != toIdx {
 			continue
 		}
 		// If promotion present, ensure types match; if not present, skip promotion moves
-		isPromo := (m.Type & MovePromotion) != 0
+		isPromo := (m.Type() & MovePromotion) != 0
 		if promo == 0 && isPromo {
 			continue
 		}
 		if promo != 0 {
 			switch promo {
 			case 'q':
-				if (m.Type & 128) == 0 { // Queen flag in our encoding
+				if (m.Type() & 128) == 0 { // Queen flag in our encoding
 					continue
 				}
 			case 'r':
-				if (m.Type & 64) == 0 {
+				if (m.Type() & 64) == 0 {
 					continue
 				}
 			case 'b':
-				if (m.Type & 32) == 0 {
+				if (m.Type() & 32) == 0 {
 					continue
 				}
 			case 'n':
-				if (m.Type & 16) == 0 {
+				if (m.Type() & 16) == 0 {
+					continue
+				}
+			case 'k': // Antichess
+				if m.PromotionPiece() != King {
 					continue
 				}
 			default:
@@ -228,7 +658,7 @@ func parseUCIMove(b *Board, uci string) (Move, bool) {
 		}
 		return m, true
 	}
-	return Move{}, false
+	return NoMove, false
 }
 
 // parseSquareToIndex converts algebraic square like "e2" to index 0..63
@@ -1,7 +1,14 @@
 package melange
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 )
@@ -74,3 +81,260 @@ func TestUCIPositionFenWithMoves(t *testing.T) {
 	// Fullmove should be 2 as black has moved once
 	assert.Equal(t, b.FullMove, uint32(2))
 }
+
+func TestUCISetOptionEvalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "params.json")
+	p := DefaultEvalParams()
+	p.Queen = 950
+	assert.NilError(t, p.Save(path))
+
+	ProcessUciCommand("setoption name EvalFile value " + path)
+	assert.Equal(t, evalParams.Queen, 950)
+
+	// An unreadable file keeps the previous parameters
+	ProcessUciCommand("setoption name EvalFile value " + filepath.Join(t.TempDir(), "missing.json"))
+	assert.Equal(t, evalParams.Queen, 950)
+
+	ProcessUciCommand("setoption name EvalFile value <empty>")
+	assert.DeepEqual(t, evalParams, DefaultEvalParams())
+}
+
+func TestUCISetOptionNNUE(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "net.nnue")
+	net := randomNetwork(8, 6)
+	assert.NilError(t, net.Save(path))
+
+	ProcessUciCommand("setoption name NNUEFile value " + path)
+	ProcessUciCommand("setoption name UseNNUE value true")
+	assert.DeepEqual(t, activeEvaluator(), Evaluator(net))
+
+	ProcessUciCommand("setoption name UseNNUE value false")
+	assert.Equal(t, activeEvaluator(), Evaluator(evalParams))
+	ProcessUciCommand("setoption name NNUEFile value <empty>")
+}
+
+func TestUCIParseGoLimits(t *testing.T) {
+	limits := parseGoLimits(tokenize("go depth 7"), true)
+	assert.Equal(t, limits, SearchLimits{Depth: 7})
+
+	limits = parseGoLimits(tokenize("go nodes 20000 movetime 1500"), true)
+	assert.Equal(t, limits, SearchLimits{Nodes: 20000, MoveTime: 1500 * time.Millisecond})
+
+	// Sin límites se busca a la profundidad por defecto
+	limits = parseGoLimits(tokenize("go"), true)
+	assert.Equal(t, limits, SearchLimits{Depth: defaultGoDepth})
+
+	// Con reloj se usa el tiempo del bando al mover
+	limits = parseGoLimits(tokenize("go wtime 60000 btime 30000 winc 1000 binc 2000"), true)
+	assert.Equal(t, limits.MoveTime, (60000/30+500)*time.Millisecond)
+	limits = parseGoLimits(tokenize("go wtime 60000 btime 30000 winc 1000 binc 2000 movestogo 10"), false)
+	assert.Equal(t, limits.MoveTime, (30000/10+1000)*time.Millisecond)
+
+	// Con infinite no hay ningún límite
+	limits = parseGoLimits(tokenize("go infinite"), true)
+	assert.Equal(t, limits, SearchLimits{})
+}
+
+// captureStdout devuelve lo que escribe f en la salida estándar.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	assert.NilError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	f()
+	os.Stdout = stdout
+	w.Close()
+	return <-out
+}
+
+func TestUCIGoInfiniteAndStop(t *testing.T) {
+	out := captureStdout(t, func() {
+		ProcessUciCommand("position startpos moves e2e4")
+		ProcessUciCommand("go infinite")
+		// La búsqueda sigue en segundo plano y se puede contestar a isready
+		ProcessUciCommand("isready")
+		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-searchDone:
+			t.Error("go infinite ha terminado sin stop")
+		default:
+		}
+		ProcessUciCommand("stop")
+		assert.Assert(t, searchDone == nil)
+	})
+	assert.Assert(t, strings.Contains(out, "readyok\n"), out)
+	assert.Assert(t, strings.Contains(out, "info depth 1 "), out)
+	assert.Assert(t, strings.Count(out, "bestmove ") == 1, out)
+
+	// Con límites termina sola y FinishUciSearch espera a que dé su jugada
+	out = captureStdout(t, func() {
+		ProcessUciCommand("position startpos")
+		ProcessUciCommand("go depth 3")
+		FinishUciSearch()
+	})
+	assert.Assert(t, strings.Contains(out, "info depth 3 "), out)
+	assert.Assert(t, strings.Contains(out, "bestmove "), out)
+}
+
+func TestUCIFormatInfo(t *testing.T) {
+	b := NewBoard()
+	e4, _ := parseUCIMove(b, "e2e4")
+	r := SearchResult{BestMove: e4, Score: 35, Depth: 3, Nodes: 1000, PV: MoveList{e4}}
+	assert.Equal(t, formatInfo(NewBoard(), r, 500*time.Millisecond), "info depth 3 score cp 35 nodes 1000 nps 2000 time 500 pv e2e4")
+	r.LowerBound = true
+	assert.Equal(t, formatInfo(NewBoard(), r, 500*time.Millisecond), "info depth 3 score cp 35 lowerbound nodes 1000 nps 2000 time 500 pv e2e4")
+	r.LowerBound, r.UpperBound = false, true
+	assert.Equal(t, formatInfo(NewBoard(), r, 500*time.Millisecond), "info depth 3 score cp 35 upperbound nodes 1000 nps 2000 time 500 pv e2e4")
+
+	// Mates are given in moves, negative when the engine is being mated
+	r.UpperBound = false
+	r.Score = mateIn(3)
+	assert.Equal(t, formatInfo(NewBoard(), r, 500*time.Millisecond), "info depth 3 score mate 2 nodes 1000 nps 2000 time 500 pv e2e4")
+	r.Score = matedIn(4)
+	assert.Equal(t, formatInfo(NewBoard(), r, 500*time.Millisecond), "info depth 3 score mate -2 nodes 1000 nps 2000 time 500 pv e2e4")
+	assert.Equal(t, xboardScore(mateIn(3)), 100002)
+	assert.Equal(t, xboardScore(matedIn(4)), -100002)
+	assert.Equal(t, xboardScore(-35), -35)
+}
+
+func TestUCIGoUsesSearcher(t *testing.T) {
+	ProcessUciCommand("ucinewgame")
+	ProcessUciCommand("position fen 6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1")
+	var iterations []SearchResult
+	searcher := NewSearcher(activeEvaluator())
+	searcher.OnIteration = func(r SearchResult) { iterations = append(iterations, r) }
+	result := searcher.Search(GetCurrentBoard(), parseGoLimits(tokenize("go depth 3"), true))
+	assert.Equal(t, result.BestMove.ToSimpleString(), "a1a8")
+	assert.Equal(t, len(iterations), 3)
+	assert.Equal(t, iterations[2].Depth, 3)
+}
+
+func TestUCISetOptionSyzygy(t *testing.T) {
+	dir := t.TempDir()
+	writeKQvKTable(t, dir, func(q, wk, bk int) bool { return true }, WDLLoss)
+	ProcessUciCommand("setoption name SyzygyPath value " + dir)
+	assert.Equal(t, tablebase.MaxPieces(), 3)
+	ProcessUciCommand("setoption name SyzygyProbeDepth value 4")
+	assert.Equal(t, syzygyProbeDepth, 4)
+
+	ProcessUciCommand("setoption name SyzygyPath value <empty>")
+	assert.Assert(t, tablebase == nil)
+	ProcessUciCommand("setoption name SyzygyProbeDepth value 1")
+}
+
+func TestUCISetOptionHash(t *testing.T) {
+	ProcessUciCommand("setoption name Hash value 1")
+	small := len(hashTable.slots)
+	ProcessUciCommand("setoption name Hash value 4")
+	assert.Equal(t, len(hashTable.slots), 4*small)
+	ProcessUciCommand("setoption name Hash value 0")
+	assert.Equal(t, len(hashTable.slots), 4*small)
+
+	hashTable.store(1, NoMove, 0, 1, boundExact)
+	ProcessUciCommand("ucinewgame")
+	_, ok := hashTable.probe(1)
+	assert.Assert(t, !ok)
+	ProcessUciCommand("setoption name Hash value 16")
+}
+
+func TestUCISetOptionThreads(t *testing.T) {
+	ProcessUciCommand("setoption name Threads value 3")
+	assert.Equal(t, threads, 3)
+	ProcessUciCommand("setoption name Threads value 0")
+	assert.Equal(t, threads, 3)
+	ProcessUciCommand("setoption name Threads value 1")
+	assert.Equal(t, threads, 1)
+}
+
+func TestUCIRepetitionHistory(t *testing.T) {
+	ProcessUciCommand("position startpos moves g1f3 g8f6 f3g1 f6g8 g1f3")
+	assert.Equal(t, len(gameHistory), 5)
+	assert.Equal(t, gameHistory[0], NewBoard().Hash())
+	assert.Equal(t, gameHistory[4], NewBoard().Hash())
+	ProcessUciCommand("position startpos")
+	assert.Equal(t, len(gameHistory), 0)
+
+	ProcessUciCommand("setoption name Contempt value 20")
+	assert.Equal(t, newEngineSearcher().Contempt, 20)
+	ProcessUciCommand("setoption name Contempt value 500")
+	assert.Equal(t, contempt, 20)
+	ProcessUciCommand("setoption name Contempt value 0")
+}
+
+func TestUCIPerftAndDivide(t *testing.T) {
+	ProcessUciCommand("position startpos")
+	var out bytes.Buffer
+	handlePerft(&out, tokenize("perft 3"))
+	assert.Assert(t, strings.HasPrefix(out.String(), "Nodes: 8902\n"), out.String())
+
+	out.Reset()
+	handlePerft(&out, tokenize("divide 2"))
+	lines := strings.Split(out.String(), "\n")
+	assert.Equal(t, lines[0], "a2a3: 20")
+	assert.Equal(t, lines[19], "h2h4: 20")
+	assert.Assert(t, strings.Contains(out.String(), "Moves: 20\nNodes: 400\n"))
+
+	// Las promociones llevan la pieza, como en los movimientos de 'position'
+	ProcessUciCommand("position fen 4k3/1P6/8/8/8/8/8/4K3 w - - 0 1")
+	out.Reset()
+	handlePerft(&out, tokenize("divide 1"))
+	assert.Assert(t, strings.Contains(out.String(), "b7b8q: 1\nb7b8r: 1\n"), out.String())
+
+	out.Reset()
+	handlePerft(&out, tokenize("perft"))
+	assert.Assert(t, strings.HasPrefix(out.String(), "info string usage"))
+}
+
+func TestUCIChess960(t *testing.T) {
+	ProcessUciCommand("setoption name UCI_Chess960 value true")
+	defer ProcessUciCommand("setoption name UCI_Chess960 value false")
+
+	// Los enroques se escriben como el rey que captura su torre, en 'position' y en la salida
+	ProcessUciCommand("position fen 1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1")
+	var out bytes.Buffer
+	handlePerft(&out, tokenize("divide 1"))
+	assert.Assert(t, strings.Contains(out.String(), "g1b1: 1\n"), out.String())
+	assert.Assert(t, strings.Contains(out.String(), "g1h1: 1\n"), out.String())
+
+	ProcessUciCommand("position fen 1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1 moves g1h1 g8h8")
+	assert.Equal(t, GetCurrentBoard().Fen(), "1r3rk1/8/8/8/8/8/8/1R3RK1 w - - 2 2")
+}
+
+func TestUCIVariant(t *testing.T) {
+	ProcessUciCommand("setoption name UCI_Variant value horde")
+	defer ProcessUciCommand("setoption name UCI_Variant value chess")
+	assert.Equal(t, GetCurrentBoard().Fen(), Horde.StartFEN())
+
+	// Los peones de la primera fila avanzan dos casillas, sin casilla al paso
+	ProcessUciCommand("position fen 4k3/8/8/8/8/8/8/P7 w - - 0 1 moves a1a3")
+	assert.Equal(t, GetCurrentBoard().Fen(), "4k3/8/8/8/8/P7/8/8 b - - 0 1")
+
+	ProcessUciCommand("setoption name UCI_Variant value 3check")
+	ProcessUciCommand("position startpos moves e2e4 e7e5 f1c4 b8c6 c4f7")
+	assert.Equal(t, GetCurrentBoard().Fen(), "r1bqkbnr/pppp1Bpp/2n5/4p3/4P3/8/PPPP1PPP/RNBQK1NR b KQkq - 2+3 0 3")
+
+	ProcessUciCommand("setoption name UCI_Variant value bughouse")
+	assert.Equal(t, GetCurrentBoard().Variant, ThreeCheck)
+}
+
+func TestUCIPrintBoard(t *testing.T) {
+	ProcessUciCommand("position startpos moves e2e4")
+	var out bytes.Buffer
+	printBoard(&out, GetCurrentBoard())
+	assert.Assert(t, strings.Contains(out.String(), "Fen: rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1\n"), out.String())
+	assert.Assert(t, strings.Contains(out.String(), fmt.Sprintf("Key: %016X\n", GetCurrentBoard().Hash())))
+}
+
+func TestUCIBenchDepth(t *testing.T) {
+	var out bytes.Buffer
+	handleBench(&out, tokenize("bench x"))
+	assert.Equal(t, out.String(), "info string invalid bench depth: x\n")
+	out.Reset()
+	handleBench(&out, tokenize("bench 2"))
+	assert.Assert(t, strings.Contains(out.String(), "Nodes searched"))
+}
@@ -0,0 +1,193 @@
+package melange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// engineStartTimeout es lo que se espera a que un motor conteste a uci e isready.
+const engineStartTimeout = 10 * time.Second
+
+// UCIEngine controla un motor UCI externo lanzado como subproceso.
+type UCIEngine struct {
+	Name  string // id name del motor, o la ruta si no lo envía
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string // Líneas de la salida estándar; se cierra al terminar el proceso
+}
+
+// StartUCIEngine lanza el ejecutable path, completa el saludo UCI y fija las opciones
+// indicadas (nombre → valor).
+func StartUCIEngine(path string, options map[string]string) (*UCIEngine, error) {
+	cmd := exec.Command(path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	e := &UCIEngine{Name: path, cmd: cmd, stdin: stdin, lines: make(chan string, 256)}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			e.lines <- scanner.Text()
+		}
+		close(e.lines)
+	}()
+
+	if err := e.send("uci"); err != nil {
+		e.Close()
+		return nil, err
+	}
+	err = e.readUntil(engineStartTimeout, func(line string) bool {
+		if name, ok := strings.CutPrefix(line, "id name "); ok {
+			e.Name = strings.TrimSpace(name)
+		}
+		return line == "uciok"
+	})
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+	for name, value := range options {
+		if err := e.send(fmt.Sprintf("setoption name %s value %s", name, value)); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+	if err := e.IsReady(); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *UCIEngine) send(command string) error {
+	_, err := io.WriteString(e.stdin, command+"\n")
+	return err
+}
+
+// readUntil lee líneas hasta que done devuelve true. Falla si el motor termina o no
+// contesta antes del plazo.
+func (e *UCIEngine) readUntil(timeout time.Duration, done func(line string) bool) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return fmt.Errorf("%s: el motor ha terminado", e.Name)
+			}
+			if done(strings.TrimSpace(line)) {
+				return nil
+			}
+		case <-timer.C:
+			return fmt.Errorf("%s: el motor no responde", e.Name)
+		}
+	}
+}
+
+// IsReady espera a que el motor conteste readyok.
+func (e *UCIEngine) IsReady() error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	return e.readUntil(engineStartTimeout, func(line string) bool { return line == "readyok" })
+}
+
+// NewGame avisa al motor de que empieza otra partida.
+func (e *UCIEngine) NewGame() error {
+	if err := e.send("ucinewgame"); err != nil {
+		return err
+	}
+	return e.IsReady()
+}
+
+// EngineReply es la respuesta del motor a un go.
+type EngineReply struct {
+	BestMove string
+	Score    int  // Centipawns desde el punto de vista del motor, de la última línea info
+	Mate     bool // Score es un mate en tantas jugadas (con signo) en lugar de centipawns
+	Depth    int
+	Elapsed  time.Duration
+}
+
+// Go envía la posición (FEN inicial y movimientos en notación UCI) y el comando go con
+// los argumentos dados, y espera bestmove como mucho timeout.
+func (e *UCIEngine) Go(fen string, moves []string, goArgs string, timeout time.Duration) (EngineReply, error) {
+	position := "position fen " + fen
+	if len(moves) > 0 {
+		position += " moves " + strings.Join(moves, " ")
+	}
+	if err := e.send(position); err != nil {
+		return EngineReply{}, err
+	}
+	start := time.Now()
+	if err := e.send(strings.TrimSpace("go " + goArgs)); err != nil {
+		return EngineReply{}, err
+	}
+	var reply EngineReply
+	err := e.readUntil(timeout, func(line string) bool {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return false
+		}
+		switch fields[0] {
+		case "info":
+			parseInfoScore(fields, &reply)
+		case "bestmove":
+			if len(fields) > 1 {
+				reply.BestMove = fields[1]
+			}
+			return true
+		}
+		return false
+	})
+	reply.Elapsed = time.Since(start)
+	return reply, err
+}
+
+// parseInfoScore extrae la profundidad y la puntuación de una línea info.
+func parseInfoScore(fields []string, reply *EngineReply) {
+	for i := 1; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "depth":
+			fmt.Sscan(fields[i+1], &reply.Depth)
+		case "score":
+			if i+2 < len(fields) {
+				reply.Mate = fields[i+1] == "mate"
+				fmt.Sscan(fields[i+2], &reply.Score)
+			}
+		}
+	}
+}
+
+// Close pide al motor que termine y, si no lo hace en un momento, lo mata.
+func (e *UCIEngine) Close() error {
+	_ = e.send("quit")
+	e.stdin.Close()
+	// Hay que leer la salida hasta el final antes de Wait
+	exited := make(chan struct{})
+	go func() {
+		for range e.lines {
+		}
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(2 * time.Second):
+		_ = e.cmd.Process.Kill()
+		<-exited
+	}
+	return e.cmd.Wait()
+}
@@ -0,0 +1,248 @@
+package melange
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Variant es la variante de ajedrez que se juega en un tablero. Cada una cambia algunas
+// reglas del ajedrez normal:
+//   - KingOfTheHill: gana también quien lleva su rey al centro (d4, e4, d5 o e5).
+//   - ThreeCheck: gana también quien da tres jaques; el tablero lleva la cuenta (Checks).
+//   - Antichess: si se puede capturar hay que hacerlo; el rey es una pieza más, sin jaques
+//     ni enroques, que se puede capturar y en la que se puede coronar, y gana quien se
+//     queda sin movimientos, normalmente por haber perdido todas las piezas.
+//   - Horde: las blancas solo tienen peones, que también avanzan dos casillas desde la
+//     primera fila; ganan dando mate y las negras capturándolas todas.
+//   - Crazyhouse: las piezas capturadas pasan a la reserva de quien captura, que puede
+//     soltarlas en una casilla vacía en lugar de mover (ver crazyhouse.go).
+type Variant uint8
+
+const (
+	Standard Variant = iota
+	KingOfTheHill
+	ThreeCheck
+	Antichess
+	Horde
+	Crazyhouse
+)
+
+// variantNames son los nombres de UCI_Variant, los mismos que usan las interfaces y otros
+// motores de variantes.
+var variantNames = [...]string{"chess", "kingofthehill", "3check", "antichess", "horde", "crazyhouse"}
+
+func (v Variant) String() string {
+	if int(v) < len(variantNames) {
+		return variantNames[v]
+	}
+	return fmt.Sprintf("Variant(%d)", v)
+}
+
+// ParseVariant devuelve la variante con ese nombre (sin distinguir mayúsculas).
+func ParseVariant(name string) (Variant, error) {
+	for v, n := range variantNames {
+		if strings.EqualFold(name, n) {
+			return Variant(v), nil
+		}
+	}
+	if strings.EqualFold(name, "standard") {
+		return Standard, nil
+	}
+	return Standard, fmt.Errorf("variante desconocida: %s", name)
+}
+
+// StartFEN devuelve la posición inicial de la variante.
+func (v Variant) StartFEN() string {
+	switch v {
+	case ThreeCheck:
+		return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 3+3 0 1"
+	case Antichess:
+		return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1"
+	case Horde:
+		return "rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1"
+	case Crazyhouse:
+		return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1"
+	}
+	return startFEN
+}
+
+// NewVariantBoard devuelve la posición inicial de la variante.
+func NewVariantBoard(v Variant) *Board {
+	b := &Board{Variant: v}
+	if err := b.SetFen(v.StartFEN()); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// royalKing indica si el rey puede recibir jaque, lo que limita los movimientos legales.
+func (v Variant) royalKing() bool {
+	return v != Antichess
+}
+
+// hill son las casillas centrales de King of the Hill.
+const hill = D4 | E4 | D5 | E5
+
+// maxChecks es el número de jaques con el que se gana en Three-check.
+const maxChecks = 3
+
+// VariantOutcome devuelve el resultado y el motivo si la partida ha terminado por una regla
+// propia de la variante. No incluye las partidas terminadas por falta de movimientos, que
+// decide noMovesResult.
+func (b *Board) VariantOutcome() (GameResult, string, bool) {
+	switch b.Variant {
+	case KingOfTheHill:
+		if b.WhitePieces.King&hill != 0 {
+			return WhiteWins, "king of the hill", true
+		}
+		if b.BlackPieces.King&hill != 0 {
+			return BlackWins, "king of the hill", true
+		}
+	case ThreeCheck:
+		if b.Checks[0] >= maxChecks {
+			return WhiteWins, "three checks", true
+		}
+		if b.Checks[1] >= maxChecks {
+			return BlackWins, "three checks", true
+		}
+	case Horde:
+		if b.WhiteOccupiedSquares() == 0 {
+			return BlackWins, "horde captured", true
+		}
+	}
+	return Ongoing, "", false
+}
+
+// noMovesResult devuelve el resultado cuando el bando al mover no tiene movimientos
+// legales: mate o ahogado, salvo en Antichess, donde quedarse sin movimientos es ganar.
+func (b *Board) noMovesResult() (GameResult, string) {
+	toMoveWins, toMoveLoses := WhiteWins, BlackWins
+	if !b.WhiteToMove {
+		toMoveWins, toMoveLoses = BlackWins, WhiteWins
+	}
+	switch {
+	case b.Variant == Antichess:
+		return toMoveWins, "no moves left"
+	case b.IsKingInCheck(b.WhiteToMove):
+		return toMoveLoses, "checkmate"
+	}
+	return Draw, "stalemate"
+}
+
+// filterVariantMoves aplica a los movimientos pseudo-legales las reglas de la variante: no
+// hay movimientos si la partida ha terminado y en Antichess las capturas son obligatorias.
+// Filtra en el sitio, así que el resultado comparte memoria con moves.
+func (b *Board) filterVariantMoves(moves MoveList) MoveList {
+	if _, _, over := b.VariantOutcome(); over {
+		return moves[:0]
+	}
+	if b.Variant != Antichess {
+		return moves
+	}
+	captures := 0
+	for _, m := range moves {
+		if m.IsCapture() {
+			moves[captures] = m
+			captures++
+		}
+	}
+	if captures > 0 {
+		return moves[:captures]
+	}
+	return moves
+}
+
+// evaluateVariant evalúa la posición de una variante con el evaluador del ajedrez normal
+// y los términos propios de la variante.
+func (b *Board) evaluateVariant(e Evaluator) int {
+	score := e.Evaluate(b)
+	switch b.Variant {
+	case Antichess:
+		// Se trata de perder el material
+		return -score
+	case KingOfTheHill:
+		score += hillBonus(b.WhitePieces.King) - hillBonus(b.BlackPieces.King)
+	case ThreeCheck:
+		score += checkBonus[min(b.Checks[0], maxChecks)] - checkBonus[min(b.Checks[1], maxChecks)]
+	case Crazyhouse:
+		score += b.Hands[0].value() - b.Hands[1].value()
+	}
+	return score
+}
+
+// checkBonus es lo que vale haber dado 0, 1, 2 o 3 jaques en Three-check.
+var checkBonus = [maxChecks + 1]int{0, 150, 400, 0}
+
+// hillBonus premia al rey por acercarse al centro en King of the Hill.
+func hillBonus(king uint64) int {
+	if king == 0 {
+		return 0
+	}
+	sq := bits.TrailingZeros64(king)
+	file, rank := sq%8, sq/8
+	// Distancia en movimientos de rey a la casilla central más cercana
+	distance := max(max(3-file, file-4, 0), max(3-rank, rank-4, 0))
+	return []int{0, 120, 60, 20}[min(distance, 3)]
+}
+
+// variantScore devuelve la puntuación, desde el punto de vista del bando al mover, de una
+// partida terminada por una regla de la variante a ply plies de la raíz, como un mate.
+func (b *Board) variantScore(ply int) (int, bool) {
+	result, _, over := b.VariantOutcome()
+	if !over {
+		return 0, false
+	}
+	if (result == WhiteWins) == b.WhiteToMove {
+		return mateIn(ply), true
+	}
+	return matedIn(ply), true
+}
+
+// antichessMoves entrega los movimientos de una posición de Antichess, donde el selector
+// por etapas no sirve porque las capturas son obligatorias: primero el de la tabla de
+// transposición y después las capturas por MVV-LVA o los tranquilos por historial.
+func (s *Searcher) antichessMoves(ttMove Move) func() (Move, bool) {
+	b := s.board
+	mp := &movePicker{b: b, s: s}
+	color := colorIndex(b.WhiteToMove)
+	for _, m := range b.GetLegalMoves() {
+		score := s.history[color][m.From()][m.To()]
+		switch {
+		case m == ttMove:
+			score = 1 << 30
+		case m.IsCapture():
+			score = mvvLva(b, m)
+		}
+		mp.moves = append(mp.moves, scoredMove{m, score})
+	}
+	return mp.pickBest
+}
+
+// antichessQuiescence es la quiescencia de Antichess: si hay capturas son obligatorias, así
+// que no se puede quedar con la evaluación estática y hay que buscarlas todas.
+func (s *Searcher) antichessQuiescence(ply int, alpha, beta int) int {
+	b := s.board
+	moves := b.GetLegalMoves()
+	if len(moves) == 0 {
+		return mateIn(ply)
+	}
+	if !moves[0].IsCapture() || ply >= maxPly-1 {
+		return s.evaluate()
+	}
+	for _, m := range moves {
+		st := b.perftMakeMove(m)
+		score := -s.quiescence(ply+1, -beta, -alpha)
+		b.unmakeMove(st)
+		if s.stopped {
+			return 0
+		}
+		if score > alpha {
+			alpha = score
+			if alpha >= beta {
+				break
+			}
+		}
+	}
+	return alpha
+}
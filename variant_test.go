@@ -0,0 +1,169 @@
+package melange
+
+import (
+	"math/rand"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseVariant(t *testing.T) {
+	for _, v := range []Variant{Standard, KingOfTheHill, ThreeCheck, Antichess, Horde, Crazyhouse} {
+		parsed, err := ParseVariant(v.String())
+		assert.NilError(t, err)
+		assert.Equal(t, parsed, v)
+	}
+	v, err := ParseVariant("3Check")
+	assert.NilError(t, err)
+	assert.Equal(t, v, ThreeCheck)
+	_, err = ParseVariant("bughouse")
+	assert.ErrorContains(t, err, "desconocida")
+}
+
+func TestVariantPerft(t *testing.T) {
+	for _, tc := range []struct {
+		variant Variant
+		fen     string
+		nodes   []int
+	}{
+		{Antichess, Antichess.StartFEN(), []int{20, 400, 8067, 153299}},
+		{Horde, Horde.StartFEN(), []int{8, 128, 1274, 23310}},
+		// Con un jaque más se gana: las posiciones tras los jaques del nivel 2 no siguen
+		{ThreeCheck, "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 1+1 0 1", []int{48, 2039, 97848}},
+		// Rc3-d4 llega a la colina y termina la partida
+		{KingOfTheHill, "k7/8/8/8/8/2K5/8/8 w - - 0 1", []int{8, 21}},
+		{KingOfTheHill, "k7/8/8/8/3K4/8/8/8 b - - 0 1", []int{0}},
+	} {
+		b := &Board{Variant: tc.variant}
+		assert.NilError(t, b.SetFen(tc.fen))
+		for i, want := range tc.nodes {
+			assert.Equal(t, b.Perft(i+1).Nodes, want, "%s %s depth %d", tc.variant, tc.fen, i+1)
+		}
+	}
+}
+
+func TestAntichessMoves(t *testing.T) {
+	// La captura es obligatoria, aunque se pierda la torre
+	b := &Board{Variant: Antichess}
+	assert.NilError(t, b.SetFen("8/8/8/1p6/8/8/7k/1R6 w - - 0 1"))
+	moves := NewGame(b).LegalMoves()
+	assert.Equal(t, len(moves), 1)
+	assert.Equal(t, moves[0].UCIString(), "b1b5")
+
+	// Se puede coronar en rey; el rey no está en jaque ni es especial
+	assert.NilError(t, b.SetFen("8/P7/8/8/8/8/8/k7 w - - 0 1"))
+	m, ok := parseUCIMove(b, "a7a8k")
+	assert.Assert(t, ok)
+	assert.Equal(t, b.SAN(m), "a8=K")
+	assert.Equal(t, len(NewGame(b).LegalMoves()), 5)
+	b.MovePiece(m, true)
+	assert.Equal(t, b.Fen(), "K7/8/8/8/8/8/8/k7 b - - 0 1")
+
+	// Quien se queda sin piezas gana
+	assert.NilError(t, b.SetFen("8/8/8/8/8/8/8/k7 w - - 0 1"))
+	result, reason := NewGame(b).Status()
+	assert.Equal(t, result, WhiteWins)
+	assert.Equal(t, reason, "no moves left")
+}
+
+func TestAntichessNNUE(t *testing.T) {
+	// Los reyes se capturan como cualquier otra pieza y el acumulador los tiene que quitar
+	net := randomNetwork(32, 3)
+	kingCaptures := 0
+	for seed := int64(0); seed < 20; seed++ {
+		board := NewVariantBoard(Antichess)
+		board.AttachNetwork(net)
+		rng := rand.New(rand.NewSource(seed))
+		for ply := 0; ply < 120; ply++ {
+			moves := legalMoves(board)
+			if len(moves) == 0 {
+				break
+			}
+			m := moves[rng.Intn(len(moves))]
+			if piece, _ := board.PieceAtSquare(uint64(1) << m.To()); piece == King && m.IsCapture() {
+				kingCaptures++
+			}
+			board.perftMakeMove(m)
+			assert.Equal(t, net.Evaluate(board), net.evaluateReference(board), "%s", board.Fen())
+		}
+	}
+	assert.Assert(t, kingCaptures > 0)
+}
+
+func TestThreeCheck(t *testing.T) {
+	b := NewVariantBoard(ThreeCheck)
+	assert.Equal(t, b.Fen(), "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 3+3 0 1")
+	game := NewGame(b)
+	for _, s := range []string{"e4", "e5", "Bc4", "Nc6", "Bxf7+", "Kxf7", "Qh5+", "g6", "Qxg6+"} {
+		m, err := game.Board.ParseSAN(s)
+		assert.NilError(t, err, s)
+		game.Play(m)
+	}
+	assert.Equal(t, game.Board.Checks, [2]uint8{3, 0})
+	result, reason := game.Status()
+	assert.Equal(t, result, WhiteWins)
+	assert.Equal(t, reason, "three checks")
+	assert.Equal(t, len(game.LegalMoves()), 0)
+
+	// Los jaques forman parte de la clave y se recuperan al deshacer
+	b = &Board{Variant: ThreeCheck}
+	assert.NilError(t, b.SetFen("4k3/8/8/8/8/8/8/4K2R w - - 1+3 0 1"))
+	key := b.Hash()
+	m, ok := parseUCIMove(b, "h1h8")
+	assert.Assert(t, ok)
+	st := b.perftMakeMove(m)
+	assert.Equal(t, b.Checks, [2]uint8{3, 0})
+	b.unmakeMove(st)
+	assert.Equal(t, b.Checks, [2]uint8{2, 0})
+	assert.Equal(t, b.Hash(), key)
+	assert.Assert(t, (&Board{}).Hash() != (&Board{Checks: [2]uint8{1, 0}}).Hash())
+
+	// También en X-FEN, con los jaques dados al final
+	assert.NilError(t, b.SetFen("4k3/8/8/8/8/8/8/4K2R w - - 0 1 +2+0"))
+	assert.Equal(t, b.Fen(), "4k3/8/8/8/8/8/8/4K2R w - - 1+3 0 1")
+	assert.ErrorContains(t, b.SetFen("4k3/8/8/8/8/8/8/4K2R w - - 4+3 0 1"), "jaques")
+}
+
+func TestVariantOutcome(t *testing.T) {
+	for _, tc := range []struct {
+		variant Variant
+		fen     string
+		result  GameResult
+		reason  string
+	}{
+		{KingOfTheHill, "k7/8/8/4K3/8/8/8/8 b - - 0 1", WhiteWins, "king of the hill"},
+		{KingOfTheHill, "8/8/8/8/3k4/8/8/K7 w - - 0 1", BlackWins, "king of the hill"},
+		{Horde, "4k3/8/8/8/8/8/8/8 w - - 0 1", BlackWins, "horde captured"},
+		{Horde, "4k3/8/8/8/8/8/8/P7 w - - 0 1", Ongoing, ""},
+		{Standard, "k7/8/8/4K3/8/8/8/8 b - - 0 1", Draw, "insufficient material"},
+	} {
+		b := &Board{Variant: tc.variant}
+		assert.NilError(t, b.SetFen(tc.fen))
+		result, reason := NewGame(b).Status()
+		assert.Equal(t, result, tc.result, tc.fen)
+		assert.Equal(t, reason, tc.reason, tc.fen)
+	}
+}
+
+func TestVariantSearch(t *testing.T) {
+	for _, tc := range []struct {
+		variant Variant
+		fen     string
+		want    string // Vacío si gana cualquier movimiento
+	}{
+		// El rey llega a la colina en lugar de capturar la dama
+		{KingOfTheHill, "k7/8/8/8/8/1qK5/8/8 w - - 0 1", "c3d4"},
+		// Cualquier jaque es el tercero, incluso si se pierde la dama
+		{ThreeCheck, "r3k3/8/8/8/8/8/8/3QK3 w - - 1+3 0 1", ""},
+		// En Antichess hay que entregar la torre
+		{Antichess, "8/8/8/8/8/k7/8/1R6 w - - 0 1", ""},
+	} {
+		b := &Board{Variant: tc.variant}
+		assert.NilError(t, b.SetFen(tc.fen))
+		r := NewSearcher(evalParams).Search(b, SearchLimits{Depth: 4})
+		assert.Assert(t, r.Score >= mateScore-maxPly, "%s: %d", tc.fen, r.Score)
+		if tc.want != "" {
+			assert.Equal(t, r.BestMove.UCIString(), tc.want, tc.fen)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+// CECP (XBoard/WinBoard) protocol: https://www.gnu.org/software/xboard/engine-intf.html
+
+package melange
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// XBoard implements the CECP front-end. It shares the board, search and options with the
+// UCI front-end (newEngineSearcher), but keeps the whole game so that it can take moves
+// back and decide when the engine has to move. Searches run in the background so that
+// '?', 'exit' and the analysis commands can interrupt them.
+type XBoard struct {
+	out   io.Writer
+	outMu sync.Mutex
+
+	mu          sync.Mutex
+	start       *Board // Position set with 'new' or 'setboard'
+	game        *Game
+	force       bool // The engine plays neither side
+	engineWhite bool // Side played by the engine when not in force mode
+	analyzing   bool
+	post        bool
+
+	// Clocks (time/otim) and time control (level, st, sd)
+	clock, otherClock time.Duration
+	movesPerControl   int
+	base, increment   time.Duration
+	moveTime          time.Duration
+	depth             int
+
+	// Search in progress: closing stop interrupts it; done is closed when it has finished.
+	// If discard is set the engine does not play the move it found.
+	stop    chan struct{}
+	done    chan struct{}
+	discard bool
+}
+
+// NewXBoard creates the front-end writing its replies to out, with a new game in which
+// the engine plays Black.
+func NewXBoard(out io.Writer) *XBoard {
+	x := &XBoard{out: out}
+	x.newGame(NewBoard())
+	return x
+}
+
+func (x *XBoard) printf(format string, args ...any) {
+	x.outMu.Lock()
+	defer x.outMu.Unlock()
+	fmt.Fprintf(x.out, format, args...)
+}
+
+// newGame starts a game from b with the engine playing the side not to move.
+func (x *XBoard) newGame(b *Board) {
+	x.start = b
+	x.game = NewGame(b)
+	x.engineWhite = !b.WhiteToMove
+}
+
+// Process handles one command and returns true on 'quit'.
+func (x *XBoard) Process(line string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	tokens := tokenize(strings.TrimSpace(line))
+	if len(tokens) == 0 {
+		return false
+	}
+	arg := ""
+	if len(tokens) > 1 {
+		arg = tokens[1]
+	}
+
+	// While the engine thinks about its move only '?' plays it at once; any other command
+	// that changes the game has to wait for the move (ping, like the GUI expects) or
+	// discards it
+	switch tokens[0] {
+	case "?":
+		x.stopSearch(false)
+		return false
+	case "ping":
+		x.waitSearch()
+		x.printf("pong %s\n", arg)
+		return false
+	case ".", "post", "nopost", "time", "otim", "hard", "easy", "accepted", "rejected",
+		"computer", "name", "rating", "random", "xboard", "protover":
+	default:
+		if !x.analyzing {
+			x.stopSearch(true)
+		}
+	}
+
+	switch tokens[0] {
+	case "xboard", "accepted", "rejected", "hard", "easy", "random", "computer", "name", "rating", ".":
+		// Nothing to do
+	case "protover":
+		x.printf("feature myname=\"Melange v0.1\" ping=1 setboard=1 usermove=1 time=1 draw=0 " +
+			"sigint=0 sigterm=0 reuse=1 analyze=1 colors=0 name=0 nps=0 done=1\n")
+	case "new":
+		x.stopSearch(true)
+		x.newGame(NewBoard())
+		x.force, x.analyzing = false, false
+		x.depth, x.moveTime = 0, 0
+	case "force":
+		x.force = true
+	case "go":
+		x.force = false
+		x.engineWhite = x.game.Board.WhiteToMove
+		x.think()
+	case "usermove":
+		x.userMove(arg)
+	case "time":
+		x.clock = centiseconds(arg)
+	case "otim":
+		x.otherClock = centiseconds(arg)
+	case "level":
+		x.level(tokens[1:])
+	case "st":
+		seconds, _ := strconv.ParseFloat(arg, 64)
+		x.moveTime = time.Duration(seconds * float64(time.Second))
+	case "sd":
+		x.depth, _ = strconv.Atoi(arg)
+	case "undo", "remove":
+		n := 1
+		if tokens[0] == "remove" {
+			n = 2
+		}
+		x.takeBack(n)
+	case "setboard":
+		b := &Board{}
+		if err := b.SetFen(strings.Join(tokens[1:], " ")); err != nil {
+			x.printf("tellusererror Illegal position: %v\n", err)
+			return false
+		}
+		x.stopSearch(true)
+		x.newGame(b)
+		x.restartAnalysis()
+	case "analyze":
+		x.analyzing = true
+		x.restartAnalysis()
+	case "exit":
+		x.stopSearch(true)
+		x.analyzing = false
+	case "post":
+		x.post = true
+	case "nopost":
+		x.post = false
+	case "result":
+		x.stopSearch(true)
+		x.force = true
+	case "quit":
+		x.stopSearch(true)
+		return true
+	default:
+		// Protocol version 1 sends the moves without 'usermove'
+		if _, ok := x.parseMove(tokens[0]); ok {
+			x.userMove(tokens[0])
+		} else {
+			x.printf("Error (unknown command): %s\n", tokens[0])
+		}
+	}
+	return false
+}
+
+func centiseconds(s string) time.Duration {
+	cs, _ := strconv.ParseInt(s, 10, 64)
+	return time.Duration(cs) * 10 * time.Millisecond
+}
+
+// level MPS BASE INC: BASE is in minutes or minutes:seconds and INC in seconds.
+func (x *XBoard) level(args []string) {
+	if len(args) < 3 {
+		x.printf("Error (bad level): %s\n", strings.Join(args, " "))
+		return
+	}
+	x.movesPerControl, _ = strconv.Atoi(args[0])
+	minutes, seconds, _ := strings.Cut(args[1], ":")
+	m, _ := strconv.Atoi(minutes)
+	sec, _ := strconv.Atoi(seconds)
+	x.base = time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	inc, _ := strconv.ParseFloat(args[2], 64)
+	x.increment = time.Duration(inc * float64(time.Second))
+	x.clock, x.otherClock = x.base, x.base
+	x.moveTime = 0
+}
+
+// parseMove accepts coordinate notation (e2e4, e7e8q) and SAN.
+func (x *XBoard) parseMove(s string) (Move, bool) {
+	b := x.game.Board
+	for _, m := range x.game.LegalMoves() {
+		if m.UCIString() == s {
+			return m, true
+		}
+	}
+	m, err := b.ParseSAN(s)
+	return m, err == nil
+}
+
+func (x *XBoard) userMove(s string) {
+	m, ok := x.parseMove(s)
+	if !ok {
+		x.printf("Illegal move: %s\n", s)
+		return
+	}
+	x.stopSearch(true)
+	x.game.Play(m)
+	if x.analyzing {
+		x.restartAnalysis()
+		return
+	}
+	// In force mode the GUI is just setting up the game
+	if !x.force && !x.reportResult() && x.game.Board.WhiteToMove == x.engineWhite {
+		x.think()
+	}
+}
+
+// takeBack undoes the last n moves by replaying the game from its start.
+func (x *XBoard) takeBack(n int) {
+	moves := x.game.Moves
+	if n > len(moves) {
+		return
+	}
+	x.stopSearch(true)
+	x.game = NewGame(x.start)
+	for _, m := range moves[:len(moves)-n] {
+		x.game.Play(m)
+	}
+	x.restartAnalysis()
+}
+
+// reportResult sends the result if the game is over and returns true in that case.
+func (x *XBoard) reportResult() bool {
+	result, reason := x.game.Status()
+	if result == Ongoing {
+		return false
+	}
+	switch {
+	case reason == "checkmate" && result == WhiteWins:
+		reason = "White mates"
+	case reason == "checkmate":
+		reason = "Black mates"
+	}
+	x.printf("%s {%s}\n", result, reason)
+	return true
+}
+
+// limits returns the search limits for the engine's next move.
+func (x *XBoard) limits() SearchLimits {
+	limits := SearchLimits{Depth: x.depth, MoveTime: x.moveTime}
+	if x.moveTime == 0 {
+		remaining := x.clock
+		if remaining <= 0 {
+			remaining = x.base
+		}
+		if remaining > 0 {
+			movesToGo := 0
+			if x.movesPerControl > 0 {
+				played := (len(x.game.Moves) + 1) / 2
+				movesToGo = x.movesPerControl - played%x.movesPerControl
+			}
+			limits.MoveTime = timeBudget(remaining.Milliseconds(), x.increment.Milliseconds(), int64(movesToGo))
+		}
+	}
+	if limits.Depth == 0 && limits.MoveTime == 0 {
+		limits.Depth = defaultGoDepth
+	}
+	return limits
+}
+
+// think starts the search for the engine's move in the background.
+func (x *XBoard) think() {
+	if x.reportResult() {
+		return
+	}
+	limits := x.limits()
+	x.search(limits, x.post, func(r SearchResult) {
+		if x.discard || r.BestMove == NoMove {
+			return
+		}
+		x.game.Play(r.BestMove)
+		x.printf("move %s\n", r.BestMove.UCIString())
+		x.reportResult()
+	})
+}
+
+// restartAnalysis starts an infinite search of the current position in analysis mode.
+func (x *XBoard) restartAnalysis() {
+	if !x.analyzing {
+		return
+	}
+	x.stopSearch(true)
+	if result, _ := x.game.Status(); result != Ongoing {
+		return
+	}
+	x.search(SearchLimits{}, true, nil)
+}
+
+// search runs the search in a goroutine. finish is called with x.mu held when the search
+// ends (normally or through stopSearch).
+func (x *XBoard) search(limits SearchLimits, post bool, finish func(SearchResult)) {
+	stop, done := make(chan struct{}), make(chan struct{})
+	x.stop, x.done, x.discard = stop, done, false
+	limits.Stop = stop
+
+	board := x.game.Board.Clone()
+	searcher := newEngineSearcher()
+	searcher.GameHistory = append([]uint64(nil), x.game.History()...)
+	start := time.Now()
+	if post {
+		searcher.OnIteration = func(r SearchResult) {
+			if !r.LowerBound && !r.UpperBound {
+				x.printf("%s\n", formatThinking(board, r, time.Since(start)))
+			}
+		}
+	}
+	go func() {
+		result := searcher.Search(board, limits)
+		x.mu.Lock()
+		defer x.mu.Unlock()
+		if finish != nil {
+			finish(result)
+		}
+		if x.done == done {
+			x.stop, x.done = nil, nil
+		}
+		close(done)
+	}()
+}
+
+// stopSearch interrupts the search in progress, if any, and waits for it to finish. With
+// discard the engine does not play the move it found. It must be called with x.mu held.
+func (x *XBoard) stopSearch(discard bool) {
+	if x.done == nil {
+		return
+	}
+	x.discard = discard
+	select {
+	case <-x.stop:
+	default:
+		close(x.stop)
+	}
+	x.waitSearch()
+}
+
+// waitSearch waits for the search in progress, if any, to finish. It must be called with
+// x.mu held, which is released meanwhile so that the search can play its move.
+func (x *XBoard) waitSearch() {
+	if done := x.done; done != nil {
+		x.mu.Unlock()
+		<-done
+		x.mu.Lock()
+	}
+}
+
+// formatThinking builds the thinking output: depth, score (see xboardScore), time in
+// centiseconds, nodes and the PV in SAN.
+func formatThinking(b *Board, r SearchResult, elapsed time.Duration) string {
+	game := NewGame(b)
+	pv := make([]string, 0, len(r.PV))
+	for _, m := range r.PV {
+		pv = append(pv, game.Board.SAN(m))
+		game.Play(m)
+	}
+	return fmt.Sprintf("%d %d %d %d %s", r.Depth, xboardScore(r.Score), elapsed.Milliseconds()/10, r.Nodes, strings.Join(pv, " "))
+}
+
+// xboardScore converts a search score to the thinking output convention: centipawns, or
+// 100000 + N for a mate in N moves and -100000 - N for being mated in N.
+func xboardScore(score int) int {
+	switch {
+	case !isMateScore(score):
+		return score
+	case score > 0:
+		return 100000 + mateMoves(score)
+	}
+	return -100000 + mateMoves(score)
+}
@@ -0,0 +1,135 @@
+package melange
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// xboardSession envía los comandos y devuelve la salida. El ping final espera a que
+// termine la búsqueda en curso.
+func xboardSession(x *XBoard, out *bytes.Buffer, commands ...string) string {
+	out.Reset()
+	for _, c := range commands {
+		x.Process(c)
+	}
+	x.Process("ping 99")
+	return out.String()
+}
+
+func TestXBoardProtover(t *testing.T) {
+	var out bytes.Buffer
+	x := NewXBoard(&out)
+	got := xboardSession(x, &out, "xboard", "protover 2", "accepted setboard")
+	assert.Assert(t, strings.HasPrefix(got, `feature myname="Melange v0.1" `), got)
+	assert.Assert(t, strings.Contains(got, " usermove=1 ") && strings.Contains(got, " done=1\n"), got)
+	assert.Assert(t, strings.HasSuffix(got, "pong 99\n"), got)
+	assert.Assert(t, x.Process("quit"))
+}
+
+func TestXBoardPlaysGame(t *testing.T) {
+	var out bytes.Buffer
+	x := NewXBoard(&out)
+	// El motor lleva las negras y contesta a cada jugada
+	got := xboardSession(x, &out, "new", "sd 2", "usermove e2e4")
+	assert.Assert(t, strings.HasPrefix(got, "move "), got)
+	assert.Equal(t, len(x.game.Moves), 2)
+	afterReply := x.game.Board.Fen()
+
+	// En modo force no contesta; también acepta SAN sin 'usermove'
+	got = xboardSession(x, &out, "force", "usermove d2d4")
+	assert.Equal(t, got, "pong 99\n")
+	san := x.game.Board.SAN(x.game.Board.GetLegalMoves()[0])
+	got = xboardSession(x, &out, san)
+	assert.Equal(t, got, "pong 99\n", san)
+	assert.Equal(t, len(x.game.Moves), 4)
+	assert.Equal(t, x.game.Board.WhiteToMove, true)
+
+	// go: el motor juega el bando al mover
+	got = xboardSession(x, &out, "go")
+	assert.Assert(t, strings.HasPrefix(got, "move "), got)
+	assert.Assert(t, x.engineWhite)
+
+	// undo quita una jugada y remove dos
+	xboardSession(x, &out, "force", "undo")
+	assert.Equal(t, len(x.game.Moves), 4)
+	xboardSession(x, &out, "remove")
+	assert.Equal(t, len(x.game.Moves), 2)
+	assert.Equal(t, x.game.Board.Fen(), afterReply)
+
+	got = xboardSession(x, &out, "usermove e2e5", "bogus")
+	assert.Equal(t, got, "Illegal move: e2e5\nError (unknown command): bogus\npong 99\n")
+}
+
+func TestXBoardReportsResult(t *testing.T) {
+	var out bytes.Buffer
+	x := NewXBoard(&out)
+	got := xboardSession(x, &out, "setboard 6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1", "sd 3", "post", "go")
+	assert.Assert(t, strings.Contains(got, "3 100001 "), got)
+	assert.Assert(t, strings.Contains(got, " Ra8#\n"), got)
+	assert.Assert(t, strings.Contains(got, "move a1a8\n1-0 {White mates}\n"), got)
+
+	// Con nopost no hay líneas de pensamiento
+	got = xboardSession(x, &out, "new", "nopost", "sd 2", "go")
+	assert.Equal(t, strings.Count(got, "\n"), 2, got)
+
+	got = xboardSession(x, &out, "setboard 8/8/8")
+	assert.Assert(t, strings.HasPrefix(got, "tellusererror Illegal position"), got)
+}
+
+func TestXBoardAnalyze(t *testing.T) {
+	var out bytes.Buffer
+	x := NewXBoard(&out)
+	x.Process("new")
+	x.Process("analyze")
+	time.Sleep(50 * time.Millisecond)
+	// Las jugadas en análisis no hacen mover al motor: reinician el análisis
+	x.Process("usermove e2e4")
+	time.Sleep(50 * time.Millisecond)
+	x.Process(".")
+	x.Process("undo")
+	x.Process("exit")
+	assert.Assert(t, !x.analyzing)
+	assert.Equal(t, len(x.game.Moves), 0)
+	got := out.String()
+	assert.Assert(t, strings.HasPrefix(got, "1 "), got)
+	assert.Assert(t, !strings.Contains(got, "move "), got)
+
+	// Tras exit no queda ninguna búsqueda en marcha
+	got = xboardSession(x, &out)
+	assert.Equal(t, got, "pong 99\n")
+}
+
+func TestXBoardMoveNow(t *testing.T) {
+	var out bytes.Buffer
+	x := NewXBoard(&out)
+	x.Process("new")
+	x.Process("st 100")
+	x.Process("go")
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	got := xboardSession(x, &out, "?")
+	assert.Assert(t, time.Since(start) < 10*time.Second)
+	assert.Assert(t, strings.HasPrefix(got, "move "), got)
+}
+
+func TestXBoardTimeLimits(t *testing.T) {
+	x := NewXBoard(&bytes.Buffer{})
+	assert.Equal(t, x.limits(), SearchLimits{Depth: defaultGoDepth})
+
+	// level 40 5 0: 5 minutos para 40 jugadas; time da el reloj del motor en centisegundos
+	x.level([]string{"40", "5", "0"})
+	assert.Equal(t, x.limits().MoveTime, timeBudget(300000, 0, 40))
+	x.Process("time 6000")
+	assert.Equal(t, x.limits().MoveTime, timeBudget(60000, 0, 40))
+	x.level([]string{"0", "2:30", "2"})
+	assert.Equal(t, x.base, 150*time.Second)
+	assert.Equal(t, x.limits().MoveTime, timeBudget(150000, 2000, 0))
+
+	x.Process("st 5")
+	x.Process("sd 7")
+	assert.Equal(t, x.limits(), SearchLimits{Depth: 7, MoveTime: 5 * time.Second})
+}
@@ -0,0 +1,120 @@
+package melange
+
+import "math/bits"
+
+// Claves Zobrist: cada pieza en cada casilla, el turno, los derechos de enroque y la
+// columna de la casilla al paso tienen un número aleatorio fijo; la clave de una posición
+// es el XOR de los de sus elementos.
+var (
+	zobristPieces    [2][7][64]uint64 // [negras][pieza][casilla]
+	zobristBlack     uint64
+	zobristCastling  [16]uint64
+	zobristEnPassant [8]uint64
+	zobristChecks    [2][maxChecks + 1]uint64 // Jaques dados en Three-check
+	zobristHand      [2][King][17]uint64      // Piezas en reserva en Crazyhouse, por cantidad
+	zobristPromoted  [64]uint64               // Piezas coronadas en Crazyhouse
+)
+
+func init() {
+	// splitmix64 con semilla fija para que las claves sean las mismas en cada ejecución
+	state := uint64(0x6d656c616e6765)
+	next := func() uint64 {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		return z ^ z>>31
+	}
+	for c := range zobristPieces {
+		for p := Pawn; p <= King; p++ {
+			for sq := range zobristPieces[c][p] {
+				zobristPieces[c][p][sq] = next()
+			}
+		}
+	}
+	zobristBlack = next()
+	for i := range zobristCastling {
+		zobristCastling[i] = next()
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = next()
+	}
+	for c := range zobristChecks {
+		for i := range zobristChecks[c] {
+			zobristChecks[c][i] = next()
+		}
+	}
+	for c := range zobristHand {
+		for p := range zobristHand[c] {
+			for n := range zobristHand[c][p] {
+				zobristHand[c][p][n] = next()
+			}
+		}
+	}
+	for i := range zobristPromoted {
+		zobristPromoted[i] = next()
+	}
+}
+
+// Hash calcula desde cero la clave Zobrist de la posición. Las posiciones con las mismas
+// piezas, turno, derechos de enroque, casilla al paso, jaques dados (Three-check) y
+// reservas y piezas coronadas (Crazyhouse) tienen la misma clave; los relojes no cuentan.
+// La búsqueda usa Board.hash, que MovePiece mantiene al día y debe ser siempre igual.
+func (b *Board) Hash() uint64 {
+	var key uint64
+	for c, pieces := range []*Pieces{&b.WhitePieces, &b.BlackPieces} {
+		for p := Pawn; p <= King; p++ {
+			for bb := pieces.Get(p); bb != 0; bb &= bb - 1 {
+				key ^= zobristPieces[c][p][bits.TrailingZeros64(bb)]
+			}
+		}
+	}
+	if !b.WhiteToMove {
+		key ^= zobristBlack
+	}
+	key ^= zobristCastling[b.Castling&15] ^ b.enPassantKey()
+	// Sin jaques la clave es la del ajedrez normal
+	for c, checks := range b.Checks {
+		key ^= checksKey(c, checks)
+	}
+	// Ni reserva ni piezas coronadas fuera de Crazyhouse
+	for c := range b.Hands {
+		for p := Pawn; p < King; p++ {
+			key ^= handKey(c, p, b.Hands[c][p])
+		}
+	}
+	return key ^ promotedKey(b.Promoted)
+}
+
+// enPassantKey es la parte de la clave de la casilla al paso.
+func (b *Board) enPassantKey() uint64 {
+	if b.EnPassant == 0 {
+		return 0
+	}
+	return zobristEnPassant[b.EnPassant%8]
+}
+
+// checksKey es la parte de la clave de los jaques dados por el color c.
+func checksKey(c int, checks uint8) uint64 {
+	if checks == 0 {
+		return 0
+	}
+	return zobristChecks[c][min(checks, maxChecks)]
+}
+
+// handKey es la parte de la clave de n piezas p en la reserva del color c.
+func handKey(c int, p Piece, n uint8) uint64 {
+	if n == 0 {
+		return 0
+	}
+	return zobristHand[c][p][min(n, 16)]
+}
+
+// promotedKey es la parte de la clave de las piezas coronadas de bb.
+func promotedKey(bb uint64) uint64 {
+	var key uint64
+	for ; bb != 0; bb &= bb - 1 {
+		key ^= zobristPromoted[bits.TrailingZeros64(bb)]
+	}
+	return key
+}
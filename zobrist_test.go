@@ -0,0 +1,84 @@
+package melange
+
+import (
+	"math/rand"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestHash(t *testing.T) {
+	start := NewBoard()
+	b := &Board{}
+	assert.NilError(t, b.SetFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 5 9"))
+	// Los relojes no forman parte de la clave
+	assert.Equal(t, b.Hash(), start.Hash())
+
+	// La misma posición por dos órdenes de jugadas distintos
+	a := NewBoard()
+	for _, uci := range []string{"g1f3", "g8f6", "b1c3", "b8c6"} {
+		a.perftMakeMove(findMove(t, a, uci))
+	}
+	c := NewBoard()
+	for _, uci := range []string{"b1c3", "b8c6", "g1f3", "g8f6"} {
+		c.perftMakeMove(findMove(t, c, uci))
+	}
+	assert.Equal(t, a.Hash(), c.Hash())
+	assert.Assert(t, a.Hash() != start.Hash())
+
+	// Turno, enroque y al paso cambian la clave
+	for _, fen := range []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w Kkq - 0 1",
+	} {
+		assert.NilError(t, b.SetFen(fen))
+		assert.Assert(t, b.Hash() != start.Hash(), fen)
+	}
+	assert.NilError(t, b.SetFen("4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1"))
+	withEP := b.Hash()
+	assert.NilError(t, b.SetFen("4k3/8/8/3pP3/8/8/8/4K3 w - - 0 1"))
+	assert.Assert(t, withEP != b.Hash())
+}
+
+func TestIncrementalHash(t *testing.T) {
+	// La clave que mantienen MovePiece, make/unmake y el movimiento nulo coincide siempre
+	// con la calculada desde cero
+	rng := rand.New(rand.NewSource(7))
+	var boards []*Board
+	for v := Standard; v <= Crazyhouse; v++ {
+		boards = append(boards, NewVariantBoard(v))
+	}
+	chess960, err := NewChess960Board(518 + 7)
+	assert.NilError(t, err)
+	boards = append(boards, chess960)
+	for _, b := range boards {
+		for game := 0; game < 5; game++ {
+			start := b.Clone()
+			var states []moveState
+			for ply := 0; ply < 100; ply++ {
+				moves := legalMoves(b)
+				if len(moves) == 0 {
+					break
+				}
+				if !b.IsKingInCheck(b.WhiteToMove) && rng.Intn(8) == 0 {
+					ep := b.makeNullMove()
+					assert.Equal(t, b.hash, b.Hash(), "%s", b.Fen())
+					b.unmakeNullMove(ep)
+				}
+				states = append(states, b.perftMakeMove(moves[rng.Intn(len(moves))]))
+				assert.Equal(t, b.hash, b.Hash(), "%s %s", b.Variant, b.Fen())
+			}
+			// MovePiece sobre una copia de la partida deja la misma clave
+			replay := start.Clone()
+			for _, st := range states {
+				replay.MovePiece(st.move, replay.WhiteToMove)
+			}
+			assert.Equal(t, replay.hash, b.hash)
+			for i := len(states) - 1; i >= 0; i-- {
+				b.unmakeMove(states[i])
+				assert.Equal(t, b.hash, b.Hash(), "%s", b.Fen())
+			}
+			assert.Equal(t, b.hash, start.hash)
+		}
+	}
+}